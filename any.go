@@ -0,0 +1,146 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+)
+
+type (
+	// AnyGenerator generates Pack and Unpack functions for google.protobuf.Any fields, which are constrained to a
+	// configured set of candidate message types. Oneof members are packed by setting the oneof, and repeated fields
+	// are packed by appending, and unpacked as a slice.
+	AnyGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Candidates returns the message types permitted for a given google.protobuf.Any field, and must be set.
+		// No functions will be generated for fields without any candidates.
+		Candidates func(field *protogen.Field) []*protogen.Message
+	}
+)
+
+const (
+	anyFullName = `google.protobuf.Any`
+)
+
+var (
+	_ Generator = (*AnyGenerator)(nil)
+
+	anypbPkg = gopoet.NewPackage("google.golang.org/protobuf/types/known/anypb")
+)
+
+// IsAny returns true if the given field is a singular or repeated google.protobuf.Any field.
+func IsAny(field *protogen.Field) bool {
+	return field.Message != nil && field.Message.Desc.FullName() == anyFullName
+}
+
+// GenerateFile returns Pack and Unpack functions for each applicable field, of each message in the given file, see
+// also FileMessages.
+func (x *AnyGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		for _, field := range v.Fields {
+			if !IsAny(field) {
+				continue
+			}
+			if candidates := x.Candidates(field); len(candidates) != 0 {
+				elements = append(elements, x.PackFunc(field, candidates), x.UnpackFunc(field, candidates))
+			}
+		}
+	}
+	return elements
+}
+
+// PackFunc returns a function that accepts a message and a proto.Message, and packs the latter into the given
+// google.protobuf.Any field (appending to repeated fields), or returns an error if it isn't one of the candidates.
+func (x *AnyGenerator) PackFunc(field *protogen.Field, candidates []*protogen.Message) *gopoet.FuncSpec {
+	var (
+		name = `Pack` + field.GoIdent.GoName
+		doc  = fmt.Sprintf("%s packs m into %s, which must be one of: %s.", name, field.Desc.FullName(), candidateNames(candidates))
+	)
+	if field.Desc.IsList() {
+		doc = fmt.Sprintf("%s packs m, and appends it to %s, where m must be one of: %s.", name, field.Desc.FullName(), candidateNames(candidates))
+	}
+	f := gopoet.NewFunc(name).
+		SetComment(doc).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(field.Parent.Desc))).
+		AddArg(`m`, gopoet.NamedType(protoPkg.Symbol(`Message`))).
+		AddResult(``, gopoet.ErrorType).
+		Println(`switch m.(type) {`)
+	for i, v := range candidates {
+		if i == 0 {
+			f.Printf(`case %s`, gopoet.PointerType(x.Cache.MessageType(v.Desc)))
+		} else {
+			f.Printf(`, %s`, gopoet.PointerType(x.Cache.MessageType(v.Desc)))
+		}
+	}
+	f.Println(`:`).
+		Println(`default:`).
+		Printlnf(`return %s(%q, m)`, fmtPkg.Symbol(`Errorf`), fmt.Sprintf(`unsupported message type for %s: %%T`, field.Desc.FullName())).
+		Println(`}`).
+		Printlnf(`a, err := %s(m)`, anypbPkg.Symbol(`New`)).
+		Println(`if err != nil {`).
+		Println(`return err`).
+		Println(`}`)
+	switch {
+	case field.Desc.IsList():
+		f.Printlnf(`v.%s = append(v.%s, a)`, field.GoName, field.GoName)
+	case field.Oneof != nil && !field.Oneof.Desc.IsSynthetic():
+		f.Printlnf(`v.%s = &%s{%s: a}`, field.Oneof.GoName, x.Cache.goPackage(field.GoIdent.GoImportPath).Symbol(field.GoIdent.GoName), field.GoName)
+	default:
+		f.Printlnf(`v.%s = a`, field.GoName)
+	}
+	return f.Println(`return nil`)
+}
+
+// UnpackFunc returns a function that accepts a message, and unpacks the given google.protobuf.Any field, returning
+// nil if it is unset (or empty), or an error if it (any element) isn't one of the candidates.
+func (x *AnyGenerator) UnpackFunc(field *protogen.Field, candidates []*protogen.Message) *gopoet.FuncSpec {
+	name := `Unpack` + field.GoIdent.GoName
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf("%s unpacks %s, which must be one of: %s.", name, field.Desc.FullName(), candidateNames(candidates))).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(field.Parent.Desc)))
+	if field.Desc.IsList() {
+		f.AddResult(``, gopoet.SliceType(gopoet.NamedType(protoPkg.Symbol(`Message`)))).
+			AddResult(``, gopoet.ErrorType).
+			Printlnf(`var r []%s`, protoPkg.Symbol(`Message`)).
+			Printlnf(`for _, a := range v.Get%s() {`, field.GoName)
+		x.unpack(f, field, candidates)
+		return f.Println(`r = append(r, m)`).
+			Println(`}`).
+			Println(`return r, nil`)
+	}
+	f.AddResult(``, gopoet.NamedType(protoPkg.Symbol(`Message`))).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`a := v.Get%s()`, field.GoName).
+		Println(`if a == nil {`).
+		Println(`return nil, nil`).
+		Println(`}`)
+	x.unpack(f, field, candidates)
+	return f.Println(`return m, nil`)
+}
+
+// unpack unpacks a, a (non-nil) google.protobuf.Any value of the given field, into m.
+func (x *AnyGenerator) unpack(f *gopoet.FuncSpec, field *protogen.Field, candidates []*protogen.Message) {
+	f.Printlnf(`var m %s`, protoPkg.Symbol(`Message`)).
+		Println(`switch a.MessageName() {`)
+	for _, v := range candidates {
+		f.Printlnf(`case %q:`, v.Desc.FullName())
+		f.Printlnf(`m = new(%s)`, x.Cache.MessageType(v.Desc))
+	}
+	f.Println(`default:`).
+		Printlnf(`return nil, %s(%q, a.GetTypeUrl())`, fmtPkg.Symbol(`Errorf`), fmt.Sprintf(`unsupported message type for %s: %%s`, field.Desc.FullName())).
+		Println(`}`).
+		Println(`if err := a.UnmarshalTo(m); err != nil {`).
+		Println(`return nil, err`).
+		Println(`}`)
+}
+
+func candidateNames(candidates []*protogen.Message) string {
+	names := make([]string, len(candidates))
+	for i, v := range candidates {
+		names[i] = string(v.Desc.FullName())
+	}
+	return strings.Join(names, `, `)
+}
@@ -0,0 +1,114 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// APILevel identifies the golang API generated by protoc-gen-go for messages, i.e. the default_api_level
+	// plugin option, or the api_level feature, see also Cache.APILevel.
+	//
+	// With the hybrid and opaque API levels, protoc-gen-go generates setter, "has" and "clear" methods, and a builder
+	// type for each message, see also Field.Setter and Cache.BuilderType. With the opaque API level, the fields of
+	// message structs are unexported, and may only be accessed via these methods. Note that generators that access
+	// fields directly (which is most generators, excluding ConstructorGenerator) therefore require APIOpen or
+	// APIHybrid.
+	APILevel int
+)
+
+const (
+	// APIOpen is the open struct API, with exported fields, and getter methods only, which is the default.
+	APIOpen APILevel = iota
+	// APIHybrid is the hybrid API, with exported fields, as well as the accessor methods of the opaque API.
+	APIHybrid
+	// APIOpaque is the opaque API, with unexported fields, and accessor methods.
+	APIOpaque
+)
+
+// ParseAPILevel returns the API level for the given name, as accepted by the default_api_level option of
+// protoc-gen-go, i.e. API_OPEN, API_HYBRID, or API_OPAQUE, or an error if the name is unknown.
+func ParseAPILevel(s string) (APILevel, error) {
+	switch s {
+	case `API_OPEN`:
+		return APIOpen, nil
+	case `API_HYBRID`:
+		return APIHybrid, nil
+	case `API_OPAQUE`:
+		return APIOpaque, nil
+	}
+	return 0, fmt.Errorf("invalid api level: %q", s)
+}
+
+// String returns the name of the API level, e.g. API_OPAQUE.
+func (x APILevel) String() string {
+	switch x {
+	case APIOpen:
+		return `API_OPEN`
+	case APIHybrid:
+		return `API_HYBRID`
+	case APIOpaque:
+		return `API_OPAQUE`
+	}
+	return fmt.Sprintf(`APILevel(%d)`, int(x))
+}
+
+// BuilderType returns the builder type generated by protoc-gen-go for the given message, with the hybrid and opaque
+// API levels, e.g. Msg_builder, which has a Build method, returning the message. The message must be loaded into the
+// cache, otherwise it will panic.
+func (x *Cache) BuilderType(v protoreflect.MessageDescriptor) gopoet.TypeName {
+	x.once.Do(x.init)
+	var t gopoet.Symbol
+	if v != nil {
+		if v := x.lookup(v); v != nil {
+			t = v.Symbol()
+		}
+	}
+	if t.Name == `` {
+		panic(fmt.Sprintf("unknown type: %v", v))
+	}
+	return gopoet.NamedType(t.Package.Symbol(t.Name + `_builder`))
+}
+
+// methodName returns the name of an accessor method, generated by protoc-gen-go for the API level of the cache,
+// given the prefix (e.g. "Set"), and the golang name of the field or oneof, or an empty string, with APIOpen.
+func (x *Cache) methodName(prefix string, name protoreflect.Name, goName string) string {
+	switch x.APILevel {
+	case APIOpen:
+		return ``
+	case APIOpaque:
+		return prefix + camelCase(name, goName)
+	default:
+		return prefix + goName
+	}
+}
+
+// getterName returns the name of the getter method for a field or oneof, which uses the name of the field, without
+// any suffix added to resolve conflicts, with APIOpaque.
+func (x *Cache) getterName(name protoreflect.Name, goName string) string {
+	if x != nil && x.APILevel == APIOpaque {
+		goName = camelCase(name, goName)
+	}
+	return x.namer().GetterName(goName)
+}
+
+// camelCase returns the golang name of a field or oneof, without any underscores appended by protogen to resolve
+// conflicts with generated methods, e.g. Reset for a field named reset, rather than Reset_.
+func camelCase(name protoreflect.Name, goName string) string {
+	if strings.HasSuffix(string(name), `_`) {
+		return goName
+	}
+	return strings.TrimRight(goName, `_`)
+}
+
+// setterType returns the argument type of the setter method, for a field with the given (getter) type, which omits
+// any pointer used to represent presence.
+func setterType(field *protogen.Field, t gopoet.TypeName) gopoet.TypeName {
+	if t.Kind() == gopoet.KindPtr && field.Desc.Message() == nil {
+		return t.Elem()
+	}
+	return t
+}
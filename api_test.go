@@ -0,0 +1,10 @@
+package gopoet_protogen_test
+
+import (
+	"github.com/joeycumines/gopoet-protogen/protogentest"
+	"testing"
+)
+
+func TestAPI(t *testing.T) {
+	protogentest.AssertAPI(t, `.`, `api.txt`)
+}
@@ -0,0 +1,61 @@
+package gopoet_protogen
+
+import (
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"io"
+)
+
+type (
+	// PackageReference models a generated package, imported by a golang file, see also AuditReferences.
+	PackageReference struct {
+		// ImportPath is the import path of the package.
+		ImportPath protogen.GoImportPath
+		// Files are the files loaded into the cache, that belong to the package.
+		Files []*protogen.File
+	}
+)
+
+// AuditReferences returns every generated package imported by the given file, where generated packages are those of
+// the files loaded into the cache, ordered by import path. The file will be rendered (then discarded), to resolve
+// its imports, and any error doing so will be returned.
+//
+// This is intended to detect references to files that aren't being generated in the current run (see also
+// PackageReference.Stale), which will work locally, but may break elsewhere, e.g. with buf's managed mode.
+func AuditReferences(file *gopoet.GoFile, cache *Cache) ([]PackageReference, error) {
+	if err := gopoet.WriteGoFile(io.Discard, file); err != nil {
+		return nil, err
+	}
+	packages := make(map[protogen.GoImportPath][]*protogen.File)
+	for _, v := range cache.Files() {
+		packages[v.GoImportPath] = append(packages[v.GoImportPath], v)
+	}
+	var references []PackageReference
+	for _, v := range file.ImportSpecs() {
+		if files, ok := packages[protogen.GoImportPath(v.ImportPath)]; ok {
+			references = append(references, PackageReference{ImportPath: protogen.GoImportPath(v.ImportPath), Files: files})
+		}
+	}
+	return references, nil
+}
+
+// IsGenerated returns true if any of the files of the package are being generated, i.e. protogen.File.Generate.
+func (x PackageReference) IsGenerated() bool {
+	for _, v := range x.Files {
+		if v.Generate {
+			return true
+		}
+	}
+	return false
+}
+
+// Stale returns the files of the package that aren't being generated, i.e. protogen.File.Generate is false.
+func (x PackageReference) Stale() []*protogen.File {
+	var files []*protogen.File
+	for _, v := range x.Files {
+		if !v.Generate {
+			files = append(files, v)
+		}
+	}
+	return files
+}
@@ -0,0 +1,124 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// FieldBehavior models the values of the google.api.FieldBehavior enum (AIP-203), see also FieldBehaviors.
+	FieldBehavior int32
+)
+
+const (
+	// FieldBehaviorFieldNumber is the field number of the google.api.field_behavior option, in
+	// google.protobuf.FieldOptions.
+	FieldBehaviorFieldNumber protoreflect.FieldNumber = 1052
+)
+
+const (
+	// FieldBehaviorUnspecified is the default value, which should not be used.
+	FieldBehaviorUnspecified FieldBehavior = iota
+	// FieldBehaviorOptional indicates the field is optional, which is the default.
+	FieldBehaviorOptional
+	// FieldBehaviorRequired indicates the field must be set, in requests.
+	FieldBehaviorRequired
+	// FieldBehaviorOutputOnly indicates the field is set by the server, and ignored in requests.
+	FieldBehaviorOutputOnly
+	// FieldBehaviorInputOnly indicates the field is set in requests, but not returned in responses.
+	FieldBehaviorInputOnly
+	// FieldBehaviorImmutable indicates the field may be set on creation, but not changed after.
+	FieldBehaviorImmutable
+	// FieldBehaviorUnorderedList indicates the order of the (repeated) field is not guaranteed.
+	FieldBehaviorUnorderedList
+	// FieldBehaviorNonEmptyDefault indicates the server returns a non-empty default, if the field is unset.
+	FieldBehaviorNonEmptyDefault
+	// FieldBehaviorIdentifier indicates the field is the resource name, used to identify the resource.
+	FieldBehaviorIdentifier
+)
+
+var (
+	fieldBehaviorNames = [...]string{
+		`FIELD_BEHAVIOR_UNSPECIFIED`,
+		`OPTIONAL`,
+		`REQUIRED`,
+		`OUTPUT_ONLY`,
+		`INPUT_ONLY`,
+		`IMMUTABLE`,
+		`UNORDERED_LIST`,
+		`NON_EMPTY_DEFAULT`,
+		`IDENTIFIER`,
+	}
+)
+
+// FieldBehaviors decodes the google.api.field_behavior option of the given field, without depending on the
+// generated google.api package, returning each value, in order, which may be packed or unpacked.
+func FieldBehaviors(desc protoreflect.FieldDescriptor) []FieldBehavior {
+	var behaviors []FieldBehavior
+	for b := OptionFields(desc, FieldBehaviorFieldNumber); len(b) != 0; {
+		_, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			var value uint64
+			value, n = protowire.ConsumeVarint(b)
+			behaviors = append(behaviors, FieldBehavior(value))
+		case protowire.BytesType:
+			var packed []byte
+			packed, n = protowire.ConsumeBytes(b)
+			for len(packed) != 0 {
+				value, m := protowire.ConsumeVarint(packed)
+				if m < 0 {
+					break
+				}
+				behaviors = append(behaviors, FieldBehavior(value))
+				packed = packed[m:]
+			}
+		default:
+			n = protowire.ConsumeFieldValue(FieldBehaviorFieldNumber, typ, b)
+		}
+		b = b[n:]
+	}
+	return behaviors
+}
+
+// HasFieldBehavior returns true if the given field has the given google.api.field_behavior, see also
+// FieldBehaviors.
+func HasFieldBehavior(desc protoreflect.FieldDescriptor, behavior FieldBehavior) bool {
+	for _, v := range FieldBehaviors(desc) {
+		if v == behavior {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the name of the enum value, e.g. REQUIRED.
+func (x FieldBehavior) String() string {
+	if x >= 0 && int(x) < len(fieldBehaviorNames) {
+		return fieldBehaviorNames[x]
+	}
+	return fmt.Sprintf(`FieldBehavior(%d)`, int32(x))
+}
+
+// requiredField returns true if the given field is required, i.e. it uses the proto2 required field rule, or has the
+// REQUIRED field behavior, and isn't OUTPUT_ONLY, or a member of a (non-synthetic) oneof.
+func requiredField(field Field) bool {
+	if field.IsRequired() {
+		return true
+	}
+	if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+		return false
+	}
+	var required bool
+	for _, v := range field.Behaviors() {
+		switch v {
+		case FieldBehaviorRequired:
+			required = true
+		case FieldBehaviorOutputOnly:
+			return false
+		}
+	}
+	return required
+}
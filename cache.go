@@ -3,85 +3,350 @@ package gopoet_protogen
 import (
 	"fmt"
 	"github.com/jhump/gopoet"
+	"go/token"
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
+	"strings"
 	"sync"
 )
 
 type (
 	// Cache implements a type cache, that may be populated by feeding it protogen.File values, see also AddFile.
 	Cache struct {
-		once sync.Once
-		data map[protoreflect.FullName]protogen.GoIdent
+		// Lazy may be set prior to use, to defer loading the types of each file added via AddFile, until a type
+		// from that file is first resolved, reducing startup cost when only a few of many files are referenced.
+		Lazy bool
+		// Namer may be set prior to use, to override the names of generated symbols, and defaults to DefaultNamer.
+		Namer Namer
+		// RPCNamer may be set prior to use, to override the names of the symbols generated for services, by an RPC
+		// framework, e.g. TwirpNamer, and defaults to GRPCNamer.
+		RPCNamer RPCNamer
+		// ImportAlias may be set prior to use, to determine the name used to reference each golang package, given
+		// its import path and package name, which is used by default, see also VersionedImportAlias and WriteGoFile.
+		ImportAlias func(importPath protogen.GoImportPath, packageName protogen.GoPackageName) string
+		// Placeholder may be set prior to use, to resolve any message or enum that isn't loaded into the cache to a
+		// placeholder type (e.g. proto.Message or interface{}), rather than panicking, enabling best-effort
+		// generation against partial descriptor sets, see also Warnings. Fields of unknown message types have the
+		// placeholder type itself, rather than a pointer to it.
+		Placeholder func(v protoreflect.Descriptor) gopoet.TypeName
+		// ManagedMode may be set prior to use, to override the import path, and package name, of each file added to
+		// the cache, consistently with buf's managed mode, taking precedence over the go_package of the file.
+		ManagedMode *ManagedMode
+		// APILevel may be set prior to use, to model the accessor methods generated by protoc-gen-go for the
+		// hybrid or opaque API levels, which must match the API level of all messages, and defaults to APIOpen.
+		APILevel APILevel
+		// Comments may be set prior to use, to configure the sanitization of proto comments, see also Comment.
+		Comments *CommentSanitizer
+		// CustomTypes may be set prior to use, to map fields of the given message types to custom golang types,
+		// rather than a pointer to the message, e.g. for Field.Type, see also CustomType. Note that generators that
+		// access the fields of messages directly expect the protobuf types, so a separate cache should be used, if
+		// custom types are only required by some generators.
+		CustomTypes map[protoreflect.FullName]*CustomType
+		// StorageOption may be set prior to use, to configure the field number of a user-defined extension of
+		// google.protobuf.FieldOptions, specifying the storage metadata of each field, e.g. the column name, and
+		// type, consumed by storage-layer generators, see also FieldStorage, and Field.Storage.
+		StorageOption protoreflect.FieldNumber
+		// Storage may be set prior to use, to determine the storage metadata of each field, e.g. to decode an
+		// extension with a different schema, and takes precedence over StorageOption.
+		Storage func(desc protoreflect.FieldDescriptor) *FieldStorage
+		// Diagnostics may be set prior to use, to collect problems with the input, reported by generators, rather
+		// than panicking, see also Diagnostics.Report. Unknown types resolved using Placeholder are also reported,
+		// as warnings, see also Warnings.
+		Diagnostics *Diagnostics
+
+		once     sync.Once
+		data     map[protoreflect.FullName]protogen.GoIdent
+		types    map[protoreflect.FullName]gopoet.TypeName
+		files    []*protogen.File
+		pending  map[string]*protogen.File
+		packages map[protogen.GoImportPath]protogen.GoPackageName
+		pkgs     map[protogen.GoImportPath]gopoet.Package
+		remap    map[protogen.GoImportPath]protogen.GoImportPath
+		messages map[protoreflect.FullName]*protogen.Message
+		enums    map[protoreflect.FullName]*protogen.Enum
+		names    map[protogen.GoIdent]protoreflect.FullName
+		mu       sync.Mutex
+		warnings []string
+		unknown  map[protoreflect.FullName]struct{}
+		frozen   bool
 	}
 )
 
 var (
 	bytesType = gopoet.SliceType(gopoet.ByteType)
+	// int32Type is used in place of gopoet.Int32Type, which gopoet formats as rune.
+	int32Type = gopoet.NamedType(gopoet.Symbol{Name: `int32`})
 )
 
+// Add validates the golang package of the given file, then loads it into the cache, like AddFile, returning an error
+// naming the file, rather than generating code that fails to compile, if the import path is empty, or invalid (e.g.
+// due to a missing go_package option), or the package name isn't a valid identifier, or if the cache is frozen.
+func (x *Cache) Add(v *protogen.File) error {
+	x.once.Do(x.init)
+	if x.frozen {
+		return fmt.Errorf("cache is frozen: %s", v.Desc.Path())
+	}
+	if err := checkImportPath(string(v.GoImportPath)); err != nil {
+		return fmt.Errorf("invalid golang import path for %s (missing go_package?): %w", v.Desc.Path(), err)
+	}
+	if !token.IsIdentifier(string(v.GoPackageName)) || v.GoPackageName == `_` {
+		return fmt.Errorf("invalid golang package name for %s: %q", v.Desc.Path(), v.GoPackageName)
+	}
+	x.AddFile(v)
+	return nil
+}
+
+// MustAdd is like Add, but panics on error.
+func (x *Cache) MustAdd(v *protogen.File) {
+	if err := x.Add(v); err != nil {
+		panic(err)
+	}
+}
+
 // AddFile loads the given file into the cache, note that it is not safe to call concurrently.
 // It is recommended that all files (provided by protogen.Plugin) are loaded into the cache, prior to any generation
-// activities that might use it.
+// activities that might use it. If the cache is Lazy, the file's types will be loaded on first use.
+// The golang package of the file is not validated, see also Add.
 func (x *Cache) AddFile(v *protogen.File) {
 	x.once.Do(x.init)
-	for _, v := range v.Enums {
-		x.addEnum(v)
+	if x.frozen {
+		panic(fmt.Sprintf("cache is frozen: %s", v.Desc.Path()))
 	}
-	for _, v := range v.Messages {
-		x.addMessage(v)
+	x.files = append(x.files, v)
+	x.packages[v.GoImportPath] = v.GoPackageName
+	if x.ManagedMode != nil {
+		if importPath, packageName, ok := x.ManagedMode.GoPackage(v.Desc.Path()); ok {
+			x.remap[v.GoImportPath] = importPath
+			x.packages[importPath] = packageName
+		}
+	}
+	if x.Lazy {
+		x.pending[v.Desc.Path()] = v
+		return
+	}
+	x.loadFile(v)
+}
+
+// Freeze prevents any further files from being added to the cache, after which it is safe for concurrent use, see
+// also Pool. Calling AddFile on a frozen cache will panic. Any files pending lazy loading will be loaded.
+func (x *Cache) Freeze() {
+	x.once.Do(x.init)
+	x.loadPending()
+	x.frozen = true
+}
+
+// Files returns all files loaded into the cache, in the order they were added.
+func (x *Cache) Files() []*protogen.File {
+	return x.files
+}
+
+// FilesInDependencyOrder returns all files loaded into the cache, like Files, but ordered such that each file is
+// preceded by the files it imports (transitively, via files loaded into the cache), e.g. for generators of per-file
+// artifacts that must be applied in dependency order. The order is stable, i.e. files are otherwise in the order
+// they were added, and imports are visited in the order they were declared.
+func (x *Cache) FilesInDependencyOrder() []*protogen.File {
+	var (
+		files   = make([]*protogen.File, 0, len(x.files))
+		visited = make(map[string]bool, len(x.files))
+		visit   func(v *protogen.File)
+	)
+	visit = func(v *protogen.File) {
+		if visited[v.Desc.Path()] {
+			return
+		}
+		visited[v.Desc.Path()] = true
+		imports := v.Desc.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			if dep := x.file(imports.Get(i).Path()); dep != nil {
+				visit(dep)
+			}
+		}
+		files = append(files, v)
 	}
+	for _, v := range x.files {
+		visit(v)
+	}
+	return files
 }
 
 // MessageType retrieves the gopoet type name for a given message from the cache, note that the type must be loaded
-// into the cache (by using AddFile on the parent file) beforehand, otherwise it will panic.
+// into the cache (by using AddFile on the parent file) beforehand, otherwise it will panic, unless Placeholder is
+// set.
 func (x *Cache) MessageType(v protoreflect.MessageDescriptor) gopoet.TypeName {
 	x.once.Do(x.init)
 	if v != nil {
-		if v := x.lookup(v.FullName()); v != nil {
+		if v := x.lookup(v); v != nil {
 			return v
 		}
+		return x.placeholder(v)
 	}
 	panic(fmt.Sprintf("unknown type: %v", v))
 }
 
+// Warnings returns a warning for each unknown type resolved using Placeholder, in the order they were first
+// encountered.
+func (x *Cache) Warnings() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return append([]string(nil), x.warnings...)
+}
+
+// Message returns the message with the given full name, or nil if it hasn't been loaded into the cache, e.g. to
+// navigate from a referenced type back to its comments or options, see also TypeFullName.
+func (x *Cache) Message(v protoreflect.FullName) *protogen.Message {
+	x.once.Do(x.init)
+	x.loadPending()
+	return x.messages[v]
+}
+
+// Enum returns the enum with the given full name, or nil if it hasn't been loaded into the cache, see also
+// TypeFullName.
+func (x *Cache) Enum(v protoreflect.FullName) *protogen.Enum {
+	x.once.Do(x.init)
+	x.loadPending()
+	return x.enums[v]
+}
+
+// TypeFullName returns the full name of the message or enum corresponding to the given type name, which may be a
+// pointer, as returned by MessageType, or false if it isn't a type loaded into the cache.
+func (x *Cache) TypeFullName(v gopoet.TypeName) (protoreflect.FullName, bool) {
+	x.once.Do(x.init)
+	x.loadPending()
+	if v.Kind() == gopoet.KindPtr {
+		v = v.Elem()
+	}
+	if v.Kind() != gopoet.KindNamed {
+		return ``, false
+	}
+	symbol := v.Symbol()
+	name, ok := x.names[protogen.GoIdent{GoName: symbol.Name, GoImportPath: protogen.GoImportPath(symbol.Package.ImportPath)}]
+	return name, ok
+}
+
+// EnumValueConst retrieves the gopoet symbol of the constant generated for a given enum value from the cache, note
+// that the parent enum must be loaded into the cache beforehand, otherwise it will panic.
+func (x *Cache) EnumValueConst(v protoreflect.EnumValueDescriptor) gopoet.Symbol {
+	x.once.Do(x.init)
+	if v != nil && x.lookup(v.Parent()) != nil {
+		if ident, ok := x.data[v.FullName()]; ok {
+			return x.goPackage(ident.GoImportPath).Symbol(ident.GoName)
+		}
+	}
+	panic(fmt.Sprintf("unknown enum value: %v", v))
+}
+
 // MessageFields returns information for all the golang fields generated for a given message, where all fields must
 // exist in the cache. Oneof fields are represented by a single value.
 func (x *Cache) MessageFields(v *protogen.Message) []Field {
 	x.once.Do(x.init)
 	var (
-		fields []Field
-		seen   = make(map[string]*goField)
+		fields = make([]Field, 0, len(v.Fields))
+		seen   = make(map[string]*goField, len(v.Fields))
 	)
-	for _, field := range v.Fields {
+	for i, field := range v.Fields {
 		var name string
 		if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
 			name = field.Oneof.GoName
 		} else {
 			name = field.GoName
 		}
-		v := seen[name]
-		if v == nil {
-			v = &goField{cache: x, name: name, oneOf: field.Oneof}
-			fields = append(fields, v)
-			seen[name] = v
+		group := seen[name]
+		if group == nil {
+			// the capacity is limited, so the slice is never appended to in-place
+			group = &goField{cache: x, name: name, oneOf: field.Oneof, fields: v.Fields[i : i+1 : i+1]}
+			fields = append(fields, group)
+			seen[name] = group
+			continue
 		}
-		if v.oneOf != field.Oneof {
+		if group.oneOf != field.Oneof {
 			panic(field)
 		}
-		v.fields = append(v.fields, field)
+		group.fields = append(group.fields, field)
+	}
+	return fields
+}
+
+// FlatFields returns information for each of the fields of a given message, like MessageFields, but without merging
+// oneof fields, e.g. for wire-level generators. Each oneof field is represented by its own value, with the type and
+// getter of the field itself (see also OneOfField), and OneOf returning the oneof.
+func (x *Cache) FlatFields(v *protogen.Message) []Field {
+	x.once.Do(x.init)
+	fields := make([]Field, len(v.Fields))
+	for i, field := range v.Fields {
+		fields[i] = &goField{cache: x, name: field.GoName, oneOf: field.Oneof, fields: v.Fields[i : i+1 : i+1], flat: true}
 	}
 	return fields
 }
 
+// file returns the file with the given path, or nil if it hasn't been added to the cache.
+func (x *Cache) file(path string) *protogen.File {
+	for _, v := range x.files {
+		if v.Desc.Path() == path {
+			return v
+		}
+	}
+	return nil
+}
+
 func (x *Cache) init() {
 	x.data = make(map[protoreflect.FullName]protogen.GoIdent)
+	x.types = make(map[protoreflect.FullName]gopoet.TypeName)
+	x.pending = make(map[string]*protogen.File)
+	x.packages = make(map[protogen.GoImportPath]protogen.GoPackageName)
+	x.pkgs = make(map[protogen.GoImportPath]gopoet.Package)
+	x.remap = make(map[protogen.GoImportPath]protogen.GoImportPath)
+	x.messages = make(map[protoreflect.FullName]*protogen.Message)
+	x.enums = make(map[protoreflect.FullName]*protogen.Enum)
+	x.names = make(map[protogen.GoIdent]protoreflect.FullName)
+	x.unknown = make(map[protoreflect.FullName]struct{})
+}
+
+// checkImportPath returns an error if the given golang import path is empty, or invalid, i.e. it has empty, ".", or
+// "..", elements, or characters other than letters, digits, and "-._~+", like the import paths of modules.
+func checkImportPath(importPath string) error {
+	if importPath == `` {
+		return fmt.Errorf("empty import path")
+	}
+	for _, elem := range strings.Split(importPath, `/`) {
+		if elem == `` || elem == `.` || elem == `..` {
+			return fmt.Errorf("invalid import path %q: invalid element %q", importPath, elem)
+		}
+		for _, r := range elem {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(`-._~+`, r)) {
+				return fmt.Errorf("invalid import path %q: invalid character %q", importPath, r)
+			}
+		}
+	}
+	return nil
+}
+
+// loadPending loads any files pending lazy loading, in the order they were added.
+func (x *Cache) loadPending() {
+	if len(x.pending) == 0 {
+		return
+	}
+	for _, v := range x.files {
+		if x.pending[v.Desc.Path()] == v {
+			delete(x.pending, v.Desc.Path())
+			x.loadFile(v)
+		}
+	}
+}
+
+func (x *Cache) loadFile(v *protogen.File) {
+	for _, v := range v.Enums {
+		x.addEnum(v)
+	}
+	for _, v := range v.Messages {
+		x.addMessage(v)
+	}
 }
 
 func (x *Cache) addEnum(v *protogen.Enum) {
 	x.once.Do(x.init)
-	x.data[v.Desc.FullName()] = v.GoIdent
+	x.addType(v.Desc.FullName(), v.GoIdent)
+	x.enums[v.Desc.FullName()] = v
 	for _, v := range v.Values {
 		x.data[v.Desc.FullName()] = v.GoIdent
 	}
@@ -89,7 +354,8 @@ func (x *Cache) addEnum(v *protogen.Enum) {
 
 func (x *Cache) addMessage(v *protogen.Message) {
 	x.once.Do(x.init)
-	x.data[v.Desc.FullName()] = v.GoIdent
+	x.addType(v.Desc.FullName(), v.GoIdent)
+	x.messages[v.Desc.FullName()] = v
 	for _, v := range v.Enums {
 		x.addEnum(v)
 	}
@@ -101,18 +367,51 @@ func (x *Cache) addMessage(v *protogen.Message) {
 func (x *Cache) enumType(v protoreflect.EnumDescriptor) gopoet.TypeName {
 	x.once.Do(x.init)
 	if v != nil {
-		if v := x.lookup(v.FullName()); v != nil {
+		if v := x.lookup(v); v != nil {
 			return v
 		}
+		return x.placeholder(v)
 	}
 	panic(fmt.Sprintf("unknown type: %v", v))
 }
 
-func (x *Cache) lookup(fullName protoreflect.FullName) gopoet.TypeName {
-	if ident := x.data[fullName]; ident != (protogen.GoIdent{}) {
-		return gopoet.NamedType(gopoet.NewPackage(string(ident.GoImportPath)).Symbol(ident.GoName))
+// placeholder returns the Placeholder for the given (unknown) type, recording a warning, or panics if it is nil.
+func (x *Cache) placeholder(v protoreflect.Descriptor) gopoet.TypeName {
+	if x.Placeholder == nil {
+		panic(fmt.Sprintf("unknown type: %v", v))
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.unknown[v.FullName()]; !ok {
+		x.unknown[v.FullName()] = struct{}{}
+		x.warnings = append(x.warnings, fmt.Sprintf("unknown type: %s", v.FullName()))
+		x.Diagnostics.Warnf(v, "unknown type: %s", v.FullName())
+	}
+	return x.Placeholder(v)
+}
+
+// addType stores the ident, as well as the type name, which is resolved once, to avoid allocating on each lookup.
+func (x *Cache) addType(fullName protoreflect.FullName, ident protogen.GoIdent) {
+	x.data[fullName] = ident
+	x.names[x.goIdent(ident)] = fullName
+	pkg, ok := x.pkgs[ident.GoImportPath]
+	if !ok {
+		pkg = x.goPackage(ident.GoImportPath)
+		x.pkgs[ident.GoImportPath] = pkg
 	}
-	return nil
+	x.types[fullName] = gopoet.NamedType(pkg.Symbol(ident.GoName))
+}
+
+func (x *Cache) lookup(v protoreflect.Descriptor) gopoet.TypeName {
+	t, ok := x.types[v.FullName()]
+	if !ok && len(x.pending) != 0 {
+		if file := x.pending[v.ParentFile().Path()]; file != nil {
+			delete(x.pending, v.ParentFile().Path())
+			x.loadFile(file)
+			t = x.types[v.FullName()]
+		}
+	}
+	return t
 }
 
 func (x *Cache) fieldType(v protoreflect.FieldDescriptor) (t gopoet.TypeName) {
@@ -131,7 +430,7 @@ func (x *Cache) fieldType(v protoreflect.FieldDescriptor) (t gopoet.TypeName) {
 	case descriptorpb.FieldDescriptorProto_TYPE_INT32,
 		descriptorpb.FieldDescriptorProto_TYPE_SINT32,
 		descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
-		t = gopoet.Int32Type
+		t = int32Type
 	case descriptorpb.FieldDescriptorProto_TYPE_INT64,
 		descriptorpb.FieldDescriptorProto_TYPE_SINT64,
 		descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
@@ -148,7 +447,13 @@ func (x *Cache) fieldType(v protoreflect.FieldDescriptor) (t gopoet.TypeName) {
 		t = gopoet.Float64Type
 	case descriptorpb.FieldDescriptorProto_TYPE_GROUP,
 		descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
-		t = gopoet.PointerType(x.MessageType(v.Message()))
+		if custom := x.CustomType(v.Message()); custom != nil {
+			t = custom.Type
+		} else if t = x.lookup(v.Message()); t != nil {
+			t = gopoet.PointerType(t)
+		} else {
+			t = x.placeholder(v.Message())
+		}
 	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
 		t = x.enumType(v.Enum())
 	default:
@@ -157,8 +462,9 @@ func (x *Cache) fieldType(v protoreflect.FieldDescriptor) (t gopoet.TypeName) {
 	if v.IsList() {
 		t = gopoet.SliceType(t)
 	}
-	if v.ParentFile().Syntax() != protoreflect.Proto3 && t.Kind() != gopoet.KindPtr && t.Kind() != gopoet.KindSlice {
-		// for proto2, type is pointer or slice
+	if v.ParentFile().Syntax() != protoreflect.Proto3 && t.Kind() != gopoet.KindPtr && t.Kind() != gopoet.KindSlice &&
+		v.Message() == nil && (v.ContainingMessage() == nil || !v.ContainingMessage().IsMapEntry()) {
+		// for proto2, type is pointer or slice (except for map keys and values)
 		t = gopoet.PointerType(t)
 	}
 	return
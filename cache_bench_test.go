@@ -0,0 +1,154 @@
+package gopoet_protogen_test
+
+import (
+	"fmt"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"sync"
+	"testing"
+)
+
+const (
+	// benchMessages is the number of messages in benchPlugin.
+	benchMessages = 10000
+)
+
+var (
+	benchPluginOnce sync.Once
+	benchPluginV    *protogen.Plugin
+)
+
+// benchPlugin returns a plugin with a single, synthetic, file of benchMessages messages, each with a string, an
+// int64, and a repeated string field, a oneof of a string and an int32 field, and (except the first) a field of the
+// previous message type. It is built from descriptors, rather than using protogentest, which is slow to compile
+// large sources.
+func benchPlugin() *protogen.Plugin {
+	benchPluginOnce.Do(func() {
+		var (
+			optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+			repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			field    = func(name string, number int32, label *descriptorpb.FieldDescriptorProto_Label, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+				return &descriptorpb.FieldDescriptorProto{
+					Name:     proto.String(name),
+					Number:   proto.Int32(number),
+					Label:    label,
+					Type:     kind.Enum(),
+					JsonName: proto.String(name),
+				}
+			}
+			file = &descriptorpb.FileDescriptorProto{
+				Name:    proto.String(`bench/v1/bench.proto`),
+				Package: proto.String(`bench.v1`),
+				Syntax:  proto.String(`proto3`),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String(`example.com/bench/v1;benchv1`)},
+			}
+		)
+		for i := 0; i < benchMessages; i++ {
+			message := &descriptorpb.DescriptorProto{
+				Name: proto.String(fmt.Sprintf(`M%d`, i)),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field(`name`, 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field(`count`, 2, optional, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+					field(`tags`, 3, repeated, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field(`a`, 5, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field(`b`, 6, optional, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String(`choice`)}},
+			}
+			message.Field[3].OneofIndex = proto.Int32(0)
+			message.Field[4].OneofIndex = proto.Int32(0)
+			if i != 0 {
+				prev := field(`prev`, 4, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+				prev.TypeName = proto.String(fmt.Sprintf(`.bench.v1.M%d`, i-1))
+				message.Field = append(message.Field, prev)
+			}
+			file.MessageType = append(file.MessageType, message)
+		}
+		plugin, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+			FileToGenerate: []string{file.GetName()},
+			ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		})
+		if err != nil {
+			panic(err)
+		}
+		benchPluginV = plugin
+	})
+	return benchPluginV
+}
+
+// benchCache returns a new cache, with every file of benchPlugin added, and loaded, see also Cache.Freeze.
+func benchCache() *gopoet_protogen.Cache {
+	cache := new(gopoet_protogen.Cache)
+	for _, file := range benchPlugin().Files {
+		cache.AddFile(file)
+	}
+	cache.Freeze()
+	return cache
+}
+
+func BenchmarkCache_MessageType(b *testing.B) {
+	var (
+		cache = benchCache()
+		descs []protoreflect.MessageDescriptor
+	)
+	for _, v := range benchPlugin().Files[0].Messages {
+		descs = append(descs, v.Desc)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if cache.MessageType(descs[i%len(descs)]) == nil {
+			b.Fatal(`unknown type`)
+		}
+	}
+}
+
+func BenchmarkCache_AddFile(b *testing.B) {
+	plugin := benchPlugin()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache := new(gopoet_protogen.Cache)
+		for _, file := range plugin.Files {
+			cache.AddFile(file)
+		}
+		cache.Freeze()
+	}
+}
+
+// BenchmarkCache_MessageFields resolves the fields (including oneofs) of every message, per op.
+func BenchmarkCache_MessageFields(b *testing.B) {
+	benchFields(b, (*gopoet_protogen.Cache).MessageFields)
+}
+
+// BenchmarkCache_FlatFields resolves the fields (with oneofs flattened) of every message, per op.
+func BenchmarkCache_FlatFields(b *testing.B) {
+	benchFields(b, (*gopoet_protogen.Cache).FlatFields)
+}
+
+// benchFields calls fields for every message of benchPlugin, per op, resolving the type, getter, HasExpr, and oneof
+// field types, of each field.
+func benchFields(b *testing.B, fields func(cache *gopoet_protogen.Cache, v *protogen.Message) []gopoet_protogen.Field) {
+	var (
+		cache    = benchCache()
+		messages = benchPlugin().Files[0].Messages
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range messages {
+			for _, field := range fields(cache, v) {
+				_ = field.Type()
+				_ = field.Getter()
+				_ = field.HasExpr(`v`)
+				for _, field := range field.OneOfFields() {
+					_ = field.Type
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package gopoet_protogen_test
+
+import (
+	"testing"
+)
+
+func TestCache_MessageFields_proto2(t *testing.T) {
+	// like protoc-gen-go, scalars have explicit presence, except for map keys and values, and repeated fields
+	result := compileItem(t)
+	types := make(map[string]string)
+	for _, field := range result.Cache.MessageFields(result.Message(`test.v2.Item`)) {
+		types[field.Name()] = field.Type().String()
+	}
+	for name, expected := range map[string]string{
+		`Name`:    `*string`,
+		`Kind`:    `*testv2.Kind`,
+		`Data`:    `[]byte`,
+		`Ratio`:   `*float64`,
+		`Numbers`: `[]int32`,
+		`Names`:   `map[int32]string`,
+		`SubMap`:  `map[string]*testv2.Item_Sub`,
+		`Subs`:    `[]*testv2.Item_Sub`,
+	} {
+		if types[name] != expected {
+			t.Errorf("unexpected type for %s: %s != %s", name, types[name], expected)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package gopoet_protogen
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strconv"
+	"time"
+)
+
+type (
+	// CallPolicyGenerator generates, for each service with any method-level call policies, tables of per-method
+	// timeouts and grpc.CallOption values, keyed by full method name, a gRPC service config, and a unary client
+	// interceptor applying the tables, e.g. for teams encoding SLOs in proto.
+	//
+	// For a service named "Service", the generated elements are named "Service_Timeouts", "Service_CallOptions",
+	// "Service_ServiceConfig" (a JSON string, for grpc.WithDefaultServiceConfig), and
+	// "Service_CallPolicyInterceptor".
+	CallPolicyGenerator struct {
+		// TimeoutOption may be used to configure the field number of a custom method option (extension of
+		// google.protobuf.MethodOptions) specifying the timeout of each method, which may be a
+		// google.protobuf.Duration, a string parsed using time.ParseDuration, or an integer number of milliseconds.
+		TimeoutOption protoreflect.FieldNumber
+		// CallOptions may be used to provide call options for each method, as code blocks evaluating to a
+		// grpc.CallOption, e.g. grpc.WaitForReady(true).
+		CallOptions func(v *protogen.Method) []*gopoet.CodeBlock
+		// RetryPolicy may be set to include a retry policy, in the service config, for each method with an
+		// idempotency_level of NO_SIDE_EFFECTS or IDEMPOTENT.
+		RetryPolicy *RetryPolicy
+	}
+
+	// RetryPolicy models the retryPolicy of a gRPC service config, see also CallPolicyGenerator.
+	RetryPolicy struct {
+		MaxAttempts          int
+		InitialBackoff       time.Duration
+		MaxBackoff           time.Duration
+		BackoffMultiplier    float64
+		RetryableStatusCodes []string
+	}
+)
+
+var (
+	_ Generator = (*CallPolicyGenerator)(nil)
+
+	durationType = gopoet.NamedType(timePkg.Symbol(`Duration`))
+)
+
+// GenerateFile returns the timeouts, call options, service config, and interceptor, for each service in the given
+// file, with any call policies.
+func (x *CallPolicyGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range file.Services {
+		if x.HasPolicy(v) {
+			elements = append(elements, x.Vars(v), x.ServiceConfig(v), x.InterceptorFunc(v))
+		}
+	}
+	return elements
+}
+
+// HasPolicy returns true if any method of the given service has a timeout, call options, or retry policy.
+func (x *CallPolicyGenerator) HasPolicy(v *protogen.Service) bool {
+	for _, method := range v.Methods {
+		if _, ok := x.Timeout(method); ok ||
+			x.CallOptions != nil && len(x.CallOptions(method)) != 0 ||
+			x.retry(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeout returns the timeout of the given method, decoded from the option configured by TimeoutOption, or false
+// if it is not set, or is not positive.
+func (x *CallPolicyGenerator) Timeout(v *protogen.Method) (time.Duration, bool) {
+	if x.TimeoutOption == 0 {
+		return 0, false
+	}
+	var timeout time.Duration
+	if values := OptionBytes(v.Desc, x.TimeoutOption); len(values) != 0 {
+		value := values[len(values)-1]
+		var err error
+		if timeout, err = time.ParseDuration(string(value)); err != nil {
+			timeout = parseDuration(value)
+		}
+	} else if value, ok := OptionVarint(v.Desc, x.TimeoutOption); ok {
+		timeout = time.Duration(value) * time.Millisecond
+	}
+	return timeout, timeout > 0
+}
+
+// Vars returns the timeouts and call options tables for the given service.
+func (x *CallPolicyGenerator) Vars(v *protogen.Service) *gopoet.VarDecl {
+	var (
+		timeouts    = v.GoName + `_Timeouts`
+		callOptions = v.GoName + `_CallOptions`
+		optionType  = gopoet.NamedType(grpcPkg.Symbol(`CallOption`))
+		timeoutsCB  = gopoet.Printlnf(`%s{`, gopoet.MapType(gopoet.StringType, durationType))
+		optionsCB   = gopoet.Printlnf(`%s{`, gopoet.MapType(gopoet.StringType, gopoet.SliceType(optionType)))
+	)
+	for _, method := range v.Methods {
+		if timeout, ok := x.Timeout(method); ok {
+			timeoutsCB.Printf(`%q: `, FullMethodName(method)).AddCode(durationCode(timeout)).Println(`,`)
+		}
+		if x.CallOptions == nil {
+			continue
+		}
+		if options := x.CallOptions(method); len(options) != 0 {
+			optionsCB.Printlnf(`%q: {`, FullMethodName(method))
+			for _, option := range options {
+				optionsCB.AddCode(option).Println(`,`)
+			}
+			optionsCB.Println(`},`)
+		}
+	}
+	return gopoet.NewVarDecl(
+		gopoet.NewVar(timeouts).
+			SetComment(fmt.Sprintf(`%s maps the full name of each %s method to its timeout, if any.`, timeouts, v.Desc.FullName())).
+			SetInitializer(timeoutsCB.Print(`}`)),
+		gopoet.NewVar(callOptions).
+			SetComment(fmt.Sprintf(`%s maps the full name of each %s method to its call options, if any.`, callOptions, v.Desc.FullName())).
+			SetInitializer(optionsCB.Print(`}`)),
+	)
+}
+
+// ServiceConfig returns a constant containing the gRPC service config (JSON) for the given service, with the
+// timeout, and retry policy, of each method.
+func (x *CallPolicyGenerator) ServiceConfig(v *protogen.Service) *gopoet.ConstDecl {
+	type (
+		methodName struct {
+			Service string `json:"service"`
+			Method  string `json:"method"`
+		}
+		retryPolicy struct {
+			MaxAttempts          int      `json:"maxAttempts"`
+			InitialBackoff       string   `json:"initialBackoff"`
+			MaxBackoff           string   `json:"maxBackoff"`
+			BackoffMultiplier    float64  `json:"backoffMultiplier"`
+			RetryableStatusCodes []string `json:"retryableStatusCodes"`
+		}
+		methodConfig struct {
+			Name        []methodName `json:"name"`
+			Timeout     string       `json:"timeout,omitempty"`
+			RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+		}
+		serviceConfig struct {
+			MethodConfig []methodConfig `json:"methodConfig"`
+		}
+	)
+	var config serviceConfig
+	for _, method := range v.Methods {
+		c := methodConfig{Name: []methodName{{Service: string(v.Desc.FullName()), Method: string(method.Desc.Name())}}}
+		if timeout, ok := x.Timeout(method); ok {
+			c.Timeout = serviceConfigDuration(timeout)
+		}
+		if x.retry(method) {
+			c.RetryPolicy = &retryPolicy{
+				MaxAttempts:          x.RetryPolicy.MaxAttempts,
+				InitialBackoff:       serviceConfigDuration(x.RetryPolicy.InitialBackoff),
+				MaxBackoff:           serviceConfigDuration(x.RetryPolicy.MaxBackoff),
+				BackoffMultiplier:    x.RetryPolicy.BackoffMultiplier,
+				RetryableStatusCodes: x.RetryPolicy.RetryableStatusCodes,
+			}
+		}
+		if c.Timeout != `` || c.RetryPolicy != nil {
+			config.MethodConfig = append(config.MethodConfig, c)
+		}
+	}
+	if config.MethodConfig == nil {
+		config.MethodConfig = []methodConfig{}
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		panic(err)
+	}
+	name := v.GoName + `_ServiceConfig`
+	return gopoet.NewConstDecl(gopoet.NewConst(name).
+		SetComment(fmt.Sprintf(`%s is the gRPC service config for %s, e.g. for use with grpc.WithDefaultServiceConfig.`, name, v.Desc.FullName())).
+		Initialize(`%q`, string(b)))
+}
+
+// InterceptorFunc returns a grpc.UnaryClientInterceptor applying the timeouts and call options for the given
+// service, where timeouts only apply if the context has no earlier deadline.
+func (x *CallPolicyGenerator) InterceptorFunc(v *protogen.Service) *gopoet.FuncSpec {
+	var (
+		name        = v.GoName + `_CallPolicyInterceptor`
+		contextType = gopoet.NamedType(contextPkg.Symbol(`Context`))
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s is a grpc.UnaryClientInterceptor, applying %s_Timeouts, and %s_CallOptions.`, name, v.GoName, v.GoName)).
+		AddArg(`ctx`, contextType).
+		AddArg(`method`, gopoet.StringType).
+		AddArg(`req`, emptyInterfaceType).
+		AddArg(`reply`, emptyInterfaceType).
+		AddArg(`cc`, gopoet.PointerType(gopoet.NamedType(grpcPkg.Symbol(`ClientConn`)))).
+		AddArg(`invoker`, gopoet.NamedType(grpcPkg.Symbol(`UnaryInvoker`))).
+		AddArg(`opts`, gopoet.SliceType(gopoet.NamedType(grpcPkg.Symbol(`CallOption`)))).
+		SetVariadic(true).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`if timeout, ok := %s_Timeouts[method]; ok {`, v.GoName).
+		Printlnf(`if deadline, ok := ctx.Deadline(); !ok || %s(deadline) > timeout {`, timePkg.Symbol(`Until`)).
+		Printlnf(`var cancel %s`, contextPkg.Symbol(`CancelFunc`)).
+		Printlnf(`ctx, cancel = %s(ctx, timeout)`, contextPkg.Symbol(`WithTimeout`)).
+		Println(`defer cancel()`).
+		Println(`}`).
+		Println(`}`).
+		Printlnf(`if options := %s_CallOptions[method]; len(options) != 0 {`, v.GoName).
+		Println(`opts = append(options[:len(options):len(options)], opts...)`).
+		Println(`}`).
+		Println(`return invoker(ctx, method, req, reply, cc, opts...)`)
+}
+
+// retry returns true if the given method should have a retry policy.
+func (x *CallPolicyGenerator) retry(v *protogen.Method) bool {
+	return x.RetryPolicy != nil && (&Method{Method: v}).IsIdempotent()
+}
+
+// parseDuration decodes an encoded google.protobuf.Duration, returning zero if it is invalid.
+func parseDuration(b []byte) time.Duration {
+	var seconds, nanos int64
+	for len(b) != 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0
+		}
+		b = b[n:]
+		if typ == protowire.VarintType {
+			var value uint64
+			if value, n = protowire.ConsumeVarint(b); n >= 0 {
+				switch num {
+				case 1:
+					seconds = int64(value)
+				case 2:
+					nanos = int64(int32(value))
+				}
+			}
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return 0
+		}
+		b = b[n:]
+	}
+	return time.Duration(seconds)*time.Second + time.Duration(nanos)
+}
+
+// durationCode returns a golang expression for the given duration, using the largest exact unit.
+func durationCode(d time.Duration) *gopoet.CodeBlock {
+	for _, unit := range [...]struct {
+		name  string
+		value time.Duration
+	}{
+		{`Hour`, time.Hour},
+		{`Minute`, time.Minute},
+		{`Second`, time.Second},
+		{`Millisecond`, time.Millisecond},
+		{`Microsecond`, time.Microsecond},
+	} {
+		if d%unit.value == 0 {
+			return gopoet.Printf(`%d * %s`, d/unit.value, timePkg.Symbol(unit.name))
+		}
+	}
+	return gopoet.Printf(`%d * %s`, d, timePkg.Symbol(`Nanosecond`))
+}
+
+// serviceConfigDuration formats the given duration as a service config duration, e.g. "1.5s".
+func serviceConfigDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + `s`
+}
@@ -0,0 +1,86 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// CELGenerator generates github.com/google/cel-go declarations and registration helpers, for each message, and a
+	// function registering every message type in the file, such that policy engines may configure CEL environments
+	// using compile-time-known types, rather than resolving them via runtime file registries.
+	//
+	// For each message, "<Message>CELVariable" returns a decls.NewVariable declaration, of the message's object
+	// type, and "<Message>CELEnvOptions" returns the options registering the type, and declaring a variable of that
+	// type. The function for the file is named using the golang name of the file descriptor variable generated by
+	// protoc-gen-go, followed by "_CELTypes", e.g. "File_pkg_v1_file_proto_CELTypes".
+	CELGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+	}
+)
+
+var (
+	_ Generator = (*CELGenerator)(nil)
+
+	celPkg      = gopoet.NewPackage("github.com/google/cel-go/cel")
+	celDeclsPkg = gopoet.NewPackage("github.com/google/cel-go/common/decls")
+	celTypesPkg = gopoet.NewPackage("github.com/google/cel-go/common/types")
+)
+
+// GenerateFile returns the variable and environment functions, for each message in the given file, followed by the
+// types function for the file, see also FileMessages.
+func (x *CELGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	messages := FileMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+	var elements []gopoet.FileElement
+	for _, v := range messages {
+		elements = append(elements, x.VariableFunc(v), x.EnvOptionsFunc(v))
+	}
+	return append(elements, x.TypesFunc(file))
+}
+
+// VariableFunc returns a function that returns a CEL variable declaration, with a given name, of the object type for
+// the given message.
+func (x *CELGenerator) VariableFunc(v *protogen.Message) *gopoet.FuncSpec {
+	name := v.GoIdent.GoName + `CELVariable`
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a CEL variable declaration, with the given name, of type %s.`, name, v.Desc.FullName())).
+		AddArg(`name`, gopoet.StringType).
+		AddResult(``, gopoet.PointerType(gopoet.NamedType(celDeclsPkg.Symbol(`VariableDecl`)))).
+		Printlnf(`return %s(name, %s(%q))`, celDeclsPkg.Symbol(`NewVariable`), celTypesPkg.Symbol(`NewObjectType`), v.Desc.FullName())
+}
+
+// EnvOptionsFunc returns a function that returns the CEL environment options registering the given message type,
+// and declaring a variable, with a given name, of that type.
+func (x *CELGenerator) EnvOptionsFunc(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name       = v.GoIdent.GoName + `CELEnvOptions`
+		optionType = gopoet.NamedType(celPkg.Symbol(`EnvOption`))
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the CEL environment options registering %s, and declaring a variable of that type, with the given name.`, name, v.Desc.FullName())).
+		AddArg(`name`, gopoet.StringType).
+		AddResult(``, gopoet.SliceType(optionType)).
+		Printlnf(`return %s{`, gopoet.SliceType(optionType)).
+		Printlnf(`%s((*%s)(nil)),`, celPkg.Symbol(`Types`), x.Cache.MessageType(v.Desc)).
+		Printlnf(`%s(name, %s(%q)),`, celPkg.Symbol(`Variable`), celPkg.Symbol(`ObjectType`), v.Desc.FullName()).
+		Println(`}`)
+}
+
+// TypesFunc returns a function that returns a CEL environment option registering every message type in the given
+// file.
+func (x *CELGenerator) TypesFunc(file *protogen.File) *gopoet.FuncSpec {
+	name := file.GoDescriptorIdent.GoName + `_CELTypes`
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a CEL environment option registering the message types declared in %s.`, name, file.Desc.Path())).
+		AddResult(``, gopoet.NamedType(celPkg.Symbol(`EnvOption`))).
+		Printlnf(`return %s(`, celPkg.Symbol(`Types`))
+	for _, v := range FileMessages(file) {
+		f.Printlnf(`(*%s)(nil),`, x.Cache.MessageType(v.Desc))
+	}
+	return f.Println(`)`)
+}
@@ -0,0 +1,114 @@
+package gopoet_protogen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"io"
+	"strings"
+)
+
+type (
+	// CodeSize models the amount of code emitted by a single generator, for a single top-level message, or service,
+	// or the remaining top-level declarations (enums and extensions) of a file, see also Cache.CodeSizes.
+	CodeSize struct {
+		// File is the path of the input file.
+		File string `json:"file"`
+		// Name is the full name of the message or service, or empty, for the remaining declarations of the file.
+		Name string `json:"name,omitempty"`
+		// Generator is the name of the (dereferenced) type of the generator, e.g. "RedactGenerator".
+		Generator string `json:"generator"`
+		// Lines is the number of lines emitted, including any imports.
+		Lines int `json:"lines"`
+		// Bytes is the number of bytes emitted, including any imports.
+		Bytes int `json:"bytes"`
+	}
+)
+
+// CodeSizes measures the code emitted by each of the given generators, for the given file, as if it were generated
+// into the package of dst (which isn't modified), e.g. to track the bloat of generated code. Each top-level message
+// (including nested declarations), and service, is measured separately, by generating a copy of the file, declaring
+// only that message or service, as are the remaining top-level enums and extensions. Output is measured by rendering
+// it, formatted, like WriteGoFile, less the size of an empty file, and empty output is omitted. Note that generators
+// that emit file-level declarations (e.g. FixtureGenerator) are measured once per copy.
+func (x *Cache) CodeSizes(dst *gopoet.GoFile, file *protogen.File, generators ...Generator) ([]CodeSize, error) {
+	base, err := x.renderedSize(dst, nil)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		sizes  []CodeSize
+		slices = make([]*protogen.File, 0, 1+len(file.Messages)+len(file.Services))
+		names  = make([]string, 0, cap(slices))
+	)
+	{
+		v := *file
+		v.Messages, v.Services = nil, nil
+		slices = append(slices, &v)
+		names = append(names, ``)
+	}
+	for _, message := range file.Messages {
+		v := *file
+		v.Enums, v.Messages, v.Extensions, v.Services = nil, []*protogen.Message{message}, nil, nil
+		slices = append(slices, &v)
+		names = append(names, string(message.Desc.FullName()))
+	}
+	for _, service := range file.Services {
+		v := *file
+		v.Enums, v.Messages, v.Extensions, v.Services = nil, nil, nil, []*protogen.Service{service}
+		slices = append(slices, &v)
+		names = append(names, string(service.Desc.FullName()))
+	}
+	for i, slice := range slices {
+		for _, generator := range generators {
+			elements := generator.GenerateFile(slice)
+			if len(elements) == 0 {
+				continue
+			}
+			size, err := x.renderedSize(dst, elements)
+			if err != nil {
+				return nil, err
+			}
+			sizes = append(sizes, CodeSize{
+				File:      file.Desc.Path(),
+				Name:      names[i],
+				Generator: generatorName(generator),
+				Lines:     size.Lines - base.Lines,
+				Bytes:     size.Bytes - base.Bytes,
+			})
+		}
+	}
+	return sizes, nil
+}
+
+// WriteCodeSizeReport writes the given sizes, as a JSON array of CodeSize, to the given writer, e.g. as a report
+// artifact, see also Pool.CodeSizes, and PoolResult.Sizes.
+func WriteCodeSizeReport(w io.Writer, sizes []CodeSize) error {
+	if sizes == nil {
+		sizes = []CodeSize{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent(``, `  `)
+	return encoder.Encode(sizes)
+}
+
+// renderedSize returns the size of the given elements, rendered in a new file, in the package of dst.
+func (x *Cache) renderedSize(dst *gopoet.GoFile, elements []gopoet.FileElement) (CodeSize, error) {
+	file := gopoet.NewGoFile(dst.Name, dst.Package().ImportPath, dst.Package().Name)
+	for _, element := range elements {
+		file.AddElement(element)
+	}
+	var b bytes.Buffer
+	if err := x.WriteGoFile(&b, file); err != nil {
+		return CodeSize{}, err
+	}
+	return CodeSize{Lines: bytes.Count(b.Bytes(), []byte("\n")), Bytes: b.Len()}, nil
+}
+
+// generatorName returns the name of the (dereferenced) type of the given generator, e.g. "RedactGenerator".
+func generatorName(v Generator) string {
+	name := fmt.Sprintf(`%T`, v)
+	return strings.TrimPrefix(name[strings.LastIndex(name, `.`)+1:], `*`)
+}
@@ -0,0 +1,113 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"regexp"
+	"strings"
+)
+
+type (
+	// CommentSanitizer converts proto comments into text suitable for golang doc comments, e.g. via SetComment,
+	// which prefixes each line with "// ", see also Cache.Comment.
+	//
+	// The common leading space, and any leading slashes (e.g. from "///" comments), are removed from each line,
+	// trailing whitespace and blank lines are removed, "*/" sequences are escaped, and markdown code fences are
+	// converted to indented code blocks, which is the golang syntax.
+	CommentSanitizer struct {
+		// Width may be set to wrap lines longer than it (excluding the comment prefix), at spaces, where possible.
+		// Indented lines, i.e. code blocks, are never wrapped.
+		Width int
+		// StripDirectives may be set to remove lines resembling golang directives, e.g. "go:generate", "go:build",
+		// or "nolint", which would be interpreted by tools, if the comment prefix were "//", rather than "// ".
+		StripDirectives bool
+	}
+)
+
+var (
+	directivePattern = regexp.MustCompile(`^([a-z0-9]+:[a-z0-9]|nolint\b|line \S+:\d)`)
+)
+
+// Sanitize returns the given comment text, sanitized, or an empty string, if there is no text.
+func (x CommentSanitizer) Sanitize(comment string) string {
+	var (
+		lines  = strings.Split(strings.ReplaceAll(comment, "\r\n", "\n"), "\n")
+		indent = -1
+	)
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if text := strings.TrimLeft(line, ` `); text == `/` || strings.HasPrefix(text, `//`) || strings.HasPrefix(text, `/ `) {
+			// e.g. "///" comments, but not paths, like "/v1/foo"
+			line = line[:len(line)-len(text)] + strings.TrimPrefix(strings.TrimLeft(text, `/`), ` `)
+		}
+		lines[i] = line
+		if line == `` {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, ` `)); indent == -1 || n < indent {
+			indent = n
+		}
+	}
+	if indent == -1 {
+		return ``
+	}
+	var (
+		result []string
+		fenced bool
+	)
+	for _, line := range lines {
+		if len(line) >= indent {
+			line = line[indent:]
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		line = strings.ReplaceAll(line, `*/`, `* /`)
+		switch {
+		case fenced:
+			if line != `` {
+				line = "\t" + line
+			}
+			result = append(result, line)
+		case x.StripDirectives && directivePattern.MatchString(line):
+		case x.Width > 0 && !strings.HasPrefix(line, ` `) && !strings.HasPrefix(line, "\t"):
+			result = append(result, wrapLine(line, x.Width)...)
+		default:
+			result = append(result, line)
+		}
+	}
+	for len(result) != 0 && result[0] == `` {
+		result = result[1:]
+	}
+	for len(result) != 0 && result[len(result)-1] == `` {
+		result = result[:len(result)-1]
+	}
+	return strings.Join(result, "\n")
+}
+
+// Comment returns the given proto comment, sanitized using the CommentSanitizer of the cache, see also
+// Cache.Comments.
+func (x *Cache) Comment(v protogen.Comments) string {
+	var sanitizer CommentSanitizer
+	if x != nil && x.Comments != nil {
+		sanitizer = *x.Comments
+	}
+	return sanitizer.Sanitize(string(v))
+}
+
+// wrapLine splits the given line at spaces, such that each line is no longer than width, unless it contains a
+// single word that is longer.
+func wrapLine(line string, width int) []string {
+	var lines []string
+	for len(line) > width {
+		i := strings.LastIndexByte(line[:width+1], ' ')
+		if i <= 0 {
+			if i = strings.IndexByte(line, ' '); i <= 0 {
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(line[:i], ` `))
+		line = strings.TrimLeft(line[i:], ` `)
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,103 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// ConfigGenerator generates UnmarshalJSON and UnmarshalYAML methods for each message, which decode using
+	// protojson, such that messages embedded in configuration structs, decoded using encoding/json or
+	// gopkg.in/yaml.v3, handle enum names, well-known types, and unknown fields, consistently with protobuf. YAML is
+	// converted to JSON, prior to decoding.
+	//
+	// The methods must be generated into the same package as the messages. A single function, converting decoded
+	// YAML values to JSON-compatible values, is generated for each file with any messages.
+	ConfigGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// DiscardUnknown may be set to ignore unknown fields, which are otherwise rejected.
+		DiscardUnknown bool
+	}
+)
+
+var (
+	_ Generator = (*ConfigGenerator)(nil)
+
+	jsonPkg      = gopoet.NewPackage("encoding/json")
+	protojsonPkg = gopoet.NewPackage("google.golang.org/protobuf/encoding/protojson")
+	yamlPkg      = gopoet.Package{ImportPath: "gopkg.in/yaml.v3", Name: "yaml"}
+)
+
+// GenerateFile returns the YAML conversion function for the given file, followed by the UnmarshalJSON and
+// UnmarshalYAML methods, for each message in the file, see also FileMessages.
+func (x *ConfigGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	messages := FileMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+	elements := []gopoet.FileElement{x.ValueFunc(file)}
+	for _, v := range messages {
+		elements = append(elements, x.UnmarshalJSONMethod(v), x.UnmarshalYAMLMethod(v))
+	}
+	return elements
+}
+
+// ValueFunc returns a function, for the given file, that converts a value decoded from YAML into a value that may be
+// encoded as JSON, i.e. with string map keys.
+func (x *ConfigGenerator) ValueFunc(file *protogen.File) *gopoet.FuncSpec {
+	name := fileSymbol(file, `_yamlValue`)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s converts a value decoded from YAML into a value that may be encoded as JSON.`, name)).
+		AddArg(`v`, emptyInterfaceType).
+		AddResult(``, emptyInterfaceType).
+		Println(`switch v := v.(type) {`).
+		Println(`case map[interface{}]interface{}:`).
+		Println(`m := make(map[string]interface{}, len(v))`).
+		Println(`for k, v := range v {`).
+		Printlnf(`m[%s(k)] = %s(v)`, fmtPkg.Symbol(`Sprint`), name).
+		Println(`}`).
+		Println(`return m`).
+		Println(`case map[string]interface{}:`).
+		Println(`for k, e := range v {`).
+		Printlnf(`v[k] = %s(e)`, name).
+		Println(`}`).
+		Println(`case []interface{}:`).
+		Println(`for i, e := range v {`).
+		Printlnf(`v[i] = %s(e)`, name).
+		Println(`}`).
+		Println(`}`).
+		Println(`return v`)
+}
+
+// UnmarshalJSONMethod returns the UnmarshalJSON method for the given message, implementing json.Unmarshaler.
+func (x *ConfigGenerator) UnmarshalJSONMethod(v *protogen.Message) *gopoet.FuncSpec {
+	return gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, x.Cache.MessageType(v.Desc)), `UnmarshalJSON`).
+		SetComment(`UnmarshalJSON implements json.Unmarshaler, using protojson.`).
+		AddArg(`b`, bytesType).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`return %s{DiscardUnknown: %t}.Unmarshal(b, x)`, protojsonPkg.Symbol(`UnmarshalOptions`), x.DiscardUnknown)
+}
+
+// UnmarshalYAMLMethod returns the UnmarshalYAML method for the given message, implementing yaml.Unmarshaler, which
+// converts the YAML to JSON, then calls UnmarshalJSON.
+func (x *ConfigGenerator) UnmarshalYAMLMethod(v *protogen.Message) *gopoet.FuncSpec {
+	file := x.Cache.file(v.Desc.ParentFile().Path())
+	if file == nil {
+		panic(fmt.Sprintf("unknown type: %v", v.Desc))
+	}
+	return gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, x.Cache.MessageType(v.Desc)), `UnmarshalYAML`).
+		SetComment(`UnmarshalYAML implements yaml.Unmarshaler, converting to JSON, then using protojson.`).
+		AddArg(`value`, gopoet.PointerType(gopoet.NamedType(yamlPkg.Symbol(`Node`)))).
+		AddResult(``, gopoet.ErrorType).
+		Println(`var v interface{}`).
+		Println(`if err := value.Decode(&v); err != nil {`).
+		Println(`return err`).
+		Println(`}`).
+		Printlnf(`b, err := %s(%s(v))`, jsonPkg.Symbol(`Marshal`), fileSymbol(file, `_yamlValue`)).
+		Println(`if err != nil {`).
+		Println(`return err`).
+		Println(`}`).
+		Println(`return x.UnmarshalJSON(b)`)
+}
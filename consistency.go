@@ -0,0 +1,266 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// ConsistencyCheck identifies a kind of problem, reported by Cache.CheckConsistency.
+	ConsistencyCheck int
+
+	// ConsistencyProblem describes a golang-level problem, spanning the files loaded into a cache, that would cause
+	// the generated code to fail to compile, see also Cache.CheckConsistency.
+	ConsistencyProblem struct {
+		// Check is the kind of problem.
+		Check ConsistencyCheck
+		// Files are the paths of the files involved, in the order they were added to the cache.
+		Files []string
+		// Message describes the problem.
+		Message string
+	}
+
+	// consistencyDecl is a declaration of a golang identifier, see also Cache.checkNames.
+	consistencyDecl struct {
+		name protoreflect.FullName
+		file string
+		kind string
+	}
+)
+
+const (
+	// CheckNameCollision reports multiple declarations (messages, enums, oneof wrapper types, or extensions), which
+	// are generated as the same golang identifier, e.g. due to multiple proto packages with the same go_package, or
+	// nested names, like "Foo.Bar", which collide with top-level names, like "Foo_Bar".
+	CheckNameCollision ConsistencyCheck = iota
+	// CheckEnumValueCollision reports enum values which are generated as the same golang identifier as another
+	// declaration (including other enum values), as enum values are prefixed with the name of the enum (or its
+	// parent message, if nested), rather than scoped, e.g. "Foo_BAR", for value BAR of a nested enum of Foo.
+	CheckEnumValueCollision
+	// CheckImportCycle reports golang packages which (transitively) import each other, as the files generated into
+	// them import each other.
+	CheckImportCycle
+)
+
+var (
+	consistencyCheckNames = [...]string{
+		`CheckNameCollision`,
+		`CheckEnumValueCollision`,
+		`CheckImportCycle`,
+	}
+)
+
+// String returns the name of the constant, e.g. CheckImportCycle.
+func (x ConsistencyCheck) String() string {
+	if x >= 0 && int(x) < len(consistencyCheckNames) {
+		return consistencyCheckNames[x]
+	}
+	return fmt.Sprintf(`ConsistencyCheck(%d)`, int(x))
+}
+
+// Error implements the error interface, returning the message.
+func (x *ConsistencyProblem) Error() string {
+	return x.Message
+}
+
+// CheckConsistency analyses all files loaded into the cache, with their golang packages (including any overrides by
+// ManagedMode), returning any problems that would cause the generated code (of protoc-gen-go, and therefore any
+// generators using it) to fail to compile, which are not detected by protoc, e.g. as they span proto packages. It
+// should be called after all files have been added, prior to generation, and returns problems in a deterministic
+// order, see also ConsistencyCheck.
+func (x *Cache) CheckConsistency() []*ConsistencyProblem {
+	x.once.Do(x.init)
+	return append(x.checkNames(), x.checkImportCycles()...)
+}
+
+// checkNames reports CheckNameCollision and CheckEnumValueCollision problems.
+func (x *Cache) checkNames() []*ConsistencyProblem {
+	var (
+		order []protogen.GoIdent
+		decls = make(map[protogen.GoIdent][]consistencyDecl)
+		add   = func(ident protogen.GoIdent, decl consistencyDecl) {
+			ident = x.goIdent(ident)
+			for _, v := range decls[ident] {
+				if v.name == decl.name {
+					return
+				}
+			}
+			if _, ok := decls[ident]; !ok {
+				order = append(order, ident)
+			}
+			decls[ident] = append(decls[ident], decl)
+		}
+	)
+	for _, file := range x.files {
+		path := file.Desc.Path()
+		for _, v := range FileEnums(file) {
+			add(v.GoIdent, consistencyDecl{v.Desc.FullName(), path, `enum`})
+			for _, value := range v.Values {
+				add(value.GoIdent, consistencyDecl{value.Desc.FullName(), path, `enum value`})
+			}
+		}
+		for _, v := range FileMessages(file) {
+			add(v.GoIdent, consistencyDecl{v.Desc.FullName(), path, `message`})
+			for _, field := range v.Fields {
+				if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+					add(field.GoIdent, consistencyDecl{field.Desc.FullName(), path, `oneof field`})
+				}
+			}
+			for _, ext := range v.Extensions {
+				add(ext.GoIdent, consistencyDecl{ext.Desc.FullName(), path, `extension`})
+			}
+		}
+		for _, ext := range file.Extensions {
+			add(ext.GoIdent, consistencyDecl{ext.Desc.FullName(), path, `extension`})
+		}
+	}
+	var problems []*ConsistencyProblem
+	for _, ident := range order {
+		list := decls[ident]
+		if len(list) < 2 {
+			continue
+		}
+		var (
+			problem = ConsistencyProblem{Check: CheckNameCollision}
+			names   = make([]string, 0, len(list))
+		)
+		for _, v := range list {
+			if v.kind == `enum value` {
+				problem.Check = CheckEnumValueCollision
+			}
+			names = append(names, fmt.Sprintf(`%s %s (%s)`, v.kind, v.name, v.file))
+			problem.Files = appendConsistencyFile(problem.Files, v.file)
+		}
+		problem.Message = fmt.Sprintf("golang identifier %s.%s is generated for multiple declarations: %s", string(ident.GoImportPath), ident.GoName, strings.Join(names, `, `))
+		problems = append(problems, &problem)
+	}
+	return problems
+}
+
+// checkImportCycles reports CheckImportCycle problems, i.e. strongly connected components of the golang package
+// import graph, with more than one package.
+func (x *Cache) checkImportCycles() []*ConsistencyProblem {
+	var (
+		order   []protogen.GoImportPath
+		imports = make(map[protogen.GoImportPath][]protogen.GoImportPath)
+		files   = make(map[protogen.GoImportPath][]*protogen.File)
+	)
+	for _, file := range x.files {
+		from := x.fileImportPath(file)
+		if _, ok := files[from]; !ok {
+			order = append(order, from)
+		}
+		files[from] = append(files[from], file)
+	}
+	for _, from := range order {
+		for _, file := range files[from] {
+			for i := 0; i < file.Desc.Imports().Len(); i++ {
+				dep := x.file(file.Desc.Imports().Get(i).Path())
+				if dep == nil {
+					continue
+				}
+				to := x.fileImportPath(dep)
+				if to != from && !containsImportPath(imports[from], to) {
+					imports[from] = append(imports[from], to)
+				}
+			}
+		}
+	}
+	// Tarjan's strongly connected components algorithm
+	var (
+		index    = make(map[protogen.GoImportPath]int)
+		lowLink  = make(map[protogen.GoImportPath]int)
+		onStack  = make(map[protogen.GoImportPath]bool)
+		stack    []protogen.GoImportPath
+		problems []*ConsistencyProblem
+		visit    func(v protogen.GoImportPath)
+	)
+	visit = func(v protogen.GoImportPath) {
+		index[v] = len(index)
+		lowLink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range imports[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				if lowLink[w] < lowLink[v] {
+					lowLink[v] = lowLink[w]
+				}
+			} else if onStack[w] && index[w] < lowLink[v] {
+				lowLink[v] = index[w]
+			}
+		}
+		if lowLink[v] != index[v] {
+			return
+		}
+		var component []protogen.GoImportPath
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) < 2 {
+			return
+		}
+		var (
+			problem = ConsistencyProblem{Check: CheckImportCycle}
+			names   = make([]string, 0, len(component))
+		)
+		for _, pkg := range order {
+			if containsImportPath(component, pkg) {
+				names = append(names, string(pkg))
+			}
+		}
+		for _, file := range x.files {
+			from := x.fileImportPath(file)
+			if !containsImportPath(component, from) {
+				continue
+			}
+			for i := 0; i < file.Desc.Imports().Len(); i++ {
+				if dep := x.file(file.Desc.Imports().Get(i).Path()); dep != nil {
+					if to := x.fileImportPath(dep); to != from && containsImportPath(component, to) {
+						problem.Files = append(problem.Files, file.Desc.Path())
+						break
+					}
+				}
+			}
+		}
+		problem.Message = fmt.Sprintf("import cycle between golang packages: %s", strings.Join(names, `, `))
+		problems = append(problems, &problem)
+	}
+	for _, v := range order {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	return problems
+}
+
+// fileImportPath returns the golang import path of the given file, including any override by ManagedMode.
+func (x *Cache) fileImportPath(file *protogen.File) protogen.GoImportPath {
+	return x.goIdent(protogen.GoIdent{GoImportPath: file.GoImportPath}).GoImportPath
+}
+
+func containsImportPath(s []protogen.GoImportPath, v protogen.GoImportPath) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func appendConsistencyFile(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
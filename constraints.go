@@ -0,0 +1,484 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+)
+
+type (
+	// FieldConstraints models the buf.validate.field option (protovalidate), of a field, decoded without depending
+	// on the generated buf.validate package, such that generators (e.g. of forms, OpenAPI, or SQL schemas) may
+	// consume validation rules, without generating validators, see also FieldValidateConstraints, and
+	// Field.Constraints. Only the rules of scalar, enum, repeated, and map fields are modeled, in a uniform way,
+	// while those of well-known types (e.g. google.protobuf.Duration) are ignored, other than Required, Ignore, and
+	// CEL.
+	FieldConstraints struct {
+		// Required is true if the field must be set, or, for oneofs, that one of the fields must be set.
+		Required bool
+		// Ignore is the value of the ignore rule (the buf.validate.Ignore enum), e.g. 3 (IGNORE_ALWAYS), or 0, if
+		// unset.
+		Ignore int32
+		// Kind is the kind of the type-specific rules, e.g. protoreflect.StringKind, for buf.validate.StringRules,
+		// or 0, if there are none, or they aren't modeled. Values (e.g. Const, and Min) are of this kind, where
+		// enums are protoreflect.EnumNumber values.
+		Kind protoreflect.Kind
+		// Const is the value the field must equal, if valid.
+		Const protoreflect.Value
+		// Min is the lower bound (gt, or gte) of a numeric field, if any. Note that Min may exceed Max, in which case
+		// the value must be outside the range.
+		Min *ConstraintBound
+		// Max is the upper bound (lt, or lte) of a numeric field, if any.
+		Max *ConstraintBound
+		// In are the values the field must be one of, if any.
+		In []protoreflect.Value
+		// NotIn are the values the field must not be one of.
+		NotIn []protoreflect.Value
+		// Finite is true if a float, or double, field must not be infinite, or NaN.
+		Finite bool
+		// MinLen is the minimum length of a string (in characters), or bytes field, if any (min_len, or len).
+		MinLen *uint64
+		// MaxLen is the maximum length of a string (in characters), or bytes field, if any (max_len, or len).
+		MaxLen *uint64
+		// MinBytes is the minimum length of a string field, in bytes, if any (min_bytes, or len_bytes).
+		MinBytes *uint64
+		// MaxBytes is the maximum length of a string field, in bytes, if any (max_bytes, or len_bytes).
+		MaxBytes *uint64
+		// Pattern is the (RE2) regular expression a string, or bytes, field must match, if any.
+		Pattern string
+		// Prefix is the prefix a string, or bytes, field must have, if any.
+		Prefix string
+		// Suffix is the suffix a string, or bytes, field must have, if any.
+		Suffix string
+		// Contains is the substring a string, or bytes, field must contain, if any.
+		Contains string
+		// NotContains is the substring a string field must not contain, if any.
+		NotContains string
+		// Format is the name of the well-known format of a string, or bytes, field, if any, e.g. "email", "uuid",
+		// or "ipv4", i.e. the name of the rule.
+		Format string
+		// DefinedOnly is true if an enum field must be one of the defined values.
+		DefinedOnly bool
+		// MinItems is the minimum number of elements of a repeated field, or pairs of a map field, if any.
+		MinItems *uint64
+		// MaxItems is the maximum number of elements of a repeated field, or pairs of a map field, if any.
+		MaxItems *uint64
+		// Unique is true if the elements of a repeated field must be unique.
+		Unique bool
+		// Items are the constraints of the elements of a repeated field, if any.
+		Items *FieldConstraints
+		// Keys are the constraints of the keys of a map field, if any.
+		Keys *FieldConstraints
+		// Values are the constraints of the values of a map field, if any.
+		Values *FieldConstraints
+		// CEL are the custom (CEL) rules of the field.
+		CEL []CELConstraint
+	}
+
+	// ConstraintBound models a bound of a numeric field, see also FieldConstraints.
+	ConstraintBound struct {
+		// Value is the bound.
+		Value protoreflect.Value
+		// Exclusive is true if the value may not equal the bound, i.e. gt, or lt.
+		Exclusive bool
+	}
+
+	// CELConstraint models a buf.validate.Rule, i.e. a custom rule, expressed in CEL.
+	CELConstraint struct {
+		ID         string
+		Message    string
+		Expression string
+	}
+)
+
+const (
+	// ValidateFieldNumber is the field number of the buf.validate.field, buf.validate.oneof, and buf.validate.message
+	// options, in google.protobuf.FieldOptions, google.protobuf.OneofOptions, and google.protobuf.MessageOptions.
+	ValidateFieldNumber protoreflect.FieldNumber = 1159
+)
+
+var (
+	// validateKinds maps the field numbers of the type-specific rules of buf.validate.FieldRules to their kind.
+	validateKinds = map[protowire.Number]protoreflect.Kind{
+		1:  protoreflect.FloatKind,
+		2:  protoreflect.DoubleKind,
+		3:  protoreflect.Int32Kind,
+		4:  protoreflect.Int64Kind,
+		5:  protoreflect.Uint32Kind,
+		6:  protoreflect.Uint64Kind,
+		7:  protoreflect.Sint32Kind,
+		8:  protoreflect.Sint64Kind,
+		9:  protoreflect.Fixed32Kind,
+		10: protoreflect.Fixed64Kind,
+		11: protoreflect.Sfixed32Kind,
+		12: protoreflect.Sfixed64Kind,
+		13: protoreflect.BoolKind,
+		14: protoreflect.StringKind,
+		15: protoreflect.BytesKind,
+		16: protoreflect.EnumKind,
+	}
+
+	// validateStringFormats maps the field numbers of the well-known format rules of buf.validate.StringRules to
+	// their name.
+	validateStringFormats = map[protowire.Number]string{
+		12: `email`,
+		13: `hostname`,
+		14: `ip`,
+		15: `ipv4`,
+		16: `ipv6`,
+		17: `uri`,
+		18: `uri_ref`,
+		21: `address`,
+		22: `uuid`,
+		26: `ip_with_prefixlen`,
+		27: `ipv4_with_prefixlen`,
+		28: `ipv6_with_prefixlen`,
+		29: `ip_prefix`,
+		30: `ipv4_prefix`,
+		31: `ipv6_prefix`,
+		32: `host_and_port`,
+		33: `tuuid`,
+	}
+
+	// validateBytesFormats maps the field numbers of the well-known format rules of buf.validate.BytesRules to
+	// their name.
+	validateBytesFormats = map[protowire.Number]string{
+		10: `ip`,
+		11: `ipv4`,
+		12: `ipv6`,
+	}
+)
+
+// FieldValidateConstraints decodes the buf.validate.field option of the given field, or returns nil, if it isn't
+// set. Malformed options are decoded on a best-effort basis.
+func FieldValidateConstraints(desc protoreflect.FieldDescriptor) *FieldConstraints {
+	values := OptionBytes(desc, ValidateFieldNumber)
+	if len(values) == 0 {
+		return nil
+	}
+	var constraints FieldConstraints
+	for _, b := range values {
+		constraints.decode(b)
+	}
+	return &constraints
+}
+
+// OneofValidateConstraints decodes the buf.validate.oneof option of the given oneof, or returns nil, if it isn't
+// set, where only Required is modeled.
+func OneofValidateConstraints(desc protoreflect.OneofDescriptor) *FieldConstraints {
+	values := OptionBytes(desc, ValidateFieldNumber)
+	if len(values) == 0 {
+		return nil
+	}
+	var constraints FieldConstraints
+	for _, b := range values {
+		rangeWireFields(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+			if num == 1 && typ == protowire.VarintType {
+				constraints.Required = v != 0
+			}
+		})
+	}
+	return &constraints
+}
+
+// decode merges the given encoded buf.validate.FieldRules into the receiver.
+func (x *FieldConstraints) decode(b []byte) {
+	rangeWireFields(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+		switch {
+		case num == 23 && typ == protowire.BytesType:
+			x.CEL = append(x.CEL, decodeCELConstraint(b))
+		case num == 25 && typ == protowire.VarintType:
+			x.Required = v != 0
+		case num == 27 && typ == protowire.VarintType:
+			x.Ignore = int32(v)
+		case num == 18 && typ == protowire.BytesType:
+			x.decodeRepeated(b)
+		case num == 19 && typ == protowire.BytesType:
+			x.decodeMap(b)
+		case typ == protowire.BytesType:
+			if kind, ok := validateKinds[num]; ok {
+				x.Kind = kind
+				x.decodeScalar(b)
+			}
+		}
+	})
+}
+
+// decodeScalar merges the given encoded type-specific rules, of Kind, into the receiver.
+func (x *FieldConstraints) decodeScalar(b []byte) {
+	rangeWireFields(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+		switch x.Kind {
+		case protoreflect.StringKind:
+			x.decodeString(num, typ, v, b)
+		case protoreflect.BytesKind:
+			x.decodeBytes(num, typ, v, b)
+		case protoreflect.EnumKind:
+			switch num {
+			case 1:
+				x.Const = x.values(typ, v, b)[0]
+			case 2:
+				x.DefinedOnly = v != 0
+			case 3:
+				x.In = append(x.In, x.values(typ, v, b)...)
+			case 4:
+				x.NotIn = append(x.NotIn, x.values(typ, v, b)...)
+			}
+		default:
+			if typ == protowire.BytesType && num != 6 && num != 7 {
+				return
+			}
+			switch num {
+			case 1:
+				x.Const = x.values(typ, v, b)[0]
+			case 2, 3:
+				x.Max = &ConstraintBound{Value: x.values(typ, v, b)[0], Exclusive: num == 2}
+			case 4, 5:
+				x.Min = &ConstraintBound{Value: x.values(typ, v, b)[0], Exclusive: num == 4}
+			case 6:
+				x.In = append(x.In, x.values(typ, v, b)...)
+			case 7:
+				x.NotIn = append(x.NotIn, x.values(typ, v, b)...)
+			case 8:
+				if x.Kind == protoreflect.FloatKind || x.Kind == protoreflect.DoubleKind {
+					x.Finite = v != 0
+				}
+			}
+		}
+	})
+}
+
+func (x *FieldConstraints) decodeString(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+	if format, ok := validateStringFormats[num]; ok {
+		if v != 0 {
+			x.Format = format
+		}
+		return
+	}
+	switch num {
+	case 1:
+		x.Const = protoreflect.ValueOfString(string(b))
+	case 19:
+		x.MinLen, x.MaxLen = uint64Ptr(v), uint64Ptr(v)
+	case 2:
+		x.MinLen = uint64Ptr(v)
+	case 3:
+		x.MaxLen = uint64Ptr(v)
+	case 20:
+		x.MinBytes, x.MaxBytes = uint64Ptr(v), uint64Ptr(v)
+	case 4:
+		x.MinBytes = uint64Ptr(v)
+	case 5:
+		x.MaxBytes = uint64Ptr(v)
+	case 6:
+		x.Pattern = string(b)
+	case 7:
+		x.Prefix = string(b)
+	case 8:
+		x.Suffix = string(b)
+	case 9:
+		x.Contains = string(b)
+	case 23:
+		x.NotContains = string(b)
+	case 10:
+		x.In = append(x.In, protoreflect.ValueOfString(string(b)))
+	case 11:
+		x.NotIn = append(x.NotIn, protoreflect.ValueOfString(string(b)))
+	}
+}
+
+func (x *FieldConstraints) decodeBytes(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+	if format, ok := validateBytesFormats[num]; ok {
+		if v != 0 {
+			x.Format = format
+		}
+		return
+	}
+	switch num {
+	case 1:
+		x.Const = protoreflect.ValueOfBytes(append([]byte(nil), b...))
+	case 13:
+		x.MinLen, x.MaxLen = uint64Ptr(v), uint64Ptr(v)
+	case 2:
+		x.MinLen = uint64Ptr(v)
+	case 3:
+		x.MaxLen = uint64Ptr(v)
+	case 4:
+		x.Pattern = string(b)
+	case 5:
+		x.Prefix = string(b)
+	case 6:
+		x.Suffix = string(b)
+	case 7:
+		x.Contains = string(b)
+	case 8:
+		x.In = append(x.In, protoreflect.ValueOfBytes(append([]byte(nil), b...)))
+	case 9:
+		x.NotIn = append(x.NotIn, protoreflect.ValueOfBytes(append([]byte(nil), b...)))
+	}
+}
+
+// decodeRepeated merges the given encoded buf.validate.RepeatedRules into the receiver.
+func (x *FieldConstraints) decodeRepeated(b []byte) {
+	rangeWireFields(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+		switch num {
+		case 1:
+			x.MinItems = uint64Ptr(v)
+		case 2:
+			x.MaxItems = uint64Ptr(v)
+		case 3:
+			x.Unique = v != 0
+		case 4:
+			if typ == protowire.BytesType {
+				if x.Items == nil {
+					x.Items = new(FieldConstraints)
+				}
+				x.Items.decode(b)
+			}
+		}
+	})
+}
+
+// decodeMap merges the given encoded buf.validate.MapRules into the receiver.
+func (x *FieldConstraints) decodeMap(b []byte) {
+	rangeWireFields(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+		switch num {
+		case 1:
+			x.MinItems = uint64Ptr(v)
+		case 2:
+			x.MaxItems = uint64Ptr(v)
+		case 4, 5:
+			if typ != protowire.BytesType {
+				return
+			}
+			target := &x.Keys
+			if num == 5 {
+				target = &x.Values
+			}
+			if *target == nil {
+				*target = new(FieldConstraints)
+			}
+			(*target).decode(b)
+		}
+	})
+}
+
+// values decodes the value(s) of a numeric, or enum, rule, of Kind, which may be packed.
+func (x *FieldConstraints) values(typ protowire.Type, v uint64, b []byte) []protoreflect.Value {
+	if typ != protowire.BytesType {
+		return []protoreflect.Value{validateValue(x.Kind, v)}
+	}
+	var values []protoreflect.Value
+	for len(b) != 0 {
+		var n int
+		switch x.Kind {
+		case protoreflect.FloatKind, protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+			var u uint32
+			u, n = protowire.ConsumeFixed32(b)
+			v = uint64(u)
+		case protoreflect.DoubleKind, protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+			v, n = protowire.ConsumeFixed64(b)
+		default:
+			v, n = protowire.ConsumeVarint(b)
+		}
+		if n < 0 {
+			break
+		}
+		values = append(values, validateValue(x.Kind, v))
+		b = b[n:]
+	}
+	if len(values) == 0 {
+		// only possible for malformed options
+		values = append(values, protoreflect.Value{})
+	}
+	return values
+}
+
+// validateValue converts the given raw (wire) value to a value of the given kind.
+func validateValue(kind protoreflect.Kind, v uint64) protoreflect.Value {
+	switch kind {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(v != 0)
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(int32(v)))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(math.Float32frombits(uint32(v)))
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(math.Float64frombits(v))
+	case protoreflect.Int32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(v))
+	case protoreflect.Int64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(int64(v))
+	case protoreflect.Sint32Kind:
+		return protoreflect.ValueOfInt32(int32(protowire.DecodeZigZag(v & math.MaxUint32)))
+	case protoreflect.Sint64Kind:
+		return protoreflect.ValueOfInt64(protowire.DecodeZigZag(v))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(v))
+	default:
+		return protoreflect.ValueOfUint64(v)
+	}
+}
+
+// decodeCELConstraint decodes the given encoded buf.validate.Rule.
+func decodeCELConstraint(b []byte) CELConstraint {
+	var v CELConstraint
+	rangeWireFields(b, func(num protowire.Number, typ protowire.Type, _ uint64, b []byte) {
+		if typ != protowire.BytesType {
+			return
+		}
+		switch num {
+		case 1:
+			v.ID = string(b)
+		case 2:
+			v.Message = string(b)
+		case 3:
+			v.Expression = string(b)
+		}
+	})
+	return v
+}
+
+// rangeWireFields calls f for each field of the given encoded message, in order, with the value, for varint, and
+// fixed fields, or the bytes, for length-delimited fields, stopping at the first malformed field. Groups are
+// skipped.
+func rangeWireFields(b []byte, f func(num protowire.Number, typ protowire.Type, v uint64, b []byte)) {
+	for len(b) != 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+		var (
+			v     uint64
+			bytes []byte
+		)
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var u uint32
+			u, n = protowire.ConsumeFixed32(b)
+			v = uint64(u)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+		f(num, typ, v, bytes)
+	}
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
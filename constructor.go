@@ -0,0 +1,104 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"go/token"
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+)
+
+type (
+	// ConstructorGenerator generates constructor functions for messages, which accept each of the message's required
+	// fields as arguments, i.e. fields using the proto2 required field rule (see also Field.IsRequired), and fields
+	// with the REQUIRED google.api.field_behavior, unless they are also OUTPUT_ONLY (see also Field.Behaviors).
+	//
+	// Required scalar fields with explicit presence are accepted as non-pointer values, and their address is used to
+	// initialise the field.
+	// With the hybrid and opaque API levels, messages are initialised using the builder, see also Cache.APILevel.
+	ConstructorGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to
+		// Namer.BuilderName, see also Cache.Namer.
+		FuncName func(v *protogen.Message) string
+		// Filter may be set to exclude fields, which are never accepted as arguments, see also FieldFilter.
+		Filter *FieldFilter
+	}
+)
+
+var (
+	_ Generator = (*ConstructorGenerator)(nil)
+)
+
+// GenerateFile returns a constructor function for each message in the given file, see also FileMessages.
+func (x *ConstructorGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *ConstructorGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return x.Cache.namer().BuilderName(v)
+}
+
+// Func returns the constructor function for the given message.
+func (x *ConstructorGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name   = x.Name(v)
+		values []string
+	)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s constructs a new %s, initialised with all required fields.`, name, v.Desc.FullName()))
+	for _, field := range x.Filter.Fields(x.Cache.MessageFields(v)) {
+		if !requiredField(field) {
+			continue
+		}
+		var (
+			arg  = argName(field.Fields()[0].Desc.JSONName())
+			name = field.Name()
+		)
+		if x.Cache.APILevel != APIOpen {
+			// the builder field name omits any suffix added to resolve conflicts
+			name = camelCase(field.Fields()[0].Desc.Name(), name)
+		}
+		if field.IsPointer() {
+			f.AddArg(arg, field.BaseType())
+			values = append(values, name+`: &`+arg)
+		} else {
+			f.AddArg(arg, field.Type())
+			values = append(values, name+`: `+arg)
+		}
+	}
+	f.AddResult(``, gopoet.PointerType(x.Cache.MessageType(v.Desc)))
+	if x.Cache.APILevel != APIOpen {
+		// the fields of the message may be unexported, so the builder is used
+		if len(values) == 0 {
+			return f.Printlnf(`return %s{}.Build()`, x.Cache.BuilderType(v.Desc))
+		}
+		return f.Printlnf(`return %s{`, x.Cache.BuilderType(v.Desc)).
+			Println(strings.Join(values, ",\n") + `,`).
+			Println(`}.Build()`)
+	}
+	if len(values) == 0 {
+		return f.Printlnf(`return &%s{}`, x.Cache.MessageType(v.Desc))
+	}
+	return f.Printlnf(`return &%s{`, x.Cache.MessageType(v.Desc)).
+		Println(strings.Join(values, ",\n") + `,`).
+		Println(`}`)
+}
+
+// argName returns a valid golang identifier for an argument or local variable, derived from a lowerCamelCase name.
+func argName(name string) string {
+	name = gopoet.Unexport(name)
+	if token.IsKeyword(name) {
+		name += `_`
+	}
+	return name
+}
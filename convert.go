@@ -0,0 +1,283 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// ConversionGenerator generates functions that convert messages to and from another version of the same message,
+	// e.g. foo.v1.Bar and foo.v2.Bar, mapping fields by number, to assist with API version migrations.
+	//
+	// Fields are only converted if they have compatible types, i.e. the same cardinality, golang type (or any enum
+	// type, converted by number), or message type, where different message types must themselves have a conversion
+	// function generated into the same package. Message values are cloned, and bytes values copied.
+	ConversionGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Target returns the message a given message (of the generated file) will be converted to and from, or nil
+		// if the message should be skipped, and must be set.
+		Target func(v *protogen.Message) *protogen.Message
+		// Map may be used to override the field a given field will be converted to, within the target message, e.g.
+		// for renamed or renumbered fields, and may return nil to skip the field. It is used for both directions,
+		// and defaults to the field with the same number.
+		Map func(field *protogen.Field, target *protogen.Message) *protogen.Field
+		// Filter may be set to exclude fields, which are not converted, in either direction, and is applied to the
+		// fields of both messages, see also FieldFilter.
+		Filter *FieldFilter
+	}
+)
+
+var (
+	_ Generator = (*ConversionGenerator)(nil)
+)
+
+// GenerateFile returns a pair of conversion functions (to and from the target) for each message in the given file,
+// that has a target, see also FileMessages.
+func (x *ConversionGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	if x.Target == nil {
+		panic(fmt.Sprintf("conversion target not set, for %s", file.Desc.Path()))
+	}
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		if target := x.Target(v); target != nil {
+			elements = append(elements, x.ToFunc(v, target), x.FromFunc(v, target))
+		}
+	}
+	return elements
+}
+
+// ToName returns the name of the function converting the given message to the target, which is the golang name of
+// the message, followed by "To", and the (exported) golang package name of the target, e.g. BarToV2.
+func (x *ConversionGenerator) ToName(v *protogen.Message, target *protogen.Message) string {
+	return v.GoIdent.GoName + `To` + x.packageName(target)
+}
+
+// FromName returns the name of the function converting the target to the given message, which is the golang name
+// of the message, followed by "From", and the (exported) golang package name of the target, e.g. BarFromV2.
+func (x *ConversionGenerator) FromName(v *protogen.Message, target *protogen.Message) string {
+	return v.GoIdent.GoName + `From` + x.packageName(target)
+}
+
+// ToFunc returns the function converting the given message to the target.
+func (x *ConversionGenerator) ToFunc(v *protogen.Message, target *protogen.Message) *gopoet.FuncSpec {
+	return x.convertFunc(x.ToName(v, target), v, v, target)
+}
+
+// FromFunc returns the function converting the target to the given message.
+func (x *ConversionGenerator) FromFunc(v *protogen.Message, target *protogen.Message) *gopoet.FuncSpec {
+	return x.convertFunc(x.FromName(v, target), v, target, v)
+}
+
+// convertFunc returns a function converting src to dst, where owner is the message from the generated file.
+func (x *ConversionGenerator) convertFunc(name string, owner, src, dst *protogen.Message) *gopoet.FuncSpec {
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s converts a %s to a %s, returning nil if v is nil.`, name, src.Desc.FullName(), dst.Desc.FullName())).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(src.Desc))).
+		AddResult(``, gopoet.PointerType(x.Cache.MessageType(dst.Desc))).
+		Println(`if v == nil {`).
+		Println(`return nil`).
+		Println(`}`).
+		Printlnf(`r := new(%s)`, x.Cache.MessageType(dst.Desc))
+	for _, field := range x.Filter.Fields(x.Cache.MessageFields(src)) {
+		if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+			var (
+				cases   []OneOfField
+				targets []*protogen.Field
+			)
+			for _, field := range field.OneOfFields() {
+				if target := x.mapField(field.Field, dst); target != nil && x.compatible(owner, field.Field, target) {
+					cases = append(cases, field)
+					targets = append(targets, target)
+				}
+			}
+			if len(cases) == 0 {
+				continue
+			}
+			f.Printlnf(`switch x := v.%s.(type) {`, field.Name())
+			for i, field := range cases {
+				f.Printlnf(`case %s:`, gopoet.PointerType(field.Type))
+				x.assign(f, owner, field.Field, targets[i], `x.`+field.Field.GoName)
+			}
+			f.Println(`}`)
+			continue
+		}
+		if target := x.mapField(field.Fields()[0], dst); target != nil && x.compatible(owner, field.Fields()[0], target) {
+			x.convertField(f, owner, field.Fields()[0], target)
+		}
+	}
+	return f.Println(`return r`)
+}
+
+func (x *ConversionGenerator) mapField(field *protogen.Field, target *protogen.Message) *protogen.Field {
+	if x.Map != nil {
+		if v := x.Map(field, target); v != nil && x.Filter.Allow(v) {
+			return v
+		}
+		return nil
+	}
+	for _, v := range target.Fields {
+		if v.Desc.Number() == field.Desc.Number() && x.Filter.Allow(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (x *ConversionGenerator) convertField(f *gopoet.FuncSpec, owner *protogen.Message, src, dst *protogen.Field) {
+	var (
+		desc = src.Desc
+		expr = `v.` + src.GoName
+	)
+	switch {
+	case desc.IsMap():
+		format, args := x.convertValue(owner, src.Message.Fields[1], dst.Message.Fields[1], `e`)
+		f.Printlnf(`if %s != nil {`, expr)
+		f.Printlnf(`r.%s = make(%s, len(%s))`, dst.GoName, x.Cache.fieldType(dst.Desc), expr)
+		f.Printlnf(`for k, e := range %s {`, expr)
+		f.Printlnf(`r.%s[k] = `+format, append([]interface{}{dst.GoName}, args...)...)
+		f.Println(`}`)
+		f.Println(`}`)
+		return
+
+	case desc.IsList():
+		format, args := x.convertValue(owner, src, dst, `e`)
+		f.Printlnf(`if %s != nil {`, expr)
+		f.Printlnf(`r.%s = make(%s, len(%s))`, dst.GoName, x.Cache.fieldType(dst.Desc), expr)
+		f.Printlnf(`for i, e := range %s {`, expr)
+		f.Printlnf(`r.%s[i] = `+format, append([]interface{}{dst.GoName}, args...)...)
+		f.Println(`}`)
+		f.Println(`}`)
+		return
+
+	case desc.Message() != nil || desc.Kind() == protoreflect.BytesKind:
+		f.Printlnf(`if %s != nil {`, expr)
+
+	case isPointerField(desc):
+		f.Printlnf(`if %s != nil {`, expr)
+		expr = `*` + expr
+
+	default:
+		f.Printlnf(`if %s {`, zeroCheck(desc.Kind(), expr))
+	}
+	x.assign(f, owner, src, dst, expr)
+	f.Println(`}`)
+}
+
+// assign prints statements assigning the (present) singular value of src, expr, to dst, within its own scope.
+func (x *ConversionGenerator) assign(f *gopoet.FuncSpec, owner *protogen.Message, src, dst *protogen.Field, expr string) {
+	format, args := x.convertValue(owner, src, dst, expr)
+	switch {
+	case dst.Oneof != nil && !dst.Oneof.Desc.IsSynthetic():
+		f.Printlnf(`r.%s = &%s{%s: `+format+`}`, append([]interface{}{
+			dst.Oneof.GoName,
+			x.Cache.goPackage(dst.GoIdent.GoImportPath).Symbol(dst.GoIdent.GoName),
+			dst.GoName,
+		}, args...)...)
+	case isPointerField(dst.Desc):
+		f.Printlnf(`t := `+format, args...)
+		f.Printlnf(`r.%s = &t`, dst.GoName)
+	default:
+		f.Printlnf(`r.%s = `+format, append([]interface{}{dst.GoName}, args...)...)
+	}
+}
+
+// convertValue returns a format string and args for an expression converting a single (non-pointer) value of src,
+// expr, to the type of dst.
+func (x *ConversionGenerator) convertValue(owner *protogen.Message, src, dst *protogen.Field, expr string) (string, []interface{}) {
+	switch src.Desc.Kind() {
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if name, _ := x.messageConverter(owner, src.Message, dst.Message); name != `` {
+			return name + `(` + expr + `)`, nil
+		}
+		return `%s(` + expr + `).(%s)`, []interface{}{protoPkg.Symbol(`Clone`), gopoet.PointerType(x.Cache.MessageType(dst.Message.Desc))}
+	case protoreflect.EnumKind:
+		return `%s(` + expr + `)`, []interface{}{x.Cache.enumType(dst.Enum.Desc)}
+	case protoreflect.BytesKind:
+		return `append([]byte(nil), ` + expr + `...)`, nil
+	default:
+		return expr, nil
+	}
+}
+
+// compatible returns true if src may be converted to dst.
+func (x *ConversionGenerator) compatible(owner *protogen.Message, src, dst *protogen.Field) bool {
+	switch {
+	case src.Desc.IsMap() != dst.Desc.IsMap(),
+		src.Desc.IsList() != dst.Desc.IsList():
+		return false
+	case src.Desc.IsMap():
+		return goKind(src.Desc.MapKey().Kind()) == goKind(dst.Desc.MapKey().Kind()) &&
+			x.compatible(owner, src.Message.Fields[1], dst.Message.Fields[1])
+	case goKind(src.Desc.Kind()) != goKind(dst.Desc.Kind()):
+		return false
+	case src.Message != nil:
+		_, ok := x.messageConverter(owner, src.Message, dst.Message)
+		return ok
+	default:
+		return true
+	}
+}
+
+// messageConverter returns the name of the function converting src to dst, or an empty string if they are the
+// same type, and whether they may be converted.
+func (x *ConversionGenerator) messageConverter(owner, src, dst *protogen.Message) (string, bool) {
+	file := owner.Desc.ParentFile().Path()
+	switch {
+	case src.Desc.FullName() == dst.Desc.FullName():
+		return ``, true
+	case src.Desc.ParentFile().Path() == file && x.isTarget(src, dst):
+		return x.ToName(src, dst), true
+	case dst.Desc.ParentFile().Path() == file && x.isTarget(dst, src):
+		return x.FromName(dst, src), true
+	default:
+		return ``, false
+	}
+}
+
+func (x *ConversionGenerator) isTarget(v, target *protogen.Message) bool {
+	t := x.Target(v)
+	return t != nil && t.Desc.FullName() == target.Desc.FullName()
+}
+
+func (x *ConversionGenerator) packageName(target *protogen.Message) string {
+	file := x.Cache.file(target.Desc.ParentFile().Path())
+	if file == nil {
+		panic(fmt.Sprintf("unknown type: %v", target.Desc))
+	}
+	name := string(file.GoPackageName)
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// isPointerField returns true if the golang field for the given (non-oneof) field is a pointer to a scalar.
+func isPointerField(desc protoreflect.FieldDescriptor) bool {
+	return desc.HasPresence() &&
+		!desc.IsList() &&
+		desc.Message() == nil &&
+		desc.Kind() != protoreflect.BytesKind &&
+		(desc.ContainingOneof() == nil || desc.ContainingOneof().IsSynthetic())
+}
+
+// goKind returns a canonical kind for each golang type, e.g. protoreflect.Int32Kind for sint32 and sfixed32.
+func goKind(kind protoreflect.Kind) protoreflect.Kind {
+	switch kind {
+	case protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind:
+		return protoreflect.Int32Kind
+	case protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind:
+		return protoreflect.Int64Kind
+	case protoreflect.Fixed32Kind:
+		return protoreflect.Uint32Kind
+	case protoreflect.Fixed64Kind:
+		return protoreflect.Uint64Kind
+	case protoreflect.GroupKind:
+		return protoreflect.MessageKind
+	default:
+		return kind
+	}
+}
@@ -0,0 +1,16 @@
+package gopoet_protogen_test
+
+import (
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"testing"
+)
+
+func TestConversionGenerator_noTarget(t *testing.T) {
+	result := compileLibrary(t)
+	defer func() {
+		if r := recover(); r != `conversion target not set, for test/v1/library.proto` {
+			t.Errorf("unexpected panic: %v", r)
+		}
+	}()
+	(&gopoet_protogen.ConversionGenerator{Cache: result.Cache}).GenerateFile(result.File(libraryFile))
+}
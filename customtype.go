@@ -0,0 +1,29 @@
+package gopoet_protogen
+
+import (
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// CustomType maps a message type to a user-specified golang type, e.g. my.company.UUID to uuid.UUID, for fields
+	// of that message type, see also Cache.CustomTypes. This is similar to the customtype option of gogoproto,
+	// except that the generated message structs are unaffected, so values must be converted when they are read
+	// from, or written to, the message.
+	CustomType struct {
+		// Type is the golang type used instead of a pointer to the message, e.g. for Field.Type.
+		Type gopoet.TypeName
+		// FromProto returns a golang expression converting expr, a (possibly nil) pointer to the message, to Type.
+		FromProto func(expr string) *gopoet.CodeBlock
+		// ToProto returns a golang expression converting expr, of Type, to a pointer to the message.
+		ToProto func(expr string) *gopoet.CodeBlock
+	}
+)
+
+// CustomType returns the custom type for the given message, or nil if there is none, see also Cache.CustomTypes.
+func (x *Cache) CustomType(v protoreflect.MessageDescriptor) *CustomType {
+	if x == nil || v == nil {
+		return nil
+	}
+	return x.CustomTypes[v.FullName()]
+}
@@ -0,0 +1,236 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// DebugStringGenerator generates functions that format messages as compact, single line, human-readable strings,
+	// e.g. `{name:"a" tags:["b" "c"] inner:{id:1} secret:[REDACTED]}`, for cases where prototext is unsuitable, as
+	// its output is deliberately unstable. Only populated fields are included, in declaration order, and map entries
+	// are sorted by key.
+	//
+	// Fields of message types declared in the same file as the generated message call the function generated for
+	// that type, and must therefore be generated into the same package, other message types fall back to prototext,
+	// in which case the whitespace between fields is (deliberately) unstable, i.e. the output is stable only up to
+	// whitespace, and should be compared accordingly, e.g. using strings.Fields.
+	DebugStringGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "DebugString"
+		// followed by the golang name of the message. The name of the append function is always "Append" followed
+		// by the name of the generated function.
+		FuncName func(v *protogen.Message) string
+		// Redact may be used to override which fields will have their values replaced with "[REDACTED]", which
+		// defaults to fields with the debug_redact option, see also IsDebugRedact.
+		Redact func(field *protogen.Field) bool
+		// Filter may be set to exclude fields, which are omitted, see also FieldFilter.
+		Filter *FieldFilter
+	}
+)
+
+const (
+	debugRedacted = `[REDACTED]`
+)
+
+var (
+	_ Generator = (*DebugStringGenerator)(nil)
+
+	prototextPkg = gopoet.NewPackage("google.golang.org/protobuf/encoding/prototext")
+	sortPkg      = gopoet.NewPackage("sort")
+	strconvPkg   = gopoet.NewPackage("strconv")
+)
+
+// GenerateFile returns a debug string function, and the corresponding append function, for each message in the
+// given file, see also FileMessages.
+func (x *DebugStringGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v), x.AppendFunc(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *DebugStringGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `DebugString` + v.GoIdent.GoName
+}
+
+// AppendName returns the name of the append function generated for the given message.
+func (x *DebugStringGenerator) AppendName(v *protogen.Message) string {
+	return `Append` + x.Name(v)
+}
+
+// Func returns the debug string function for the given message, which accepts a (possibly nil) pointer to the
+// message, and returns the formatted string.
+func (x *DebugStringGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.Name(v)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s formats a %s as a compact, single line, human-readable string.`, name, v.Desc.FullName())).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, gopoet.StringType).
+		Printlnf(`return string(%s(nil, v))`, x.AppendName(v))
+}
+
+// AppendFunc returns the append function for the given message, which accepts a byte slice, and a (possibly nil)
+// pointer to the message, and returns the byte slice with the formatted message appended.
+func (x *DebugStringGenerator) AppendFunc(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.AppendName(v)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s appends a %s to b, formatted like %s.`, name, v.Desc.FullName(), x.Name(v))).
+		AddArg(`b`, bytesType).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, bytesType).
+		Println(`if v == nil {`).
+		Println(`return append(b, "<nil>"...)`).
+		Println(`}`)
+	fields := x.Filter.Fields(x.Cache.MessageFields(v))
+	if len(fields) == 0 {
+		return f.Println(`return append(b, "{}"...)`)
+	}
+	f.Println(`n := len(b)`).
+		Println(`b = append(b, '{')`)
+	for _, field := range fields {
+		if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+			f.Printlnf(`switch x := v.%s.(type) {`, field.Name())
+			for _, field := range field.OneOfFields() {
+				f.Printlnf(`case %s:`, gopoet.PointerType(field.Type))
+				x.appendLabel(f, field.Field)
+				x.appendValue(f, v, field.Field, `x.`+field.Field.GoName)
+			}
+			f.Println(`}`)
+			continue
+		}
+		x.appendField(f, v, field.Fields()[0])
+	}
+	return f.Println(`return append(b, '}')`)
+}
+
+func (x *DebugStringGenerator) redact(field *protogen.Field) bool {
+	if x.Redact != nil {
+		return x.Redact(field)
+	}
+	return IsDebugRedact(field.Desc)
+}
+
+func (x *DebugStringGenerator) appendField(f *gopoet.FuncSpec, parent *protogen.Message, field *protogen.Field) {
+	var (
+		desc = field.Desc
+		expr = `v.` + field.GoName
+	)
+	switch {
+	case desc.IsMap() || desc.IsList():
+		f.Printlnf(`if len(%s) != 0 {`, expr)
+	case desc.Message() != nil || desc.Kind() == protoreflect.BytesKind && desc.HasPresence():
+		f.Printlnf(`if %s != nil {`, expr)
+	case desc.HasPresence():
+		f.Printlnf(`if %s != nil {`, expr)
+		expr = `*` + expr
+	default:
+		f.Printlnf(`if %s {`, zeroCheck(desc.Kind(), expr))
+	}
+	x.appendLabel(f, field)
+	switch {
+	case x.redact(field):
+		f.Printlnf(`b = append(b, %q...)`, debugRedacted)
+	case desc.IsMap():
+		key, value := field.Message.Fields[0], field.Message.Fields[1]
+		f.Printlnf(`keys := make([]%s, 0, len(%s))`, x.Cache.fieldType(key.Desc), expr)
+		f.Printlnf(`for k := range %s {`, expr)
+		f.Println(`keys = append(keys, k)`)
+		f.Println(`}`)
+		if key.Desc.Kind() == protoreflect.BoolKind {
+			f.Printlnf(`%s(keys, func(i, j int) bool { return !keys[i] && keys[j] })`, sortPkg.Symbol(`Slice`))
+		} else {
+			f.Printlnf(`%s(keys, func(i, j int) bool { return keys[i] < keys[j] })`, sortPkg.Symbol(`Slice`))
+		}
+		f.Println(`b = append(b, '{')`)
+		f.Println(`for i, k := range keys {`)
+		f.Println(`if i != 0 {`)
+		f.Println(`b = append(b, ' ')`)
+		f.Println(`}`)
+		x.appendValue(f, parent, key, `k`)
+		f.Println(`b = append(b, ':')`)
+		x.appendValue(f, parent, value, expr+`[k]`)
+		f.Println(`}`)
+		f.Println(`b = append(b, '}')`)
+	case desc.IsList():
+		f.Println(`b = append(b, '[')`)
+		f.Printlnf(`for i, e := range %s {`, expr)
+		f.Println(`if i != 0 {`)
+		f.Println(`b = append(b, ' ')`)
+		f.Println(`}`)
+		x.appendValue(f, parent, field, `e`)
+		f.Println(`}`)
+		f.Println(`b = append(b, ']')`)
+	default:
+		x.appendValue(f, parent, field, expr)
+	}
+	f.Println(`}`)
+}
+
+// appendLabel prints the separator (if necessary) and the name of the field, where n is the length of the buffer
+// prior to the opening brace.
+func (x *DebugStringGenerator) appendLabel(f *gopoet.FuncSpec, field *protogen.Field) {
+	f.Println(`if len(b) != n+1 {`)
+	f.Println(`b = append(b, ' ')`)
+	f.Println(`}`)
+	f.Printlnf(`b = append(b, %q...)`, string(field.Desc.Name())+`:`)
+}
+
+// appendValue prints a statement appending a single value to b, where expr is the golang expression for the value.
+// Oneof fields are redacted here, as they are only ever singular.
+func (x *DebugStringGenerator) appendValue(f *gopoet.FuncSpec, parent *protogen.Message, field *protogen.Field, expr string) {
+	desc := field.Desc
+	if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() && x.redact(field) {
+		f.Printlnf(`b = append(b, %q...)`, debugRedacted)
+		return
+	}
+	switch desc.Kind() {
+	case protoreflect.BoolKind:
+		f.Printlnf(`b = %s(b, %s)`, strconvPkg.Symbol(`AppendBool`), expr)
+	case protoreflect.StringKind:
+		f.Printlnf(`b = %s(b, %s)`, strconvPkg.Symbol(`AppendQuote`), expr)
+	case protoreflect.BytesKind:
+		f.Printlnf(`b = %s(b, string(%s))`, strconvPkg.Symbol(`AppendQuote`), expr)
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind,
+		protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind:
+		f.Printlnf(`b = %s(b, int64(%s), 10)`, strconvPkg.Symbol(`AppendInt`), expr)
+	case protoreflect.Uint32Kind,
+		protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind,
+		protoreflect.Fixed64Kind:
+		f.Printlnf(`b = %s(b, uint64(%s), 10)`, strconvPkg.Symbol(`AppendUint`), expr)
+	case protoreflect.FloatKind:
+		f.Printlnf(`b = %s(b, float64(%s), 'g', -1, 32)`, strconvPkg.Symbol(`AppendFloat`), expr)
+	case protoreflect.DoubleKind:
+		f.Printlnf(`b = %s(b, %s, 'g', -1, 64)`, strconvPkg.Symbol(`AppendFloat`), expr)
+	case protoreflect.EnumKind:
+		if strings.HasPrefix(expr, `*`) {
+			expr = `(` + expr + `)`
+		}
+		f.Printlnf(`b = append(b, %s.String()...)`, expr)
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if field.Message.Desc.ParentFile().Path() == parent.Desc.ParentFile().Path() {
+			f.Printlnf(`b = %s(b, %s)`, x.AppendName(field.Message), expr)
+		} else {
+			f.Println(`b = append(b, '{')`)
+			f.Printlnf(`b = append(b, %s{}.Format(%s)...)`, prototextPkg.Symbol(`MarshalOptions`), expr)
+			f.Println(`b = append(b, '}')`)
+		}
+	default:
+		panic(fmt.Sprintf("unknown type: %v", desc))
+	}
+}
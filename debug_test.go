@@ -0,0 +1,61 @@
+package gopoet_protogen_test
+
+import (
+	"github.com/jhump/gopoet"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"github.com/joeycumines/gopoet-protogen/protogentest"
+	"testing"
+)
+
+func TestDebugStringGenerator_run(t *testing.T) {
+	const shelfFile = `test/v1/shelf.proto`
+	result := protogentest.MustCompile(t, map[string]string{
+		libraryFile: libraryProto,
+		// Book is declared in another file, so it is formatted using prototext
+		shelfFile: `syntax = "proto3";
+
+package test.v1;
+
+import "test/v1/library.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+message Shelf {
+  string name = 1;
+  Book book = 2;
+  repeated Book books = 3;
+}
+`,
+	}, nil)
+	dst := generateFile(result.File(shelfFile), `gen_debug.go`, &gopoet_protogen.DebugStringGenerator{Cache: result.Cache})
+	runGenerated(t, result, []*gopoet.GoFile{dst}, map[string]string{
+		`test/v1/gen_debug_test.go`: `package testv1
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDebugStringShelf(t *testing.T) {
+	v := &Shelf{
+		Name: "a",
+		Book: &Book{
+			Name:      "b  \"c  d\\\"  e\"",
+			Pages:     3,
+			Tags:      []string{"x", "y  z"},
+			Published: &timestamppb.Timestamp{Seconds: 1, Nanos: 2},
+			Nested:    &Nested{Note: "n"},
+		},
+		Books: []*Book{{Title: "t"}, {Pages: 1, Genre: Genre_GENRE_FICTION}},
+	}
+	const expected = ` + "`" + `{name:"a" book:{name:"b  \"c  d\\\"  e\"" pages:3 tags:"x" tags:"y  z" published:{seconds:1 nanos:2} nested:{note:"n"}} books:[{title:"t"} {pages:1 genre:GENRE_FICTION}]}` + "`" + `
+	// Book is formatted using prototext, which (deliberately) varies the whitespace between fields
+	if s := DebugStringShelf(v); strings.Join(strings.Fields(s), " ") != strings.Join(strings.Fields(expected), " ") {
+		t.Errorf("unexpected debug string:\n%s\n%s", s, expected)
+	}
+}
+`,
+	})
+}
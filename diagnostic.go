@@ -0,0 +1,167 @@
+package gopoet_protogen
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"strings"
+	"sync"
+)
+
+type (
+	// Severity is the severity of a Diagnostic.
+	Severity int
+
+	// Diagnostic models a problem with the input of a generator, e.g. an unsupported option, referring to the
+	// proto declaration responsible, such that it may be reported to proto authors, with its source location.
+	Diagnostic struct {
+		// Severity is the severity of the diagnostic, where errors should fail generation.
+		Severity Severity
+		// Descriptor is the declaration the diagnostic refers to, if any, see also Location.
+		Descriptor protoreflect.Descriptor
+		// Message describes the problem.
+		Message string
+	}
+
+	// Diagnostics collects diagnostics reported by generators, which may be reported in bulk, rather than failing
+	// on the first problem, e.g. via Report, see also Cache.Diagnostics. It is safe for concurrent use.
+	//
+	// Reporting an error to a nil Diagnostics panics, while warnings are discarded, such that generators may
+	// report problems unconditionally, while still failing fast, if no collector is configured.
+	Diagnostics struct {
+		mu     sync.Mutex
+		values []Diagnostic
+	}
+)
+
+const (
+	// SeverityWarning indicates a problem that doesn't prevent generation, e.g. an ignored option.
+	SeverityWarning Severity = iota
+	// SeverityError indicates a problem that prevents generation, or causes the output to be incorrect.
+	SeverityError
+)
+
+var (
+	severityNames = [...]string{
+		`SeverityWarning`,
+		`SeverityError`,
+	}
+)
+
+// String returns the name of the constant, e.g. SeverityWarning.
+func (x Severity) String() string {
+	if x >= 0 && int(x) < len(severityNames) {
+		return severityNames[x]
+	}
+	return fmt.Sprintf(`Severity(%d)`, int(x))
+}
+
+// Location returns the location of the descriptor, if any, see also DescriptorLocation.
+func (x Diagnostic) Location() SourceLocation {
+	if x.Descriptor == nil {
+		return SourceLocation{}
+	}
+	return DescriptorLocation(x.Descriptor)
+}
+
+// String formats the diagnostic consistently with protoc, e.g. "foo/v1/bar.proto:12:3: message", where warnings
+// are prefixed with "warning: ", and the location is omitted, if there is no descriptor.
+func (x Diagnostic) String() string {
+	var b strings.Builder
+	if location := x.Location(); location.Path != `` {
+		b.WriteString(location.String())
+		b.WriteString(`: `)
+	}
+	if x.Severity == SeverityWarning {
+		b.WriteString(`warning: `)
+	}
+	b.WriteString(x.Message)
+	return b.String()
+}
+
+// Add appends the given diagnostic, which panics, for errors, if the receiver is nil, see also Diagnostics.
+func (x *Diagnostics) Add(v Diagnostic) {
+	if x == nil {
+		if v.Severity == SeverityWarning {
+			return
+		}
+		panic(v.String())
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.values = append(x.values, v)
+}
+
+// Warnf appends a warning for the given descriptor, which may be nil, see also Add.
+func (x *Diagnostics) Warnf(desc protoreflect.Descriptor, format string, args ...interface{}) {
+	x.Add(Diagnostic{Severity: SeverityWarning, Descriptor: desc, Message: fmt.Sprintf(format, args...)})
+}
+
+// Errorf appends an error for the given descriptor, which may be nil, see also Add.
+func (x *Diagnostics) Errorf(desc protoreflect.Descriptor, format string, args ...interface{}) {
+	x.Add(Diagnostic{Severity: SeverityError, Descriptor: desc, Message: fmt.Sprintf(format, args...)})
+}
+
+// Recover appends an error for the given descriptor, if the caller is panicking, and must be called directly by a
+// deferred statement, e.g. `defer diagnostics.Recover(file.Desc)`, to report panics from generators (which may
+// not use Diagnostics) against the file being generated. Panics aren't recovered if the receiver is nil.
+func (x *Diagnostics) Recover(desc protoreflect.Descriptor) {
+	if x == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		x.Errorf(desc, "%v", r)
+	}
+}
+
+// Values returns the diagnostics, in the order they were added.
+func (x *Diagnostics) Values() []Diagnostic {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return append([]Diagnostic(nil), x.values...)
+}
+
+// HasErrors returns true if any diagnostic is an error.
+func (x *Diagnostics) HasErrors() bool {
+	for _, v := range x.Values() {
+		if v.Severity != SeverityWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an error listing the errors, one per line, see also Diagnostic.String, or nil, if there are none.
+func (x *Diagnostics) Err() error {
+	var lines []string
+	for _, v := range x.Values() {
+		if v.Severity != SeverityWarning {
+			lines = append(lines, v.String())
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// Report writes each diagnostic to w (typically os.Stderr, which protoc relays), one per line, and sets the error
+// of the given plugin (which may be nil) to Err, if there are any errors, such that protoc fails, listing the
+// location of each. The error is also returned.
+func (x *Diagnostics) Report(plugin *protogen.Plugin, w io.Writer) error {
+	if w != nil {
+		for _, v := range x.Values() {
+			_, _ = fmt.Fprintln(w, v.String())
+		}
+	}
+	err := x.Err()
+	if err != nil && plugin != nil {
+		plugin.Error(err)
+	}
+	return err
+}
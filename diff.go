@@ -0,0 +1,179 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// DiffGenerator generates functions that compare two messages, field by field, returning the paths of the
+	// fields that differ, e.g. for audit logging, or optimistic concurrency, without reflection.
+	//
+	// Paths use proto field names, and are in declaration order, with nested paths for singular fields of message
+	// types declared in the same file as the generated message, e.g. "inner.name", which call the function generated
+	// for that type, and must therefore be generated into the same package. Other message fields, and repeated and
+	// map fields, are compared as a whole, using proto.Equal for messages. A nil message is equivalent to an empty
+	// message, and fields with explicit presence differ if they are present in only one message. Unknown fields, and
+	// extensions, are ignored.
+	DiffGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "Diff" followed by
+		// the golang name of the message.
+		FuncName func(v *protogen.Message) string
+		// FieldMask may be set to also generate a function returning the paths as a google.protobuf.FieldMask, named
+		// the same as the diff function, followed by "FieldMask".
+		FieldMask bool
+		// Filter may be set to exclude fields, which are not compared, see also FieldFilter.
+		Filter *FieldFilter
+	}
+)
+
+var (
+	_ Generator = (*DiffGenerator)(nil)
+
+	fieldmaskpbPkg = gopoet.NewPackage("google.golang.org/protobuf/types/known/fieldmaskpb")
+	bytesPkg       = gopoet.NewPackage("bytes")
+)
+
+// GenerateFile returns a diff function, and optionally a field mask function, for each message in the given file,
+// see also FileMessages.
+func (x *DiffGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v))
+		if x.FieldMask {
+			elements = append(elements, x.FieldMaskFunc(v))
+		}
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *DiffGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `Diff` + v.GoIdent.GoName
+}
+
+// Func returns the diff function for the given message, which accepts two (possibly nil) pointers to the message,
+// and returns the paths of the fields that differ, or nil, if there are none.
+func (x *DiffGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name        = x.Name(v)
+		messageType = x.Cache.MessageType(v.Desc)
+	)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the paths of the fields that differ between two %s messages.`, name, v.Desc.FullName())).
+		AddArg(`a`, gopoet.PointerType(messageType)).
+		AddArg(`b`, gopoet.PointerType(messageType)).
+		AddResult(``, gopoet.SliceType(gopoet.StringType)).
+		Println(`if a == nil {`).
+		Printlnf(`a = new(%s)`, messageType).
+		Println(`}`).
+		Println(`if b == nil {`).
+		Printlnf(`b = new(%s)`, messageType).
+		Println(`}`).
+		Println(`var paths []string`)
+	for _, field := range x.Filter.Fields(x.Cache.FlatFields(v)) {
+		x.diffField(f, v, field)
+	}
+	return f.Println(`return paths`)
+}
+
+// FieldMaskFunc returns the function returning the paths of the diff function, for the given message, as a field
+// mask.
+func (x *DiffGenerator) FieldMaskFunc(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name        = x.Name(v) + `FieldMask`
+		messageType = gopoet.PointerType(x.Cache.MessageType(v.Desc))
+		maskType    = fieldmaskpbPkg.Symbol(`FieldMask`)
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a field mask of the fields that differ between two %s messages, see also %s.`, name, v.Desc.FullName(), x.Name(v))).
+		AddArg(`a`, messageType).
+		AddArg(`b`, messageType).
+		AddResult(``, gopoet.PointerType(gopoet.NamedType(maskType))).
+		Printlnf(`return &%s{Paths: %s(a, b)}`, maskType, x.Name(v))
+}
+
+func (x *DiffGenerator) diffField(f *gopoet.FuncSpec, parent *protogen.Message, field Field) {
+	var (
+		desc   = field.Fields()[0].Desc
+		path   = string(desc.Name())
+		getter = field.Getter().Name
+		a      = `a.` + getter + `()`
+		b      = `b.` + getter + `()`
+	)
+	switch {
+	case desc.IsMap():
+		f.Println(`if func() bool {`).
+			Printlnf(`x, y := %s, %s`, a, b).
+			Println(`if len(x) != len(y) {`).
+			Println(`return true`).
+			Println(`}`).
+			Println(`for k, v := range x {`).
+			Print(`if w, ok := y[k]; !ok || `).
+			AddCode(x.notEqual(desc.MapValue(), `v`, `w`)).
+			Println(` {`).
+			Println(`return true`).
+			Println(`}`).
+			Println(`}`).
+			Println(`return false`).
+			Println(`}() {`)
+	case desc.IsList():
+		f.Println(`if func() bool {`).
+			Printlnf(`x, y := %s, %s`, a, b).
+			Println(`if len(x) != len(y) {`).
+			Println(`return true`).
+			Println(`}`).
+			Println(`for i := range x {`).
+			Print(`if `).
+			AddCode(x.notEqual(desc, `x[i]`, `y[i]`)).
+			Println(` {`).
+			Println(`return true`).
+			Println(`}`).
+			Println(`}`).
+			Println(`return false`).
+			Println(`}() {`)
+	case desc.Message() != nil && field.OneOf() == nil &&
+		field.Fields()[0].Message.Desc.ParentFile().Path() == parent.Desc.ParentFile().Path():
+		f.Printlnf(`if (%s == nil) != (%s == nil) {`, a, b).
+			Printlnf(`paths = append(paths, %q)`, path).
+			// both nil must not recurse, as nil is replaced by an empty message, which would never terminate
+			Printlnf(`} else if %s != nil {`, a).
+			Printlnf(`for _, path := range %s(%s, %s) {`, x.Name(field.Fields()[0].Message), a, b).
+			Printlnf(`paths = append(paths, %q+path)`, path+`.`).
+			Println(`}`).
+			Println(`}`)
+		return
+	case desc.HasPresence() && desc.Message() == nil:
+		f.Print(`if (`).
+			AddCode(field.HasExpr(`a`)).
+			Print(`) != (`).
+			AddCode(field.HasExpr(`b`)).
+			Print(`) || `).
+			AddCode(x.notEqual(desc, a, b)).
+			Println(` {`)
+	default:
+		f.Print(`if `).
+			AddCode(x.notEqual(desc, a, b)).
+			Println(` {`)
+	}
+	f.Printlnf(`paths = append(paths, %q)`, path).
+		Println(`}`)
+}
+
+// notEqual returns a golang expression that is true if the given (non-repeated) values of the given field differ.
+func (x *DiffGenerator) notEqual(desc protoreflect.FieldDescriptor, a, b string) *gopoet.CodeBlock {
+	switch {
+	case desc.Message() != nil:
+		return gopoet.Printf(`!%s(%s, %s)`, protoPkg.Symbol(`Equal`), a, b)
+	case desc.Kind() == protoreflect.BytesKind:
+		return gopoet.Printf(`!%s(%s, %s)`, bytesPkg.Symbol(`Equal`), a, b)
+	}
+	return gopoet.Print(a + ` != ` + b)
+}
@@ -0,0 +1,52 @@
+package gopoet_protogen_test
+
+import (
+	"github.com/jhump/gopoet"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"testing"
+)
+
+func TestDiffGenerator_run(t *testing.T) {
+	result := compileLibrary(t)
+	dst := generateFile(result.File(libraryFile), `gen_diff.go`, &gopoet_protogen.DiffGenerator{
+		Cache:     result.Cache,
+		FieldMask: true,
+	})
+	runGenerated(t, result, []*gopoet.GoFile{dst}, map[string]string{
+		`test/v1/gen_diff_test.go`: `package testv1
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDiffBook(t *testing.T) {
+	for _, tc := range [...]struct {
+		name  string
+		a, b  *Book
+		paths []string
+	}{
+		{name: "nil", a: nil, b: nil},
+		{name: "empty", a: nil, b: &Book{}},
+		{name: "recursive equal", a: &Book{Nested: &Nested{Deep: &Deep{Book: &Book{Title: "a"}}}}, b: &Book{Nested: &Nested{Deep: &Deep{Book: &Book{Title: "a"}}}}},
+		{name: "recursive", a: &Book{Nested: &Nested{Deep: &Deep{Book: &Book{Title: "a"}}}}, b: &Book{Nested: &Nested{Deep: &Deep{Book: &Book{Title: "b"}}}}, paths: []string{"nested.deep.book.title"}},
+		{name: "presence", a: &Book{Nested: &Nested{}}, b: &Book{}, paths: []string{"nested"}},
+		{name: "children", a: &Book{Nested: &Nested{Deep: &Deep{Children: []*Nested{{Note: "a"}}}}}, b: &Book{Nested: &Nested{Deep: &Deep{}}}, paths: []string{"nested.deep.children"}},
+		{name: "fields", a: &Book{Name: "a", Tags: []string{"a"}, Labels: map[string]string{"a": "b"}, Published: timestamppb.New(timestamppb.Now().AsTime())}, b: &Book{Name: "b"}, paths: []string{"name", "tags", "labels", "published"}},
+		{name: "optional", a: &Book{Isbn: new(string)}, b: &Book{}, paths: []string{"isbn"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if paths := DiffBook(tc.a, tc.b); !reflect.DeepEqual(paths, tc.paths) {
+				t.Errorf("unexpected paths: %q", paths)
+			}
+			if mask := DiffBookFieldMask(tc.a, tc.b); !reflect.DeepEqual(mask.GetPaths(), tc.paths) {
+				t.Errorf("unexpected mask: %v", mask)
+			}
+		})
+	}
+}
+`,
+	})
+}
@@ -0,0 +1,105 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// Encoding models the wire encoding of a protobuf field value, which cannot be determined using the golang type
+	// alone, e.g. int32, sint32 and sfixed32 are all int32 values, but use varint, zigzag and fixed encoding.
+	Encoding int
+)
+
+const (
+	// EncodingInvalid indicates an unknown encoding.
+	EncodingInvalid Encoding = iota
+	// EncodingVarint is used by bool, enum, int32, int64, uint32 and uint64 values.
+	EncodingVarint
+	// EncodingZigZag is used by sint32 and sint64 values, which are zigzag encoded prior to varint encoding.
+	EncodingZigZag
+	// EncodingFixed32 is used by fixed32, sfixed32 and float values.
+	EncodingFixed32
+	// EncodingFixed64 is used by fixed64, sfixed64 and double values.
+	EncodingFixed64
+	// EncodingBytes is used by string, bytes, message and map values, which are length-delimited.
+	EncodingBytes
+	// EncodingGroup is used by group values, which are delimited by start and end tags.
+	EncodingGroup
+)
+
+// KindEncoding returns the wire encoding of the given kind.
+func KindEncoding(kind protoreflect.Kind) Encoding {
+	switch kind {
+	case protoreflect.BoolKind,
+		protoreflect.EnumKind,
+		protoreflect.Int32Kind,
+		protoreflect.Int64Kind,
+		protoreflect.Uint32Kind,
+		protoreflect.Uint64Kind:
+		return EncodingVarint
+	case protoreflect.Sint32Kind,
+		protoreflect.Sint64Kind:
+		return EncodingZigZag
+	case protoreflect.Fixed32Kind,
+		protoreflect.Sfixed32Kind,
+		protoreflect.FloatKind:
+		return EncodingFixed32
+	case protoreflect.Fixed64Kind,
+		protoreflect.Sfixed64Kind,
+		protoreflect.DoubleKind:
+		return EncodingFixed64
+	case protoreflect.StringKind,
+		protoreflect.BytesKind,
+		protoreflect.MessageKind:
+		return EncodingBytes
+	case protoreflect.GroupKind:
+		return EncodingGroup
+	default:
+		return EncodingInvalid
+	}
+}
+
+// IsZigZag returns true for EncodingZigZag.
+func (x Encoding) IsZigZag() bool { return x == EncodingZigZag }
+
+// IsFixed returns true for EncodingFixed32 and EncodingFixed64.
+func (x Encoding) IsFixed() bool { return x == EncodingFixed32 || x == EncodingFixed64 }
+
+// WireType returns the protowire.Type for (non-packed) values using this encoding, or -1 if invalid.
+func (x Encoding) WireType() protowire.Type {
+	switch x {
+	case EncodingVarint, EncodingZigZag:
+		return protowire.VarintType
+	case EncodingFixed32:
+		return protowire.Fixed32Type
+	case EncodingFixed64:
+		return protowire.Fixed64Type
+	case EncodingBytes:
+		return protowire.BytesType
+	case EncodingGroup:
+		return protowire.StartGroupType
+	default:
+		return -1
+	}
+}
+
+// String returns the name of the encoding.
+func (x Encoding) String() string {
+	switch x {
+	case EncodingVarint:
+		return `varint`
+	case EncodingZigZag:
+		return `zigzag`
+	case EncodingFixed32:
+		return `fixed32`
+	case EncodingFixed64:
+		return `fixed64`
+	case EncodingBytes:
+		return `bytes`
+	case EncodingGroup:
+		return `group`
+	default:
+		return `invalid`
+	}
+}
@@ -0,0 +1,162 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type (
+	// EnumAliasMode determines how enum-based generators handle aliases, i.e. values with the same number as
+	// another value, which are only possible if allow_alias is set, see also EnumAliasMode.Values. Regardless of
+	// the mode, switch statements have a single case per number, as duplicate cases will not compile.
+	EnumAliasMode int
+)
+
+const (
+	// EnumAliasSkip omits aliases, i.e. each number is represented by the first declared value, see also
+	// EnumValues. This is the default.
+	EnumAliasSkip EnumAliasMode = iota
+	// EnumAliasCanonical represents each number by its canonical value, which is the first declared value that
+	// isn't deprecated, or the first declared value, if all are deprecated, see also CanonicalEnumValue.
+	EnumAliasCanonical
+	// EnumAliasAll includes every value, e.g. in lists of names, other than in switch statements, which use the
+	// canonical value of each number, see also EnumAliasCanonical.
+	EnumAliasAll
+)
+
+var (
+	enumAliasModeNames = [...]string{
+		`EnumAliasSkip`,
+		`EnumAliasCanonical`,
+		`EnumAliasAll`,
+	}
+)
+
+// FileEnums returns all the enums declared in the given file, including those nested within messages, ordered
+// depth-first by declaration, see also FileMessages.
+func FileEnums(file *protogen.File) []*protogen.Enum {
+	enums := append([]*protogen.Enum(nil), file.Enums...)
+	for _, v := range FileMessages(file) {
+		enums = append(enums, v.Enums...)
+	}
+	return enums
+}
+
+// EnumValues returns the distinct values of the given enum, in declaration order, excluding aliases, i.e. values
+// with the same number as a previously declared value, which are only possible if allow_alias is set.
+func EnumValues(v *protogen.Enum) []*protogen.EnumValue {
+	var (
+		values = make([]*protogen.EnumValue, 0, len(v.Values))
+		seen   = make(map[protoreflect.EnumNumber]struct{}, len(v.Values))
+	)
+	for _, v := range v.Values {
+		if _, ok := seen[v.Desc.Number()]; ok {
+			continue
+		}
+		seen[v.Desc.Number()] = struct{}{}
+		values = append(values, v)
+	}
+	return values
+}
+
+// EnumAllowsAlias returns true if the given enum has the allow_alias option set.
+func EnumAllowsAlias(v *protogen.Enum) bool {
+	options, _ := v.Desc.Options().(*descriptorpb.EnumOptions)
+	return options.GetAllowAlias()
+}
+
+// IsEnumAlias returns true if the given value has the same number as a previously declared value of its enum.
+func IsEnumAlias(v *protogen.EnumValue) bool {
+	return v.Desc.Index() != v.Desc.Parent().(protoreflect.EnumDescriptor).Values().ByNumber(v.Desc.Number()).Index()
+}
+
+// EnumAliases returns the other values of the enum of the given value, with the same number, in declaration order,
+// or nil, if there are none.
+func EnumAliases(v *protogen.EnumValue) []*protogen.EnumValue {
+	var aliases []*protogen.EnumValue
+	for _, value := range v.Parent.Values {
+		if value != v && value.Desc.Number() == v.Desc.Number() {
+			aliases = append(aliases, value)
+		}
+	}
+	return aliases
+}
+
+// CanonicalEnumValue returns the canonical value with the same number as the given value, i.e. the first declared
+// value that isn't deprecated, or the first declared value, if all are deprecated.
+func CanonicalEnumValue(v *protogen.EnumValue) *protogen.EnumValue {
+	var first *protogen.EnumValue
+	for _, value := range v.Parent.Values {
+		if value.Desc.Number() != v.Desc.Number() {
+			continue
+		}
+		if options, _ := value.Desc.Options().(*descriptorpb.EnumValueOptions); !options.GetDeprecated() {
+			return value
+		}
+		if first == nil {
+			first = value
+		}
+	}
+	return first
+}
+
+// Values returns the values of the given enum, in declaration order (of the first value with each number), as
+// determined by the mode.
+func (x EnumAliasMode) Values(v *protogen.Enum) []*protogen.EnumValue {
+	switch x {
+	case EnumAliasCanonical:
+		values := EnumValues(v)
+		for i, value := range values {
+			values[i] = CanonicalEnumValue(value)
+		}
+		return values
+	case EnumAliasAll:
+		return append([]*protogen.EnumValue(nil), v.Values...)
+	default:
+		return EnumValues(v)
+	}
+}
+
+// SwitchValues returns the values of the given enum, with a single value per number, as used by switch statements,
+// i.e. Values, except for EnumAliasAll, which uses the canonical value of each number.
+func (x EnumAliasMode) SwitchValues(v *protogen.Enum) []*protogen.EnumValue {
+	if x == EnumAliasAll {
+		x = EnumAliasCanonical
+	}
+	return x.Values(v)
+}
+
+// String returns the name of the constant, e.g. EnumAliasSkip.
+func (x EnumAliasMode) String() string {
+	if x >= 0 && int(x) < len(enumAliasModeNames) {
+		return enumAliasModeNames[x]
+	}
+	return fmt.Sprintf(`EnumAliasMode(%d)`, int(x))
+}
+
+// EnumSwitch returns an exhaustive switch statement over expr, a golang expression of the given enum type, with a
+// case for each distinct value (see also EnumValues), where body returns the code for each case, and def is the
+// (optional) code for the default case, see also EnumAliasSwitch.
+func (x *Cache) EnumSwitch(v *protogen.Enum, expr string, body func(value *protogen.EnumValue) *gopoet.CodeBlock, def *gopoet.CodeBlock) *gopoet.CodeBlock {
+	return x.EnumAliasSwitch(v, EnumAliasSkip, expr, body, def)
+}
+
+// EnumAliasSwitch is like EnumSwitch, with a case for each value returned by EnumAliasMode.SwitchValues, for the
+// given mode, i.e. a single case per number, as duplicate cases will not compile.
+func (x *Cache) EnumAliasSwitch(v *protogen.Enum, mode EnumAliasMode, expr string, body func(value *protogen.EnumValue) *gopoet.CodeBlock, def *gopoet.CodeBlock) *gopoet.CodeBlock {
+	cb := gopoet.Printlnf(`switch %s {`, expr)
+	for _, value := range mode.SwitchValues(v) {
+		cb.Printlnf(`case %s:`, x.EnumValueConst(value.Desc))
+		if code := body(value); code != nil {
+			cb.AddCode(code)
+		}
+	}
+	if def != nil {
+		cb.Println(`default:`)
+		cb.AddCode(def)
+	}
+	return cb.Println(`}`)
+}
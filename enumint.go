@@ -0,0 +1,82 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// EnumIntGenerator generates functions that convert enums to and from int32, e.g. for storage layers, that
+	// persist enums by number.
+	//
+	// For each enum, three functions are generated, named using the golang name of the enum, followed by "ToInt32",
+	// "FromInt32", and "FromInt32Checked", where only the latter rejects unknown numbers, using the name map
+	// generated by protoc-gen-go.
+	EnumIntGenerator struct {
+		// Cache is used to resolve enum types, and must contain all referenced files.
+		Cache *Cache
+		// AllowUnspecified may be set to convert unknown numbers to the default value of the enum (i.e. the first
+		// value, e.g. FOO_UNSPECIFIED), rather than returning an error, from the checked conversion.
+		AllowUnspecified bool
+	}
+)
+
+var (
+	_ Generator = (*EnumIntGenerator)(nil)
+)
+
+// GenerateFile returns the conversion functions for each enum in the given file, see also FileEnums.
+func (x *EnumIntGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileEnums(file) {
+		elements = append(elements, x.ToFunc(v), x.FromFunc(v), x.CheckedFunc(v))
+	}
+	return elements
+}
+
+// ToFunc returns the function converting the given enum to int32.
+func (x *EnumIntGenerator) ToFunc(v *protogen.Enum) *gopoet.FuncSpec {
+	name := v.GoIdent.GoName + `ToInt32`
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the number of a %s value.`, name, v.Desc.FullName())).
+		AddArg(`v`, x.Cache.enumType(v.Desc)).
+		AddResult(``, int32Type).
+		Println(`return int32(v)`)
+}
+
+// FromFunc returns the function converting int32 to the given enum, which accepts any number, including unknown
+// numbers, consistent with the proto3 semantics of open enums.
+func (x *EnumIntGenerator) FromFunc(v *protogen.Enum) *gopoet.FuncSpec {
+	name := v.GoIdent.GoName + `FromInt32`
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the %s value with the given number, which may be unknown.`, name, v.Desc.FullName())).
+		AddArg(`n`, int32Type).
+		AddResult(``, x.Cache.enumType(v.Desc)).
+		Printlnf(`return %s(n)`, x.Cache.enumType(v.Desc))
+}
+
+// CheckedFunc returns the function converting int32 to the given enum, which returns an error if the number is
+// unknown, unless AllowUnspecified is set.
+func (x *EnumIntGenerator) CheckedFunc(v *protogen.Enum) *gopoet.FuncSpec {
+	var (
+		name     = v.GoIdent.GoName + `FromInt32Checked`
+		enumType = x.Cache.enumType(v.Desc)
+		doc      = fmt.Sprintf(`%s returns the %s value with the given number, or an error if it is unknown.`, name, v.Desc.FullName())
+	)
+	if x.AllowUnspecified {
+		doc = fmt.Sprintf(`%s returns the %s value with the given number, or %s, if it is unknown.`, name, v.Desc.FullName(), v.Values[0].Desc.Name())
+	}
+	f := gopoet.NewFunc(name).
+		SetComment(doc).
+		AddArg(`n`, int32Type).
+		AddResult(``, enumType).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`if _, ok := %s[n]; ok {`, x.Cache.enumMap(v, `_name`)).
+		Printlnf(`return %s(n), nil`, enumType).
+		Println(`}`)
+	if x.AllowUnspecified {
+		return f.Printlnf(`return %s, nil`, x.Cache.EnumValueConst(v.Values[0].Desc))
+	}
+	return f.Printlnf(`return 0, %s(%q, n)`, fmtPkg.Symbol(`Errorf`), fmt.Sprintf(`invalid %s: %%d`, v.Desc.FullName()))
+}
@@ -0,0 +1,121 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// EnumStringGenerator generates functions that convert enums from and to their value names, using the name and
+	// value maps generated by protoc-gen-go, e.g. for parsing CLI flags or REST query parameters.
+	EnumStringGenerator struct {
+		// Cache is used to resolve enum types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated parse function, which defaults to the golang
+		// name of the enum, followed by "FromString".
+		FuncName func(v *protogen.Enum) string
+		// CaseInsensitive may be set to fall back to matching value names case-insensitively, in which case it is
+		// unspecified which value is returned, if multiple names match.
+		CaseInsensitive bool
+		// JSON may be set to also accept enum numbers, formatted as decimal strings, consistent with protojson.
+		JSON bool
+		// Methods may be set to generate a StringName method on each enum type, rather than a function, which
+		// requires that the generated code is in the same package as the enum.
+		Methods bool
+	}
+)
+
+var (
+	_ Generator = (*EnumStringGenerator)(nil)
+)
+
+// GenerateFile returns a parse function, and a StringName function or method, for each enum in the given file, see
+// also FileEnums.
+func (x *EnumStringGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileEnums(file) {
+		elements = append(elements, x.Func(v), x.StringNameFunc(v))
+	}
+	return elements
+}
+
+// Name returns the name of the parse function generated for the given enum.
+func (x *EnumStringGenerator) Name(v *protogen.Enum) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return v.GoIdent.GoName + `FromString`
+}
+
+// StringName returns the name of the StringName function generated for the given enum, which is always
+// "StringName" if Methods is set.
+func (x *EnumStringGenerator) StringName(v *protogen.Enum) string {
+	if x.Methods {
+		return `StringName`
+	}
+	return v.GoIdent.GoName + `StringName`
+}
+
+// Func returns the parse function for the given enum, which accepts a string, and returns the corresponding value,
+// or an error if there is no such value.
+func (x *EnumStringGenerator) Func(v *protogen.Enum) *gopoet.FuncSpec {
+	var (
+		name     = x.Name(v)
+		enumType = x.Cache.enumType(v.Desc)
+		valueMap = x.Cache.enumMap(v, `_value`)
+		doc      = fmt.Sprintf(`%s returns the %s with the given value name`, name, v.Desc.FullName())
+	)
+	if x.CaseInsensitive {
+		doc += ` (case-insensitive)`
+	}
+	if x.JSON {
+		doc += `, or number`
+	}
+	f := gopoet.NewFunc(name).
+		SetComment(doc+`.`).
+		AddArg(`s`, gopoet.StringType).
+		AddResult(``, enumType).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`if v, ok := %s[s]; ok {`, valueMap).
+		Printlnf(`return %s(v), nil`, enumType).
+		Println(`}`)
+	if x.CaseInsensitive {
+		f.Printlnf(`for k, v := range %s {`, valueMap).
+			Printlnf(`if %s(k, s) {`, stringsPkg.Symbol(`EqualFold`)).
+			Printlnf(`return %s(v), nil`, enumType).
+			Println(`}`).
+			Println(`}`)
+	}
+	if x.JSON {
+		f.Printlnf(`if v, err := %s(s, 10, 32); err == nil {`, strconvPkg.Symbol(`ParseInt`)).
+			Printlnf(`return %s(v), nil`, enumType).
+			Println(`}`)
+	}
+	return f.Printlnf(`return 0, %s(%q, s)`, fmtPkg.Symbol(`Errorf`), fmt.Sprintf(`invalid %s: %%q`, v.Desc.FullName()))
+}
+
+// StringNameFunc returns the StringName function or method for the given enum, which returns the name of a given
+// value, or an empty string if it is unknown, unlike the String method, which returns the number.
+func (x *EnumStringGenerator) StringNameFunc(v *protogen.Enum) *gopoet.FuncSpec {
+	var (
+		name     = x.StringName(v)
+		enumType = x.Cache.enumType(v.Desc)
+		f        *gopoet.FuncSpec
+	)
+	if x.Methods {
+		f = gopoet.NewMethod(gopoet.NewReceiverForType(`x`, enumType), name).
+			SetComment(fmt.Sprintf(`%s returns the name of the %s value, or an empty string if it is unknown.`, name, v.Desc.FullName()))
+	} else {
+		f = gopoet.NewFunc(name).
+			SetComment(fmt.Sprintf(`%s returns the name of a %s value, or an empty string if it is unknown.`, name, v.Desc.FullName())).
+			AddArg(`x`, enumType)
+	}
+	return f.AddResult(``, gopoet.StringType).
+		Printlnf(`return %s[int32(x)]`, x.Cache.enumMap(v, `_name`))
+}
+
+// enumMap returns the symbol for the name or value map generated by protoc-gen-go for the given enum.
+func (x *Cache) enumMap(v *protogen.Enum, suffix string) gopoet.Symbol {
+	return x.goPackage(v.GoIdent.GoImportPath).Symbol(v.GoIdent.GoName + suffix)
+}
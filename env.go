@@ -0,0 +1,100 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// EnvGenerator generates functions loading messages from environment variables, mapping each singular scalar
+	// and enum field to a variable, e.g. for services configured by a proto message.
+	//
+	// Each function is named "Load", followed by the golang name of the message, followed by "FromEnv", and accepts
+	// a prefix for the variable names, returning an error if any variable is invalid. Enum variables accept value
+	// names, and fields are only set if the variable is present, such that fields with explicit presence (e.g. proto3
+	// optional fields) remain unset otherwise. Messages without any variables are skipped.
+	EnvGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Name may be used to override the variable name (excluding the prefix) of each field, and takes precedence
+		// over Option, where an empty string excludes the field.
+		Name func(field *protogen.Field) string
+		// Option may be used to configure the field number of a custom string field option (extension of
+		// google.protobuf.FieldOptions) specifying the variable name of each field. If both Name and Option are unset,
+		// or the option is not set for a field, the variable name defaults to the upper-case proto field name.
+		Option protoreflect.FieldNumber
+	}
+)
+
+var (
+	_ Generator = (*EnvGenerator)(nil)
+
+	osPkg = gopoet.NewPackage("os")
+)
+
+// GenerateFile returns the load function, for each message in the given file, that has any variables, see also
+// FileMessages.
+func (x *EnvGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		if len(x.Fields(v)) != 0 {
+			elements = append(elements, x.Func(v))
+		}
+	}
+	return elements
+}
+
+// VarName returns the variable name (excluding the prefix) of the given field, or an empty string if it is not
+// mapped to a variable.
+func (x *EnvGenerator) VarName(field *protogen.Field) string {
+	if !isScalarField(field) {
+		return ``
+	}
+	if x.Name != nil {
+		return x.Name(field)
+	}
+	if x.Option != 0 {
+		if values := OptionBytes(field.Desc, x.Option); len(values) != 0 {
+			return string(values[len(values)-1])
+		}
+	}
+	return strings.ToUpper(string(field.Desc.Name()))
+}
+
+// Fields returns the fields of the given message that are mapped to variables, in declaration order.
+func (x *EnvGenerator) Fields(v *protogen.Message) []*protogen.Field {
+	var fields []*protogen.Field
+	for _, field := range v.Fields {
+		if x.VarName(field) != `` {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Func returns the load function for the given message, which accepts the prefix of the variable names, and
+// returns the loaded message, or an error, identifying the variable, if any are invalid.
+func (x *EnvGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	name := `Load` + v.GoIdent.GoName + `FromEnv`
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a %s, loaded from the environment variables with the given prefix, or an error if any are invalid.`, name, v.Desc.FullName())).
+		AddArg(`prefix`, gopoet.StringType).
+		AddResult(``, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`v := new(%s)`, x.Cache.MessageType(v.Desc))
+	for _, field := range x.Fields(v) {
+		key := x.VarName(field)
+		f.Printlnf(`if s, ok := %s(prefix + %q); ok {`, osPkg.Symbol(`LookupEnv`), key).
+			Println(`if err := func() error {`).
+			AddCode(x.Cache.parseScalar(field, `v.`+field.GoName, `s`, `return `)).
+			Println(`return nil`).
+			Println(`}(); err != nil {`).
+			Printlnf(`return nil, %s("invalid %%s: %%w", prefix+%q, err)`, fmtPkg.Symbol(`Errorf`), key).
+			Println(`}`).
+			Println(`}`)
+	}
+	return f.Println(`return v, nil`)
+}
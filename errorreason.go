@@ -0,0 +1,243 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// ErrorReasonGenerator generates error types for error reason enums, standardizing the AIP-193 error pattern,
+	// where each error has a gRPC status code, and a google.rpc.ErrorInfo detail, with a reason (the enum value name),
+	// and a domain.
+	//
+	// For an enum named "ErrorReason", an error type named "ErrorReasonError" is generated, implementing error, and
+	// GRPCStatus (for status.FromError), along with an error variable for each value (excluding zero), e.g.
+	// "ErrErrorReason_NOT_FOUND", that matches any error with that reason, via errors.Is, and a function named
+	// "ErrorReasonErrorFromError", that extracts the error, including from the details of a status error, e.g. on
+	// the client side. The generated code depends on google.golang.org/grpc, and google.golang.org/genproto.
+	ErrorReasonGenerator struct {
+		// Cache is used to resolve enum types, and must contain all referenced files.
+		Cache *Cache
+		// Option may be used to configure the field number of a custom enum option (extension of
+		// google.protobuf.EnumOptions, of type bool) marking error reason enums, in addition to enums with names
+		// ending in "ErrorReason".
+		Option protoreflect.FieldNumber
+		// CodeOption may be used to configure the field number of a custom enum value option (extension of
+		// google.protobuf.EnumValueOptions) specifying the gRPC status code of each value, which may be an integer
+		// (e.g. google.rpc.Code), or a string, naming the code, e.g. "NOT_FOUND" or "NotFound". The code defaults to
+		// codes.Unknown.
+		CodeOption protoreflect.FieldNumber
+		// Domain may be used to override the domain of the ErrorInfo of each enum, which defaults to the proto package
+		// of the enum.
+		Domain func(v *protogen.Enum) string
+	}
+)
+
+var (
+	_ Generator = (*ErrorReasonGenerator)(nil)
+
+	codesPkg      = gopoet.NewPackage("google.golang.org/grpc/codes")
+	statusPkg     = gopoet.NewPackage("google.golang.org/grpc/status")
+	errdetailsPkg = gopoet.NewPackage("google.golang.org/genproto/googleapis/rpc/errdetails")
+
+	// codeNames are the names of the codes.Code constants, indexed by number.
+	codeNames = [...]string{
+		`OK`,
+		`Canceled`,
+		`Unknown`,
+		`InvalidArgument`,
+		`DeadlineExceeded`,
+		`NotFound`,
+		`AlreadyExists`,
+		`PermissionDenied`,
+		`ResourceExhausted`,
+		`FailedPrecondition`,
+		`Aborted`,
+		`OutOfRange`,
+		`Unimplemented`,
+		`Internal`,
+		`Unavailable`,
+		`DataLoss`,
+		`Unauthenticated`,
+	}
+)
+
+// GenerateFile returns the error type, methods, variables, and extraction function, for each error reason enum in
+// the given file, see also FileEnums.
+func (x *ErrorReasonGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileEnums(file) {
+		if x.IsErrorReason(v) {
+			elements = append(elements, x.Type(v)...)
+			if vars := x.Vars(v); vars != nil {
+				elements = append(elements, vars)
+			}
+			elements = append(elements, x.FromErrorFunc(v))
+		}
+	}
+	return elements
+}
+
+// IsErrorReason returns true if the given enum has a name ending in "ErrorReason", or has the option configured by
+// Option set to true.
+func (x *ErrorReasonGenerator) IsErrorReason(v *protogen.Enum) bool {
+	if strings.HasSuffix(string(v.Desc.Name()), `ErrorReason`) {
+		return true
+	}
+	if x.Option == 0 {
+		return false
+	}
+	value, ok := OptionVarint(v.Desc, x.Option)
+	return ok && value != 0
+}
+
+// Name returns the name of the error type generated for the given enum.
+func (x *ErrorReasonGenerator) Name(v *protogen.Enum) string {
+	return v.GoIdent.GoName + `Error`
+}
+
+// DomainFor returns the ErrorInfo domain of the given enum.
+func (x *ErrorReasonGenerator) DomainFor(v *protogen.Enum) string {
+	if x.Domain != nil {
+		return x.Domain(v)
+	}
+	return string(v.Desc.ParentFile().Package())
+}
+
+// Code returns the name of the codes.Code constant for the given value, decoded from the option configured by
+// CodeOption, or "Unknown", if it is not set, or is invalid.
+func (x *ErrorReasonGenerator) Code(v *protogen.EnumValue) string {
+	if x.CodeOption == 0 {
+		return `Unknown`
+	}
+	if values := OptionBytes(v.Desc, x.CodeOption); len(values) != 0 {
+		value := strings.ReplaceAll(strings.ToLower(string(values[len(values)-1])), `_`, ``)
+		for _, name := range codeNames {
+			if strings.ToLower(name) == value || value == `cancelled` && name == `Canceled` {
+				return name
+			}
+		}
+	} else if value, ok := OptionVarint(v.Desc, x.CodeOption); ok && value < uint64(len(codeNames)) {
+		return codeNames[value]
+	}
+	return `Unknown`
+}
+
+// Type returns the error type, and its methods, for the given enum.
+func (x *ErrorReasonGenerator) Type(v *protogen.Enum) []gopoet.FileElement {
+	var (
+		name      = x.Name(v)
+		receiver  = gopoet.NewPointerReceiverForType(`x`, localType(name))
+		codeType  = gopoet.NamedType(codesPkg.Symbol(`Code`))
+		errorInfo = errdetailsPkg.Symbol(`ErrorInfo`)
+		code      = gopoet.NewMethod(receiver, `Code`).
+				SetComment(`Code returns the gRPC status code of the reason.`).
+				AddResult(``, codeType)
+		order []string
+		cases = make(map[string][]interface{})
+	)
+	for _, value := range EnumValues(v) {
+		if c := x.Code(value); c != `Unknown` {
+			if _, ok := cases[c]; !ok {
+				order = append(order, c)
+			}
+			cases[c] = append(cases[c], x.Cache.EnumValueConst(value.Desc))
+		}
+	}
+	if order != nil {
+		code.Println(`switch x.Reason {`)
+	}
+	for _, c := range order {
+		code.Printlnf(`case `+strings.TrimSuffix(strings.Repeat(`%s, `, len(cases[c])), `, `)+`:`, cases[c]...).
+			Printlnf(`return %s`, codesPkg.Symbol(c))
+	}
+	if order != nil {
+		code.Println(`}`)
+	}
+	code.Printlnf(`return %s`, codesPkg.Symbol(`Unknown`))
+	return []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+			gopoet.NewField(`Reason`, x.Cache.enumType(v.Desc)),
+			gopoet.NewField(`Message`, gopoet.StringType),
+			gopoet.NewField(`Metadata`, gopoet.MapType(gopoet.StringType, gopoet.StringType)),
+		).SetComment(fmt.Sprintf(`%s is an error with a %s reason, an optional message, and optional metadata, for the ErrorInfo.`, name, v.Desc.FullName()))),
+		gopoet.NewMethod(receiver, `Error`).
+			SetComment(`Error returns the reason, followed by the message, if any.`).
+			AddResult(``, gopoet.StringType).
+			Println(`if x.Message != "" {`).
+			Println(`return x.Reason.String() + ": " + x.Message`).
+			Println(`}`).
+			Println(`return x.Reason.String()`),
+		code,
+		gopoet.NewMethod(receiver, `ErrorInfo`).
+			SetComment(fmt.Sprintf(`ErrorInfo returns the ErrorInfo detail of the error, with the domain %q.`, x.DomainFor(v))).
+			AddResult(``, gopoet.PointerType(gopoet.NamedType(errorInfo))).
+			Printlnf(`return &%s{Reason: x.Reason.String(), Domain: %q, Metadata: x.Metadata}`, errorInfo, x.DomainFor(v)),
+		gopoet.NewMethod(receiver, `GRPCStatus`).
+			SetComment(`GRPCStatus returns the status of the error, with the ErrorInfo attached, see also status.FromError.`).
+			AddResult(``, gopoet.PointerType(gopoet.NamedType(statusPkg.Symbol(`Status`)))).
+			Printlnf(`s := %s(x.Code(), x.Error())`, statusPkg.Symbol(`New`)).
+			Println(`if d, err := s.WithDetails(x.ErrorInfo()); err == nil {`).
+			Println(`return d`).
+			Println(`}`).
+			Println(`return s`),
+		gopoet.NewMethod(receiver, `Is`).
+			SetComment(`Is returns true if the target has the same reason, e.g. for errors.Is.`).
+			AddArg(`target`, gopoet.ErrorType).
+			AddResult(``, gopoet.BoolType).
+			Printlnf(`t, ok := target.(%s)`, gopoet.PointerType(localType(name))).
+			Println(`return ok && t.Reason == x.Reason`),
+	}
+}
+
+// Vars returns the error variables for the given enum, one for each value, excluding zero, and aliases, or nil, if
+// there are none.
+func (x *ErrorReasonGenerator) Vars(v *protogen.Enum) *gopoet.VarDecl {
+	var vars []*gopoet.VarSpec
+	for _, value := range EnumValues(v) {
+		if value.Desc.Number() == 0 {
+			continue
+		}
+		name := `Err` + value.GoIdent.GoName
+		vars = append(vars, gopoet.NewVar(name).
+			SetComment(fmt.Sprintf(`%s is a %s error, matching any error with that reason.`, name, value.Desc.Name())).
+			SetInitializer(gopoet.Printf(`error(&%s{Reason: %s})`, localType(x.Name(v)), x.Cache.EnumValueConst(value.Desc))))
+	}
+	if vars == nil {
+		return nil
+	}
+	return gopoet.NewVarDecl(vars...)
+}
+
+// FromErrorFunc returns the function extracting the error type of the given enum from an error, which may wrap it,
+// or be a status error (e.g. returned by a gRPC client), with an ErrorInfo of the same domain, and a known reason.
+func (x *ErrorReasonGenerator) FromErrorFunc(v *protogen.Enum) *gopoet.FuncSpec {
+	var (
+		name      = x.Name(v) + `FromError`
+		errorType = gopoet.PointerType(localType(x.Name(v)))
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the %s of the given error, if any, including from the details of a status error.`, name, x.Name(v))).
+		AddArg(`err`, gopoet.ErrorType).
+		AddResult(``, errorType).
+		AddResult(``, gopoet.BoolType).
+		Printlnf(`var e %s`, errorType).
+		Printlnf(`if %s(err, &e) {`, errorsPkg.Symbol(`As`)).
+		Println(`return e, true`).
+		Println(`}`).
+		Printlnf(`s, ok := %s(err)`, statusPkg.Symbol(`FromError`)).
+		Println(`if !ok {`).
+		Println(`return nil, false`).
+		Println(`}`).
+		Println(`for _, d := range s.Details() {`).
+		Printlnf(`if d, ok := d.(%s); ok && d.GetDomain() == %q {`, gopoet.PointerType(gopoet.NamedType(errdetailsPkg.Symbol(`ErrorInfo`))), x.DomainFor(v)).
+		Printlnf(`if reason, ok := %s[d.GetReason()]; ok {`, x.Cache.enumMap(v, `_value`)).
+		Printlnf(`return &%s{Reason: %s(reason), Message: %s(%s(s.Message(), d.GetReason()), ": "), Metadata: d.GetMetadata()}, true`, localType(x.Name(v)), x.Cache.enumType(v.Desc), stringsPkg.Symbol(`TrimPrefix`), stringsPkg.Symbol(`TrimPrefix`)).
+		Println(`}`).
+		Println(`}`).
+		Println(`}`).
+		Println(`return nil, false`)
+}
@@ -0,0 +1,171 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// EventGenerator generates, for each message annotated as an event, topic and subject name constants, and typed
+	// wrappers for publishing and consuming the message, marshalled using the proto wire format, e.g. for use with
+	// Kafka, and a schema registry.
+	//
+	// The generated publish function accepts any publisher with a method like
+	// `Publish(ctx context.Context, topic string, key, value []byte) error`, and the generated consume function adapts
+	// a typed handler to a func(ctx context.Context, value []byte) error.
+	EventGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Topic may be used to override the topic name of each message, and takes precedence over Option, where an
+		// empty string indicates the message is not an event.
+		Topic func(v *protogen.Message) string
+		// Option may be used to configure the field number of a custom message option (extension of
+		// google.protobuf.MessageOptions), annotating events. If the option is a string, it specifies the topic name,
+		// otherwise (e.g. a bool), it must be non-zero, and the topic name defaults to the full name of the message.
+		Option protoreflect.FieldNumber
+		// SubjectNameStrategy configures the subject name constant, and defaults to TopicNameStrategy.
+		SubjectNameStrategy SubjectNameStrategy
+	}
+
+	// SubjectNameStrategy models the naming strategies used by schema registries (e.g. Confluent's) to derive the
+	// subject name, under which the schema of an event is registered, see also EventGenerator.
+	SubjectNameStrategy int
+)
+
+const (
+	// TopicNameStrategy derives the subject name from the topic name, e.g. "orders-value".
+	TopicNameStrategy SubjectNameStrategy = iota
+	// RecordNameStrategy derives the subject name from the full name of the message, e.g. "pkg.OrderCreated".
+	RecordNameStrategy
+	// TopicRecordNameStrategy derives the subject name from both the topic name and the full name of the message,
+	// e.g. "orders-pkg.OrderCreated".
+	TopicRecordNameStrategy
+)
+
+var (
+	_ Generator = (*EventGenerator)(nil)
+
+	contextPkg = gopoet.NewPackage("context")
+)
+
+// Subject returns the subject name for the given topic and message.
+func (x SubjectNameStrategy) Subject(topic string, desc protoreflect.MessageDescriptor) string {
+	switch x {
+	case TopicNameStrategy:
+		return topic + `-value`
+	case RecordNameStrategy:
+		return string(desc.FullName())
+	case TopicRecordNameStrategy:
+		return topic + `-` + string(desc.FullName())
+	default:
+		panic(fmt.Sprintf("unknown type: %v", x))
+	}
+}
+
+// GenerateFile returns the constants, publish, and consume functions, for each event in the given file, see also
+// FileMessages.
+func (x *EventGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		if x.TopicFor(v) != `` {
+			elements = append(elements, x.Consts(v), x.PublishFunc(v), x.ConsumeFunc(v))
+		}
+	}
+	return elements
+}
+
+// TopicFor returns the topic name of the given message, or an empty string if it is not an event.
+func (x *EventGenerator) TopicFor(v *protogen.Message) string {
+	if v.Desc.IsMapEntry() {
+		return ``
+	}
+	if x.Topic != nil {
+		return x.Topic(v)
+	}
+	if x.Option != 0 {
+		if values := OptionBytes(v.Desc, x.Option); len(values) != 0 {
+			return string(values[len(values)-1])
+		}
+		if value, _ := OptionVarint(v.Desc, x.Option); value != 0 {
+			return string(v.Desc.FullName())
+		}
+	}
+	return ``
+}
+
+// SubjectFor returns the subject name of the given message, see also SubjectNameStrategy.
+func (x *EventGenerator) SubjectFor(v *protogen.Message) string {
+	return x.SubjectNameStrategy.Subject(x.TopicFor(v), v.Desc)
+}
+
+// Consts returns the topic and subject name constants for the given message, named using the golang name of the
+// message, followed by "Topic" and "Subject", respectively.
+func (x *EventGenerator) Consts(v *protogen.Message) *gopoet.ConstDecl {
+	topic, subject := v.GoIdent.GoName+`Topic`, v.GoIdent.GoName+`Subject`
+	return gopoet.NewConstDecl(
+		gopoet.NewConst(topic).
+			SetComment(fmt.Sprintf(`%s is the topic name for %s events.`, topic, v.Desc.FullName())).
+			Initialize(`%q`, x.TopicFor(v)),
+		gopoet.NewConst(subject).
+			SetComment(fmt.Sprintf(`%s is the schema registry subject name for %s events.`, subject, v.Desc.FullName())).
+			Initialize(`%q`, x.SubjectFor(v)),
+	)
+}
+
+// PublishFunc returns a function that marshals the given message, then publishes it to its topic.
+func (x *EventGenerator) PublishFunc(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name        = `Publish` + v.GoIdent.GoName
+		contextType = gopoet.NamedType(contextPkg.Symbol(`Context`))
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s marshals a %s, then publishes it to %sTopic.`, name, v.Desc.FullName(), v.GoIdent.GoName)).
+		AddArg(`ctx`, contextType).
+		AddArg(`publisher`, gopoet.InterfaceType(nil, gopoet.MethodType{Name: `Publish`, Signature: gopoet.Signature{
+			Args: []gopoet.ArgType{
+				{Name: `ctx`, Type: contextType},
+				{Name: `topic`, Type: gopoet.StringType},
+				{Name: `key`, Type: bytesType},
+				{Name: `value`, Type: bytesType},
+			},
+			Results: []gopoet.ArgType{{Type: gopoet.ErrorType}},
+		}})).
+		AddArg(`key`, bytesType).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`value, err := %s(v)`, protoPkg.Symbol(`Marshal`)).
+		Println(`if err != nil {`).
+		Println(`return err`).
+		Println(`}`).
+		Printlnf(`return publisher.Publish(ctx, %sTopic, key, value)`, v.GoIdent.GoName)
+}
+
+// ConsumeFunc returns a function that adapts a handler of the given message to a handler of marshalled values, as
+// consumed from its topic.
+func (x *EventGenerator) ConsumeFunc(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name        = `Consume` + v.GoIdent.GoName
+		contextType = gopoet.NamedType(contextPkg.Symbol(`Context`))
+		messageType = x.Cache.MessageType(v.Desc)
+		errorResult = []gopoet.ArgType{{Type: gopoet.ErrorType}}
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a handler that unmarshals each value consumed from %sTopic as a %s, then calls the given handler.`, name, v.GoIdent.GoName, v.Desc.FullName())).
+		AddArg(`handler`, gopoet.FuncType(
+			[]gopoet.ArgType{{Name: `ctx`, Type: contextType}, {Name: `v`, Type: gopoet.PointerType(messageType)}},
+			errorResult,
+		)).
+		AddResult(``, gopoet.FuncType(
+			[]gopoet.ArgType{{Name: `ctx`, Type: contextType}, {Name: `value`, Type: bytesType}},
+			errorResult,
+		)).
+		Printlnf(`return func(ctx %s, value %s) error {`, contextType, bytesType).
+		Printlnf(`var v %s`, messageType).
+		Printlnf(`if err := %s(value, &v); err != nil {`, protoPkg.Symbol(`Unmarshal`)).
+		Printlnf(`return %s("invalid %s event: %%w", err)`, fmtPkg.Symbol(`Errorf`), v.Desc.FullName()).
+		Println(`}`).
+		Println(`return handler(ctx, &v)`).
+		Println(`}`)
+}
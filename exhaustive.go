@@ -0,0 +1,115 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+)
+
+type (
+	// EnumExhaustiveGenerator generates helpers that guarantee downstream code handles every value of each enum, which
+	// break (at compile time, or when tests run) when values are added, and the code is regenerated.
+	//
+	// For each enum, two functions are generated, named using the golang name of the enum, followed by "Switch", which
+	// accepts a value, and one function argument per distinct value (see also EnumValues), such that adding a value
+	// changes its signature, and "AssertExhaustive", which panics if it isn't passed every distinct value, e.g. to be
+	// called from a test, listing the values handled by a hand-written switch. The generated switch statements are
+	// annotated with "//exhaustive:enforce", for the exhaustive linter, see also Switch.
+	EnumExhaustiveGenerator struct {
+		// Cache is used to resolve enum types, and must contain all referenced files.
+		Cache *Cache
+		// Aliases determines which value represents each number, for enums with aliases, i.e. the value used to name
+		// the argument of SwitchFunc, and the case of Switch, which defaults to the first declared value.
+		Aliases EnumAliasMode
+	}
+)
+
+var (
+	_ Generator = (*EnumExhaustiveGenerator)(nil)
+)
+
+// GenerateFile returns the switch and assertion functions for each enum in the given file, see also FileEnums.
+func (x *EnumExhaustiveGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileEnums(file) {
+		elements = append(elements, x.SwitchFunc(v), x.AssertFunc(v))
+	}
+	return elements
+}
+
+// Switch returns a switch statement like Cache.EnumAliasSwitch, annotated for the exhaustive linter, which reports it
+// if a case is missing, even if it has a default case (with the explicit-exhaustive-switch option).
+func (x *EnumExhaustiveGenerator) Switch(v *protogen.Enum, expr string, body func(value *protogen.EnumValue) *gopoet.CodeBlock, def *gopoet.CodeBlock) *gopoet.CodeBlock {
+	return gopoet.Println(`//exhaustive:enforce`).
+		AddCode(x.Cache.EnumAliasSwitch(v, x.Aliases, expr, body, def))
+}
+
+// SwitchFunc returns the function calling the argument corresponding to the value of the given enum, which calls
+// none of them, if the value is unknown. Arguments are named "on", followed by the value name, in camel case, e.g.
+// "onFooBar" for "FOO_BAR".
+func (x *EnumExhaustiveGenerator) SwitchFunc(v *protogen.Enum) *gopoet.FuncSpec {
+	var (
+		name = v.GoIdent.GoName + `Switch`
+		args = make(map[string]string)
+		f    = gopoet.NewFunc(name).
+			SetComment(fmt.Sprintf(`%s calls the function corresponding to the %s value, or none, if it is unknown. Each value must be handled, as adding a value changes the signature.`, name, v.Desc.FullName())).
+			AddArg(`v`, x.Cache.enumType(v.Desc))
+	)
+	for _, value := range x.Aliases.SwitchValues(v) {
+		arg := `on` + enumValueCamelCase(string(value.Desc.Name()))
+		args[value.GoIdent.GoName] = arg
+		f.AddArg(arg, gopoet.FuncType(nil, nil))
+	}
+	return f.AddCode(x.Switch(v, `v`, func(value *protogen.EnumValue) *gopoet.CodeBlock {
+		return gopoet.Printlnf(`%s()`, args[value.GoIdent.GoName])
+	}, nil))
+}
+
+// AssertFunc returns the function panicking if it isn't passed every distinct value of the given enum, i.e. the
+// values handled by some switch statement, reporting the names of the missing values.
+func (x *EnumExhaustiveGenerator) AssertFunc(v *protogen.Enum) *gopoet.FuncSpec {
+	var (
+		name     = v.GoIdent.GoName + `AssertExhaustive`
+		enumType = x.Cache.enumType(v.Desc)
+		values   = gopoet.Printf(`[]%s{`, enumType)
+	)
+	for i, value := range x.Aliases.SwitchValues(v) {
+		if i != 0 {
+			values.Print(`, `)
+		}
+		values.Printf(`%s`, x.Cache.EnumValueConst(value.Desc))
+	}
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s panics if the handled values don't include every %s value, e.g. to check a switch statement from a test.`, name, v.Desc.FullName())).
+		AddArg(`handled`, gopoet.SliceType(enumType)).
+		SetVariadic(true).
+		Printlnf(`seen := make(map[%s]bool, len(handled))`, enumType).
+		Println(`for _, v := range handled {`).
+		Println(`seen[v] = true`).
+		Println(`}`).
+		Println(`var missing []string`).
+		Print(`for _, v := range `).
+		AddCode(values).
+		Println(`} {`).
+		Println(`if !seen[v] {`).
+		Println(`missing = append(missing, v.String())`).
+		Println(`}`).
+		Println(`}`).
+		Println(`if len(missing) != 0 {`).
+		Printlnf(`panic(%q + %s(missing, ", "))`, fmt.Sprintf(`unhandled %s values: `, v.Desc.FullName()), stringsPkg.Symbol(`Join`)).
+		Println(`}`)
+}
+
+// enumValueCamelCase converts an enum value name, e.g. "FOO_BAR", to camel case, e.g. "FooBar".
+func enumValueCamelCase(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, `_`) {
+		if part == `` {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(strings.ToLower(part[1:]))
+	}
+	return b.String()
+}
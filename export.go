@@ -0,0 +1,82 @@
+package gopoet_protogen
+
+import (
+	"encoding/gob"
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"sort"
+)
+
+type (
+	// cacheTable is the serialized form of a Cache, see also Cache.Export. Slices are used rather than maps, sorted
+	// by key, as gob encodes maps in iteration order, which would make the output nondeterministic.
+	cacheTable struct {
+		Types    []cacheEntry
+		Values   []cacheEntry
+		Packages []cachePackage
+	}
+
+	cacheEntry struct {
+		FullName protoreflect.FullName
+		Ident    protogen.GoIdent
+	}
+
+	cachePackage struct {
+		ImportPath protogen.GoImportPath
+		Name       protogen.GoPackageName
+	}
+)
+
+// Export writes the table of golang identifiers, for every message, enum, and enum value loaded into the cache,
+// encoded using encoding/gob, to the given writer, see also Import. Import paths overridden by ManagedMode are
+// exported as such.
+func (x *Cache) Export(w io.Writer) error {
+	x.once.Do(x.init)
+	x.loadPending()
+	var table cacheTable
+	for _, k := range sortedKeys(x.data) {
+		if _, ok := x.types[k]; ok {
+			table.Types = append(table.Types, cacheEntry{FullName: k, Ident: x.goIdent(x.data[k])})
+		} else {
+			table.Values = append(table.Values, cacheEntry{FullName: k, Ident: x.goIdent(x.data[k])})
+		}
+	}
+	for k, v := range x.packages {
+		table.Packages = append(table.Packages, cachePackage{ImportPath: k, Name: v})
+	}
+	sort.Slice(table.Packages, func(i, j int) bool { return table.Packages[i].ImportPath < table.Packages[j].ImportPath })
+	return gob.NewEncoder(w).Encode(&table)
+}
+
+// Import reads a table written by Export, from the given reader, adding any types that haven't been loaded into the
+// cache, enabling type resolution across plugin invocations, e.g. where files are generated by separate protoc runs.
+// Imported types are resolvable by descriptor, e.g. via MessageType and EnumValueConst, but aren't associated with
+// any protogen models, e.g. for Message. Like AddFile, it will panic if the cache is frozen.
+func (x *Cache) Import(r io.Reader) error {
+	x.once.Do(x.init)
+	if x.frozen {
+		panic(`cache is frozen`)
+	}
+	var table cacheTable
+	if err := gob.NewDecoder(r).Decode(&table); err != nil {
+		return fmt.Errorf("invalid cache table: %w", err)
+	}
+	for _, v := range table.Packages {
+		if _, ok := x.packages[v.ImportPath]; !ok {
+			x.packages[v.ImportPath] = v.Name
+		}
+	}
+	for _, v := range table.Types {
+		if _, ok := x.data[v.FullName]; !ok {
+			x.addType(v.FullName, v.Ident)
+		}
+	}
+	for _, v := range table.Values {
+		if _, ok := x.data[v.FullName]; !ok {
+			x.data[v.FullName] = v.Ident
+		}
+	}
+	return nil
+}
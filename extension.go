@@ -0,0 +1,84 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// ExtensionRegistryGenerator generates a function that registers every extension, declared by the files loaded
+	// into the cache, into a protoregistry.Types, for servers that need dynamic extension resolution.
+	ExtensionRegistryGenerator struct {
+		// Cache provides the files to register the extensions of, see also Cache.Files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to
+		// "RegisterExtensions".
+		FuncName string
+	}
+)
+
+var (
+	protoregistryPkg = gopoet.NewPackage("google.golang.org/protobuf/reflect/protoregistry")
+	protoreflectPkg  = gopoet.NewPackage("google.golang.org/protobuf/reflect/protoreflect")
+)
+
+// FileExtensions returns all the extensions declared in the given file, including those nested within messages,
+// ordered depth-first by declaration.
+func FileExtensions(file *protogen.File) []*protogen.Extension {
+	extensions := append([]*protogen.Extension(nil), file.Extensions...)
+	for _, v := range FileMessages(file) {
+		extensions = append(extensions, v.Extensions...)
+	}
+	return extensions
+}
+
+// ExtensionVar returns the symbol of the ExtensionInfo var generated by protoc-gen-go for the given extension.
+func ExtensionVar(v *protogen.Extension) gopoet.Symbol {
+	return gopoet.NewPackage(string(v.GoIdent.GoImportPath)).Symbol(`E_` + v.GoIdent.GoName)
+}
+
+// Name returns the name of the generated function.
+func (x *ExtensionRegistryGenerator) Name() string {
+	if x.FuncName != `` {
+		return x.FuncName
+	}
+	return `RegisterExtensions`
+}
+
+// Func returns the registration function, which accepts a *protoregistry.Types, and returns the first error
+// encountered while registering the extensions, if any.
+func (x *ExtensionRegistryGenerator) Func() *gopoet.FuncSpec {
+	name := x.Name()
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s registers all known extensions into the given registry.`, name)).
+		AddArg(`types`, gopoet.PointerType(gopoet.NamedType(protoregistryPkg.Symbol(`Types`)))).
+		AddResult(``, gopoet.ErrorType)
+	var extensions []*protogen.Extension
+	for _, file := range x.Cache.Files() {
+		extensions = append(extensions, FileExtensions(file)...)
+	}
+	if len(extensions) == 0 {
+		return f.Println(`return nil`)
+	}
+	f.Printlnf(`for _, ext := range []%s{`, protoreflectPkg.Symbol(`ExtensionType`))
+	for _, v := range extensions {
+		f.Printlnf(`%s,`, ExtensionVar(v))
+	}
+	return f.Println(`} {`).
+		Println(`if err := types.RegisterExtension(ext); err != nil {`).
+		Println(`return err`).
+		Println(`}`).
+		Println(`}`).
+		Println(`return nil`)
+}
+
+// FileDescriptorVar returns the symbol of the protoreflect.FileDescriptor var generated by protoc-gen-go for the
+// file with the given path, e.g. File_foo_v1_bar_proto, which must be loaded into the cache, otherwise it will panic.
+func (x *Cache) FileDescriptorVar(path string) gopoet.Symbol {
+	file := x.file(path)
+	if file == nil {
+		panic(fmt.Sprintf("unknown file: %s", path))
+	}
+	return x.goPackage(file.GoDescriptorIdent.GoImportPath).Symbol(file.GoDescriptorIdent.GoName)
+}
@@ -0,0 +1,169 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+)
+
+type (
+	// ServiceFakeGenerator generates, for each service, a fake implementation of the server interface generated by
+	// protoc-gen-go-grpc, with a function field per method, as well as a fake implementation of the stream interface,
+	// for each streaming method, e.g. for testing clients and interceptors, without depending on mockgen.
+	//
+	// The fake server is named "Fake", followed by the name of the server interface, e.g. "FakeServiceServer", and
+	// embeds the unimplemented server, which handles methods without a function. Each fake stream is named "Fake",
+	// followed by the name of the stream interface, e.g. "FakeService_MethodServer", and records sent messages,
+	// and returns received messages from a slice, followed by io.EOF.
+	ServiceFakeGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+	}
+)
+
+var (
+	_ Generator = (*ServiceFakeGenerator)(nil)
+
+	grpcPkg = gopoet.NewPackage("google.golang.org/grpc")
+	ioPkg   = gopoet.NewPackage("io")
+	syncPkg = gopoet.NewPackage("sync")
+)
+
+// GenerateFile returns the fake server, and fake streams, for each service in the given file.
+func (x *ServiceFakeGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range file.Services {
+		elements = append(elements, x.Server(v)...)
+		for _, method := range v.Methods {
+			if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+				elements = append(elements, x.Stream(method)...)
+			}
+		}
+	}
+	return elements
+}
+
+// Server returns the fake server struct, and its methods, for the given service.
+func (x *ServiceFakeGenerator) Server(v *protogen.Service) []gopoet.FileElement {
+	var (
+		server        = x.symbol(v, v.GoName+`Server`)
+		unimplemented = x.symbol(v, `Unimplemented`+v.GoName+`Server`)
+		name          = `Fake` + server.Name
+		fields        = []*gopoet.FieldSpec{gopoet.NewField(``, gopoet.NamedType(unimplemented))}
+		methods       []gopoet.FileElement
+	)
+	for _, method := range v.Methods {
+		var (
+			signature = x.signature(method)
+			field     = method.GoName + `Func`
+			args      []string
+		)
+		fields = append(fields, gopoet.NewField(field, gopoet.FuncTypeFromSig(signature)).
+			SetComment(fmt.Sprintf(`%s implements %s, if set.`, field, method.GoName)))
+		f := gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, localType(name)), method.GoName).
+			SetComment(fmt.Sprintf(`%s calls %s, or the unimplemented server, if it is nil.`, method.GoName, field))
+		f.Signature = *signature
+		for _, arg := range signature.Args {
+			args = append(args, arg.Name)
+		}
+		f.Printlnf(`if x.%s == nil {`, field).
+			Printlnf(`return x.%s.%s(%s)`, unimplemented.Name, method.GoName, strings.Join(args, `, `)).
+			Println(`}`).
+			Printlnf(`return x.%s(%s)`, field, strings.Join(args, `, `))
+		methods = append(methods, f)
+	}
+	return append([]gopoet.FileElement{gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name, fields...).
+		SetComment(fmt.Sprintf(`%s is a fake implementation of %s, for the %s service.`, name, server, v.Desc.FullName())))}, methods...)
+}
+
+// Stream returns the fake stream struct, and its methods, for the given (streaming) method.
+func (x *ServiceFakeGenerator) Stream(v *protogen.Method) []gopoet.FileElement {
+	var (
+		stream      = x.symbol(v.Parent, v.Parent.GoName+`_`+v.GoName+`Server`)
+		name        = `Fake` + stream.Name
+		receiver    = gopoet.NewPointerReceiverForType(`x`, localType(name))
+		contextType = gopoet.NamedType(contextPkg.Symbol(`Context`))
+		request     = gopoet.PointerType(x.Cache.MessageType(v.Input.Desc))
+		response    = gopoet.PointerType(x.Cache.MessageType(v.Output.Desc))
+		fields      = []*gopoet.FieldSpec{
+			gopoet.NewField(``, gopoet.NamedType(grpcPkg.Symbol(`ServerStream`))).
+				SetComment(`ServerStream may be set to implement the remaining methods, which will otherwise panic.`),
+			gopoet.NewField(`Ctx`, contextType).
+				SetComment(`Ctx is returned by Context, and defaults to context.Background.`),
+		}
+		methods = []gopoet.FileElement{
+			gopoet.NewMethod(receiver, `Context`).
+				SetComment(`Context returns Ctx, or context.Background, if it is nil.`).
+				AddResult(``, contextType).
+				Println(`if x.Ctx == nil {`).
+				Printlnf(`return %s()`, contextPkg.Symbol(`Background`)).
+				Println(`}`).
+				Println(`return x.Ctx`),
+		}
+	)
+	if v.Desc.IsStreamingClient() {
+		fields = append(fields, gopoet.NewField(`Requests`, gopoet.SliceType(request)).
+			SetComment(`Requests are returned by Recv, in order, followed by io.EOF.`))
+		methods = append(methods, gopoet.NewMethod(receiver, `Recv`).
+			SetComment(`Recv returns the next request, or io.EOF, if there are none remaining.`).
+			AddResult(``, request).
+			AddResult(``, gopoet.ErrorType).
+			Println(`x.mu.Lock()`).
+			Println(`defer x.mu.Unlock()`).
+			Println(`if len(x.Requests) == 0 {`).
+			Printlnf(`return nil, %s`, ioPkg.Symbol(`EOF`)).
+			Println(`}`).
+			Println(`v := x.Requests[0]`).
+			Println(`x.Requests = x.Requests[1:]`).
+			Println(`return v, nil`))
+	}
+	fields = append(fields,
+		gopoet.NewField(`Responses`, gopoet.SliceType(response)).
+			SetComment(`Responses are the sent responses, in order.`),
+		gopoet.NewField(`mu`, gopoet.NamedType(syncPkg.Symbol(`Mutex`))))
+	send := `Send`
+	if !v.Desc.IsStreamingServer() {
+		send = `SendAndClose`
+	}
+	methods = append(methods, gopoet.NewMethod(receiver, send).
+		SetComment(fmt.Sprintf(`%s appends the given response to Responses.`, send)).
+		AddArg(`v`, response).
+		AddResult(``, gopoet.ErrorType).
+		Println(`x.mu.Lock()`).
+		Println(`defer x.mu.Unlock()`).
+		Println(`x.Responses = append(x.Responses, v)`).
+		Println(`return nil`))
+	return append([]gopoet.FileElement{gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name, fields...).
+		SetComment(fmt.Sprintf(`%s is a fake implementation of %s, for the %s method.`, name, stream, v.Desc.FullName())))}, methods...)
+}
+
+// signature returns the signature of the given method, in the server interface.
+func (x *ServiceFakeGenerator) signature(v *protogen.Method) *gopoet.Signature {
+	var (
+		signature gopoet.Signature
+		request   = gopoet.PointerType(x.Cache.MessageType(v.Input.Desc))
+		stream    = gopoet.NamedType(x.symbol(v.Parent, v.Parent.GoName+`_`+v.GoName+`Server`))
+	)
+	switch {
+	case v.Desc.IsStreamingClient():
+		signature.AddArg(`stream`, stream)
+	case v.Desc.IsStreamingServer():
+		signature.AddArg(`req`, request).AddArg(`stream`, stream)
+	default:
+		signature.AddArg(`ctx`, gopoet.NamedType(contextPkg.Symbol(`Context`))).
+			AddArg(`req`, request).
+			AddResult(``, gopoet.PointerType(x.Cache.MessageType(v.Output.Desc)))
+	}
+	signature.AddResult(``, gopoet.ErrorType)
+	return &signature
+}
+
+// symbol returns the symbol with the given name, in the package of the given service.
+func (x *ServiceFakeGenerator) symbol(v *protogen.Service, name string) gopoet.Symbol {
+	file := x.Cache.file(v.Desc.ParentFile().Path())
+	if file == nil {
+		panic(fmt.Sprintf("unknown type: %v", v.Desc))
+	}
+	return x.Cache.goPackage(file.GoImportPath).Symbol(name)
+}
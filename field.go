@@ -3,6 +3,8 @@ package gopoet_protogen
 import (
 	"github.com/jhump/gopoet"
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
 	"sync"
 )
 
@@ -19,13 +21,63 @@ type (
 		// be more than one in the case of oneof fields.
 		Fields() []*protogen.Field
 		// Type returns the gopoet.TypeName for this field, which will be the unexported interface type in the case of
-		// oneof fields (it's the return value of the getter method, in all cases).
+		// oneof fields (it's the return value of the getter method, in all cases). Like protoc-gen-go, the keys and
+		// (scalar) values of map fields are never pointers, including for proto2, e.g. map[string]int32.
 		Type() gopoet.TypeName
+		// BaseType returns Type, with any pointer used to represent presence removed, i.e. if IsPointer is true, e.g.
+		// int32, for a proto2 optional int32 field, or a proto3 optional int32 field.
+		BaseType() gopoet.TypeName
+		// IsPointer returns true if the golang struct field is a pointer to a scalar value (of BaseType), used to
+		// represent presence, i.e. for non-oneof fields with explicit presence, excluding messages and bytes, see also
+		// FieldIsOptional. Note that Type is the pointer type for proto2, but not proto3, as it is the return type of
+		// the getter.
+		IsPointer() bool
 		// Getter returns the gopoet.MethodType for the generated getter method (the generic one, for oneof fields).
+		// Note that the generic getter is not generated for oneof fields, with APIOpaque, in which case the name
+		// will be empty.
 		Getter() gopoet.MethodType
+		// Setter returns the gopoet.MethodType for the generated setter method, with the hybrid and opaque API
+		// levels, see also Cache.APILevel. The name will be empty with APIOpen, or for oneof fields, see also
+		// OneOfFields.
+		Setter() gopoet.MethodType
+		// Hazzer returns the gopoet.MethodType for the generated "has" method, returning bool, for fields with
+		// explicit presence (including oneof fields), with the hybrid and opaque API levels, otherwise the name will
+		// be empty.
+		Hazzer() gopoet.MethodType
+		// Clearer returns the gopoet.MethodType for the generated "clear" method, which is generated for the same
+		// fields as Hazzer, otherwise the name will be empty.
+		Clearer() gopoet.MethodType
+		// HasExpr returns a golang expression that is true if the field is present, for the given receiver, a golang
+		// expression of the (pointer) message type, e.g. `v.Foo != nil`. Fields with explicit presence, including
+		// oneof fields, use the "has" method, if any (see also Hazzer), otherwise they are compared to nil, or, for
+		// oneof members (see also Cache.FlatFields), a type assertion is used. Repeated and map fields are present if
+		// they are non-empty, and other fields if they are non-zero, using the getter. Note that there is no option
+		// to treat bytes fields as having presence, i.e. bytes fields with explicit presence (proto2) are compared to
+		// nil, and bytes fields with implicit presence are present if non-empty, e.g. `len(v.GetFoo()) != 0`.
+		HasExpr(receiver string) *gopoet.CodeBlock
+		// Location returns the source location of the field, or the oneof, for oneof fields, see also
+		// DescriptorLocation.
+		Location() SourceLocation
 		// OneOfFields returns the same information as Type and Getter and Fields, for each of the actual oneof fields,
 		// if any.
 		OneOfFields() []OneOfField
+		// IsRequired returns true if the field uses the proto2 required field rule.
+		// Note that required fields are represented using pointer types, in the same way as proto2 optional fields.
+		IsRequired() bool
+		// Behaviors returns the google.api.field_behavior values of the field, or nil for oneof fields, see also
+		// FieldBehaviors, and OneOfField.Behaviors.
+		Behaviors() []FieldBehavior
+		// Constraints returns the decoded buf.validate.field option of the field, or the buf.validate.oneof option,
+		// for oneof fields, or nil, if it isn't set, see also FieldValidateConstraints, and OneOfField.Constraints.
+		Constraints() *FieldConstraints
+		// Storage returns the storage metadata of the field, or nil, if there is none, or for oneof fields, see also
+		// Cache.FieldStorage.
+		Storage() *FieldStorage
+		// Kind returns the protoreflect.Kind of the field, or 0 for oneof fields, see also OneOfFields.
+		// Map fields are represented as protoreflect.MessageKind.
+		Kind() protoreflect.Kind
+		// Encoding returns the wire encoding of the field, or EncodingInvalid for oneof fields, see also KindEncoding.
+		Encoding() Encoding
 	}
 
 	// OneOfField models the actual type information for a specific oneof field.
@@ -36,6 +88,12 @@ type (
 		Type gopoet.TypeName
 		// Getter is the gopoet.MethodType for the generated getter method (it's return type is Type).
 		Getter gopoet.MethodType
+		// Setter is the gopoet.MethodType for the generated setter method, see also Field.Setter.
+		Setter gopoet.MethodType
+		// Hazzer is the gopoet.MethodType for the generated "has" method, see also Field.Hazzer.
+		Hazzer gopoet.MethodType
+		// Clearer is the gopoet.MethodType for the generated "clear" method, see also Field.Clearer.
+		Clearer gopoet.MethodType
 	}
 
 	goField struct {
@@ -43,9 +101,13 @@ type (
 		name        string
 		oneOf       *protogen.Oneof
 		fields      []*protogen.Field
+		flat        bool
 		once        sync.Once
 		typeName    gopoet.TypeName
 		getter      gopoet.MethodType
+		setter      gopoet.MethodType
+		hazzer      gopoet.MethodType
+		clearer     gopoet.MethodType
 		oneOfFields []OneOfField
 	}
 )
@@ -62,40 +124,185 @@ func FieldIsOptional(field Field) bool {
 	return false
 }
 
+// Kind returns the protoreflect.Kind of the field.
+func (x OneOfField) Kind() protoreflect.Kind { return x.Field.Desc.Kind() }
+
+// Encoding returns the wire encoding of the field, see also KindEncoding.
+func (x OneOfField) Encoding() Encoding { return KindEncoding(x.Kind()) }
+
+// Behaviors returns the google.api.field_behavior values of the field, see also FieldBehaviors.
+func (x OneOfField) Behaviors() []FieldBehavior { return FieldBehaviors(x.Field.Desc) }
+
+// Constraints returns the decoded buf.validate.field option of the field, see also FieldValidateConstraints.
+func (x OneOfField) Constraints() *FieldConstraints { return FieldValidateConstraints(x.Field.Desc) }
+
+// Location returns the source location of the field, see also DescriptorLocation.
+func (x OneOfField) Location() SourceLocation { return DescriptorLocation(x.Field.Desc) }
+
 func (x *goField) Name() string { return x.name }
 
 func (x *goField) OneOf() *protogen.Oneof { return x.oneOf }
 
 func (x *goField) Fields() []*protogen.Field { return x.fields }
 
+func (x *goField) IsRequired() bool {
+	return x.oneOf == nil && x.fields[0].Desc.Cardinality() == protoreflect.Required
+}
+
+func (x *goField) Behaviors() []FieldBehavior {
+	if x.merged() {
+		return nil
+	}
+	return FieldBehaviors(x.fields[0].Desc)
+}
+
+func (x *goField) Constraints() *FieldConstraints {
+	if x.merged() {
+		return OneofValidateConstraints(x.oneOf.Desc)
+	}
+	return FieldValidateConstraints(x.fields[0].Desc)
+}
+
+func (x *goField) Storage() *FieldStorage {
+	if x.merged() {
+		return nil
+	}
+	return x.cache.FieldStorage(x.fields[0].Desc)
+}
+
+func (x *goField) Kind() protoreflect.Kind {
+	if x.merged() {
+		return 0
+	}
+	return x.fields[0].Desc.Kind()
+}
+
+func (x *goField) Encoding() Encoding { return KindEncoding(x.Kind()) }
+
 func (x *goField) Type() gopoet.TypeName {
 	x.once.Do(x.init)
 	return x.typeName
 }
 
+func (x *goField) BaseType() gopoet.TypeName {
+	t := x.Type()
+	if x.IsPointer() && t.Kind() == gopoet.KindPtr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func (x *goField) IsPointer() bool {
+	return (x.oneOf == nil || x.oneOf.Desc.IsSynthetic()) && isPointerField(x.fields[0].Desc)
+}
+
 func (x *goField) Getter() gopoet.MethodType {
 	x.once.Do(x.init)
 	return x.getter
 }
 
+func (x *goField) Setter() gopoet.MethodType {
+	x.once.Do(x.init)
+	return x.setter
+}
+
+func (x *goField) Hazzer() gopoet.MethodType {
+	x.once.Do(x.init)
+	return x.hazzer
+}
+
+func (x *goField) Clearer() gopoet.MethodType {
+	x.once.Do(x.init)
+	return x.clearer
+}
+
+func (x *goField) HasExpr(receiver string) *gopoet.CodeBlock {
+	if hazzer := x.Hazzer(); hazzer.Name != `` {
+		return gopoet.Printf(`%s.%s()`, receiver, hazzer.Name)
+	}
+	desc := x.fields[0].Desc
+	switch {
+	case x.merged():
+		return gopoet.Printf(`%s.%s != nil`, receiver, x.name)
+	case x.oneOf != nil && !x.oneOf.Desc.IsSynthetic():
+		field := x.fields[0]
+		return gopoet.Printf(`func() bool { _, ok := %s.%s.(*%s); return ok }()`, receiver, x.oneOf.GoName, x.cache.goPackage(field.GoIdent.GoImportPath).Symbol(field.GoIdent.GoName))
+	case desc.IsList() || desc.IsMap():
+		return gopoet.Printf(`len(%s.%s()) != 0`, receiver, x.Getter().Name)
+	case desc.Message() != nil:
+		return gopoet.Printf(`%s.%s() != nil`, receiver, x.Getter().Name)
+	case desc.HasPresence():
+		// the getter can't distinguish presence
+		return gopoet.Printf(`%s.%s != nil`, receiver, x.name)
+	}
+	return gopoet.Print(zeroCheck(desc.Kind(), receiver+`.`+x.Getter().Name+`()`))
+}
+
+func (x *goField) Location() SourceLocation {
+	if x.merged() {
+		return DescriptorLocation(x.oneOf.Desc)
+	}
+	return DescriptorLocation(x.fields[0].Desc)
+}
+
 func (x *goField) OneOfFields() []OneOfField {
 	x.once.Do(x.init)
 	return x.oneOfFields
 }
 
+// merged returns true if the field represents all the fields of a (non-synthetic) oneof, see also Cache.FlatFields.
+func (x *goField) merged() bool {
+	return x.oneOf != nil && !x.oneOf.Desc.IsSynthetic() && !x.flat
+}
+
 func (x *goField) init() {
-	if x.oneOf != nil && !x.oneOf.Desc.IsSynthetic() {
+	if x.merged() {
 		// https://github.com/protocolbuffers/protobuf-go/blob/fc9592f7ac4bade8f83e636263f8f07715c698d1/cmd/protoc-gen-go/internal_gengo/main.go#L810
-		x.typeName = gopoet.NamedType(gopoet.NewPackage(string(x.oneOf.GoIdent.GoImportPath)).Symbol("is" + x.oneOf.GoIdent.GoName))
+		x.typeName = gopoet.NamedType(x.cache.goPackage(x.oneOf.GoIdent.GoImportPath).Symbol(x.cache.namer().InterfaceName(x.oneOf)))
 		for _, field := range x.fields {
-			x.oneOfFields = append(x.oneOfFields, OneOfField{
+			var (
+				t     = x.cache.fieldType(field.Desc)
+				ident = field.GoIdent.GoName
+			)
+			if x.cache.APILevel == APIOpaque {
+				// the wrapper types are unexported
+				ident = strings.ToLower(ident[:1]) + ident[1:]
+			}
+			oneOfField := OneOfField{
 				Field:  field,
-				Type:   gopoet.NamedType(gopoet.NewPackage(string(field.GoIdent.GoImportPath)).Symbol(field.GoIdent.GoName)),
-				Getter: gopoet.MethodType{Name: `Get` + field.GoName, Signature: gopoet.Signature{Results: []gopoet.ArgType{{Type: x.cache.fieldType(field.Desc)}}}},
-			})
+				Type:   gopoet.NamedType(x.cache.goPackage(field.GoIdent.GoImportPath).Symbol(ident)),
+				Getter: gopoet.MethodType{Name: x.cache.getterName(field.Desc.Name(), field.GoName), Signature: gopoet.Signature{Results: []gopoet.ArgType{{Type: t}}}},
+			}
+			oneOfField.Setter, oneOfField.Hazzer, oneOfField.Clearer = x.cache.accessors(field.Desc.Name(), field.GoName, setterType(field, t), true)
+			x.oneOfFields = append(x.oneOfFields, oneOfField)
 		}
-	} else {
-		x.typeName = x.cache.fieldType(x.fields[0].Desc)
+		if x.cache.APILevel != APIOpaque {
+			x.getter = gopoet.MethodType{Name: x.cache.namer().GetterName(x.name), Signature: gopoet.Signature{Results: []gopoet.ArgType{{Type: x.typeName}}}}
+		}
+		_, x.hazzer, x.clearer = x.cache.accessors(x.oneOf.Desc.Name(), x.oneOf.GoName, nil, true)
+		return
+	}
+	var (
+		field = x.fields[0]
+		desc  = field.Desc
+	)
+	x.typeName = x.cache.fieldType(desc)
+	x.getter = gopoet.MethodType{Name: x.cache.getterName(desc.Name(), x.name), Signature: gopoet.Signature{Results: []gopoet.ArgType{{Type: x.typeName}}}}
+	x.setter, x.hazzer, x.clearer = x.cache.accessors(desc.Name(), x.name, setterType(field, x.typeName), desc.HasPresence() && !desc.IsList())
+}
+
+// accessors returns the setter (if t is non-nil), "has", and "clear" (if presence is true) methods for a field or
+// oneof, with the hybrid and opaque API levels, see also Cache.APILevel.
+func (x *Cache) accessors(name protoreflect.Name, goName string, t gopoet.TypeName, presence bool) (setter, hazzer, clearer gopoet.MethodType) {
+	if x.APILevel == APIOpen {
+		return
+	}
+	if t != nil {
+		setter = gopoet.MethodType{Name: x.methodName(`Set`, name, goName), Signature: gopoet.Signature{Args: []gopoet.ArgType{{Name: `v`, Type: t}}}}
+	}
+	if presence {
+		hazzer = gopoet.MethodType{Name: x.methodName(`Has`, name, goName), Signature: gopoet.Signature{Results: []gopoet.ArgType{{Type: gopoet.BoolType}}}}
+		clearer = gopoet.MethodType{Name: x.methodName(`Clear`, name, goName)}
 	}
-	x.getter = gopoet.MethodType{Name: `Get` + x.name, Signature: gopoet.Signature{Results: []gopoet.ArgType{{Type: x.typeName}}}}
+	return
 }
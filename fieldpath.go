@@ -0,0 +1,107 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// FieldPath models a valid google.protobuf.FieldMask path, see also FieldPaths.
+	FieldPath struct {
+		// Path is the dot-separated path of proto field names, e.g. "inner.deep.v".
+		Path string
+		// Fields is the chain of fields, from the root message, one for each element of the path.
+		Fields []*protogen.Field
+	}
+
+	// FieldPathOptions configures FieldPaths.
+	FieldPathOptions struct {
+		// MaxDepth may be set to limit the number of elements of each path, otherwise paths will be enumerated until
+		// a message recurses, i.e. a message field is not descended into if its type is already in the path.
+		MaxDepth int
+		// Exclude may be set to exclude fields, and therefore any paths containing them.
+		Exclude func(field *protogen.Field) bool
+	}
+)
+
+// FieldPaths enumerates the valid field mask paths of the given message, depth-first, in declaration order, where
+// every field is a path, and singular message fields are recursed into. Repeated and map fields are not recursed
+// into, as field masks may not traverse them. The options are optional.
+func FieldPaths(v *protogen.Message, options *FieldPathOptions) []FieldPath {
+	if options == nil {
+		options = new(FieldPathOptions)
+	}
+	var paths []FieldPath
+	appendFieldPaths(&paths, options, v, nil, map[protoreflect.FullName]bool{v.Desc.FullName(): true})
+	return paths
+}
+
+// Last returns the last field of the path.
+func (x FieldPath) Last() *protogen.Field {
+	return x.Fields[len(x.Fields)-1]
+}
+
+// Names returns the proto field names of each element of the path.
+func (x FieldPath) Names() []string {
+	return strings.Split(x.Path, `.`)
+}
+
+func appendFieldPaths(paths *[]FieldPath, options *FieldPathOptions, v *protogen.Message, parent []*protogen.Field, visiting map[protoreflect.FullName]bool) {
+	if options.MaxDepth > 0 && len(parent) >= options.MaxDepth {
+		return
+	}
+	for _, field := range v.Fields {
+		if options.Exclude != nil && options.Exclude(field) {
+			continue
+		}
+		fields := make([]*protogen.Field, len(parent)+1)
+		copy(fields, parent)
+		fields[len(parent)] = field
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = string(field.Desc.Name())
+		}
+		*paths = append(*paths, FieldPath{Path: strings.Join(names, `.`), Fields: fields})
+		if field.Message == nil || field.Desc.IsList() || field.Desc.IsMap() ||
+			options.MaxDepth <= 0 && visiting[field.Message.Desc.FullName()] {
+			continue
+		}
+		visiting[field.Message.Desc.FullName()] = true
+		appendFieldPaths(paths, options, field.Message, fields, visiting)
+		delete(visiting, field.Message.Desc.FullName())
+	}
+}
+
+// ResolveFieldPath resolves the given dot-separated path of proto field names, relative to the given message,
+// returning an error if any element doesn't exist, or if any element but the last isn't a singular message field.
+func ResolveFieldPath(v *protogen.Message, path string) (FieldPath, error) {
+	var (
+		fields  []*protogen.Field
+		message = v
+	)
+	for _, name := range strings.Split(path, `.`) {
+		if message == nil {
+			last := fields[len(fields)-1]
+			return FieldPath{}, fmt.Errorf("invalid field path %q for %s: %s is not a singular message field", path, v.Desc.FullName(), last.Desc.Name())
+		}
+		var field *protogen.Field
+		for _, f := range message.Fields {
+			if string(f.Desc.Name()) == name {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return FieldPath{}, fmt.Errorf("invalid field path %q for %s: unknown field %q", path, v.Desc.FullName(), name)
+		}
+		fields = append(fields, field)
+		if field.Desc.IsList() || field.Desc.IsMap() {
+			message = nil
+		} else {
+			message = field.Message
+		}
+	}
+	return FieldPath{Path: path, Fields: fields}, nil
+}
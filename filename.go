@@ -0,0 +1,54 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"path"
+	"strings"
+)
+
+// PluginParameter returns the value of the named plugin parameter (e.g. "module"), and whether it was present. If
+// the parameter was provided multiple times, the last value is returned.
+func PluginParameter(plugin *protogen.Plugin, name string) (string, bool) {
+	var (
+		value string
+		ok    bool
+	)
+	for _, param := range strings.Split(plugin.Request.GetParameter(), `,`) {
+		k, v := param, ``
+		if i := strings.Index(param, `=`); i >= 0 {
+			k, v = param[:i], param[i+1:]
+		}
+		if k == name {
+			value, ok = v, true
+		}
+	}
+	return value, ok
+}
+
+// GeneratedFilename returns the name of the output file for the given input file, with the given suffix (e.g.
+// ".pb.mygen.go"), derived the same way as protoc-gen-go. The paths parameter is honored by
+// protogen.File.GeneratedFilenamePrefix, while the module parameter is honored by stripping the module prefix,
+// returning an error if the file would be generated outside of it.
+func GeneratedFilename(plugin *protogen.Plugin, file *protogen.File, suffix string) (string, error) {
+	filename := file.GeneratedFilenamePrefix + suffix
+	if module, ok := PluginParameter(plugin, `module`); ok && module != `` {
+		trimmed := strings.TrimPrefix(filename, module+`/`)
+		if trimmed == filename {
+			return ``, fmt.Errorf("%v: generated file does not match prefix %q", filename, module)
+		}
+		filename = trimmed
+	}
+	return filename, nil
+}
+
+// NewGoFile returns a new gopoet.GoFile, in the golang package of the given input file, named using the base name of
+// GeneratedFilename, which should be used as the name of the output file, see also Namer.FileSuffix.
+func NewGoFile(plugin *protogen.Plugin, file *protogen.File, suffix string) (*gopoet.GoFile, error) {
+	filename, err := GeneratedFilename(plugin, file, suffix)
+	if err != nil {
+		return nil, err
+	}
+	return gopoet.NewGoFile(path.Base(filename), string(file.GoImportPath), string(file.GoPackageName)), nil
+}
@@ -0,0 +1,103 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// FieldFilter configures which fields are included by helper generators, e.g. to exclude internal fields, where
+	// a field is included if it matches Include (if set), and none of the exclusions. A nil filter includes every
+	// field, see also Fields.
+	FieldFilter struct {
+		// Include may be set to only include fields for which it returns true.
+		Include func(field *protogen.Field) bool
+		// Exclude may be set to exclude fields for which it returns true.
+		Exclude func(field *protogen.Field) bool
+		// Names may be set to exclude fields with any of the given names, each of which may be the name of a field,
+		// e.g. "internal_id", or its full name, e.g. "pkg.Msg.internal_id".
+		Names []string
+		// Numbers may be set to exclude fields with any of the given numbers.
+		Numbers []protoreflect.FieldNumber
+		// Option may be used to configure the field number of a custom bool field option (extension of
+		// google.protobuf.FieldOptions) marking fields as excluded.
+		Option protoreflect.FieldNumber
+		// Behaviors may be set to exclude fields with any of the given google.api.field_behavior values, e.g.
+		// FieldBehaviorOutputOnly, see also FieldBehaviors.
+		Behaviors []FieldBehavior
+	}
+)
+
+// Allow returns true if the given field is included by the filter, which may be nil.
+func (x *FieldFilter) Allow(field *protogen.Field) bool {
+	if x == nil {
+		return true
+	}
+	if x.Include != nil && !x.Include(field) {
+		return false
+	}
+	if x.Exclude != nil && x.Exclude(field) {
+		return false
+	}
+	desc := field.Desc
+	for _, name := range x.Names {
+		if name == string(desc.Name()) || name == string(desc.FullName()) {
+			return false
+		}
+	}
+	for _, number := range x.Numbers {
+		if number == desc.Number() {
+			return false
+		}
+	}
+	if x.Option != 0 {
+		if v, _ := OptionVarint(desc, x.Option); v != 0 {
+			return false
+		}
+	}
+	if len(x.Behaviors) != 0 {
+		for _, v := range FieldBehaviors(desc) {
+			for _, behavior := range x.Behaviors {
+				if v == behavior {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Fields returns the fields included by the filter, which may be nil, given the result of Cache.MessageFields, or
+// Cache.FlatFields. Each (merged) oneof field only represents its included fields, and is excluded if there are
+// none. The given slice is returned as-is, if every field is included.
+func (x *FieldFilter) Fields(fields []Field) []Field {
+	if x == nil {
+		return fields
+	}
+	var (
+		filtered = make([]Field, 0, len(fields))
+		changed  bool
+	)
+	for _, field := range fields {
+		var included []*protogen.Field
+		for _, v := range field.Fields() {
+			if x.Allow(v) {
+				included = append(included, v)
+			}
+		}
+		switch {
+		case len(included) == len(field.Fields()):
+			filtered = append(filtered, field)
+		case len(included) != 0:
+			v := field.(*goField)
+			filtered = append(filtered, &goField{cache: v.cache, name: v.name, oneOf: v.oneOf, fields: included, flat: v.flat})
+			changed = true
+		default:
+			changed = true
+		}
+	}
+	if !changed {
+		return fields
+	}
+	return filtered
+}
@@ -0,0 +1,149 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"path"
+	"strings"
+	"unicode"
+)
+
+type (
+	// FixtureGenerator generates typed loaders of test fixtures, for each message, which are stored as text format
+	// (prototext) files, embedded using embed.FS, such that test data remains human-readable, but access is typed.
+	//
+	// For a message named "Foo", with the full name "pkg.Foo", the generated function is named "LoadFooFixture", and
+	// accepts the name of a fixture, e.g. "basic", which is loaded from "testdata/fixtures/pkg.Foo/basic.textproto",
+	// relative to the package directory (see also Dir), panicking if it doesn't exist, or fails to unmarshal. A single
+	// embed.FS variable is generated per file (see also VarName), which embeds the whole directory, and therefore the
+	// directory must exist, and contain at least one file, for the package to compile. Output must be rendered using
+	// Cache.WriteGoFile (or Pool), which corrects the "//go:embed" directive.
+	FixtureGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "Load" followed by
+		// the golang name of the message, followed by "Fixture".
+		FuncName func(v *protogen.Message) string
+		// Dir may be used to override the (slash separated) directory containing the fixtures, relative to the
+		// package directory, which defaults to "testdata/fixtures".
+		Dir string
+		// VarName may be used to override the name of the embed.FS variable, which defaults to the base name of the
+		// file, in lower camel case, followed by "Fixtures", e.g. "fooBarFixtures" for "pkg/foo_bar.proto".
+		VarName func(file *protogen.File) string
+	}
+)
+
+var (
+	_ Generator = (*FixtureGenerator)(nil)
+
+	embedPkg = gopoet.NewPackage("embed")
+)
+
+// GenerateFile returns the embed.FS variable, and the loader functions for each message in the given file, if it
+// declares any messages, see also FileMessages.
+func (x *FixtureGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	messages := FileMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+	elements := []gopoet.FileElement{x.Var(file)}
+	for _, v := range messages {
+		elements = append(elements, x.Func(file, v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *FixtureGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `Load` + v.GoIdent.GoName + `Fixture`
+}
+
+// FSName returns the name of the embed.FS variable generated for the given file.
+func (x *FixtureGenerator) FSName(file *protogen.File) string {
+	if x.VarName != nil {
+		return x.VarName(file)
+	}
+	var (
+		b     strings.Builder
+		upper bool
+	)
+	for _, r := range strings.TrimSuffix(path.Base(file.Desc.Path()), `.proto`) {
+		switch {
+		case r >= unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)):
+			upper = b.Len() != 0
+		case b.Len() == 0 && unicode.IsDigit(r):
+			b.WriteString(`x`)
+			b.WriteRune(r)
+		case b.Len() == 0:
+			b.WriteRune(unicode.ToLower(r))
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String() + `Fixtures`
+}
+
+// Path returns the (slash separated) path of the directory containing the fixtures of the given message, relative to
+// the package directory.
+func (x *FixtureGenerator) Path(v *protogen.Message) string {
+	return path.Join(x.dir(), string(v.Desc.FullName()))
+}
+
+// Var returns the embed.FS variable, embedding the fixtures of the given file.
+func (x *FixtureGenerator) Var(file *protogen.File) *gopoet.VarDecl {
+	return gopoet.NewVarDecl(gopoet.NewVar(x.FSName(file)).
+		SetType(gopoet.NamedType(embedPkg.Symbol(`FS`))).
+		SetComment(`go:embed ` + x.dir()))
+}
+
+// Func returns the loader function for the given message, of the given file.
+func (x *FixtureGenerator) Func(file *protogen.File, v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name = x.Name(v)
+		t    = x.Cache.MessageType(v.Desc)
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the named %s fixture, loaded from %s, panicking on error.`, name, v.Desc.FullName(), x.Path(v)+`/<name>.textproto`)).
+		AddArg(`name`, gopoet.StringType).
+		AddResult(``, gopoet.PointerType(t)).
+		Printlnf(`b, err := %s.ReadFile(%q + name + ".textproto")`, x.FSName(file), x.Path(v)+`/`).
+		Println(`if err == nil {`).
+		Printlnf(`v := new(%s)`, t).
+		Printlnf(`if err = %s(b, v); err == nil {`, prototextPkg.Symbol(`Unmarshal`)).
+		Println(`return v`).
+		Println(`}`).
+		Println(`}`).
+		Printlnf(`panic(%s("%s: %%q: %%w", name, err))`, fmtPkg.Symbol(`Errorf`), name)
+}
+
+func (x *FixtureGenerator) dir() string {
+	if x.Dir != `` {
+		return x.Dir
+	}
+	return `testdata/fixtures`
+}
+
+// fixEmbedDirectives replaces "// go:embed" lines, directly preceding a var declaration (or spec), with "//go:embed"
+// directives, see also FixtureGenerator.
+func fixEmbedDirectives(src []byte) []byte {
+	lines := strings.SplitAfter(string(src), "\n")
+	var fixed bool
+	for i := 0; i+1 < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], "\t")
+		if next := strings.TrimSpace(lines[i+1]); strings.HasPrefix(line, `// go:embed `) && next != `` && !strings.HasPrefix(next, `//`) {
+			lines[i] = lines[i][:len(lines[i])-len(line)] + `//go:embed ` + strings.TrimPrefix(line, `// go:embed `)
+			fixed = true
+		}
+	}
+	if !fixed {
+		return src
+	}
+	return []byte(strings.Join(lines, ``))
+}
@@ -0,0 +1,256 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// FlagGenerator generates functions binding the singular scalar and enum fields of each message to command-line
+	// flags, using the flag package, or github.com/spf13/pflag, e.g. for CLI tools configured by a proto message.
+	//
+	// Each function is named using the golang name of the message, followed by "Flags", and registers a flag per
+	// field, named using the JSON name of the field, with an optional prefix, returning a message that is populated
+	// as the flags are parsed. Enum flags accept value names, and fields with explicit presence (e.g. proto3
+	// optional fields) are only set if the flag is provided. A single value type, implementing both flag.Value and
+	// pflag.Value, is generated for each file, and messages without any supported fields are skipped.
+	FlagGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// PFlag may be set to use github.com/spf13/pflag, rather than the flag package.
+		PFlag bool
+	}
+)
+
+var (
+	_ Generator = (*FlagGenerator)(nil)
+
+	flagPkg  = gopoet.NewPackage("flag")
+	pflagPkg = gopoet.NewPackage("github.com/spf13/pflag")
+)
+
+// GenerateFile returns the value type for the given file, followed by the flags function for each message in the
+// file, with any supported fields, see also FileMessages.
+func (x *FlagGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		if len(x.Fields(v)) != 0 {
+			elements = append(elements, x.Func(v))
+		}
+	}
+	if elements == nil {
+		return nil
+	}
+	return append(x.ValueType(file), elements...)
+}
+
+// Fields returns the fields of the given message that are bound to flags, in declaration order.
+func (x *FlagGenerator) Fields(v *protogen.Message) []*protogen.Field {
+	var fields []*protogen.Field
+	for _, field := range v.Fields {
+		if isScalarField(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ValueType returns the value type, and its methods, for the given file, which implements flag.Value, and
+// pflag.Value, using functions.
+func (x *FlagGenerator) ValueType(file *protogen.File) []gopoet.FileElement {
+	var (
+		name     = x.valueType(file)
+		receiver = gopoet.NewPointerReceiverForType(`x`, localType(name))
+	)
+	return []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+			gopoet.NewField(`value`, gopoet.FuncType(nil, []gopoet.ArgType{{Type: gopoet.StringType}})),
+			gopoet.NewField(`set`, gopoet.FuncType([]gopoet.ArgType{{Name: `s`, Type: gopoet.StringType}}, []gopoet.ArgType{{Type: gopoet.ErrorType}})),
+			gopoet.NewField(`typ`, gopoet.StringType),
+			gopoet.NewField(`boolean`, gopoet.BoolType),
+		).SetComment(fmt.Sprintf(`%s implements flag.Value, and pflag.Value, for the flags of messages declared in %s.`, name, file.Desc.Path()))),
+		gopoet.NewMethod(receiver, `String`).
+			AddResult(``, gopoet.StringType).
+			Println(`if x == nil || x.value == nil {`).
+			Println(`return ""`).
+			Println(`}`).
+			Println(`return x.value()`),
+		gopoet.NewMethod(receiver, `Set`).
+			AddArg(`s`, gopoet.StringType).
+			AddResult(``, gopoet.ErrorType).
+			Println(`return x.set(s)`),
+		gopoet.NewMethod(receiver, `Type`).
+			AddResult(``, gopoet.StringType).
+			Println(`return x.typ`),
+		gopoet.NewMethod(receiver, `IsBoolFlag`).
+			AddResult(``, gopoet.BoolType).
+			Println(`return x.boolean`),
+	}
+}
+
+// Func returns the flags function for the given message, which accepts the flag set, and a prefix for the flag
+// names, and returns the message populated by the flags.
+func (x *FlagGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name        = v.GoIdent.GoName + `Flags`
+		messageType = gopoet.PointerType(x.Cache.MessageType(v.Desc))
+		file        = x.Cache.file(v.Desc.ParentFile().Path())
+		flagSetType = gopoet.PointerType(gopoet.NamedType(flagPkg.Symbol(`FlagSet`)))
+	)
+	if file == nil {
+		panic(fmt.Sprintf("unknown type: %v", v.Desc))
+	}
+	if x.PFlag {
+		flagSetType = gopoet.PointerType(gopoet.NamedType(pflagPkg.Symbol(`FlagSet`)))
+	}
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s registers a flag for each scalar field of %s, with the given prefix, returning a message that is populated as the flags are parsed.`, name, v.Desc.FullName())).
+		AddArg(`fs`, flagSetType).
+		AddArg(`prefix`, gopoet.StringType).
+		AddResult(``, messageType).
+		Printlnf(`v := new(%s)`, x.Cache.MessageType(v.Desc))
+	for _, field := range x.Fields(v) {
+		var (
+			desc  = field.Desc
+			typ   = desc.Kind().String()
+			usage = commentText(field.Comments.Leading)
+		)
+		if desc.Enum() != nil {
+			typ = string(desc.Enum().Name())
+		}
+		if usage == `` {
+			usage = fmt.Sprintf(`sets %s`, desc.FullName())
+		}
+		f.Printlnf(`fs.Var(&%s{`, localType(x.valueType(file))).
+			Printlnf(`typ: %q,`, typ)
+		if desc.Kind() == protoreflect.BoolKind {
+			f.Println(`boolean: true,`)
+		}
+		f.Printlnf(`value: func() string {`)
+		expr := `v.` + field.GoName
+		if isPointerField(desc) {
+			f.Printlnf(`if %s == nil {`, expr).
+				Println(`return ""`).
+				Println(`}`)
+			expr = `*` + expr
+		}
+		f.Print(`return `).AddCode(x.Cache.formatScalar(field, expr)).Println(``).
+			Println(`},`).
+			Println(`set: func(s string) error {`).
+			AddCode(x.Cache.parseScalar(field, `v.`+field.GoName, `s`, `return `)).
+			Println(`return nil`).
+			Println(`},`).
+			Printlnf(`}, prefix+%q, %q)`, desc.JSONName(), usage)
+		if x.PFlag && desc.Kind() == protoreflect.BoolKind {
+			f.Printlnf(`fs.Lookup(prefix+%q).NoOptDefVal = "true"`, desc.JSONName())
+		}
+	}
+	return f.Println(`return v`)
+}
+
+// valueType returns the name of the value type for the given file.
+func (x *FlagGenerator) valueType(file *protogen.File) string {
+	return fileSymbol(file, `_flagValue`)
+}
+
+// fileSymbol returns the name of an unexported symbol, for the given file, e.g. "file_pkg_v1_file_proto_suffix",
+// derived from the golang name of the file descriptor variable generated by protoc-gen-go.
+func fileSymbol(file *protogen.File, suffix string) string {
+	name := file.GoDescriptorIdent.GoName
+	return strings.ToLower(name[:1]) + name[1:] + suffix
+}
+
+// isScalarField returns true if the given field is a singular scalar or enum field, excluding oneof fields, but
+// including optional fields.
+func isScalarField(field *protogen.Field) bool {
+	desc := field.Desc
+	return !desc.IsList() && !desc.IsMap() && desc.Message() == nil &&
+		(desc.ContainingOneof() == nil || desc.ContainingOneof().IsSynthetic())
+}
+
+// formatScalar returns a golang expression formatting the given (singular scalar or enum) value as a string.
+func (x *Cache) formatScalar(field *protogen.Field, expr string) *gopoet.CodeBlock {
+	switch kind := field.Desc.Kind(); kind {
+	case protoreflect.BoolKind:
+		return gopoet.Printf(`%s(%s)`, strconvPkg.Symbol(`FormatBool`), expr)
+	case protoreflect.StringKind:
+		return gopoet.Print(expr)
+	case protoreflect.BytesKind:
+		return gopoet.Printf(`string(%s)`, expr)
+	case protoreflect.EnumKind:
+		if strings.HasPrefix(expr, `*`) {
+			expr = `(` + expr + `)`
+		}
+		return gopoet.Printf(`%s.String()`, expr)
+	case protoreflect.FloatKind:
+		return gopoet.Printf(`%s(float64(%s), 'g', -1, 32)`, strconvPkg.Symbol(`FormatFloat`), expr)
+	case protoreflect.DoubleKind:
+		return gopoet.Printf(`%s(%s, 'g', -1, 64)`, strconvPkg.Symbol(`FormatFloat`), expr)
+	default:
+		switch goKind(kind) {
+		case protoreflect.Int32Kind, protoreflect.Int64Kind:
+			return gopoet.Printf(`%s(int64(%s), 10)`, strconvPkg.Symbol(`FormatInt`), expr)
+		case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+			return gopoet.Printf(`%s(uint64(%s), 10)`, strconvPkg.Symbol(`FormatUint`), expr)
+		}
+	}
+	panic(fmt.Sprintf("unknown type: %v", field.Desc))
+}
+
+// parseScalar returns statements parsing the string expression s, and assigning the result to the target field
+// expression, which is a pointer, for fields with explicit presence. If parsing fails, the error is returned, using
+// ret as the return statement, prefixing the error, e.g. "return " or "return nil, ".
+func (x *Cache) parseScalar(field *protogen.Field, target, s, ret string) *gopoet.CodeBlock {
+	var (
+		desc = field.Desc
+		cb   = new(gopoet.CodeBlock)
+	)
+	switch kind := goKind(desc.Kind()); kind {
+	case protoreflect.StringKind:
+		cb.Printlnf(`t := %s`, s)
+	case protoreflect.BytesKind:
+		cb.Printlnf(`t := []byte(%s)`, s)
+	case protoreflect.EnumKind:
+		cb.Printlnf(`n, ok := %s[%s]`, x.enumMap(field.Enum, `_value`), s).
+			Println(`if !ok {`).
+			Printlnf(`%s%s(%q, %s)`, ret, fmtPkg.Symbol(`Errorf`), fmt.Sprintf(`invalid %s: %%q`, desc.Enum().FullName()), s).
+			Println(`}`).
+			Printlnf(`t := %s(n)`, x.enumType(desc.Enum()))
+	default:
+		var parse, args, typ string
+		switch kind {
+		case protoreflect.BoolKind:
+			parse = `ParseBool`
+		case protoreflect.Int32Kind:
+			parse, args, typ = `ParseInt`, `, 0, 32`, `int32`
+		case protoreflect.Int64Kind:
+			parse, args = `ParseInt`, `, 0, 64`
+		case protoreflect.Uint32Kind:
+			parse, args, typ = `ParseUint`, `, 0, 32`, `uint32`
+		case protoreflect.Uint64Kind:
+			parse, args = `ParseUint`, `, 0, 64`
+		case protoreflect.FloatKind:
+			parse, args, typ = `ParseFloat`, `, 32`, `float32`
+		case protoreflect.DoubleKind:
+			parse, args = `ParseFloat`, `, 64`
+		default:
+			panic(fmt.Sprintf("unknown type: %v", desc))
+		}
+		cb.Printlnf(`n, err := %s(%s%s)`, strconvPkg.Symbol(parse), s, args).
+			Println(`if err != nil {`).
+			Printlnf(`%serr`, ret).
+			Println(`}`)
+		if typ != `` {
+			cb.Printlnf(`t := %s(n)`, typ)
+		} else {
+			cb.Println(`t := n`)
+		}
+	}
+	if isPointerField(desc) {
+		return cb.Printlnf(`%s = &t`, target)
+	}
+	return cb.Printlnf(`%s = t`, target)
+}
@@ -0,0 +1,158 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// FlagEnumGenerator generates bitmask types for enums annotated as flags, i.e. enums where each value (other than
+	// zero) is a distinct power of two, such that a combination of values may be stored in a field of the enum type
+	// (which is only possible for open enums), or an integer field.
+	//
+	// For an enum named "Foo", the generated type is named "FooFlags", with the underlying type int32, and has the
+	// methods Has, Set, and Clear, which accept a Foo, Enum, which converts it back to a Foo, and String, which joins
+	// the names of the set values, using "|", e.g. "FOO_A|FOO_B". Flags enums are validated when generated, see also
+	// ValidateEnumFlags.
+	FlagEnumGenerator struct {
+		// Cache is used to resolve enum types, and must contain all referenced files.
+		Cache *Cache
+		// TypeName may be used to override the name of the generated type, which defaults to the golang name of the
+		// enum, followed by "Flags".
+		TypeName func(v *protogen.Enum) string
+		// Flags may be used to configure which enums are flags, and takes precedence over Option.
+		Flags func(v *protogen.Enum) bool
+		// Option may be used to configure the field number of a custom bool enum option (extension of
+		// google.protobuf.EnumOptions) marking enums as flags. If both Flags and Option are unset, no enums are flags.
+		Option protoreflect.FieldNumber
+	}
+)
+
+var (
+	_ Generator = (*FlagEnumGenerator)(nil)
+)
+
+// ValidateEnumFlags returns an error if any value of the given enum, other than zero, isn't a (positive) power of two,
+// i.e. the values aren't disjoint bits. Aliases are permitted, as they share the number of another value.
+func ValidateEnumFlags(v *protogen.Enum) error {
+	for _, value := range EnumValues(v) {
+		if n := value.Desc.Number(); n < 0 || n&(n-1) != 0 {
+			return fmt.Errorf("invalid flags enum %s: value %s (%d) is not a power of two", v.Desc.FullName(), value.Desc.Name(), n)
+		}
+	}
+	return nil
+}
+
+// GenerateFile returns the bitmask type, and its methods, for each flags enum in the given file, see also FileEnums.
+// It will panic if a flags enum is invalid, see also ValidateEnumFlags.
+func (x *FlagEnumGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileEnums(file) {
+		if !x.IsFlags(v) {
+			continue
+		}
+		if err := ValidateEnumFlags(v); err != nil {
+			panic(err)
+		}
+		elements = append(elements, x.Type(v))
+		elements = append(elements, x.Methods(v)...)
+	}
+	return elements
+}
+
+// IsFlags returns true if the given enum is a flags enum.
+func (x *FlagEnumGenerator) IsFlags(v *protogen.Enum) bool {
+	if x.Flags != nil {
+		return x.Flags(v)
+	}
+	if x.Option != 0 {
+		n, _ := OptionVarint(v.Desc, x.Option)
+		return n != 0
+	}
+	return false
+}
+
+// Name returns the name of the type generated for the given enum.
+func (x *FlagEnumGenerator) Name(v *protogen.Enum) string {
+	if x.TypeName != nil {
+		return x.TypeName(v)
+	}
+	return v.GoIdent.GoName + `Flags`
+}
+
+// Type returns the bitmask type for the given enum.
+func (x *FlagEnumGenerator) Type(v *protogen.Enum) *gopoet.TypeDecl {
+	name := x.Name(v)
+	return gopoet.NewTypeDecl(gopoet.NewTypeSpec(name, int32Type).
+		SetComment(fmt.Sprintf(`%s is a set of %s values, which are bit flags.`, name, v.Desc.FullName())))
+}
+
+// Methods returns the methods of the bitmask type for the given enum.
+func (x *FlagEnumGenerator) Methods(v *protogen.Enum) []gopoet.FileElement {
+	var (
+		name     = x.Name(v)
+		t        = localType(name)
+		enumType = x.Cache.enumType(v.Desc)
+		flags    = gopoet.Printf(`[]%s{`, enumType)
+		zero     *protogen.EnumValue
+		started  bool
+	)
+	for _, value := range EnumValues(v) {
+		if value.Desc.Number() == 0 {
+			zero = value
+			continue
+		}
+		if started {
+			flags.Print(`, `)
+		}
+		started = true
+		flags.Printf(`%s`, x.Cache.EnumValueConst(value.Desc))
+	}
+	str := gopoet.NewMethod(gopoet.NewReceiverForType(`x`, t), `String`).
+		SetComment(`String returns the names of the set values, joined by "|", followed by any unknown bits, in hexadecimal.`).
+		AddResult(``, gopoet.StringType).
+		Println(`if x == 0 {`)
+	if zero != nil {
+		str.Printlnf(`return %q`, zero.Desc.Name())
+	} else {
+		str.Println(`return "0"`)
+	}
+	str.Println(`}`).
+		Println(`var names []string`).
+		Print(`for _, f := range `).
+		AddCode(flags).
+		Println(`} {`).
+		Printlnf(`if x&%s(f) != 0 {`, name).
+		Println(`names = append(names, f.String())`).
+		Printlnf(`x &^= %s(f)`, name).
+		Println(`}`).
+		Println(`}`).
+		Println(`if x != 0 {`).
+		Printlnf(`names = append(names, "0x"+%s(uint64(uint32(x)), 16))`, strconvPkg.Symbol(`FormatUint`)).
+		Println(`}`).
+		Printlnf(`return %s(names, "|")`, stringsPkg.Symbol(`Join`))
+	return []gopoet.FileElement{
+		gopoet.NewMethod(gopoet.NewReceiverForType(`x`, t), `Has`).
+			SetComment(`Has returns true if every bit of the given value is set.`).
+			AddArg(`f`, enumType).
+			AddResult(``, gopoet.BoolType).
+			Printlnf(`return x&%s(f) == %s(f)`, name, name),
+		gopoet.NewMethod(gopoet.NewReceiverForType(`x`, t), `Set`).
+			SetComment(`Set returns a copy with the bits of the given value set.`).
+			AddArg(`f`, enumType).
+			AddResult(``, t).
+			Printlnf(`return x | %s(f)`, name),
+		gopoet.NewMethod(gopoet.NewReceiverForType(`x`, t), `Clear`).
+			SetComment(`Clear returns a copy with the bits of the given value cleared.`).
+			AddArg(`f`, enumType).
+			AddResult(``, t).
+			Printlnf(`return x &^ %s(f)`, name),
+		gopoet.NewMethod(gopoet.NewReceiverForType(`x`, t), `Enum`).
+			SetComment(fmt.Sprintf(`Enum returns the set as a %s, which may be an unknown value, e.g. for a field of the enum type.`, v.Desc.FullName())).
+			AddResult(``, enumType).
+			Printlnf(`return %s(x)`, enumType),
+		str,
+	}
+}
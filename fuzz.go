@@ -0,0 +1,412 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+	"strconv"
+)
+
+type (
+	// FuzzGenerator generates native golang fuzz tests (go test -fuzz, requiring go 1.18), for each message, that
+	// construct an instance from the fuzz input, and assert that it round-trips, via proto.Marshal and
+	// proto.Unmarshal, as well as protojson.Marshal and protojson.Unmarshal, i.e. that the result is proto.Equal.
+	// The output must therefore be a "_test.go" file.
+	//
+	// For a message named "Foo", the generated elements are "FuzzFoo", and "fuzzFoo", which constructs a message,
+	// consuming the input. Fields are populated using the field model, see also Cache.MessageFields: required fields
+	// are always set, fields with explicit presence are either unset, set to their default value, or set to a value
+	// derived from the input, and each oneof is set to at most one of its members. Fields of message types declared
+	// in the same file call the constructor function of that type, and must therefore be generated into the same
+	// package, where recursive fields (see also RecursiveFields) are only set up to MaxDepth, unless they are
+	// required, such that required fields are always set, though a cycle of required fields can't be constructed.
+	// Other message types are set to an empty message (which therefore must not have required fields), except for
+	// google.protobuf.Value, which is never set, as an empty value can't be represented as JSON. Generated code
+	// accesses the struct fields directly, and therefore requires the open API level, see also Cache.APILevel.
+	FuzzGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated fuzz test, which defaults to "Fuzz" followed by
+		// the golang name of the message.
+		FuncName func(v *protogen.Message) string
+		// MaxDepth may be used to override the maximum depth of nested messages, beyond which (optional) recursive
+		// fields aren't set, which defaults to 3.
+		MaxDepth int
+	}
+
+	// fuzzState tracks which of the helper closures of a constructor function are used.
+	fuzzState struct {
+		next  bool
+		chunk bool
+	}
+)
+
+var (
+	_ Generator = (*FuzzGenerator)(nil)
+
+	mathPkg    = gopoet.NewPackage("math")
+	testingPkg = gopoet.NewPackage("testing")
+)
+
+// GenerateFile returns the constructor function, and fuzz test, for each message in the given file, see also
+// FileMessages.
+func (x *FuzzGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.ConstructorFunc(v), x.Func(v))
+	}
+	return elements
+}
+
+// Name returns the name of the fuzz test generated for the given message.
+func (x *FuzzGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `Fuzz` + v.GoIdent.GoName
+}
+
+// ConstructorName returns the name of the (unexported) constructor function generated for the given message.
+func (x *FuzzGenerator) ConstructorName(v *protogen.Message) string {
+	return `fuzz` + v.GoIdent.GoName
+}
+
+// Func returns the fuzz test for the given message.
+func (x *FuzzGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name = x.Name(v)
+		t    = x.Cache.MessageType(v.Desc)
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s asserts that a %s, constructed from the input, round-trips via the binary and JSON encodings.`, name, v.Desc.FullName())).
+		AddArg(`f`, gopoet.PointerType(gopoet.NamedType(testingPkg.Symbol(`F`)))).
+		Println(`f.Add([]byte{})`).
+		Printlnf(`f.Add(%s([]byte{1}, 64))`, bytesPkg.Symbol(`Repeat`)).
+		Printlnf(`f.Fuzz(func(t *%s, data []byte) {`, testingPkg.Symbol(`T`)).
+		Printlnf(`v := %s(&data, 0)`, x.ConstructorName(v)).
+		Printlnf(`b, err := %s(v)`, protoPkg.Symbol(`Marshal`)).
+		Println(`if err != nil {`).
+		Println(`t.Fatal(err)`).
+		Println(`}`).
+		Printlnf(`w := new(%s)`, t).
+		Printlnf(`if err := %s(b, w); err != nil {`, protoPkg.Symbol(`Unmarshal`)).
+		Println(`t.Fatal(err)`).
+		Println(`}`).
+		Printlnf(`if !%s(v, w) {`, protoPkg.Symbol(`Equal`)).
+		Println(`t.Fatalf("binary round-trip mismatch:\n%v\n%v", v, w)`).
+		Println(`}`).
+		Printlnf(`if b, err = %s(v); err != nil {`, protojsonPkg.Symbol(`Marshal`)).
+		Println(`t.Fatal(err)`).
+		Println(`}`).
+		Printlnf(`w = new(%s)`, t).
+		Printlnf(`if err := %s(b, w); err != nil {`, protojsonPkg.Symbol(`Unmarshal`)).
+		Println(`t.Fatal(err)`).
+		Println(`}`).
+		Printlnf(`if !%s(v, w) {`, protoPkg.Symbol(`Equal`)).
+		Println(`t.Fatalf("JSON round-trip mismatch:\n%v\n%v", v, w)`).
+		Println(`}`).
+		Println(`})`)
+}
+
+// ConstructorFunc returns the function constructing the given message, consuming the input, at a given depth.
+func (x *FuzzGenerator) ConstructorFunc(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name   = x.ConstructorName(v)
+		t      = x.Cache.MessageType(v.Desc)
+		state  fuzzState
+		fields = gopoet.Printlnf(`v := new(%s)`, t)
+	)
+	for _, field := range x.Cache.MessageFields(v) {
+		if field.Kind() == 0 {
+			x.oneOf(fields, &state, v, field)
+		} else {
+			x.field(fields, &state, v, field)
+		}
+	}
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a %s, populated by consuming data.`, name, v.Desc.FullName())).
+		AddArg(`data`, gopoet.PointerType(gopoet.SliceType(gopoet.ByteType))).
+		AddArg(`depth`, gopoet.IntType).
+		AddResult(``, gopoet.PointerType(t))
+	if state.next || state.chunk {
+		f.Println(`next := func(n int) (u uint64) {`).
+			Println(`for ; n > 0 && len(*data) != 0; n-- {`).
+			Println(`u = u<<8 | uint64((*data)[0])`).
+			Println(`*data = (*data)[1:]`).
+			Println(`}`).
+			Println(`return u`).
+			Println(`}`)
+	}
+	if state.chunk {
+		f.Println(`chunk := func() []byte {`).
+			Println(`n := int(next(1) % 16)`).
+			Println(`if n > len(*data) {`).
+			Println(`n = len(*data)`).
+			Println(`}`).
+			Println(`b := append([]byte{}, (*data)[:n]...)`).
+			Println(`*data = (*data)[n:]`).
+			Println(`return b`).
+			Println(`}`)
+	}
+	return f.AddCode(fields).
+		Println(`return v`)
+}
+
+// oneOf populates a (merged) oneof field, with at most one of its members.
+func (x *FuzzGenerator) oneOf(cb *gopoet.CodeBlock, state *fuzzState, parent *protogen.Message, field Field) {
+	members := field.OneOfFields()
+	state.next = true
+	cb.Printlnf(`switch next(1) %% %d {`, len(members)+1)
+	for i, member := range members {
+		desc := member.Field.Desc
+		if desc.Message() != nil && !x.supported(desc.Message()) {
+			continue
+		}
+		cb.Printlnf(`case %d:`, i)
+		guard := x.recursive(parent, member.Field.Message)
+		if guard {
+			cb.Printlnf(`if depth < %d {`, x.maxDepth())
+		}
+		cb.Printf(`v.%s = &%s{%s: `, field.Name(), member.Type, member.Field.GoName).
+			AddCode(x.value(state, parent, member.Field)).
+			Println(`}`)
+		if guard {
+			cb.Println(`}`)
+		}
+	}
+	cb.Println(`}`)
+}
+
+// field populates a non-oneof field.
+func (x *FuzzGenerator) field(cb *gopoet.CodeBlock, state *fuzzState, parent *protogen.Message, field Field) {
+	var (
+		f    = field.Fields()[0]
+		desc = f.Desc
+		expr = `v.` + field.Name()
+	)
+	switch {
+	case desc.IsMap():
+		key, value := f.Message.Fields[0], f.Message.Fields[1]
+		if value.Message != nil && !x.supported(value.Desc.Message()) {
+			return
+		}
+		state.next = true
+		guard := x.recursive(parent, value.Message)
+		if guard {
+			cb.Printlnf(`if depth < %d {`, x.maxDepth())
+		}
+		cb.Println(`if n := int(next(1) % 4); n != 0 {`).
+			Printlnf(`%s = make(%s, n)`, expr, field.Type()).
+			Println(`for i := 0; i < n; i++ {`).
+			Printf(`%s[`, expr).
+			AddCode(x.value(state, parent, key)).
+			Print(`] = `).
+			AddCode(x.value(state, parent, value)).
+			Println(``).
+			Println(`}`).
+			Println(`}`)
+		if guard {
+			cb.Println(`}`)
+		}
+
+	case desc.Message() != nil && !x.supported(desc.Message()):
+
+	case desc.IsList():
+		state.next = true
+		guard := x.recursive(parent, f.Message)
+		if guard {
+			cb.Printlnf(`if depth < %d {`, x.maxDepth())
+		}
+		cb.Println(`for i, n := 0, int(next(1)%4); i < n; i++ {`).
+			Printf(`%s = append(%s, `, expr, expr).
+			AddCode(x.value(state, parent, f)).
+			Println(`)`).
+			Println(`}`)
+		if guard {
+			cb.Println(`}`)
+		}
+
+	case desc.Message() != nil:
+		state.next = true
+		switch {
+		case field.IsRequired():
+			cb.Println(`{`)
+		case x.recursive(parent, f.Message):
+			cb.Printlnf(`if depth < %d && next(1)&1 != 0 {`, x.maxDepth())
+		default:
+			cb.Println(`if next(1)&1 != 0 {`)
+		}
+		cb.Printf(`%s = `, expr).
+			AddCode(x.value(state, parent, f)).
+			Println(``).
+			Println(`}`)
+
+	case desc.HasPresence():
+		// unset, the default (or zero) value, or a value from the input
+		state.next = true
+		if field.IsRequired() {
+			cb.Println(`{`).
+				Println(`s := next(1)%2 + 1`)
+		} else {
+			cb.Println(`if s := next(1) % 3; s != 0 {`)
+		}
+		cb.Print(`e := `).
+			AddCode(x.Cache.defaultValue(f.Desc)).
+			Println(``).
+			Println(`if s == 1 {`).
+			Print(`e = `).
+			AddCode(x.value(state, parent, f)).
+			Println(``).
+			Println(`}`)
+		if field.IsPointer() {
+			cb.Printlnf(`%s = &e`, expr)
+		} else {
+			cb.Printlnf(`%s = e`, expr)
+		}
+		cb.Println(`}`)
+
+	default:
+		cb.Printf(`%s = `, expr).
+			AddCode(x.value(state, parent, f)).
+			Println(``)
+	}
+}
+
+// value returns an expression evaluating to a single value of the given field, derived from the input.
+func (x *FuzzGenerator) value(state *fuzzState, parent *protogen.Message, field *protogen.Field) *gopoet.CodeBlock {
+	desc := field.Desc
+	switch desc.Kind() {
+	case protoreflect.BoolKind:
+		state.next = true
+		return gopoet.Print(`next(1)&1 != 0`)
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind:
+		state.next = true
+		return gopoet.Print(`int32(next(4))`)
+	case protoreflect.Int64Kind,
+		protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind:
+		state.next = true
+		return gopoet.Print(`int64(next(8))`)
+	case protoreflect.Uint32Kind,
+		protoreflect.Fixed32Kind:
+		state.next = true
+		return gopoet.Print(`uint32(next(4))`)
+	case protoreflect.Uint64Kind,
+		protoreflect.Fixed64Kind:
+		state.next = true
+		return gopoet.Print(`next(8)`)
+	case protoreflect.FloatKind:
+		state.next = true
+		return gopoet.Printf(`%s(uint32(next(4)))`, mathPkg.Symbol(`Float32frombits`))
+	case protoreflect.DoubleKind:
+		state.next = true
+		return gopoet.Printf(`%s(next(8))`, mathPkg.Symbol(`Float64frombits`))
+	case protoreflect.StringKind:
+		// invalid UTF-8 can't be marshaled
+		state.next, state.chunk = true, true
+		return gopoet.Printf(`%s(string(chunk()), "")`, stringsPkg.Symbol(`ToValidUTF8`))
+	case protoreflect.BytesKind:
+		state.next, state.chunk = true, true
+		return gopoet.Print(`chunk()`)
+	case protoreflect.EnumKind:
+		var (
+			values = EnumValues(field.Enum)
+			cb     = gopoet.Printf(`[]%s{`, x.Cache.enumType(desc.Enum()))
+		)
+		state.next = true
+		for i, value := range values {
+			if i != 0 {
+				cb.Print(`, `)
+			}
+			cb.Printf(`%s`, x.Cache.EnumValueConst(value.Desc))
+		}
+		return cb.Printf(`}[next(1)%%%d]`, len(values))
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if x.sameFile(parent, field.Message) {
+			return gopoet.Printf(`%s(data, depth+1)`, x.ConstructorName(field.Message))
+		}
+		return gopoet.Printf(`new(%s)`, x.Cache.MessageType(desc.Message()))
+	default:
+		panic(fmt.Sprintf("unknown type: %v", desc))
+	}
+}
+
+// defaultValue returns an expression evaluating to the default value of the given (singular, scalar) field.
+func (x *Cache) defaultValue(desc protoreflect.FieldDescriptor) *gopoet.CodeBlock {
+	value := desc.Default()
+	switch desc.Kind() {
+	case protoreflect.BoolKind:
+		return gopoet.Printf(`%t`, value.Bool())
+	case protoreflect.StringKind:
+		return gopoet.Printf(`%q`, value.String())
+	case protoreflect.BytesKind:
+		return gopoet.Printf(`[]byte(%q)`, value.Bytes())
+	case protoreflect.EnumKind:
+		if v := desc.DefaultEnumValue(); v != nil {
+			return gopoet.Printf(`%s`, x.EnumValueConst(v))
+		}
+		return gopoet.Printf(`%s(%d)`, x.enumType(desc.Enum()), value.Enum())
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind:
+		return gopoet.Printf(`int32(%d)`, value.Int())
+	case protoreflect.Int64Kind,
+		protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind:
+		return gopoet.Printf(`int64(%d)`, value.Int())
+	case protoreflect.Uint32Kind,
+		protoreflect.Fixed32Kind:
+		return gopoet.Printf(`uint32(%d)`, value.Uint())
+	case protoreflect.Uint64Kind,
+		protoreflect.Fixed64Kind:
+		return gopoet.Printf(`uint64(%d)`, value.Uint())
+	case protoreflect.FloatKind:
+		return gopoet.Print(`float32(`).AddCode(floatLiteral(value.Float())).Print(`)`)
+	case protoreflect.DoubleKind:
+		return gopoet.Print(`float64(`).AddCode(floatLiteral(value.Float())).Print(`)`)
+	default:
+		panic(fmt.Sprintf("unknown type: %v", desc))
+	}
+}
+
+// supported returns false for message types that can't be populated, see also FuzzGenerator.
+func (x *FuzzGenerator) supported(desc protoreflect.MessageDescriptor) bool {
+	return desc.FullName() != `google.protobuf.Value`
+}
+
+// recursive returns true if the given message type (which may be nil), of a field of the parent, has a constructor
+// function, which (transitively) constructs the parent, i.e. the field must be depth capped, see also MaxDepth.
+func (x *FuzzGenerator) recursive(parent *protogen.Message, message *protogen.Message) bool {
+	return x.sameFile(parent, message) && recursiveField(parent, message)
+}
+
+// sameFile returns true if the given message type (which may be nil) is declared in the same file as the parent,
+// i.e. it has a constructor function.
+func (x *FuzzGenerator) sameFile(parent *protogen.Message, message *protogen.Message) bool {
+	return message != nil && message.Desc.ParentFile().Path() == parent.Desc.ParentFile().Path()
+}
+
+func (x *FuzzGenerator) maxDepth() int {
+	if x.MaxDepth > 0 {
+		return x.MaxDepth
+	}
+	return 3
+}
+
+// floatLiteral returns a golang expression for the given float, which may be infinite, or NaN.
+func floatLiteral(f float64) *gopoet.CodeBlock {
+	switch {
+	case math.IsNaN(f):
+		return gopoet.Printf(`%s()`, mathPkg.Symbol(`NaN`))
+	case math.IsInf(f, 1):
+		return gopoet.Printf(`%s(1)`, mathPkg.Symbol(`Inf`))
+	case math.IsInf(f, -1):
+		return gopoet.Printf(`%s(-1)`, mathPkg.Symbol(`Inf`))
+	}
+	return gopoet.Print(strconv.FormatFloat(f, 'g', -1, 64))
+}
@@ -0,0 +1,50 @@
+package gopoet_protogen
+
+import (
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// Generator models a code generation module, that produces gopoet elements for a given protogen.File.
+	// Implementations are expected to return elements in a deterministic order.
+	Generator interface {
+		// GenerateFile returns the elements generated for the given file, which may be none.
+		GenerateFile(file *protogen.File) []gopoet.FileElement
+	}
+)
+
+var (
+	errorsPkg    = gopoet.NewPackage("errors")
+	fmtPkg       = gopoet.NewPackage("fmt")
+	protoPkg     = gopoet.NewPackage("google.golang.org/protobuf/proto")
+	protowirePkg = gopoet.NewPackage("google.golang.org/protobuf/encoding/protowire")
+	stringsPkg   = gopoet.NewPackage("strings")
+)
+
+// Generate adds the elements produced by each of the given generators, for the given file, to the gopoet.GoFile,
+// in the order the generators were provided.
+func Generate(dst *gopoet.GoFile, file *protogen.File, generators ...Generator) {
+	for _, generator := range generators {
+		for _, element := range generator.GenerateFile(file) {
+			dst.AddElement(element)
+		}
+	}
+}
+
+// FileMessages returns all the messages declared in the given file, including nested messages, ordered depth-first
+// by declaration. Map entry messages are excluded, as they have no corresponding golang type.
+func FileMessages(file *protogen.File) []*protogen.Message {
+	return appendMessages(nil, file.Messages)
+}
+
+func appendMessages(dst []*protogen.Message, messages []*protogen.Message) []*protogen.Message {
+	for _, v := range messages {
+		if v.Desc.IsMapEntry() {
+			continue
+		}
+		dst = append(dst, v)
+		dst = appendMessages(dst, v.Messages)
+	}
+	return dst
+}
@@ -4,5 +4,6 @@ go 1.16
 
 require (
 	github.com/jhump/gopoet v0.1.0
+	github.com/jhump/protoreflect v1.14.1
 	google.golang.org/protobuf v1.28.1
 )
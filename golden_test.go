@@ -0,0 +1,2807 @@
+package gopoet_protogen_test
+
+import (
+	"encoding/json"
+	"github.com/jhump/gopoet"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"github.com/joeycumines/gopoet-protogen/protogentest"
+	"go/types"
+	"google.golang.org/protobuf/compiler/protogen"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// httpFile declares a service with google.api.http rules, the option being declared by a minimal copy of
+	// google/api/annotations.proto, and google/api/http.proto.
+	httpFile  = `test/v1/shelves.proto`
+	httpProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/api/annotations.proto";
+import "test/v1/library.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+message CreateBookRequest {
+  string parent = 1;
+  Book book = 2;
+  string request_id = 3;
+}
+
+message DeleteBookRequest {
+  int64 shelf = 1;
+  string id = 2;
+}
+
+message Empty {}
+
+service Shelves {
+  rpc GetBook(GetBookRequest) returns (Book) {
+    option (google.api.http) = {get: "/v1/{name=shelves/*/books/*}"};
+  }
+  rpc ListBooks(ListBooksRequest) returns (ListBooksResponse) {
+    option (google.api.http) = {get: "/v1/books"};
+  }
+  rpc CreateBook(CreateBookRequest) returns (Book) {
+    option (google.api.http) = {post: "/v1/{parent=shelves/*}/books" body: "book"};
+  }
+  rpc DeleteBook(DeleteBookRequest) returns (Empty) {
+    option (google.api.http) = {delete: "/v1/shelves/{shelf}/books/{id}"};
+  }
+  rpc NoRule(Empty) returns (Empty);
+}
+`
+	annotationsProto = `syntax = "proto3";
+
+package google.api;
+
+import "google/api/http.proto";
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/protogentest/google/api;annotations";
+
+extend google.protobuf.MethodOptions {
+  HttpRule http = 72295728;
+}
+`
+	httpRuleProto = `syntax = "proto3";
+
+package google.api;
+
+option go_package = "example.com/protogentest/google/api;annotations";
+
+message HttpRule {
+  string selector = 1;
+  oneof pattern {
+    string get = 2;
+    string put = 3;
+    string post = 4;
+    string delete = 5;
+    string patch = 6;
+    CustomHttpPattern custom = 8;
+  }
+  string body = 7;
+  string response_body = 12;
+  repeated HttpRule additional_bindings = 11;
+}
+
+message CustomHttpPattern {
+  string kind = 1;
+  string path = 2;
+}
+`
+
+	// eventsFile declares messages annotated as events, using custom message options, a string option (topic),
+	// and a bool option (event).
+	eventsFile  = `test/v1/events.proto`
+	eventsProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+extend google.protobuf.MessageOptions {
+  string topic = 50001;
+  bool event = 50002;
+}
+
+message BookCreated {
+  option (topic) = "books";
+
+  string name = 1;
+}
+
+message BookDeleted {
+  option (event) = true;
+
+  string name = 1;
+}
+
+message BookIgnored {
+  option (event) = false;
+
+  string name = 1;
+}
+`
+
+	// policyFile declares a service with call policies, using a custom method option (timeout), and the
+	// idempotency_level method option.
+	policyFile  = `test/v1/policy.proto`
+	policyProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/protobuf/descriptor.proto";
+import "test/v1/library.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+extend google.protobuf.MethodOptions {
+  string timeout = 50010;
+}
+
+service Policies {
+  rpc Get(GetBookRequest) returns (Book) {
+    option (timeout) = "1.5s";
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+  rpc Update(Book) returns (Book) {
+    option (timeout) = "250ms";
+  }
+  rpc Delete(GetBookRequest) returns (Book) {
+    option idempotency_level = IDEMPOTENT;
+  }
+}
+`
+
+	// envFile declares a configuration message, using a custom string field option (env) to name some variables,
+	// and an enum with an alias.
+	envFile  = `test/v1/env.proto`
+	envProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+extend google.protobuf.FieldOptions {
+  string env = 50020;
+}
+
+enum Level {
+  option allow_alias = true;
+  LEVEL_UNSPECIFIED = 0;
+  LEVEL_DEBUG = 1;
+  LEVEL_INFO = 2;
+  LEVEL_VERBOSE = 1;
+}
+
+message Server {
+  string host = 1 [(env) = "HOST_NAME"];
+  uint32 port = 2;
+  bool tls = 3;
+  optional double ratio = 4;
+  Level level = 5;
+  repeated string peers = 6;
+  Server fallback = 7;
+}
+`
+
+	// errorsFile declares error reason enums, one identified by name, and one by a custom bool enum option
+	// (error_reason), using a custom string enum value option (code) to map values to gRPC status codes.
+	errorsFile  = `test/v1/errors.proto`
+	errorsProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+extend google.protobuf.EnumOptions {
+  bool error_reason = 50031;
+}
+
+extend google.protobuf.EnumValueOptions {
+  string code = 50030;
+}
+
+enum BookErrorReason {
+  BOOK_ERROR_REASON_UNSPECIFIED = 0;
+  BOOK_NOT_FOUND = 1 [(code) = "NOT_FOUND"];
+  BOOK_EXISTS = 2 [(code) = "AlreadyExists"];
+  BOOK_LOCKED = 3 [(code) = "cancelled"];
+  BOOK_MISSING = 4 [(code) = "not_found"];
+  BOOK_OTHER = 5;
+}
+
+enum Quota {
+  option (error_reason) = true;
+  QUOTA_UNSPECIFIED = 0;
+  QUOTA_EXCEEDED = 1 [(code) = "RESOURCE_EXHAUSTED"];
+}
+
+enum Unrelated {
+  UNRELATED_UNSPECIFIED = 0;
+}
+`
+
+	// resourcesFile declares messages with google.api.resource options, the option being declared by a minimal copy
+	// of google/api/resource.proto.
+	resourcesFile  = `test/v1/resources.proto`
+	resourcesProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/api/resource.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+message Shelf {
+  option (google.api.resource) = {
+    type: "library.example.com/Shelf"
+    pattern: "shelves/{shelf}"
+  };
+  string name = 1;
+}
+
+message Volume {
+  option (google.api.resource) = {
+    type: "library.example.com/Volume"
+    pattern: "shelves/{shelf}/volumes/{volume}"
+    pattern: "publishers/{publisher_id}/volumes/{volume}"
+    name_field: "path"
+  };
+  string path = 1;
+  string title = 2;
+}
+
+message Unnamed {
+  string name = 1;
+}
+`
+	resourceProto = `syntax = "proto3";
+
+package google.api;
+
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/protogentest/google/api;annotations";
+
+extend google.protobuf.MessageOptions {
+  ResourceDescriptor resource = 1053;
+}
+
+message ResourceDescriptor {
+  string type = 1;
+  repeated string pattern = 2;
+  string name_field = 3;
+  string plural = 5;
+  string singular = 6;
+}
+`
+
+	// operationsFile declares a service with methods returning long-running operations, using minimal copies of
+	// google/longrunning/operations.proto, and google/rpc/status.proto, with the go_package of the published protos.
+	operationsFile  = `test/v1/operations.proto`
+	operationsProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/longrunning/operations.proto";
+import "test/v1/library.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+message CreateBookMetadata {
+  int32 progress = 1;
+}
+
+service Operations {
+  rpc CreateBook(Book) returns (google.longrunning.Operation) {
+    option (google.longrunning.operation_info) = {
+      response_type: "Book"
+      metadata_type: "test.v1.CreateBookMetadata"
+    };
+  }
+  rpc DeleteBook(GetBookRequest) returns (google.longrunning.Operation) {
+    option (google.longrunning.operation_info) = {
+      response_type: ".test.v1.GetBookRequest"
+    };
+  }
+  rpc GetBook(GetBookRequest) returns (google.longrunning.Operation);
+}
+`
+	longrunningProto = `syntax = "proto3";
+
+package google.longrunning;
+
+import "google/protobuf/any.proto";
+import "google/protobuf/descriptor.proto";
+import "google/rpc/status.proto";
+
+option go_package = "cloud.google.com/go/longrunning/autogen/longrunningpb;longrunningpb";
+
+extend google.protobuf.MethodOptions {
+  OperationInfo operation_info = 1049;
+}
+
+message Operation {
+  string name = 1;
+  google.protobuf.Any metadata = 2;
+  bool done = 3;
+  oneof result {
+    google.rpc.Status error = 4;
+    google.protobuf.Any response = 5;
+  }
+}
+
+message GetOperationRequest {
+  string name = 1;
+}
+
+message OperationInfo {
+  string response_type = 1;
+  string metadata_type = 2;
+}
+`
+	statusProto = `syntax = "proto3";
+
+package google.rpc;
+
+import "google/protobuf/any.proto";
+
+option go_package = "google.golang.org/genproto/googleapis/rpc/status;status";
+
+message Status {
+  int32 code = 1;
+  string message = 2;
+  repeated google.protobuf.Any details = 3;
+}
+`
+
+	// normalizeFile declares a (recursive) message, with normalization rules, using a custom message field option
+	// (normalize).
+	normalizeFile  = `test/v1/normalize.proto`
+	normalizeProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+extend google.protobuf.FieldOptions {
+  Normalize normalize = 50040;
+}
+
+message Normalize {
+  bool trim = 1;
+  bool lower = 2;
+  bool upper = 3;
+  bool sort = 4;
+  optional double min = 5;
+  optional double max = 6;
+}
+
+enum Role {
+  ROLE_UNSPECIFIED = 0;
+  ROLE_READER = 1;
+  ROLE_WRITER = 2;
+}
+
+message Profile {
+  string email = 1 [(normalize) = {trim: true, lower: true}];
+  repeated string tags = 2 [(normalize) = {trim: true, upper: true, sort: true}];
+  int32 age = 3 [(normalize) = {min: 0, max: 150}];
+  float score = 4 [(normalize) = {min: 0.5}];
+  map<string, string> labels = 5 [(normalize) = {trim: true}];
+  optional uint32 level = 6 [(normalize) = {max: 10}];
+  Profile parent = 7;
+  repeated Profile children = 8;
+  map<string, Profile> friends = 9;
+  oneof contact {
+    string phone = 10 [(normalize) = {trim: true}];
+    Profile manager = 11;
+  }
+  repeated Role roles = 12 [(normalize) = {sort: true}];
+  string raw = 13;
+}
+
+message Plain {
+  string name = 1;
+  Normalize rule = 2;
+}
+`
+
+	// storeFile is a proto2 schema, declaring a service with unary, and streaming, methods, with requests that have
+	// required fields, including a request declared in another file (itemFile).
+	storeFile  = `test/v2/store.proto`
+	storeProto = `syntax = "proto2";
+
+package test.v2;
+
+import "test/v2/item.proto";
+
+option go_package = "example.com/protogentest/test/v2;testv2";
+
+message PutRequest {
+  required string key = 1;
+  optional Entry entry = 2;
+  repeated Entry entries = 3;
+}
+
+message Entry {
+  required string id = 1;
+}
+
+service Store {
+  rpc Put(PutRequest) returns (Entry);
+  rpc PutItem(Item) returns (Entry);
+  rpc PutAll(stream PutRequest) returns (stream Entry);
+}
+`
+
+	// holderFile is a proto2 schema, in a nested directory, referencing the types of itemFile.
+	holderFile  = `test/v2/holder/holder.proto`
+	holderProto = `syntax = "proto2";
+
+package test.v2.holder;
+
+import "test/v2/item.proto";
+
+option go_package = "example.com/protogentest/test/v2/holder;holder";
+
+message Holder {
+  required test.v2.Item item = 1;
+  map<string, test.v2.Item.Sub> subs = 2;
+  repeated test.v2.Kind kinds = 3;
+}
+`
+
+	// permissionsFile declares an enum marked as flags, using a custom bool enum option (flags), and an enum that
+	// isn't, the values of which aren't powers of two.
+	permissionsFile  = `test/v1/permissions.proto`
+	permissionsProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+extend google.protobuf.EnumOptions {
+  bool flags = 50050;
+}
+
+enum Permission {
+  option (flags) = true;
+  PERMISSION_NONE = 0;
+  PERMISSION_READ = 1;
+  PERMISSION_WRITE = 2;
+  PERMISSION_ADMIN = 4;
+}
+
+enum Priority {
+  PRIORITY_UNSPECIFIED = 0;
+  PRIORITY_LOW = 1;
+  PRIORITY_MEDIUM = 2;
+  PRIORITY_HIGH = 3;
+}
+
+message Grant {
+  Permission permissions = 1;
+}
+`
+
+	// libraryV2File declares the next version of libraryFile (see also libraryProto), where Book.pages has an
+	// incompatible type, and Book.subtitle is new.
+	libraryV2File  = `test/v2/library.proto`
+	libraryV2Proto = `syntax = "proto3";
+
+package test.v2;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/protogentest/test/v2;testv2";
+
+enum Genre {
+  GENRE_UNSPECIFIED = 0;
+  GENRE_FICTION = 1;
+  GENRE_HISTORY = 2;
+  GENRE_POETRY = 3;
+}
+
+message Book {
+  string name = 1;
+  string title = 2;
+  int64 pages = 3;
+  Genre genre = 4;
+  repeated string tags = 5;
+  map<string, string> labels = 6;
+  google.protobuf.Timestamp published = 7;
+  Nested nested = 8;
+  optional string isbn = 9;
+  oneof format {
+    string ebook_url = 10;
+    int32 print_run = 11;
+  }
+  bytes cover = 12;
+  string subtitle = 13;
+}
+
+message Nested {
+  Deep deep = 1;
+  string note = 2;
+}
+
+message Deep {
+  Book book = 1;
+  repeated Nested children = 2;
+}
+`
+)
+
+var (
+	// generatorTests are the generator outputs covered by golden (see also testdata), build, and stability, tests,
+	// each of which is generated into the package of the input file, where tests are the sources of any tests of the
+	// generated code, keyed by path, and requires are any other modules required by the generated code, see also
+	// runGenerated.
+	generatorTests = [...]struct {
+		name     string
+		sources  map[string]string
+		file     string
+		generate func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile
+		tests    map[string]string
+		requires []string
+	}{
+		{
+			name:    `routing`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				dst := gopoet.NewGoFile(`gen_routing.go`, string(file.GoImportPath), string(file.GoPackageName))
+				for _, element := range (&gopoet_protogen.RouteTableGenerator{Cache: cache}).Elements() {
+					dst.AddElement(element)
+				}
+				return dst
+			},
+		},
+		{
+			name:    `service`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_service.go`, &gopoet_protogen.ServiceMethodsGenerator{Cache: cache, FullNames: true})
+			},
+		},
+		{
+			name:    `diff`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_diff.go`, &gopoet_protogen.DiffGenerator{Cache: cache, FieldMask: true})
+			},
+		},
+		{
+			name:    `merge`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_merge.go`, &gopoet_protogen.MergeGenerator{Cache: cache})
+			},
+		},
+		{
+			name:    `size`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_size.go`, &gopoet_protogen.SizeGenerator{Cache: cache})
+			},
+		},
+		{
+			name:    `validate`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_validate.go`, &gopoet_protogen.ValidatorGenerator{Cache: cache})
+			},
+		},
+		{
+			name:    `debug`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_debug.go`, &gopoet_protogen.DebugStringGenerator{Cache: cache})
+			},
+		},
+		{
+			name:    `httpclient`,
+			sources: httpSources(),
+			file:    httpFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_httpclient.go`, &gopoet_protogen.HTTPClientGenerator{Cache: cache})
+			},
+		},
+		{
+			name:    `fuzz`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_fuzz_test.go`, &gopoet_protogen.FuzzGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v2/fuzz_required_test.go`: `package testv2
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFuzzItem_required(t *testing.T) {
+	// required fields must be set at every depth, including beyond MaxDepth
+	for depth := 0; depth <= 5; depth++ {
+		data := bytes.Repeat([]byte{1}, 4096)
+		if err := proto.CheckInitialized(fuzzItem(&data, depth)); err != nil {
+			t.Errorf("depth %d: %v", depth, err)
+		}
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `random`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_random.go`, &gopoet_protogen.RandomGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v2/random_required_test.go`: `package testv2
+
+import (
+	"math/rand"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewItemForTest_required(t *testing.T) {
+	// required fields must be set at every depth, including beyond MaxDepth
+	for i := int64(0); i < 100; i++ {
+		v := NewItemForTest(rand.New(rand.NewSource(i)))
+		if err := proto.CheckInitialized(v); err != nil {
+			t.Fatalf("seed %d: %v", i, err)
+		}
+		if _, err := proto.Marshal(v); err != nil {
+			t.Fatalf("seed %d: %v", i, err)
+		}
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `enumint`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_enumint.go`, &gopoet_protogen.EnumIntGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v2/enumint_test.go`: `package testv2
+
+import (
+	"testing"
+)
+
+func TestKindFromInt32Checked(t *testing.T) {
+	if v := KindToInt32(Kind_KIND_B); v != 2 {
+		t.Error(v)
+	}
+	if v, err := KindFromInt32Checked(2); err != nil || v != Kind_KIND_B {
+		t.Error(v, err)
+	}
+	if v, err := KindFromInt32Checked(3); err == nil {
+		t.Error(v)
+	}
+	if v := KindFromInt32(3); v != 3 {
+		t.Error(v)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `convert`,
+			sources: map[string]string{libraryFile: libraryProto, libraryV2File: libraryV2Proto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_convert.go`, &gopoet_protogen.ConversionGenerator{
+					Cache: cache,
+					Target: func(v *protogen.Message) *protogen.Message {
+						return cache.Message(`test.v2` + v.Desc.FullName()[len(`test.v1`):])
+					},
+				})
+			},
+			tests: map[string]string{
+				`test/v1/convert_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	testv2 "example.com/protogentest/test/v2"
+)
+
+func TestBookToTestv2(t *testing.T) {
+	isbn := "i"
+	v := &Book{
+		Name:   "a",
+		Pages:  3,
+		Genre:  Genre_GENRE_HISTORY,
+		Tags:   []string{"b"},
+		Labels: map[string]string{"c": "d"},
+		Nested: &Nested{Deep: &Deep{Book: &Book{Title: "e"}}},
+		Isbn:   &isbn,
+		Format: &Book_PrintRun{PrintRun: 7},
+		Cover:  []byte("f"),
+	}
+	r := BookToTestv2(v)
+	expected := &testv2.Book{
+		Name:   "a",
+		Genre:  testv2.Genre_GENRE_HISTORY,
+		Tags:   []string{"b"},
+		Labels: map[string]string{"c": "d"},
+		Nested: &testv2.Nested{Deep: &testv2.Deep{Book: &testv2.Book{Title: "e"}}},
+		Isbn:   &isbn,
+		Format: &testv2.Book_PrintRun{PrintRun: 7},
+		Cover:  []byte("f"),
+	}
+	if !proto.Equal(r, expected) {
+		t.Errorf("unexpected conversion:\n%v\n%v", r, expected)
+	}
+	v.Pages = 0
+	if b := BookFromTestv2(r); !proto.Equal(b, v) {
+		t.Errorf("unexpected conversion:\n%v\n%v", b, v)
+	}
+	if BookToTestv2(nil) != nil {
+		t.Error("expected nil")
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `any`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_any.go`, &gopoet_protogen.AnyGenerator{
+					Cache: cache,
+					Candidates: func(field *protogen.Field) []*protogen.Message {
+						return []*protogen.Message{cache.Message(`test.v2.Item.Sub`), cache.Message(`google.protobuf.Timestamp`)}
+					},
+				})
+			},
+			tests: map[string]string{
+				`test/v2/any_test.go`: `package testv2
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPackItem(t *testing.T) {
+	var (
+		v   = new(Item)
+		sub = &Item_Sub{Id: proto.String("a")}
+		ts  = &timestamppb.Timestamp{Seconds: 1}
+	)
+	if m, err := UnpackItem_Cany(v); m != nil || err != nil {
+		t.Fatal(m, err)
+	}
+	if m, err := UnpackItem_Anys(v); m != nil || err != nil {
+		t.Fatal(m, err)
+	}
+	if err := PackItem_Any(v, sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := PackItem_Cany(v, ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := PackItem_Anys(v, sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := PackItem_Anys(v, ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := PackItem_Anys(v, v); err == nil {
+		t.Fatal("expected error")
+	}
+	if m, err := UnpackItem_Any(v); err != nil || !proto.Equal(m, sub) {
+		t.Error(m, err)
+	}
+	if m, err := UnpackItem_Cany(v); err != nil || !proto.Equal(m, ts) {
+		t.Error(m, err)
+	}
+	if m, err := UnpackItem_Anys(v); err != nil || len(m) != 2 || !proto.Equal(m[0], sub) || !proto.Equal(m[1], ts) {
+		t.Error(m, err)
+	}
+	v.Anys[1].TypeUrl = "type.googleapis.com/test.v2.Item"
+	if m, err := UnpackItem_Anys(v); err == nil {
+		t.Error(m)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `constructor`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_constructor.go`, &gopoet_protogen.ConstructorGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v2/constructor_test.go`: `package testv2
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewItem(t *testing.T) {
+	v := NewItem("a", NewItem_Sub("b"))
+	if err := proto.CheckInitialized(v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetName() != "a" || v.GetRsub().GetId() != "b" {
+		t.Error(v)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `extension`,
+			sources: httpSources(),
+			file:    httpFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				dst := gopoet.NewGoFile(`gen_extension.go`, string(file.GoImportPath), string(file.GoPackageName))
+				dst.AddElement((&gopoet_protogen.ExtensionRegistryGenerator{Cache: cache}).Func())
+				return dst
+			},
+			tests: map[string]string{
+				`test/v1/extension_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestRegisterExtensions(t *testing.T) {
+	types := new(protoregistry.Types)
+	if err := RegisterExtensions(types); err != nil {
+		t.Fatal(err)
+	}
+	if ext, err := types.FindExtensionByName("google.api.http"); err != nil || ext.TypeDescriptor().Number() != 72295728 {
+		t.Error(ext, err)
+	}
+	if err := RegisterExtensions(types); err == nil {
+		t.Error("expected conflict")
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `redact`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_redact.go`, &gopoet_protogen.RedactGenerator{
+					Cache: cache,
+					Sensitive: func(field *protogen.Field) bool {
+						switch field.Desc.Name() {
+						case `isbn`, `tags`, `labels`, `published`, `ebook_url`, `cover`, `note`:
+							return true
+						}
+						return false
+					},
+					Mask: `***`,
+				})
+			},
+			tests: map[string]string{
+				`test/v1/redact_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRedactBook(t *testing.T) {
+	isbn := "i"
+	v := &Book{
+		Name:      "a",
+		Tags:      []string{"b", ""},
+		Labels:    map[string]string{"c": "d"},
+		Published: &timestamppb.Timestamp{Seconds: 1},
+		Nested:    &Nested{Note: "n", Deep: &Deep{Book: &Book{Isbn: &isbn}}},
+		Isbn:      &isbn,
+		Format:    &Book_EbookUrl{EbookUrl: "e"},
+		Cover:     []byte("f"),
+	}
+	RedactBook(v)
+	mask := "***"
+	expected := &Book{
+		Name:   "a",
+		Tags:   []string{"***", ""},
+		Labels: map[string]string{"c": "***"},
+		Nested: &Nested{Note: "***", Deep: &Deep{Book: &Book{Isbn: &mask}}},
+		Isbn:   &mask,
+		Format: &Book_EbookUrl{EbookUrl: "***"},
+	}
+	if !proto.Equal(v, expected) {
+		t.Errorf("unexpected redacted message:\n%v\n%v", v, expected)
+	}
+	RedactBook(nil)
+}
+`,
+			},
+		},
+		{
+			name:    `enumstring`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_enumstring.go`, &gopoet_protogen.EnumStringGenerator{
+					Cache:           cache,
+					CaseInsensitive: true,
+					JSON:            true,
+					Methods:         true,
+				})
+			},
+			tests: map[string]string{
+				`test/v1/enumstring_test.go`: `package testv1
+
+import (
+	"testing"
+)
+
+func TestGenreFromString(t *testing.T) {
+	for _, s := range []string{"GENRE_HISTORY", "genre_history", "2"} {
+		if v, err := GenreFromString(s); err != nil || v != Genre_GENRE_HISTORY {
+			t.Error(s, v, err)
+		}
+	}
+	if v, err := GenreFromString("history"); err == nil {
+		t.Error(v)
+	}
+	if s := Genre_GENRE_HISTORY.StringName(); s != "GENRE_HISTORY" {
+		t.Error(s)
+	}
+	if s := Genre(3).StringName(); s != "" {
+		t.Error(s)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `sql`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_sql.go`, &gopoet_protogen.SQLGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v2/sql_test.go`: `package testv2
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// row scans values like database/sql, for values of the same type
+type row []interface{}
+
+func (x row) Scan(dest ...interface{}) error {
+	for i, v := range x {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func TestScanItem(t *testing.T) {
+	v := &Item{Name: proto.String("a"), Kind: Kind_KIND_B.Enum(), Data: []byte("b")}
+	if columns, values := ItemColumns(), ItemValues(v); len(columns) != len(values) || columns[0] != "name" {
+		t.Fatal(columns, values)
+	}
+	var r Item
+	if err := ScanItem(&r, row(ItemValues(v))); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(&r, v) {
+		t.Errorf("unexpected row:\n%v\n%v", &r, v)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `event`,
+			sources: map[string]string{eventsFile: eventsProto},
+			file:    eventsFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_event.go`,
+					&gopoet_protogen.EventGenerator{Cache: cache, Option: 50001},
+					&gopoet_protogen.EventGenerator{Cache: cache, Option: 50002, SubjectNameStrategy: gopoet_protogen.TopicRecordNameStrategy},
+				)
+			},
+			tests: map[string]string{
+				`test/v1/event_test.go`: `package testv1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type publisher struct {
+	topic string
+	key   []byte
+	value []byte
+}
+
+func (x *publisher) Publish(ctx context.Context, topic string, key, value []byte) error {
+	x.topic, x.key, x.value = topic, key, value
+	return nil
+}
+
+func TestPublishBookCreated(t *testing.T) {
+	if BookCreatedTopic != "books" || BookCreatedSubject != "books-value" {
+		t.Error(BookCreatedTopic, BookCreatedSubject)
+	}
+	if BookDeletedTopic != "test.v1.BookDeleted" || BookDeletedSubject != "test.v1.BookDeleted-test.v1.BookDeleted" {
+		t.Error(BookDeletedTopic, BookDeletedSubject)
+	}
+	var p publisher
+	v := &BookCreated{Name: "a"}
+	if err := PublishBookCreated(context.Background(), &p, []byte("k"), v); err != nil || p.topic != "books" || string(p.key) != "k" {
+		t.Fatal(err, p)
+	}
+	var r *BookCreated
+	handler := ConsumeBookCreated(func(ctx context.Context, v *BookCreated) error {
+		r = v
+		return nil
+	})
+	if err := handler(context.Background(), p.value); err != nil || !proto.Equal(r, v) {
+		t.Error(r, err)
+	}
+	if err := handler(context.Background(), []byte{0xff}); err == nil || errors.Unwrap(err) == nil {
+		t.Error(err)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `graphql`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_graphql.go`, &gopoet_protogen.GraphQLGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/graphql_test.go`: `package testv1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type libraryResolver struct{}
+
+func (libraryResolver) GetBook(ctx context.Context, input *GetBookRequest) (*BookModel, error) {
+	return NewBookModel(&Book{Name: input.GetName()}), nil
+}
+
+func (libraryResolver) ListBooks(ctx context.Context, input *ListBooksRequest) (*ListBooksResponseModel, error) {
+	return NewListBooksResponseModel(&ListBooksResponse{}), nil
+}
+
+var _ LibraryResolver = libraryResolver{}
+
+func TestNewBookModel(t *testing.T) {
+	m := NewBookModel(&Book{
+		Name:      "a",
+		Pages:     3,
+		Genre:     Genre_GENRE_FICTION,
+		Published: &timestamppb.Timestamp{Seconds: 1},
+		Nested:    &Nested{Deep: &Deep{Children: []*Nested{{Note: "b"}}}},
+		Format:    &Book_PrintRun{PrintRun: 7},
+		Cover:     []byte{0xff},
+	})
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = ` + "`" + `{"name":"a","title":"","pages":3,"genre":"GENRE_FICTION","tags":[],"published":"1970-01-01T00:00:01Z","nested":{"deep":{"book":null,"children":[{"deep":null,"note":"b"}]},"note":""},"isbn":null,"cover":"/w==","format":{"value":7}}` + "`" + `
+	if string(b) != expected {
+		t.Errorf("unexpected model:\n%s\n%s", b, expected)
+	}
+	if NewBookModel(nil) != nil {
+		t.Error("expected nil")
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `cel`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_cel.go`, &gopoet_protogen.CELGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/cel_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+)
+
+func TestBookCELEnvOptions(t *testing.T) {
+	env, err := cel.NewEnv(append(BookCELEnvOptions("book"), File_test_v1_library_proto_CELTypes())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err = env.Extend(cel.VariableDecls(NestedCELVariable("nested")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, iss := env.Compile("book.name == nested.deep.book.name && book.genre == test.v1.Genre.GENRE_FICTION")
+	if iss.Err() != nil {
+		t.Fatal(iss.Err())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := prg.Eval(map[string]interface{}{
+		"book":   &Book{Name: "a", Genre: Genre_GENRE_FICTION},
+		"nested": &Nested{Deep: &Deep{Book: &Book{Name: "a"}}},
+	})
+	if err != nil || out.Value() != true {
+		t.Error(out, err)
+	}
+}
+`,
+			},
+			requires: []string{`github.com/google/cel-go v0.26.0`},
+		},
+		{
+			name:    `fake`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_fake.go`, &gopoet_protogen.ServiceFakeGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				libraryGRPCFile: libraryGRPC(),
+				`test/v1/fake_test.go`: `package testv1
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ LibraryServer = (*FakeLibraryServer)(nil)
+
+func TestFakeLibraryServer(t *testing.T) {
+	server := &FakeLibraryServer{
+		GetBookFunc: func(ctx context.Context, req *GetBookRequest) (*Book, error) {
+			return &Book{Name: req.GetName()}, nil
+		},
+		UploadBooksFunc: func(stream Library_UploadBooksServer) error {
+			var response ListBooksResponse
+			for {
+				v, err := stream.Recv()
+				if err == io.EOF {
+					return stream.SendAndClose(&response)
+				}
+				if err != nil {
+					return err
+				}
+				response.Books = append(response.Books, v)
+			}
+		},
+	}
+	if v, err := server.GetBook(context.Background(), &GetBookRequest{Name: "a"}); err != nil || v.GetName() != "a" {
+		t.Error(v, err)
+	}
+	if _, err := server.ListBooks(context.Background(), nil); status.Code(err) != codes.Unimplemented {
+		t.Error(err)
+	}
+	stream := &FakeLibrary_UploadBooksServer{Requests: []*Book{{Name: "b"}, {Name: "c"}}}
+	if err := server.UploadBooks(stream); err != nil || len(stream.Responses) != 1 || len(stream.Responses[0].GetBooks()) != 2 {
+		t.Error(stream.Responses, err)
+	}
+	if stream.Context() == nil {
+		t.Error("expected context")
+	}
+	watch := new(FakeLibrary_WatchBooksServer)
+	if err := watch.Send(&Book{}); err != nil || len(watch.Responses) != 1 {
+		t.Error(watch.Responses, err)
+	}
+	var _ Library_SyncBooksServer = new(FakeLibrary_SyncBooksServer)
+}
+`,
+			},
+			requires: []string{grpcModule},
+		},
+		{
+			name:    `callpolicy`,
+			sources: map[string]string{libraryFile: libraryProto, policyFile: policyProto},
+			file:    policyFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				grpcPkg := gopoet.NewPackage(`google.golang.org/grpc`)
+				return generateFile(file, `gen_callpolicy.go`, &gopoet_protogen.CallPolicyGenerator{
+					TimeoutOption: 50010,
+					CallOptions: func(v *protogen.Method) []*gopoet.CodeBlock {
+						if v.Desc.Name() != `Update` {
+							return nil
+						}
+						return []*gopoet.CodeBlock{gopoet.Printf(`%s(true)`, grpcPkg.Symbol(`WaitForReady`))}
+					},
+					RetryPolicy: &gopoet_protogen.RetryPolicy{
+						MaxAttempts:          3,
+						InitialBackoff:       100 * time.Millisecond,
+						MaxBackoff:           time.Second,
+						BackoffMultiplier:    2,
+						RetryableStatusCodes: []string{`UNAVAILABLE`},
+					},
+				})
+			},
+			tests: map[string]string{
+				`test/v1/callpolicy_test.go`: `package testv1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestPolicies_CallPolicyInterceptor(t *testing.T) {
+	cc, err := grpc.NewClient("passthrough:///localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithDefaultServiceConfig(Policies_ServiceConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	var (
+		deadline time.Time
+		options  []grpc.CallOption
+	)
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		deadline, _ = ctx.Deadline()
+		options = opts
+		return nil
+	}
+	if err := Policies_CallPolicyInterceptor(context.Background(), "/test.v1.Policies/Update", nil, nil, cc, invoker, grpc.Header(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(deadline); d <= 0 || d > 250*time.Millisecond || len(options) != 2 {
+		t.Error(d, options)
+	}
+	// an earlier deadline takes precedence
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	expected, _ := ctx.Deadline()
+	if err := Policies_CallPolicyInterceptor(ctx, "/test.v1.Policies/Get", nil, nil, cc, invoker); err != nil || !deadline.Equal(expected) || len(options) != 0 {
+		t.Error(err, deadline, options)
+	}
+	if err := Policies_CallPolicyInterceptor(context.Background(), "/test.v1.Policies/Delete", nil, nil, cc, invoker); err != nil || !deadline.IsZero() {
+		t.Error(err, deadline)
+	}
+}
+`,
+			},
+			requires: []string{grpcModule},
+		},
+		{
+			name:    `flag`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_flag.go`, &gopoet_protogen.FlagGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/flag_test.go`: `package testv1
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestBookFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	v := BookFlags(fs, "book.")
+	if err := fs.Parse([]string{"-book.title=T", "-book.pages=0x10", "-book.genre=GENRE_HISTORY", "-book.isbn=", "-book.cover=abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if v.Title != "T" || v.Pages != 16 || v.Genre != Genre_GENRE_HISTORY || v.Isbn == nil || *v.Isbn != "" || string(v.Cover) != "abc" || v.Name != "" {
+		t.Error(v)
+	}
+	if s := fs.Lookup("book.genre").Value.String(); s != "GENRE_HISTORY" {
+		t.Error(s)
+	}
+	if fs.Lookup("book.tags") != nil || fs.Lookup("book.ebookUrl") != nil {
+		t.Error("unexpected flag")
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(new(nopWriter))
+	BookFlags(fs, "")
+	for _, args := range [][]string{{"-genre=GENRE_OTHER"}, {"-pages=x"}} {
+		if err := fs.Parse(args); err == nil {
+			t.Error(args)
+		}
+	}
+}
+
+type nopWriter struct{}
+
+func (*nopWriter) Write(b []byte) (int, error) { return len(b), nil }
+`,
+			},
+		},
+		{
+			name:    `flag_pflag`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_flag.go`, &gopoet_protogen.FlagGenerator{Cache: cache, PFlag: true})
+			},
+			tests: map[string]string{
+				`test/v2/flag_test.go`: `package testv2
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestItemFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	v := ItemFlags(fs, "")
+	if err := fs.Parse([]string{"--name=N", "--kind=KIND_B", "--ratio=0.25"}); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetName() != "N" || v.GetKind() != Kind_KIND_B || v.GetRatio() != 0.25 || v.Data != nil {
+		t.Error(v)
+	}
+	if typ := fs.Lookup("kind").Value.Type(); typ != "Kind" {
+		t.Error(typ)
+	}
+	fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	s := Item_SubFlags(fs, "sub-")
+	if s.Count != nil {
+		t.Error(s)
+	}
+	if err := fs.Parse([]string{"--sub-count", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.GetCount() != 3 || s.Id != nil {
+		t.Error(s)
+	}
+}
+`,
+			},
+			requires: []string{`github.com/spf13/pflag v1.0.5`},
+		},
+		{
+			name:    `env`,
+			sources: map[string]string{envFile: envProto},
+			file:    envFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_env.go`, &gopoet_protogen.EnvGenerator{Cache: cache, Option: 50020})
+			},
+			tests: map[string]string{
+				`test/v1/env_test.go`: `package testv1
+
+import (
+	"testing"
+)
+
+func TestLoadServerFromEnv(t *testing.T) {
+	t.Setenv("TEST_HOST_NAME", "localhost")
+	t.Setenv("TEST_PORT", "8080")
+	t.Setenv("TEST_TLS", "true")
+	t.Setenv("TEST_LEVEL", "LEVEL_INFO")
+	t.Setenv("TEST_PEERS", "ignored")
+	v, err := LoadServerFromEnv("TEST_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Host != "localhost" || v.Port != 8080 || !v.Tls || v.Ratio != nil || v.Level != Level_LEVEL_INFO || v.Peers != nil {
+		t.Error(v)
+	}
+	t.Setenv("TEST_RATIO", "0")
+	if v, err := LoadServerFromEnv("TEST_"); err != nil || v.Ratio == nil || *v.Ratio != 0 {
+		t.Error(v, err)
+	}
+	t.Setenv("TEST_PORT", "-1")
+	if _, err := LoadServerFromEnv("TEST_"); err == nil || err.Error()[:22] != "invalid TEST_PORT: str" {
+		t.Error(err)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `config`,
+			sources: map[string]string{envFile: envProto},
+			file:    envFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_config.go`, &gopoet_protogen.ConfigGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/config_test.go`: `package testv1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type config struct {
+	Server *Server ` + "`json:\"server\" yaml:\"server\"`" + `
+}
+
+func TestServer_UnmarshalJSON(t *testing.T) {
+	var c config
+	if err := json.Unmarshal([]byte(` + "`{\"server\": {\"host\": \"h\", \"port\": 1, \"level\": \"LEVEL_DEBUG\", \"fallback\": {\"port\": 2}}}`" + `), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.GetHost() != "h" || c.Server.GetPort() != 1 || c.Server.GetLevel() != Level_LEVEL_DEBUG || c.Server.GetFallback().GetPort() != 2 {
+		t.Error(c.Server)
+	}
+	if err := json.Unmarshal([]byte(` + "`{\"server\": {\"unknown\": 1}}`" + `), &c); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestServer_UnmarshalYAML(t *testing.T) {
+	var c config
+	if err := yaml.Unmarshal([]byte("server:\n  host: h\n  ratio: 0.5\n  peers: [a, b]\n  fallback:\n    level: LEVEL_INFO\n"), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.GetHost() != "h" || c.Server.Ratio == nil || *c.Server.Ratio != 0.5 || len(c.Server.GetPeers()) != 2 || c.Server.GetFallback().GetLevel() != Level_LEVEL_INFO {
+		t.Error(c.Server)
+	}
+	if err := yaml.Unmarshal([]byte("server:\n  port: x\n"), &c); err == nil {
+		t.Error("expected error")
+	}
+}
+`,
+			},
+			requires: []string{`gopkg.in/yaml.v3 v3.0.1`},
+		},
+		{
+			name:    `iter`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_iter.go`, &gopoet_protogen.IterGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/iter_test.go`: `package testv1
+
+import (
+	"testing"
+)
+
+func TestRangeBook_Tags(t *testing.T) {
+	var tags []string
+	RangeBook_Tags(&Book{Tags: []string{"a", "b", "c"}})(func(v string) bool {
+		tags = append(tags, v)
+		return len(tags) != 2
+	})
+	if len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Error(tags)
+	}
+	RangeBook_Tags(nil)(func(v string) bool {
+		t.Error(v)
+		return true
+	})
+	labels := make(map[string]string)
+	RangeBook_Labels(&Book{Labels: map[string]string{"k1": "v1", "k2": "v2"}})(func(k, v string) bool {
+		labels[k] = v
+		return true
+	})
+	if len(labels) != 2 || labels["k1"] != "v1" || labels["k2"] != "v2" {
+		t.Error(labels)
+	}
+	var n int
+	RangeListBooksResponse_Books(&ListBooksResponse{Books: []*Book{{}, {}}})(func(v *Book) bool {
+		n++
+		return true
+	})
+	if n != 2 {
+		t.Error(n)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `iter_methods`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_iter.go`, &gopoet_protogen.IterGenerator{Cache: cache, Methods: true})
+			},
+			tests: map[string]string{
+				`test/v2/iter_test.go`: `package testv2
+
+import (
+	"testing"
+)
+
+func TestItem_RangeSubMap(t *testing.T) {
+	var ids []string
+	(&Item{SubMap: map[string]*Item_Sub{"a": {Id: new(string)}}}).RangeSubMap(func(k string, v *Item_Sub) bool {
+		ids = append(ids, k)
+		return v != nil
+	})
+	if len(ids) != 1 || ids[0] != "a" {
+		t.Error(ids)
+	}
+	var sum int32
+	(*Item)(nil).RangeNumbers(func(v int32) bool {
+		t.Error(v)
+		return true
+	})
+	(&Item{Numbers: []int32{1, 2, 3}}).RangeNumbers(func(v int32) bool {
+		sum += v
+		return true
+	})
+	if sum != 6 {
+		t.Error(sum)
+	}
+	names := make(map[int32]string)
+	(&Item{Names: map[int32]string{1: "one"}}).RangeNames(func(k int32, v string) bool {
+		names[k] = v
+		return true
+	})
+	if names[1] != "one" {
+		t.Error(names)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `msgpool`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_msgpool.go`, &gopoet_protogen.MessagePoolGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/msgpool_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestResetBook(t *testing.T) {
+	isbn := "isbn"
+	v := AcquireBook()
+	v.Name = "name"
+	v.Pages = 3
+	v.Genre = Genre_GENRE_FICTION
+	v.Tags = append(v.Tags, "a", "b")
+	v.Labels = map[string]string{"k": "v"}
+	v.Published = timestamppb.Now()
+	v.Nested = &Nested{Note: "note"}
+	v.Isbn = &isbn
+	v.Format = &Book_PrintRun{PrintRun: 1}
+	v.Cover = []byte("cover")
+	v.ProtoReflect().SetUnknown([]byte{0xf8, 0x3e, 0x01})
+	tags, labels := v.Tags, v.Labels
+	ResetBook(v)
+	if !proto.Equal(v, new(Book)) || v.ProtoReflect().GetUnknown() != nil {
+		t.Error(v)
+	}
+	if cap(v.Tags) != cap(tags) || v.Labels == nil || len(labels) != 0 {
+		t.Error(v.Tags, v.Labels)
+	}
+	ResetBook(nil)
+	ReleaseBook(v)
+	ReleaseBook(nil)
+	if v := AcquireBook(); !proto.Equal(v, new(Book)) {
+		t.Error(v)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `registry`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				var (
+					dst = gopoet.NewGoFile(`gen_registry.go`, string(file.GoImportPath), string(file.GoPackageName))
+					reg = &gopoet_protogen.FileRegistryGenerator{Cache: cache, Registry: dst.Package().Symbol(`Files`)}
+				)
+				dst.AddElement(gopoet.NewVarDecl(gopoet.NewVar(`Files`).SetComment(`Files is populated by init.`).
+					SetInitializer(gopoet.Printf(`new(%s)`, gopoet.NewPackage(`google.golang.org/protobuf/reflect/protoregistry`).Symbol(`Files`)))))
+				dst.AddElement(reg.Func())
+				dst.AddElement(reg.InitFunc())
+				return dst
+			},
+			tests: map[string]string{
+				`test/v1/registry_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestRegisterFiles(t *testing.T) {
+	if n := Files.NumFiles(); n != 2 {
+		t.Error(n)
+	}
+	if d, err := Files.FindDescriptorByName("test.v1.Book"); err != nil || d != (*Book)(nil).ProtoReflect().Descriptor() {
+		t.Error(d, err)
+	}
+	if _, err := Files.FindFileByPath("google/protobuf/timestamp.proto"); err != nil {
+		t.Error(err)
+	}
+	if err := RegisterFiles(Files); err != nil {
+		t.Error(err)
+	}
+	if err := RegisterFiles(new(protoregistry.Files)); err != nil {
+		t.Error(err)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `errorreason`,
+			sources: map[string]string{errorsFile: errorsProto},
+			file:    errorsFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_errorreason.go`, &gopoet_protogen.ErrorReasonGenerator{
+					Cache:      cache,
+					Option:     50031,
+					CodeOption: 50030,
+					Domain: func(v *protogen.Enum) string {
+						if v.Desc.Name() == `Quota` {
+							return `quota.example.com`
+						}
+						return `books.example.com`
+					},
+				})
+			},
+			tests: map[string]string{
+				`test/v1/errorreason_test.go`: `package testv1
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBookErrorReasonError(t *testing.T) {
+	base := &BookErrorReasonError{Reason: BookErrorReason_BOOK_NOT_FOUND, Message: "no such book", Metadata: map[string]string{"name": "b"}}
+	err := fmt.Errorf("wrapped: %w", base)
+	if !errors.Is(err, ErrBookErrorReason_BOOK_NOT_FOUND) || errors.Is(err, ErrBookErrorReason_BOOK_EXISTS) {
+		t.Error(err)
+	}
+	if err.Error() != "wrapped: BOOK_NOT_FOUND: no such book" {
+		t.Error(err)
+	}
+	for reason, code := range map[BookErrorReason]codes.Code{
+		BookErrorReason_BOOK_NOT_FOUND: codes.NotFound,
+		BookErrorReason_BOOK_EXISTS:    codes.AlreadyExists,
+		BookErrorReason_BOOK_LOCKED:    codes.Canceled,
+		BookErrorReason_BOOK_MISSING:   codes.NotFound,
+		BookErrorReason_BOOK_OTHER:     codes.Unknown,
+	} {
+		if c := status.Code(&BookErrorReasonError{Reason: reason}); c != code {
+			t.Error(reason, c)
+		}
+	}
+	// round trip via a status error, e.g. from a gRPC client
+	e, ok := BookErrorReasonErrorFromError(status.ErrorProto(status.Convert(base).Proto()))
+	if !ok || e.Reason != BookErrorReason_BOOK_NOT_FOUND || e.Message != "no such book" || e.Metadata["name"] != "b" {
+		t.Error(e, ok)
+	}
+	if e, ok := BookErrorReasonErrorFromError(status.Error(codes.NotFound, "not found")); ok {
+		t.Error(e)
+	}
+	if e, ok := BookErrorReasonErrorFromError(ErrQuota_QUOTA_EXCEEDED); ok {
+		t.Error(e)
+	}
+	if e, ok := QuotaErrorFromError(status.Convert(ErrQuota_QUOTA_EXCEEDED).Err()); !ok || e.Reason != Quota_QUOTA_EXCEEDED || e.Code() != codes.ResourceExhausted {
+		t.Error(e, ok)
+	}
+}
+`,
+			},
+			requires: []string{grpcModule},
+		},
+		{
+			name:    `pager`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_pager.go`, &gopoet_protogen.PagerGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				libraryGRPCFile: libraryGRPC(),
+				`test/v1/pager_test.go`: `package testv1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+// the constructor accepts the method of the client
+var _ = func(c LibraryClient) *Library_ListBooksPager {
+	return NewLibrary_ListBooksPager(c.ListBooks, nil)
+}
+
+func TestLibrary_ListBooksPager(t *testing.T) {
+	var (
+		pages = map[string]*ListBooksResponse{
+			"":   {Books: []*Book{{Name: "a"}, {Name: "b"}}, NextPageToken: "p2"},
+			"p2": {Books: []*Book{{Name: "c"}}, NextPageToken: "p3"},
+			"p3": {},
+		}
+		tokens  []string
+		failure error
+	)
+	fetch := func(ctx context.Context, req *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error) {
+		if req.PageSize != 2 || len(opts) != 1 {
+			t.Error(req, opts)
+		}
+		tokens = append(tokens, req.PageToken)
+		if failure != nil {
+			return nil, failure
+		}
+		return pages[req.PageToken], nil
+	}
+	req := &ListBooksRequest{PageSize: 2}
+	pager := NewLibrary_ListBooksPager(fetch, req, grpc.WaitForReady(true))
+	var names []string
+	pager.All(context.Background())(func(v *Book, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, v.Name)
+		return true
+	})
+	if len(names) != 3 || names[2] != "c" || len(tokens) != 3 || tokens[2] != "p3" || req.PageToken != "" {
+		t.Error(names, tokens, req)
+	}
+	if page, err := pager.NextPage(context.Background()); page != nil || err != io.EOF {
+		t.Error(page, err)
+	}
+	// iteration stops early, and after an error
+	pager = NewLibrary_ListBooksPager(fetch, req, grpc.WaitForReady(true))
+	pager.All(context.Background())(func(v *Book, err error) bool {
+		return false
+	})
+	if page, err := pager.NextPage(context.Background()); err != nil || len(page.Books) != 1 {
+		t.Error(page, err)
+	}
+	failure = errors.New("failure")
+	var errs []error
+	pager.All(context.Background())(func(v *Book, err error) bool {
+		errs = append(errs, err)
+		return true
+	})
+	if len(errs) != 1 || errs[0] != failure {
+		t.Error(errs)
+	}
+}
+`,
+			},
+			requires: []string{grpcModule},
+		},
+		{
+			name:    `resource`,
+			sources: map[string]string{resourcesFile: resourcesProto, `google/api/resource.proto`: resourceProto},
+			file:    resourcesFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_resource.go`, &gopoet_protogen.ResourceNameGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/resource_test.go`: `package testv1
+
+import (
+	"testing"
+)
+
+func TestParseVolumeName(t *testing.T) {
+	name, err := ParseShelfName("shelves/s1")
+	if err != nil || name.Shelf != "s1" || name.String() != "shelves/s1" {
+		t.Error(name, err)
+	}
+	if name, err := ShelfNameOf(&Shelf{Name: "shelves/s1"}); err != nil || name.Shelf != "s1" {
+		t.Error(name, err)
+	}
+	v1, err := VolumeName_ShelfVolumeOf(&Volume{Path: "shelves/s1/volumes/v1"})
+	if err != nil || v1 != (VolumeName_ShelfVolume{Shelf: "s1", Volume: "v1"}) || v1.String() != "shelves/s1/volumes/v1" {
+		t.Error(v1, err)
+	}
+	v2, err := ParseVolumeName_PublisherIdVolume("publishers/p1/volumes/v1")
+	if err != nil || v2.PublisherId != "p1" || v2.Volume != "v1" || v2.String() != "publishers/p1/volumes/v1" {
+		t.Error(v2, err)
+	}
+	for _, s := range []string{"", "shelves/s1", "shelves//volumes/v1", "shelves/s1/volumes/v1/x", "shelves/s1/books/v1", "publishers/p1/volumes/v1"} {
+		if VolumeName_ShelfVolumeMatchesPattern(s) {
+			t.Error(s)
+		}
+	}
+	if _, err := ParseShelfName("shelves/"); err == nil || err.Error() != ` + "`invalid test.v1.Shelf name \"shelves/\", expected pattern \"shelves/{shelf}\"`" + ` {
+		t.Error(err)
+	}
+}
+`,
+			},
+		},
+		{
+			name: `operation`,
+			sources: map[string]string{
+				libraryFile:                           libraryProto,
+				operationsFile:                        operationsProto,
+				`google/longrunning/operations.proto`: longrunningProto,
+				`google/rpc/status.proto`:             statusProto,
+			},
+			file: operationsFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_operation.go`, &gopoet_protogen.OperationGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/operation_test.go`: `package testv1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestOperations_CreateBookOperation(t *testing.T) {
+	metadata, _ := anypb.New(&CreateBookMetadata{Progress: 50})
+	response, _ := anypb.New(&Book{Name: "b"})
+	var polls int
+	get := func(ctx context.Context, req *longrunningpb.GetOperationRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error) {
+		polls++
+		if req.Name != "operations/1" || len(opts) != 1 {
+			t.Error(req, opts)
+		}
+		if polls == 1 {
+			return &longrunningpb.Operation{Name: req.Name, Metadata: metadata}, nil
+		}
+		return &longrunningpb.Operation{Name: req.Name, Done: true, Result: &longrunningpb.Operation_Response{Response: response}}, nil
+	}
+	op := NewOperations_CreateBookOperation(&longrunningpb.Operation{Name: "operations/1"}, get, grpc.WaitForReady(true))
+	if v, err := op.Response(); v != nil || err != nil || op.Done() {
+		t.Error(v, err)
+	}
+	if v, err := op.Metadata(); v != nil || err != nil {
+		t.Error(v, err)
+	}
+	if err := op.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := op.Metadata(); err != nil || v.GetProgress() != 50 {
+		t.Error(v, err)
+	}
+	v, err := op.Wait(context.Background(), time.Millisecond)
+	if err != nil || v.GetName() != "b" || polls != 2 || !op.Done() {
+		t.Error(v, err, polls)
+	}
+	if err := op.Poll(context.Background()); err != nil || polls != 2 {
+		t.Error(err, polls)
+	}
+}
+
+func TestOperations_DeleteBookOperation(t *testing.T) {
+	op := NewOperations_DeleteBookOperation(&longrunningpb.Operation{Done: true, Result: &longrunningpb.Operation_Error{Error: &status.Status{Code: int32(codes.NotFound), Message: "not found"}}}, nil)
+	if v, err := op.Response(); v != nil || grpcstatus.Code(err) != codes.NotFound {
+		t.Error(v, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	op = NewOperations_DeleteBookOperation(&longrunningpb.Operation{}, nil)
+	if v, err := op.Wait(ctx, time.Hour); v != nil || err != context.Canceled {
+		t.Error(v, err)
+	}
+}
+`,
+			},
+			requires: []string{grpcModule, `cloud.google.com/go/longrunning v0.5.7`},
+		},
+		{
+			name:    `normalize`,
+			sources: map[string]string{normalizeFile: normalizeProto},
+			file:    normalizeFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_normalize.go`, &gopoet_protogen.NormalizeGenerator{Cache: cache, Option: 50040})
+			},
+			tests: map[string]string{
+				`test/v1/normalize_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNormalizeProfile(t *testing.T) {
+	level := uint32(20)
+	v := &Profile{
+		Email:    " A@B.C ",
+		Tags:     []string{" b", "a "},
+		Age:      -1,
+		Score:    0.25,
+		Labels:   map[string]string{"k": " v "},
+		Level:    &level,
+		Parent:   &Profile{Email: "P", Age: 200},
+		Children: []*Profile{{Email: "C"}},
+		Friends:  map[string]*Profile{"f": {Email: "F"}},
+		Contact:  &Profile_Phone{Phone: " 123 "},
+		Roles:    []Role{Role_ROLE_WRITER, Role_ROLE_READER},
+		Raw:      " Raw ",
+	}
+	NormalizeProfile(v)
+	expected := &Profile{
+		Email:    "a@b.c",
+		Tags:     []string{"A", "B"},
+		Score:    0.5,
+		Labels:   map[string]string{"k": "v"},
+		Level:    proto.Uint32(10),
+		Parent:   &Profile{Email: "p", Age: 150, Score: 0.5},
+		Children: []*Profile{{Email: "c", Score: 0.5}},
+		Friends:  map[string]*Profile{"f": {Email: "f", Score: 0.5}},
+		Contact:  &Profile_Phone{Phone: "123"},
+		Roles:    []Role{Role_ROLE_READER, Role_ROLE_WRITER},
+		Raw:      " Raw ",
+	}
+	if !proto.Equal(v, expected) {
+		t.Errorf("unexpected: %v", v)
+	}
+	v = &Profile{Contact: &Profile_Manager{Manager: &Profile{Email: "M"}}}
+	NormalizeProfile(v)
+	if v.GetManager().GetEmail() != "m" {
+		t.Error(v)
+	}
+	NormalizeProfile(nil)
+	NormalizePlain(&Plain{})
+}
+`,
+			},
+		},
+		{
+			name:    `interceptor`,
+			sources: map[string]string{itemFile: itemProto, storeFile: storeProto},
+			file:    storeFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_interceptor.go`,
+					&gopoet_protogen.ValidatorGenerator{Cache: cache},
+					&gopoet_protogen.ValidationInterceptorGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v2/interceptor_test.go`: `package testv2
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func violation(t *testing.T, err error) string {
+	t.Helper()
+	s := status.Convert(err)
+	if s.Code() != codes.InvalidArgument || len(s.Details()) != 1 {
+		t.Fatal(err)
+	}
+	return s.Details()[0].(*errdetails.BadRequest).GetFieldViolations()[0].GetField()
+}
+
+func TestStore_ValidationUnaryServerInterceptor(t *testing.T) {
+	var calls int
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return req, nil
+	}
+	put := &grpc.UnaryServerInfo{FullMethod: "/test.v2.Store/Put"}
+	if _, err := Store_ValidationUnaryServerInterceptor(context.Background(), &PutRequest{Key: proto.String("k")}, put, handler); err != nil || calls != 1 {
+		t.Fatal(err, calls)
+	}
+	_, err := Store_ValidationUnaryServerInterceptor(context.Background(), &PutRequest{Key: proto.String("k"), Entries: []*Entry{{Id: proto.String("a")}, {}}}, put, handler)
+	if field := violation(t, err); field != "entries[1].id" || calls != 1 {
+		t.Error(field, calls)
+	}
+	_, err = Store_ValidationUnaryServerInterceptor(context.Background(), &PutRequest{Entry: &Entry{}}, put, handler)
+	if field := violation(t, err); field != "key" {
+		t.Error(field)
+	}
+	_, err = Store_ValidationUnaryServerInterceptor(context.Background(), &Item{}, &grpc.UnaryServerInfo{FullMethod: "/test.v2.Store/PutItem"}, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error(err)
+	}
+	// other methods are passed through
+	if _, err := Store_ValidationUnaryServerInterceptor(context.Background(), &PutRequest{}, &grpc.UnaryServerInfo{FullMethod: "/other.Service/Put"}, handler); err != nil || calls != 2 {
+		t.Error(err, calls)
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	messages []*PutRequest
+}
+
+func (x *serverStream) RecvMsg(m interface{}) error {
+	if len(x.messages) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(*PutRequest), x.messages[0])
+	x.messages = x.messages[1:]
+	return nil
+}
+
+func TestStore_ValidationStreamServerInterceptor(t *testing.T) {
+	var errs []error
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		for {
+			err := ss.RecvMsg(new(PutRequest))
+			if err == io.EOF {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	ss := &serverStream{messages: []*PutRequest{{Key: proto.String("k")}, {Key: proto.String("k"), Entry: &Entry{}}}}
+	if err := Store_ValidationStreamServerInterceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/test.v2.Store/PutAll"}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 || errs[0] != nil || violation(t, errs[1]) != "entry.id" {
+		t.Error(errs)
+	}
+}
+`,
+			},
+			requires: []string{grpcModule},
+		},
+		{
+			name:    `wrapper`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				var (
+					dst = gopoet.NewGoFile(`gen_wrapper.go`, string(file.GoImportPath)+`/domain`, `domain`)
+					g   = &gopoet_protogen.WrapperGenerator{
+						Cache: cache,
+						Methods: func(v *protogen.Message) []gopoet.MethodType {
+							if v.Desc.Name() != `Book` {
+								return nil
+							}
+							return []gopoet.MethodType{{Name: `Summary`, Signature: gopoet.Signature{
+								Args:    []gopoet.ArgType{{Name: `n`, Type: gopoet.IntType}},
+								Results: []gopoet.ArgType{{Type: gopoet.StringType}},
+							}}}
+						},
+					}
+				)
+				gopoet_protogen.Generate(dst, file, g)
+				for _, v := range g.Stubs(file) {
+					dst.AddElement(v)
+				}
+				return dst
+			},
+			tests: map[string]string{
+				`test/v1/domain/wrapper_test.go`: `package domain
+
+import (
+	"testing"
+
+	testv1 "example.com/protogentest/test/v1"
+)
+
+func TestWrapBook(t *testing.T) {
+	v := WrapBook(&testv1.Book{Title: "t", Nested: &testv1.Nested{Note: "n"}})
+	if v.GetTitle() != "t" || v.Title != "t" || WrapNested(v.GetNested()).GetNote() != "n" {
+		t.Error(v)
+	}
+	if v := WrapBook(nil); v.GetTitle() != "" || v.Book != nil {
+		t.Error(v)
+	}
+	var m BookMethods = v
+	defer func() {
+		if r := recover(); r != "not implemented: Book.Summary" {
+			t.Error(r)
+		}
+	}()
+	m.Summary(1)
+}
+`,
+			},
+		},
+		{
+			name:    `fixture`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_fixture.go`, &gopoet_protogen.FixtureGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/testdata/fixtures/test.v1.Book/basic.textproto`:   "name: \"books/1\"\ntitle: \"Basic\"\ngenre: GENRE_FICTION\npublished { seconds: 1 }\n",
+				`test/v1/testdata/fixtures/test.v1.Book/invalid.textproto`: "unknown: 1\n",
+				`test/v1/fixture_test.go`: `package testv1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadBookFixture(t *testing.T) {
+	v := LoadBookFixture("basic")
+	if v.GetName() != "books/1" || v.GetTitle() != "Basic" || v.GetGenre() != Genre_GENRE_FICTION || v.GetPublished().GetSeconds() != 1 {
+		t.Error(v)
+	}
+	for _, name := range [...]string{"missing", "invalid"} {
+		func() {
+			defer func() {
+				if r, _ := recover().(error); r == nil || !strings.HasPrefix(r.Error(), "LoadBookFixture: \""+name+"\": ") {
+					t.Error(name, r)
+				}
+			}()
+			LoadBookFixture(name)
+		}()
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `exhaustive`,
+			sources: map[string]string{envFile: envProto},
+			file:    envFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_exhaustive.go`, &gopoet_protogen.EnumExhaustiveGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v1/exhaustive_test.go`: `package testv1
+
+import (
+	"testing"
+)
+
+func TestLevelSwitch(t *testing.T) {
+	var called []string
+	for _, v := range [...]Level{Level_LEVEL_INFO, Level_LEVEL_VERBOSE, Level_LEVEL_UNSPECIFIED, 7} {
+		LevelSwitch(v,
+			func() { called = append(called, "unspecified") },
+			func() { called = append(called, "debug") },
+			func() { called = append(called, "info") },
+		)
+	}
+	if len(called) != 3 || called[0] != "info" || called[1] != "debug" || called[2] != "unspecified" {
+		t.Error(called)
+	}
+}
+
+func TestLevelAssertExhaustive(t *testing.T) {
+	LevelAssertExhaustive(Level_LEVEL_UNSPECIFIED, Level_LEVEL_VERBOSE, Level_LEVEL_INFO)
+	defer func() {
+		if r := recover(); r != "unhandled test.v1.Level values: LEVEL_UNSPECIFIED, LEVEL_INFO" {
+			t.Error(r)
+		}
+	}()
+	LevelAssertExhaustive(Level_LEVEL_DEBUG)
+}
+`,
+			},
+		},
+		{
+			name:    `flags`,
+			sources: map[string]string{permissionsFile: permissionsProto},
+			file:    permissionsFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_flags.go`, &gopoet_protogen.FlagEnumGenerator{Cache: cache, Option: 50050})
+			},
+			tests: map[string]string{
+				`test/v1/flags_test.go`: `package testv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPermissionFlags(t *testing.T) {
+	var v PermissionFlags
+	if v.String() != "PERMISSION_NONE" || v.Has(Permission_PERMISSION_READ) || !v.Has(Permission_PERMISSION_NONE) {
+		t.Error(v)
+	}
+	v = v.Set(Permission_PERMISSION_READ).Set(Permission_PERMISSION_ADMIN)
+	if !v.Has(Permission_PERMISSION_READ) || v.Has(Permission_PERMISSION_WRITE) || v.String() != "PERMISSION_READ|PERMISSION_ADMIN" {
+		t.Error(v)
+	}
+	// the set is stored in a field of the enum type, and round trips
+	b, err := proto.Marshal(&Grant{Permissions: v.Enum()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var grant Grant
+	if err := proto.Unmarshal(b, &grant); err != nil || PermissionFlags(grant.Permissions) != v {
+		t.Error(&grant, err)
+	}
+	if v := v.Clear(Permission_PERMISSION_READ) | 0x18; v.String() != "PERMISSION_ADMIN|0x18" {
+		t.Error(v)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `idempotency`,
+			sources: map[string]string{libraryFile: libraryProto, policyFile: policyProto},
+			file:    policyFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_idempotency.go`, &gopoet_protogen.IdempotencyGenerator{})
+			},
+			tests: map[string]string{
+				`test/v1/idempotency_test.go`: `package testv1
+
+import (
+	"testing"
+)
+
+func TestPolicies_IdempotentMethods(t *testing.T) {
+	for method, expected := range map[string][2]bool{
+		"/test.v1.Policies/Get":    {true, true},
+		"/test.v1.Policies/Update": {false, false},
+		"/test.v1.Policies/Delete": {false, true},
+	} {
+		safe, ok1 := Policies_SafeMethods[method]
+		idempotent, ok2 := Policies_IdempotentMethods[method]
+		if !ok1 || !ok2 || safe != expected[0] || idempotent != expected[1] {
+			t.Error(method, safe, idempotent)
+		}
+	}
+	if len(Policies_SafeMethods) != 3 || len(Policies_IdempotentMethods) != 3 {
+		t.Error(Policies_SafeMethods, Policies_IdempotentMethods)
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `sealed`,
+			sources: map[string]string{itemFile: itemProto},
+			file:    itemFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_sealed.go`, &gopoet_protogen.SealedOneofGenerator{Cache: cache})
+			},
+			tests: map[string]string{
+				`test/v2/sealed_test.go`: `package testv2
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func describe(v ItemChoice) string {
+	switch v := v.(type) {
+	case nil:
+		return "none"
+	case *ItemChoiceCname:
+		return "name " + v.Cname
+	case *ItemChoiceCsub:
+		return "sub " + v.Csub.GetId()
+	case *ItemChoiceCany:
+		return "any " + v.Cany.GetTypeUrl()
+	}
+	panic("unreachable")
+}
+
+func TestToItemChoice(t *testing.T) {
+	for expected, v := range map[string]*Item{
+		"none":     {},
+		"name n":   {Choice: &Item_Cname{Cname: "n"}},
+		"sub s":    {Choice: &ItemChoiceCsub{Csub: &Item_Sub{Id: proto.String("s")}}},
+		"any type": {Choice: &Item_Cany{Cany: &anypb.Any{TypeUrl: "type"}}},
+	} {
+		if s := describe(ToItemChoice(v.GetChoice())); s != expected {
+			t.Error(expected, s)
+		}
+	}
+	if ToItemChoice(nil) != nil {
+		t.Error("expected nil")
+	}
+}
+`,
+			},
+		},
+		{
+			name:    `structmap`,
+			sources: map[string]string{libraryFile: libraryProto},
+			file:    libraryFile,
+			generate: func(cache *gopoet_protogen.Cache, file *protogen.File) *gopoet.GoFile {
+				return generateFile(file, `gen_structmap.go`, &gopoet_protogen.StructMapGenerator{Cache: cache, Target: structMapTarget()})
+			},
+			tests: map[string]string{
+				`test/v1/model/model.go`: `package model
+
+import "time"
+
+type Book struct {
+	Name      string
+	Heading   string ` + "`proto:\"title\"`" + `
+	Pages     int
+	Genre     string
+	Tags      []string
+	Labels    map[string]string
+	Published time.Time
+	Nested    *Nested
+	Isbn      string
+	Cover     []byte
+	Internal  string ` + "`proto:\"-\"`" + `
+}
+
+type Nested struct {
+	Note string
+}
+`,
+				`test/v1/structmap_test.go`: `package testv1
+
+import (
+	"testing"
+	"time"
+
+	"example.com/protogentest/test/v1/model"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestBookToBook(t *testing.T) {
+	published := time.Unix(1700000000, 0).UTC()
+	v := &Book{
+		Name:      "n",
+		Title:     "t",
+		Pages:     42,
+		Genre:     Genre_GENRE_HISTORY,
+		Tags:      []string{"a", "b"},
+		Labels:    map[string]string{"k": "v"},
+		Published: timestamppb.New(published),
+		Nested:    &Nested{Note: "note"},
+		Isbn:      proto.String("isbn"),
+		Cover:     []byte("cover"),
+	}
+	m := BookToBook(v)
+	if m.Name != "n" || m.Heading != "t" || m.Pages != 42 || m.Genre != "GENRE_HISTORY" ||
+		len(m.Tags) != 2 || m.Tags[1] != "b" || m.Labels["k"] != "v" || !m.Published.Equal(published) ||
+		m.Nested == nil || m.Nested.Note != "note" || m.Isbn != "isbn" || string(m.Cover) != "cover" {
+		t.Errorf("%+v", m)
+	}
+	if r := BookFromBook(m); !proto.Equal(r, v) {
+		t.Error(r)
+	}
+	if BookToBook(nil) != nil || BookFromBook(nil) != nil {
+		t.Error("expected nil")
+	}
+	if r := BookFromBook(&model.Book{Internal: "x"}); r.Nested != nil || r.Isbn == nil || r.GetIsbn() != "" {
+		t.Error(r)
+	}
+}
+`,
+			},
+		},
+	}
+
+	// documentTests are the generator outputs, other than golang files, covered by golden (see also testdata), and
+	// stability, tests, where check validates the output, in place of the build tests, see also generatorTests.
+	documentTests = [...]struct {
+		name     string
+		sources  map[string]string
+		generate func(t testing.TB, result *protogentest.Result) []byte
+		check    func(t *testing.T, output []byte)
+	}{
+		{
+			name:    `openapi`,
+			sources: map[string]string{libraryFile: libraryProto},
+			generate: func(t testing.TB, result *protogentest.Result) []byte {
+				b, err := (&gopoet_protogen.OpenAPIGenerator{}).Document(`Library`, `v1`, result.File(libraryFile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return b
+			},
+			check: func(t *testing.T, output []byte) {
+				var document struct {
+					OpenAPI    string
+					Components struct {
+						Schemas map[string]json.RawMessage
+					}
+				}
+				if err := json.Unmarshal(output, &document); err != nil {
+					t.Fatal(err)
+				}
+				if document.OpenAPI != `3.1.0` || len(document.Components.Schemas) == 0 {
+					t.Fatalf("unexpected document: %+v", document)
+				}
+				checkRefs(t, output, func(ref string) bool {
+					_, ok := document.Components.Schemas[strings.TrimPrefix(ref, `#/components/schemas/`)]
+					return ok && strings.HasPrefix(ref, `#/components/schemas/`)
+				})
+			},
+		},
+		{
+			name:    `graphql_schema`,
+			sources: map[string]string{libraryFile: libraryProto},
+			generate: func(t testing.TB, result *protogentest.Result) []byte {
+				return []byte((&gopoet_protogen.GraphQLGenerator{Cache: result.Cache}).Schema(result.File(libraryFile)))
+			},
+			check: func(t *testing.T, output []byte) {
+				// every referenced type (other than the built-in scalars, and Time) is defined
+				defined := map[string]bool{`ID`: true, `String`: true, `Int`: true, `Float`: true, `Boolean`: true, `Time`: true}
+				for _, m := range regexp.MustCompile(`(?m)^(?:type|input|enum|union|scalar) (\w+)`).FindAllSubmatch(output, -1) {
+					defined[string(m[1])] = true
+				}
+				var referenced []string
+				for _, m := range regexp.MustCompile(`(?m)^  \w+(?:\([^)]*\))?: \[?(\w+)`).FindAllSubmatch(output, -1) {
+					referenced = append(referenced, string(m[1]))
+				}
+				for _, m := range regexp.MustCompile(`(?m)^union \w+ = (.+)$`).FindAllSubmatch(output, -1) {
+					referenced = append(referenced, strings.Split(string(m[1]), ` | `)...)
+				}
+				if len(referenced) == 0 {
+					t.Fatal("no referenced types")
+				}
+				for _, name := range referenced {
+					if !defined[name] {
+						t.Errorf("undefined type: %s", name)
+					}
+				}
+			},
+		},
+		{
+			name:    `sqlenum`,
+			sources: map[string]string{libraryFile: libraryProto, envFile: envProto},
+			generate: func(t testing.TB, result *protogentest.Result) []byte {
+				var (
+					g     = &gopoet_protogen.SQLEnumGenerator{}
+					level = result.File(envFile).Enums[0]
+				)
+				return []byte(g.DDL(result.File(libraryFile), result.File(envFile)) + "\n" +
+					"CREATE TABLE servers (level " + g.TypeNameFor(level) + ", fallback_level INTEGER " + g.Check(level, `fallback_level`) + ");\n")
+			},
+			check: func(t *testing.T, output []byte) {
+				checkSQLEnums(t, output, `CREATE DOMAIN (\w+) AS INTEGER CHECK \(VALUE IN \((.+)\)\);`, `\d+`)
+			},
+		},
+		{
+			name:    `sqlenum_names`,
+			sources: map[string]string{libraryFile: libraryProto, envFile: envProto},
+			generate: func(t testing.TB, result *protogentest.Result) []byte {
+				g := &gopoet_protogen.SQLEnumGenerator{
+					TypeName: func(v *protogen.Enum) string { return strings.ToLower(v.GoIdent.GoName) },
+					Names:    true,
+					Aliases:  gopoet_protogen.EnumAliasAll,
+				}
+				return []byte(g.DDL(result.File(libraryFile), result.File(envFile)))
+			},
+			check: func(t *testing.T, output []byte) {
+				checkSQLEnums(t, output, `CREATE TYPE (\w+) AS ENUM \((.+)\);`, `'\w+'`)
+			},
+		},
+		{
+			name:    `jsonschema`,
+			sources: map[string]string{libraryFile: libraryProto},
+			generate: func(t testing.TB, result *protogentest.Result) []byte {
+				b, err := (&gopoet_protogen.JSONSchemaGenerator{}).Document(result.Message(`test.v1.Book`))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return b
+			},
+			check: func(t *testing.T, output []byte) {
+				var document struct {
+					Schema string `json:"$schema"`
+					Ref    string `json:"$ref"`
+					Defs   map[string]struct {
+						Properties map[string]json.RawMessage
+						OneOf      []json.RawMessage
+					} `json:"$defs"`
+				}
+				if err := json.Unmarshal(output, &document); err != nil {
+					t.Fatal(err)
+				}
+				book, ok := document.Defs[strings.TrimPrefix(document.Ref, `#/$defs/`)]
+				if document.Schema != `https://json-schema.org/draft/2020-12/schema` || !ok {
+					t.Fatalf("unexpected document: %+v", document)
+				}
+				// properties use the JSON names, and the oneof members are mutually exclusive
+				for _, name := range [...]string{`name`, `tags`, `labels`, `published`, `nested`, `isbn`, `ebookUrl`, `printRun`, `cover`} {
+					if _, ok := book.Properties[name]; !ok {
+						t.Errorf("missing property: %s", name)
+					}
+				}
+				if len(book.OneOf) == 0 {
+					t.Error("missing oneOf")
+				}
+				checkRefs(t, output, func(ref string) bool {
+					_, ok := document.Defs[strings.TrimPrefix(ref, `#/$defs/`)]
+					return ok && strings.HasPrefix(ref, `#/$defs/`)
+				})
+			},
+		},
+		{
+			name:    `typescript`,
+			sources: map[string]string{libraryFile: libraryProto},
+			generate: func(t testing.TB, result *protogentest.Result) []byte {
+				return (&gopoet_protogen.TypeScriptGenerator{Cache: result.Cache}).Declarations(result.File(libraryFile))
+			},
+			check: checkTypeScript,
+		},
+		{
+			name:    `typescript_import`,
+			sources: map[string]string{itemFile: itemProto, holderFile: holderProto},
+			generate: func(t testing.TB, result *protogentest.Result) []byte {
+				return (&gopoet_protogen.TypeScriptGenerator{Cache: result.Cache, UseProtoNames: true}).Declarations(result.File(holderFile))
+			},
+			check: checkTypeScript,
+		},
+	}
+)
+
+// httpSources returns the sources of httpFile, and its imports.
+func httpSources() map[string]string {
+	return map[string]string{
+		libraryFile:                    libraryProto,
+		httpFile:                       httpProto,
+		`google/api/annotations.proto`: annotationsProto,
+		`google/api/http.proto`:        httpRuleProto,
+	}
+}
+
+func TestGenerators_golden(t *testing.T) {
+	for _, tc := range generatorTests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			result := protogentest.MustCompile(t, tc.sources, nil)
+			protogentest.AssertGolden(t, tc.generate(result.Cache, result.File(tc.file)), filepath.Join(`testdata`, tc.name+`.golden`))
+		})
+	}
+}
+
+func TestDocuments_golden(t *testing.T) {
+	for _, tc := range documentTests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			result := protogentest.MustCompile(t, tc.sources, nil)
+			protogentest.AssertGoldenBytes(t, tc.generate(t, result), filepath.Join(`testdata`, tc.name+`.golden`))
+		})
+	}
+}
+
+func TestDocuments_check(t *testing.T) {
+	for _, tc := range documentTests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			result := protogentest.MustCompile(t, tc.sources, nil)
+			tc.check(t, tc.generate(t, result))
+		})
+	}
+}
+
+// checkRefs fails the test if any "$ref" within the given JSON document isn't resolved by the given function.
+func checkRefs(t *testing.T, document []byte, resolve func(ref string) bool) {
+	t.Helper()
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v[`$ref`].(string); ok && !resolve(ref) {
+				t.Errorf("unresolved $ref: %s", ref)
+			}
+			for _, v := range v {
+				walk(v)
+			}
+		case []interface{}:
+			for _, v := range v {
+				walk(v)
+			}
+		}
+	}
+	var v interface{}
+	if err := json.Unmarshal(document, &v); err != nil {
+		t.Fatal(err)
+	}
+	walk(v)
+}
+
+func TestGenerators_build(t *testing.T) {
+	for _, tc := range generatorTests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			result := protogentest.MustCompile(t, tc.sources, nil)
+			runGenerated(t, result, []*gopoet.GoFile{tc.generate(result.Cache, result.File(tc.file))}, tc.tests, tc.requires...)
+		})
+	}
+}
+
+// checkSQLEnums checks that each statement of the given DDL matches the given pattern, with submatches for the type
+// name, and the comma-separated values, which are distinct, and match the given value pattern.
+func checkSQLEnums(t *testing.T, ddl []byte, pattern, value string) {
+	t.Helper()
+	var (
+		statement = regexp.MustCompile(`^` + pattern + `$`)
+		literal   = regexp.MustCompile(`^` + value + `$`)
+		types     = make(map[string]bool)
+	)
+	for _, line := range strings.Split(strings.TrimSpace(string(ddl)), "\n") {
+		if line == `` || strings.HasPrefix(line, `-- `) || strings.HasPrefix(line, `CREATE TABLE `) {
+			continue
+		}
+		m := statement.FindStringSubmatch(line)
+		if m == nil {
+			t.Errorf("unexpected statement: %s", line)
+			continue
+		}
+		if types[m[1]] {
+			t.Errorf("duplicate type: %s", m[1])
+		}
+		types[m[1]] = true
+		values := make(map[string]bool)
+		for _, v := range strings.Split(m[2], `, `) {
+			if !literal.MatchString(v) || values[v] {
+				t.Errorf("invalid value %s: %s", v, line)
+			}
+			values[v] = true
+		}
+	}
+	if len(types) == 0 {
+		t.Error("no types")
+	}
+}
+
+// checkTypeScript checks that each type referenced by the properties of the given declarations is either built-in,
+// declared, or qualified by an imported namespace.
+func checkTypeScript(t *testing.T, declarations []byte) {
+	t.Helper()
+	var (
+		defined    = map[string]bool{`string`: true, `number`: true, `boolean`: true, `null`: true, `unknown`: true, `never`: true, `Record`: true, `key`: true}
+		namespaces = make(map[string]bool)
+		references int
+	)
+	for _, m := range regexp.MustCompile(`(?m)^export (?:type|interface) (\w+)`).FindAllSubmatch(declarations, -1) {
+		defined[string(m[1])] = true
+	}
+	for _, m := range regexp.MustCompile(`(?m)^import type \* as (\w+) from "\.{1,2}/[^"]+";$`).FindAllSubmatch(declarations, -1) {
+		namespaces[string(m[1])] = true
+	}
+	for _, m := range regexp.MustCompile(`(?m)^  [\w"]+\??: (.+);$`).FindAllSubmatch(declarations, -1) {
+		// quoted property names, and string literal types, aren't references
+		typ := regexp.MustCompile(`"[^"]*"`).ReplaceAll(m[1], nil)
+		for _, ref := range regexp.MustCompile(`[A-Za-z_$][\w$]*(?:\.\w+)?`).FindAll(typ, -1) {
+			references++
+			if i := strings.IndexByte(string(ref), '.'); i != -1 {
+				if !namespaces[string(ref[:i])] {
+					t.Errorf("unknown namespace: %s", ref)
+				}
+			} else if !defined[string(ref)] {
+				t.Errorf("undefined type: %s", ref)
+			}
+		}
+	}
+	if references == 0 {
+		t.Error("no properties")
+	}
+	if strings.Count(string(declarations), `{`) != strings.Count(string(declarations), `}`) {
+		t.Error("unbalanced braces")
+	}
+}
+
+// structMapTarget returns a StructMapGenerator.Target mapping Book and Nested, of libraryProto, to the structs of
+// the same name, in the model package, declared by the structmap test.
+func structMapTarget() func(v *protogen.Message) *types.Named {
+	var (
+		pkg     = types.NewPackage(protogentest.DefaultImportPrefix+`/test/v1/model`, `model`)
+		timeT   = types.NewNamed(types.NewTypeName(0, types.NewPackage(`time`, `time`), `Time`, nil), types.NewStruct(nil, nil), nil)
+		str     = types.Typ[types.String]
+		field   = func(name string, typ types.Type) *types.Var { return types.NewField(0, pkg, name, typ, false) }
+		named   = func(name string) *types.Named { return types.NewNamed(types.NewTypeName(0, pkg, name, nil), nil, nil) }
+		book    = named(`Book`)
+		nested  = named(`Nested`)
+		targets = map[string]*types.Named{`Book`: book, `Nested`: nested}
+	)
+	nested.SetUnderlying(types.NewStruct([]*types.Var{field(`Note`, str)}, nil))
+	book.SetUnderlying(types.NewStruct([]*types.Var{
+		field(`Name`, str),
+		field(`Heading`, str),
+		field(`Pages`, types.Typ[types.Int]),
+		field(`Genre`, str),
+		field(`Tags`, types.NewSlice(str)),
+		field(`Labels`, types.NewMap(str, str)),
+		field(`Published`, timeT),
+		field(`Nested`, types.NewPointer(nested)),
+		field(`Isbn`, str),
+		field(`Cover`, types.NewSlice(types.Typ[types.Byte])),
+		field(`Internal`, str),
+	}, []string{``, `proto:"title"`, ``, ``, ``, ``, ``, ``, ``, ``, `proto:"-"`}))
+	return func(v *protogen.Message) *types.Named {
+		return targets[v.GoIdent.GoName]
+	}
+}
@@ -0,0 +1,517 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// GraphQLGenerator generates gqlgen compatible model structs, for each message, with a function converting from
+	// the message, and a resolver interface, for each service, as well as the corresponding GraphQL schema, see also
+	// GraphQLGenerator.Schema and GraphQLGenerator.Models.
+	//
+	// Enums are mapped to GraphQL enums (represented as strings, in the models), google.protobuf.Timestamp is mapped
+	// to the Time scalar (supported by gqlgen), and each oneof is mapped to a union, of object types wrapping each
+	// field, with a single "value" field. The 64-bit integer types, and uint32, are mapped to String, and bytes are
+	// mapped to base64 encoded String, consistent with protojson. Map fields, as well as message fields of types
+	// declared in other files, are not supported, and are omitted.
+	GraphQLGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// TypeName may be used to override the GraphQL type name of each message or enum, which defaults to the
+		// golang name. Model structs are named using the GraphQL type name, followed by "Model".
+		TypeName func(desc protoreflect.Descriptor) string
+	}
+)
+
+const (
+	timestampFullName = `google.protobuf.Timestamp`
+)
+
+var (
+	_ Generator = (*GraphQLGenerator)(nil)
+
+	base64Pkg = gopoet.NewPackage("encoding/base64")
+	timePkg   = gopoet.NewPackage("time")
+)
+
+// GenerateFile returns the model structs, union interfaces, and model functions, for each message in the given file,
+// with any supported fields, and the resolver interface for each service, see also HasModel.
+func (x *GraphQLGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range x.fileMessages(file) {
+		elements = append(elements, gopoet.NewTypeDecl(x.Model(v)))
+		for _, oneof := range x.modelOneofs(v) {
+			elements = append(elements, x.Union(oneof)...)
+		}
+		elements = append(elements, x.ModelFunc(v))
+	}
+	for _, v := range file.Services {
+		if resolver := x.Resolver(v); resolver != nil {
+			elements = append(elements, gopoet.NewTypeDecl(resolver))
+		}
+	}
+	return elements
+}
+
+// TypeNameFor returns the GraphQL type name of the given message or enum.
+func (x *GraphQLGenerator) TypeNameFor(desc protoreflect.Descriptor) string {
+	if x.TypeName != nil {
+		return x.TypeName(desc)
+	}
+	switch desc := desc.(type) {
+	case protoreflect.MessageDescriptor:
+		return x.Cache.MessageType(desc).Symbol().Name
+	case protoreflect.EnumDescriptor:
+		return x.Cache.enumType(desc).Symbol().Name
+	default:
+		panic(fmt.Sprintf("unknown type: %T", desc))
+	}
+}
+
+// ModelName returns the name of the model struct generated for the given message.
+func (x *GraphQLGenerator) ModelName(v *protogen.Message) string {
+	return x.TypeNameFor(v.Desc) + `Model`
+}
+
+// UnionName returns the GraphQL type name of the union for the given oneof, which is the type name of the parent
+// message, then "_", then the golang name of the oneof, e.g. "Message_Choice". The union is represented as an
+// interface, named using the union name, followed by "Model".
+func (x *GraphQLGenerator) UnionName(v *protogen.Oneof) string {
+	return x.TypeNameFor(v.Parent.Desc) + `_` + v.GoName
+}
+
+// MemberName returns the GraphQL type name of the union member for the given oneof field, which is the type name of
+// the parent message, then "_", then the golang name of the field, e.g. "Message_Name".
+func (x *GraphQLGenerator) MemberName(field *protogen.Field) string {
+	return x.TypeNameFor(field.Parent.Desc) + `_` + field.GoName
+}
+
+// HasModel returns true if the given message has any supported fields, otherwise it is omitted, as GraphQL object
+// types must have at least one field.
+func (x *GraphQLGenerator) HasModel(v *protogen.Message) bool {
+	return x.hasModel(v, make(map[*protogen.Message]struct{}))
+}
+
+// IsSupported returns true if the given field is mapped to a GraphQL field (or union member).
+func (x *GraphQLGenerator) IsSupported(field *protogen.Field) bool {
+	return x.isSupported(field, make(map[*protogen.Message]struct{}))
+}
+
+func (x *GraphQLGenerator) hasModel(v *protogen.Message, visiting map[*protogen.Message]struct{}) bool {
+	if _, ok := visiting[v]; ok || v.Desc.IsMapEntry() {
+		return false
+	}
+	visiting[v] = struct{}{}
+	defer delete(visiting, v)
+	for _, field := range v.Fields {
+		if x.isSupported(field, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *GraphQLGenerator) isSupported(field *protogen.Field, visiting map[*protogen.Message]struct{}) bool {
+	switch {
+	case field.Desc.IsMap():
+		return false
+	case isTimestamp(field):
+		return true
+	case field.Message != nil:
+		return field.Message.Desc.ParentFile().Path() == field.Parent.Desc.ParentFile().Path() &&
+			x.hasModel(field.Message, visiting)
+	default:
+		return true
+	}
+}
+
+// Model returns the model struct for the given message, with a field for each supported field, and each oneof (with
+// any supported fields), with json tags consistent with the GraphQL field names.
+func (x *GraphQLGenerator) Model(v *protogen.Message) *gopoet.TypeSpec {
+	var fields []*gopoet.FieldSpec
+	for _, field := range x.modelFields(v) {
+		fields = append(fields, gopoet.NewField(field.GoName, x.fieldType(field)).
+			SetTag(fmt.Sprintf(`json:%q`, field.Desc.JSONName())))
+	}
+	for _, oneof := range x.modelOneofs(v) {
+		fields = append(fields, gopoet.NewField(oneof.GoName, localType(x.UnionName(oneof)+`Model`)).
+			SetTag(fmt.Sprintf(`json:%q`, jsonCamelCase(string(oneof.Desc.Name())))))
+	}
+	name := x.ModelName(v)
+	return gopoet.NewStructTypeSpec(name, fields...).
+		SetComment(fmt.Sprintf(`%s is the GraphQL model for %s.`, name, v.Desc.FullName()))
+}
+
+// Union returns the interface representing the union for the given oneof, and the wrapper struct, with a method
+// implementing the interface, for each supported field.
+func (x *GraphQLGenerator) Union(v *protogen.Oneof) []gopoet.FileElement {
+	var (
+		name     = x.UnionName(v) + `Model`
+		method   = `Is` + name
+		elements = []gopoet.FileElement{gopoet.NewTypeDecl(gopoet.NewInterfaceTypeSpec(name, gopoet.NewInterfaceMethod(method)).
+				SetComment(fmt.Sprintf(`%s is the GraphQL model for the %s oneof.`, name, v.Desc.FullName())))}
+	)
+	for _, field := range x.unionFields(v) {
+		member := x.MemberName(field) + `Model`
+		spec := gopoet.NewStructTypeSpec(member, gopoet.NewField(`Value`, x.valueType(field)).SetTag(`json:"value"`)).
+			SetComment(fmt.Sprintf(`%s is the GraphQL model for the %s field, as a member of %s.`, member, field.Desc.FullName(), name))
+		elements = append(elements,
+			gopoet.NewTypeDecl(spec),
+			gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, localType(member)), method).
+				SetComment(fmt.Sprintf(`%s implements %s.`, method, name)))
+	}
+	return elements
+}
+
+// ModelFunc returns a function that converts the given message to its model, named "New", followed by the model
+// name, which returns nil if the message is nil.
+func (x *GraphQLGenerator) ModelFunc(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		model = x.ModelName(v)
+		name  = `New` + model
+	)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s converts a %s to a %s.`, name, v.Desc.FullName(), model)).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, gopoet.PointerType(localType(model))).
+		Println(`if v == nil {`).
+		Println(`return nil`).
+		Println(`}`).
+		Printlnf(`var m %s`, model)
+	for _, field := range x.modelFields(v) {
+		expr := `v.` + field.GoName
+		switch {
+		case field.Desc.IsList():
+			f.Printlnf(`m.%s = make(%s, len(%s))`, field.GoName, x.fieldType(field), expr).
+				Printlnf(`for i, value := range %s {`, expr).
+				AddCode(x.convert(field, `value`, `m.%s[i] = `, field.GoName)).
+				Println(`}`)
+		case field.Message != nil:
+			if isTimestamp(field) {
+				f.Printlnf(`if %s != nil {`, expr).
+					AddCode(x.convert(field, expr, `value := `)).
+					Printlnf(`m.%s = &value`, field.GoName).
+					Println(`}`)
+			} else {
+				f.AddCode(x.convert(field, expr, `m.%s = `, field.GoName))
+			}
+		case field.Desc.HasPresence():
+			value := expr
+			if isPointerField(field.Desc) {
+				value = `*` + expr
+			}
+			f.Printlnf(`if %s != nil {`, expr).
+				AddCode(x.convert(field, value, `value := `)).
+				Printlnf(`m.%s = &value`, field.GoName).
+				Println(`}`)
+		default:
+			f.AddCode(x.convert(field, expr, `m.%s = `, field.GoName))
+		}
+	}
+	for _, oneof := range x.modelOneofs(v) {
+		f.Printlnf(`switch value := v.%s.(type) {`, oneof.GoName)
+		for _, field := range x.unionFields(oneof) {
+			f.Printlnf(`case *%s:`, x.Cache.goPackage(field.GoIdent.GoImportPath).Symbol(field.GoIdent.GoName)).
+				Printlnf(`var member %sModel`, x.MemberName(field)).
+				AddCode(x.convert(field, `value.`+field.GoName, `member.Value = `)).
+				Printlnf(`m.%s = &member`, oneof.GoName)
+		}
+		f.Println(`}`)
+	}
+	return f.Println(`return &m`)
+}
+
+// Resolver returns an interface, for the given service, with a method per unary method (with a supported response
+// type), which accepts the request message, and returns the response model, or nil if there are no such methods. The
+// interface is named using the golang name of the service, followed by "Resolver".
+func (x *GraphQLGenerator) Resolver(v *protogen.Service) *gopoet.TypeSpec {
+	var methods []gopoet.InterfaceElement
+	for _, method := range v.Methods {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() ||
+			method.Output.Desc.ParentFile().Path() != v.Desc.ParentFile().Path() ||
+			!x.HasModel(method.Output) {
+			continue
+		}
+		methods = append(methods, gopoet.NewInterfaceMethod(method.GoName).
+			AddArg(`ctx`, gopoet.NamedType(contextPkg.Symbol(`Context`))).
+			AddArg(`input`, gopoet.PointerType(x.Cache.MessageType(method.Input.Desc))).
+			AddResult(``, gopoet.PointerType(localType(x.ModelName(method.Output)))).
+			AddResult(``, gopoet.ErrorType))
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	name := v.GoName + `Resolver`
+	return gopoet.NewInterfaceTypeSpec(name, methods...).
+		SetComment(fmt.Sprintf(`%s is the GraphQL resolver for the %s service.`, name, v.Desc.FullName()))
+}
+
+// Schema renders the GraphQL schema (SDL) for the types declared in the given files, consistent with the models.
+func (x *GraphQLGenerator) Schema(files ...*protogen.File) string {
+	var (
+		b    strings.Builder
+		time bool
+	)
+	for _, file := range files {
+		for _, v := range FileEnums(file) {
+			b.WriteString(graphQLDescription(``, v.Comments.Leading))
+			fmt.Fprintf(&b, "enum %s {\n", x.TypeNameFor(v.Desc))
+			for _, value := range EnumValues(v) {
+				fmt.Fprintf(&b, "  %s\n", value.Desc.Name())
+			}
+			b.WriteString("}\n\n")
+		}
+		for _, v := range x.fileMessages(file) {
+			b.WriteString(graphQLDescription(``, v.Comments.Leading))
+			fmt.Fprintf(&b, "type %s {\n", x.TypeNameFor(v.Desc))
+			for _, field := range x.modelFields(v) {
+				b.WriteString(graphQLDescription(`  `, field.Comments.Leading))
+				fmt.Fprintf(&b, "  %s: %s\n", field.Desc.JSONName(), x.graphQLType(field))
+				time = time || isTimestamp(field)
+			}
+			for _, oneof := range x.modelOneofs(v) {
+				b.WriteString(graphQLDescription(`  `, oneof.Comments.Leading))
+				fmt.Fprintf(&b, "  %s: %s\n", jsonCamelCase(string(oneof.Desc.Name())), x.UnionName(oneof))
+			}
+			b.WriteString("}\n\n")
+			for _, oneof := range x.modelOneofs(v) {
+				var members []string
+				for _, field := range x.unionFields(oneof) {
+					members = append(members, x.MemberName(field))
+				}
+				fmt.Fprintf(&b, "union %s = %s\n\n", x.UnionName(oneof), strings.Join(members, ` | `))
+				for _, field := range x.unionFields(oneof) {
+					fmt.Fprintf(&b, "type %s {\n  value: %s!\n}\n\n", x.MemberName(field), x.graphQLBaseType(field))
+					time = time || isTimestamp(field)
+				}
+			}
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "\n")
+	if time {
+		s = "scalar Time\n\n" + s
+	}
+	return s
+}
+
+// Models returns the gqlgen models configuration, mapping each GraphQL type name, for the given files, to the
+// qualified golang type name, given the import path of the package containing the generated models.
+func (x *GraphQLGenerator) Models(importPath string, files ...*protogen.File) map[string]string {
+	models := make(map[string]string)
+	for _, file := range files {
+		for _, v := range x.fileMessages(file) {
+			models[x.TypeNameFor(v.Desc)] = importPath + `.` + x.ModelName(v)
+			for _, oneof := range x.modelOneofs(v) {
+				models[x.UnionName(oneof)] = importPath + `.` + x.UnionName(oneof) + `Model`
+				for _, field := range x.unionFields(oneof) {
+					models[x.MemberName(field)] = importPath + `.` + x.MemberName(field) + `Model`
+				}
+			}
+		}
+		for _, v := range FileEnums(file) {
+			models[x.TypeNameFor(v.Desc)] = `github.com/99designs/gqlgen/graphql.String`
+		}
+	}
+	return models
+}
+
+// fileMessages returns the messages in the given file with models, see also FileMessages.
+func (x *GraphQLGenerator) fileMessages(file *protogen.File) []*protogen.Message {
+	var messages []*protogen.Message
+	for _, v := range FileMessages(file) {
+		if x.HasModel(v) {
+			messages = append(messages, v)
+		}
+	}
+	return messages
+}
+
+// modelFields returns the supported fields of the given message, excluding oneof fields.
+func (x *GraphQLGenerator) modelFields(v *protogen.Message) []*protogen.Field {
+	var fields []*protogen.Field
+	for _, field := range v.Fields {
+		if (field.Oneof == nil || field.Oneof.Desc.IsSynthetic()) && x.IsSupported(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// modelOneofs returns the (non-synthetic) oneofs of the given message with any supported fields.
+func (x *GraphQLGenerator) modelOneofs(v *protogen.Message) []*protogen.Oneof {
+	var oneofs []*protogen.Oneof
+	for _, oneof := range v.Oneofs {
+		if len(x.unionFields(oneof)) != 0 {
+			oneofs = append(oneofs, oneof)
+		}
+	}
+	return oneofs
+}
+
+// unionFields returns the supported fields of the given oneof, or nil if it is synthetic.
+func (x *GraphQLGenerator) unionFields(v *protogen.Oneof) []*protogen.Field {
+	if v.Desc.IsSynthetic() {
+		return nil
+	}
+	var fields []*protogen.Field
+	for _, field := range v.Fields {
+		if x.IsSupported(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// fieldType returns the golang type of the model field for the given (non-oneof) field.
+func (x *GraphQLGenerator) fieldType(field *protogen.Field) gopoet.TypeName {
+	t := x.valueType(field)
+	switch {
+	case field.Desc.IsList():
+		return gopoet.SliceType(t)
+	case field.Desc.HasPresence() && (field.Message == nil || isTimestamp(field)):
+		return gopoet.PointerType(t)
+	default:
+		return t
+	}
+}
+
+// valueType returns the golang type of a single (non-null) value of the given field, in the model, where models are
+// always pointers.
+func (x *GraphQLGenerator) valueType(field *protogen.Field) gopoet.TypeName {
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return gopoet.BoolType
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind:
+		return int32Type
+	case protoreflect.FloatKind,
+		protoreflect.DoubleKind:
+		return gopoet.Float64Type
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if isTimestamp(field) {
+			return gopoet.NamedType(timePkg.Symbol(`Time`))
+		}
+		return gopoet.PointerType(localType(x.ModelName(field.Message)))
+	default:
+		return gopoet.StringType
+	}
+}
+
+// convert returns a line of code, consisting of the given format and args, followed by an expression converting the
+// given expression, a single value of the given field, to the model value type.
+func (x *GraphQLGenerator) convert(field *protogen.Field, expr string, format string, args ...interface{}) *gopoet.CodeBlock {
+	switch field.Desc.Kind() {
+	case protoreflect.FloatKind:
+		return gopoet.Printlnf(format+`float64(%s)`, append(args, expr)...)
+	case protoreflect.Uint32Kind,
+		protoreflect.Fixed32Kind:
+		return gopoet.Printlnf(format+`%s(uint64(%s), 10)`, append(args, strconvPkg.Symbol(`FormatUint`), expr)...)
+	case protoreflect.Int64Kind,
+		protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind:
+		return gopoet.Printlnf(format+`%s(%s, 10)`, append(args, strconvPkg.Symbol(`FormatInt`), expr)...)
+	case protoreflect.Uint64Kind,
+		protoreflect.Fixed64Kind:
+		return gopoet.Printlnf(format+`%s(%s, 10)`, append(args, strconvPkg.Symbol(`FormatUint`), expr)...)
+	case protoreflect.BytesKind:
+		return gopoet.Printlnf(format+`%s.EncodeToString(%s)`, append(args, base64Pkg.Symbol(`StdEncoding`), expr)...)
+	case protoreflect.EnumKind:
+		if strings.HasPrefix(expr, `*`) {
+			expr = `(` + expr + `)`
+		}
+		return gopoet.Printlnf(format+`%s.String()`, append(args, expr)...)
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if isTimestamp(field) {
+			return gopoet.Printlnf(format+`%s.AsTime()`, append(args, expr)...)
+		}
+		return gopoet.Printlnf(format+`New%s(%s)`, append(args, x.ModelName(field.Message), expr)...)
+	default:
+		return gopoet.Printlnf(format+`%s`, append(args, expr)...)
+	}
+}
+
+// graphQLType returns the GraphQL type of the given (non-oneof) field.
+func (x *GraphQLGenerator) graphQLType(field *protogen.Field) string {
+	t := x.graphQLBaseType(field)
+	switch {
+	case field.Desc.IsList():
+		return `[` + t + `!]!`
+	case field.Desc.HasPresence():
+		return t
+	default:
+		return t + `!`
+	}
+}
+
+// graphQLBaseType returns the (nullable) GraphQL type of a single value of the given field.
+func (x *GraphQLGenerator) graphQLBaseType(field *protogen.Field) string {
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return `Boolean`
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind:
+		return `Int`
+	case protoreflect.FloatKind,
+		protoreflect.DoubleKind:
+		return `Float`
+	case protoreflect.EnumKind:
+		return x.TypeNameFor(field.Enum.Desc)
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if isTimestamp(field) {
+			return `Time`
+		}
+		return x.TypeNameFor(field.Message.Desc)
+	default:
+		return `String`
+	}
+}
+
+// graphQLDescription returns the given comment as a GraphQL block string description, with the given indent, or an
+// empty string if there is no comment.
+func graphQLDescription(indent string, comment protogen.Comments) string {
+	text := commentText(comment)
+	if text == `` {
+		return ``
+	}
+	text = strings.ReplaceAll(text, `"""`, `\"""`)
+	return indent + `"""` + "\n" + indent + strings.ReplaceAll(text, "\n", "\n"+indent) + "\n" + indent + `"""` + "\n"
+}
+
+// isTimestamp returns true if the given field is a google.protobuf.Timestamp.
+func isTimestamp(field *protogen.Field) bool {
+	return field.Message != nil && field.Message.Desc.FullName() == timestampFullName
+}
+
+// jsonCamelCase converts a snake_case name to lowerCamelCase, consistent with the default JSON name of fields.
+func jsonCamelCase(s string) string {
+	var (
+		b     strings.Builder
+		upper bool
+	)
+	for _, c := range s {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// localType returns a type name for a type declared in the package being generated.
+func localType(name string) gopoet.TypeName {
+	return gopoet.NamedType(gopoet.Symbol{Name: name})
+}
@@ -0,0 +1,51 @@
+package gopoet_protogen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DescriptorHash returns a stable hash (hex encoded SHA-256) of the given file, message, enum, service, method, or
+// field (or extension) descriptor, which changes if the names, numbers, types, or options of the descriptor (or any
+// nested descriptors) change, but not if only comments change, e.g. to skip generating unchanged outputs, or as a
+// cache key across runs. Note that referenced types are included by name only. It will panic if the descriptor is
+// of any other type.
+func DescriptorHash(v protoreflect.Descriptor) string {
+	var m proto.Message
+	switch v := v.(type) {
+	case protoreflect.FileDescriptor:
+		file := protodesc.ToFileDescriptorProto(v)
+		file.SourceCodeInfo = nil
+		m = file
+	case protoreflect.MessageDescriptor:
+		m = protodesc.ToDescriptorProto(v)
+	case protoreflect.EnumDescriptor:
+		m = protodesc.ToEnumDescriptorProto(v)
+	case protoreflect.ServiceDescriptor:
+		m = protodesc.ToServiceDescriptorProto(v)
+	case protoreflect.MethodDescriptor:
+		m = protodesc.ToMethodDescriptorProto(v)
+	case protoreflect.FieldDescriptor:
+		m = protodesc.ToFieldDescriptorProto(v)
+	default:
+		panic(fmt.Sprintf("unknown type: %v", v))
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	h := sha256.New()
+	h.Write([]byte(v.FullName()))
+	h.Write([]byte{0})
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Hash returns the DescriptorHash of the method.
+func (x *Method) Hash() string {
+	return DescriptorHash(x.Method.Desc)
+}
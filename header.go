@@ -0,0 +1,81 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+)
+
+type (
+	// GoFileFactory creates output files (see also NewGoFile), with the standard generated code header, consistent
+	// with protoc-gen-go, including the generator and compiler versions, the source file, and an optional build
+	// constraint.
+	//
+	// Build constraints are rendered as "// go:build", due to gopoet's handling of comments, which is corrected by
+	// Cache.WriteGoFile (and therefore Pool), and must be corrected when rendering by other means.
+	GoFileFactory struct {
+		// Plugin is used to resolve file names, and the compiler version.
+		Plugin *protogen.Plugin
+		// Name is the name of the generator, e.g. "protoc-gen-go-foo".
+		Name string
+		// Version may be set to the version of the generator, e.g. "v1.2.3", and is omitted if empty.
+		Version string
+		// BuildConstraint may be set to a build constraint expression (e.g. "!js"), added as a //go:build line.
+		BuildConstraint string
+	}
+)
+
+// NewGoFile returns a new file for the given input file, see also NewGoFile, with the header set as the file comment.
+func (x *GoFileFactory) NewGoFile(file *protogen.File, suffix string) (*gopoet.GoFile, error) {
+	goFile, err := NewGoFile(x.Plugin, file, suffix)
+	if err != nil {
+		return nil, err
+	}
+	goFile.FileComment = x.Header(file)
+	return goFile, nil
+}
+
+// Header returns the header for the given input file, as the text of a comment (without the comment markers).
+func (x *GoFileFactory) Header(file *protogen.File) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Code generated by %s. DO NOT EDIT.\nversions:\n", x.Name)
+	if x.Version != `` {
+		fmt.Fprintf(&b, "\t%s %s\n", x.Name, x.Version)
+	}
+	fmt.Fprintf(&b, "\tprotoc %s\n", x.compilerVersion())
+	fmt.Fprintf(&b, "source: %s", file.Desc.Path())
+	if x.BuildConstraint != `` {
+		fmt.Fprintf(&b, "\n\ngo:build %s", x.BuildConstraint)
+	}
+	return b.String()
+}
+
+// compilerVersion returns the version of protoc, as formatted by protoc-gen-go, e.g. "v3.21.12", or "(unknown)".
+func (x *GoFileFactory) compilerVersion() string {
+	v := x.Plugin.Request.GetCompilerVersion()
+	if v == nil {
+		return `(unknown)`
+	}
+	version := fmt.Sprintf(`v%d.%d.%d`, v.GetMajor(), v.GetMinor(), v.GetPatch())
+	if v.GetSuffix() != `` {
+		version += `-` + v.GetSuffix()
+	}
+	return version
+}
+
+// fixBuildConstraints replaces "// go:build" lines, preceding the package clause, with "//go:build" directives, see
+// also GoFileFactory.
+func fixBuildConstraints(src []byte) []byte {
+	lines := strings.SplitAfter(string(src), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, `//`) && strings.TrimSpace(line) != `` {
+			break
+		}
+		if strings.HasPrefix(line, `// go:build `) {
+			lines[i] = `//go:build ` + strings.TrimPrefix(line, `// go:build `)
+			return []byte(strings.Join(lines, ``))
+		}
+	}
+	return src
+}
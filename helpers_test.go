@@ -0,0 +1,258 @@
+package gopoet_protogen_test
+
+import (
+	"bytes"
+	"github.com/jhump/gopoet"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"github.com/joeycumines/gopoet-protogen/protogentest"
+	"google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// libraryProto is the schema shared by the generator tests, including a recursive message (Book, Nested, and
+	// Deep), a oneof, maps, well-known types, and every streaming kind.
+	libraryProto = `syntax = "proto3";
+
+package test.v1;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/protogentest/test/v1;testv1";
+
+enum Genre {
+  GENRE_UNSPECIFIED = 0;
+  GENRE_FICTION = 1;
+  GENRE_HISTORY = 2;
+}
+
+message Book {
+  string name = 1;
+  string title = 2;
+  int32 pages = 3;
+  Genre genre = 4;
+  repeated string tags = 5;
+  map<string, string> labels = 6;
+  google.protobuf.Timestamp published = 7;
+  Nested nested = 8;
+  optional string isbn = 9;
+  oneof format {
+    string ebook_url = 10;
+    int32 print_run = 11;
+  }
+  bytes cover = 12;
+}
+
+message Nested {
+  Deep deep = 1;
+  string note = 2;
+}
+
+message Deep {
+  Book book = 1;
+  repeated Nested children = 2;
+}
+
+message GetBookRequest {
+  string name = 1;
+}
+
+message ListBooksRequest {
+  int32 page_size = 1;
+  string page_token = 2;
+}
+
+message ListBooksResponse {
+  repeated Book books = 1;
+  string next_page_token = 2;
+}
+
+service Library {
+  rpc GetBook(GetBookRequest) returns (Book);
+  rpc ListBooks(ListBooksRequest) returns (ListBooksResponse);
+  rpc WatchBooks(GetBookRequest) returns (stream Book);
+  rpc UploadBooks(stream Book) returns (ListBooksResponse);
+  rpc SyncBooks(stream Book) returns (stream Book);
+}
+`
+
+	libraryFile = `test/v1/library.proto`
+
+	// itemProto is a proto2 schema, including required (scalar, and message) fields, defaults, recursive
+	// messages (Item, and Item.Sub), and google.protobuf.Any fields (singular, repeated, and in a oneof).
+	itemProto = `syntax = "proto2";
+
+package test.v2;
+
+import "google/protobuf/any.proto";
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/protogentest/test/v2;testv2";
+
+enum Kind {
+  KIND_UNKNOWN = 0;
+  KIND_A = 1;
+  KIND_B = 2;
+}
+
+message Item {
+  message Sub {
+    required string id = 1;
+    optional int32 count = 2 [default = 7];
+    optional Sub next = 3;
+  }
+
+  required string name = 1;
+  required Sub rsub = 2;
+  optional Sub osub = 3;
+  repeated Sub subs = 4;
+  map<string, Sub> sub_map = 5;
+  optional Kind kind = 6 [default = KIND_A];
+  optional bytes data = 7;
+  optional double ratio = 8 [default = 0.5];
+  repeated int32 numbers = 9;
+  map<int32, string> names = 10;
+  optional Item child = 11;
+  oneof choice {
+    string cname = 12;
+    Sub csub = 13;
+    google.protobuf.Any cany = 14;
+  }
+  optional google.protobuf.Any any = 15;
+  repeated google.protobuf.Any anys = 16;
+  optional google.protobuf.Timestamp created = 17;
+}
+`
+
+	itemFile = `test/v2/item.proto`
+
+	// libraryGRPCFile is the path of the protoc-gen-go-grpc output for libraryFile, within the module written by
+	// runGenerated, see also libraryGRPC.
+	libraryGRPCFile = `test/v1/library_grpc.pb.go`
+
+	// grpcModule is the module required by libraryGRPC, see also runGenerated.
+	grpcModule = `google.golang.org/grpc v1.64.0`
+)
+
+// libraryGRPC returns the protoc-gen-go-grpc (v1.5.1, with use_generic_streams_experimental=false) output for
+// libraryFile, which is checked in as testdata, as protoc-gen-go-grpc isn't usable as a library.
+func libraryGRPC() string {
+	b, err := os.ReadFile(filepath.Join(`testdata`, `library_grpc.pb.go`))
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// compileItem compiles itemProto, see also protogentest.MustCompile.
+func compileItem(t testing.TB) *protogentest.Result {
+	t.Helper()
+	return protogentest.MustCompile(t, map[string]string{itemFile: itemProto}, nil)
+}
+
+// compileLibrary compiles libraryProto, see also protogentest.MustCompile.
+func compileLibrary(t testing.TB) *protogentest.Result {
+	t.Helper()
+	return protogentest.MustCompile(t, map[string]string{libraryFile: libraryProto}, nil)
+}
+
+// generateFile returns a new file, in the package of the given input file, named name, populated by the given
+// generators, see also gopoet_protogen.Generate.
+func generateFile(file *protogen.File, name string, generators ...gopoet_protogen.Generator) *gopoet.GoFile {
+	dst := gopoet.NewGoFile(name, string(file.GoImportPath), string(file.GoPackageName))
+	gopoet_protogen.Generate(dst, file, generators...)
+	return dst
+}
+
+// runGenerated writes a module (protogentest.DefaultImportPrefix), containing the protoc-gen-go output for every
+// file of the given result, that doesn't belong to another module, the given files, rendered using the cache of the
+// result, and the given sources (e.g. tests), keyed by their path within the module, then runs its tests, failing
+// the test if they fail. The module is also vetted. It is skipped by -short, as it invokes the go command.
+// Any other modules required by the generated code (each "path version") are added to the module, and resolved using
+// the go command, where the test is skipped if they can't be, e.g. due to a lack of network access.
+func runGenerated(t *testing.T, result *protogentest.Result, files []*gopoet.GoFile, sources map[string]string, requires ...string) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip(`skipping test that runs generated code in short mode`)
+	}
+	goBin, err := exec.LookPath(`go`)
+	if err != nil {
+		t.Skip(`go command not found`)
+	}
+
+	var (
+		dir    = t.TempDir()
+		prefix = protogentest.DefaultImportPrefix + `/`
+		write  = func(name string, content []byte) {
+			t.Helper()
+			name = filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(name, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	)
+
+	mod := "module " + protogentest.DefaultImportPrefix + "\n\ngo 1.16\n\nrequire google.golang.org/protobuf v1.28.1\n"
+	for _, v := range requires {
+		mod += "\nrequire " + v + "\n"
+	}
+	write(`go.mod`, []byte(mod))
+	sum, err := os.ReadFile(`go.sum`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(`go.sum`, sum)
+
+	for _, file := range result.Plugin.Files {
+		if strings.HasPrefix(string(file.GoImportPath), prefix) {
+			internal_gengo.GenerateFile(result.Plugin, file)
+		}
+	}
+	response := result.Plugin.Response()
+	if response.Error != nil {
+		t.Fatal(response.GetError())
+	}
+	for _, file := range response.File {
+		if name := file.GetName(); strings.HasPrefix(name, prefix) {
+			write(strings.TrimPrefix(name, prefix), []byte(file.GetContent()))
+		}
+	}
+
+	for _, file := range files {
+		var b bytes.Buffer
+		if err := result.Cache.WriteGoFile(&b, file); err != nil {
+			t.Fatal(err)
+		}
+		write(strings.TrimPrefix(file.Package().ImportPath, prefix)+`/`+file.Name, b.Bytes())
+	}
+
+	for name, content := range sources {
+		write(name, []byte(content))
+	}
+
+	command := func(args ...string) *exec.Cmd {
+		cmd := exec.Command(goBin, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), `GOFLAGS=-mod=mod`, `GOWORK=off`)
+		return cmd
+	}
+	if len(requires) != 0 {
+		if output, err := command(`mod`, `tidy`).CombinedOutput(); err != nil {
+			t.Skipf("skipping test that requires %s: %v\n%s", strings.Join(requires, `, `), err, output)
+		}
+	}
+	for _, args := range [...][]string{{`vet`, `./...`}, {`test`, `./...`}} {
+		if output, err := command(args...).CombinedOutput(); err != nil {
+			t.Fatalf("generated code failed go %s: %v\n%s", args[0], err, output)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// HTTPRule models a google.api.http rule, see also Method.
+	HTTPRule struct {
+		// Method is the HTTP method, e.g. "GET", or the kind of a custom pattern.
+		Method string
+		// Path is the path template, e.g. "/v1/{name=shelves/*}/books".
+		Path string
+		// Variables are the variables of the path template, in order of appearance.
+		Variables []PathVariable
+		// Body is the name of the request field mapped to the HTTP request body, "*" for all fields not bound by
+		// the path template, or empty if there is no request body.
+		Body string
+		// BodyField is the request field named by Body, or nil if Body is empty or "*".
+		BodyField *protogen.Field
+		// ResponseBody is the name of the response field mapped to the HTTP response body, or empty if the entire
+		// response message is mapped.
+		ResponseBody string
+		// ResponseBodyField is the response field named by ResponseBody, or nil if ResponseBody is empty.
+		ResponseBodyField *protogen.Field
+	}
+
+	// PathVariable models a variable of a path template, e.g. "{name=shelves/*}", see also HTTPRule.
+	PathVariable struct {
+		// FieldPath is the path of the request field the variable is bound to.
+		FieldPath
+		// Pattern is the segment pattern the variable matches, e.g. "shelves/*", and defaults to "*".
+		Pattern string
+		// Type is the golang type of the field the variable is bound to.
+		Type gopoet.TypeName
+	}
+)
+
+const (
+	// HTTPFieldNumber is the field number of the google.api.http option, in google.protobuf.MethodOptions.
+	HTTPFieldNumber protoreflect.FieldNumber = 72295728
+)
+
+// httpRules decodes the google.api.http option of the given method, if any, without depending on the generated
+// google.api package, resolving fields using the cache.
+func (x *Cache) httpRules(v *protogen.Method) ([]*HTTPRule, error) {
+	values := OptionBytes(v.Desc, HTTPFieldNumber)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var b []byte
+	for _, value := range values {
+		// repeated occurrences of a message field are merged, which is equivalent to concatenating them
+		b = append(b, value...)
+	}
+	rule, bindings, err := parseHTTPRule(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid google.api.http option for %s: %w", v.Desc.FullName(), err)
+	}
+	rules := []*HTTPRule{rule}
+	for _, b := range bindings {
+		rule, _, err := parseHTTPRule(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid google.api.http option for %s: %w", v.Desc.FullName(), err)
+		}
+		rules = append(rules, rule)
+	}
+	for _, rule := range rules {
+		if err := x.resolveHTTPRule(v, rule); err != nil {
+			return nil, fmt.Errorf("invalid google.api.http option for %s: %w", v.Desc.FullName(), err)
+		}
+	}
+	return rules, nil
+}
+
+func (x *Cache) resolveHTTPRule(v *protogen.Method, rule *HTTPRule) error {
+	variables, err := parsePathVariables(rule.Path)
+	if err != nil {
+		return err
+	}
+	for _, variable := range variables {
+		path, err := ResolveFieldPath(v.Input, variable.Path)
+		if err != nil {
+			return err
+		}
+		variable.FieldPath = path
+		variable.Type = x.fieldType(path.Fields[len(path.Fields)-1].Desc)
+		rule.Variables = append(rule.Variables, variable)
+	}
+	if rule.Body != `` && rule.Body != `*` {
+		path, err := ResolveFieldPath(v.Input, rule.Body)
+		if err != nil {
+			return err
+		}
+		rule.BodyField = path.Fields[len(path.Fields)-1]
+	}
+	if rule.ResponseBody != `` {
+		path, err := ResolveFieldPath(v.Output, rule.ResponseBody)
+		if err != nil {
+			return err
+		}
+		rule.ResponseBodyField = path.Fields[len(path.Fields)-1]
+	}
+	return nil
+}
+
+// parseHTTPRule decodes an encoded google.api.HttpRule, returning the encoded additional bindings separately.
+func parseHTTPRule(b []byte) (*HTTPRule, [][]byte, error) {
+	var (
+		rule     HTTPRule
+		bindings [][]byte
+	)
+	for len(b) != 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return nil, nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return nil, nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 2:
+			rule.Method, rule.Path = `GET`, string(value)
+		case 3:
+			rule.Method, rule.Path = `PUT`, string(value)
+		case 4:
+			rule.Method, rule.Path = `POST`, string(value)
+		case 5:
+			rule.Method, rule.Path = `DELETE`, string(value)
+		case 6:
+			rule.Method, rule.Path = `PATCH`, string(value)
+		case 7:
+			rule.Body = string(value)
+		case 8:
+			kind, path, err := parseCustomHTTPPattern(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			rule.Method, rule.Path = kind, path
+		case 11:
+			bindings = append(bindings, value)
+		case 12:
+			rule.ResponseBody = string(value)
+		}
+	}
+	return &rule, bindings, nil
+}
+
+// parseCustomHTTPPattern decodes an encoded google.api.CustomHttpPattern.
+func parseCustomHTTPPattern(b []byte) (kind, path string, err error) {
+	for len(b) != 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return ``, ``, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return ``, ``, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return ``, ``, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			kind = string(value)
+		case 2:
+			path = string(value)
+		}
+	}
+	return
+}
+
+// parsePathVariables returns the variables of the given path template, with only Path and Pattern populated.
+func parsePathVariables(template string) ([]PathVariable, error) {
+	var variables []PathVariable
+	for s := template; ; {
+		i := strings.IndexByte(s, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("invalid path template %q: unterminated variable", template)
+		}
+		variable := PathVariable{Pattern: `*`}
+		variable.Path = s[i+1 : i+j]
+		if k := strings.IndexByte(variable.Path, '='); k >= 0 {
+			variable.Path, variable.Pattern = variable.Path[:k], variable.Path[k+1:]
+		}
+		if variable.Path == `` {
+			return nil, fmt.Errorf("invalid path template %q: empty variable", template)
+		}
+		variables = append(variables, variable)
+		s = s[i+j+1:]
+	}
+	return variables, nil
+}
@@ -0,0 +1,338 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// HTTPClientGenerator generates, for each service with any google.api.http rules, a plain net/http client, with a
+	// method for each unary method with a rule, using the primary binding (see also Method.HTTP), e.g. for
+	// environments that can't depend on gRPC, calling services exposed via a gateway, like grpc-gateway.
+	//
+	// Path variables are interpolated from the (scalar) request fields they are bound to, escaped, and the remaining
+	// scalar, enum, and repeated scalar request fields, which aren't bound to the path or body, are encoded as query
+	// parameters, named by their field path (e.g. "inner.value"), recursing into singular message fields, other than
+	// well-known types. Zero values are omitted, unless the field has explicit presence. Request and response bodies
+	// are encoded as JSON, using protojson, where the body must be "*", or a singular message field, and the response
+	// body, if any, must be a singular message field.
+	//
+	// For a service named "Service", the client is named "ServiceHTTPClient", and responses with a non-2xx status
+	// are returned as a "ServiceHTTPError".
+	HTTPClientGenerator struct {
+		// Cache is used to resolve message types, and the rules of each method, and must contain all referenced
+		// files.
+		Cache *Cache
+	}
+)
+
+var (
+	_ Generator = (*HTTPClientGenerator)(nil)
+
+	httpPkg = gopoet.NewPackage("net/http")
+	urlPkg  = gopoet.NewPackage("net/url")
+)
+
+// GenerateFile returns the client, and error type, for each service in the given file, with any google.api.http
+// rules.
+func (x *HTTPClientGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range file.Services {
+		methods := x.Methods(v)
+		if len(methods) == 0 {
+			continue
+		}
+		elements = append(elements, x.Client(v))
+		for _, method := range methods {
+			elements = append(elements, x.Func(method))
+		}
+		elements = append(elements, x.Error(v)...)
+	}
+	return elements
+}
+
+// Methods returns the model of each unary method of the given service, with any google.api.http rules. It panics
+// if any method has invalid, or unsupported, options, unless Cache.Diagnostics is set, in which case an error is
+// reported, and the method skipped.
+func (x *HTTPClientGenerator) Methods(v *protogen.Service) []*Method {
+	var methods []*Method
+	for _, method := range v.Methods {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			continue
+		}
+		m, err := x.Cache.Method(method)
+		if err != nil {
+			x.Cache.Diagnostics.Errorf(method.Desc, "%v", err)
+			continue
+		}
+		if len(m.HTTP) == 0 {
+			continue
+		}
+		if reason := x.unsupported(m); reason != `` {
+			x.Cache.Diagnostics.Errorf(method.Desc, "unsupported google.api.http rule for %s: %s", method.Desc.FullName(), reason)
+			continue
+		}
+		methods = append(methods, m)
+	}
+	return methods
+}
+
+// unsupported returns the reason the (first) HTTP rule of the given method isn't supported, or an empty string.
+func (x *HTTPClientGenerator) unsupported(method *Method) string {
+	rule := method.HTTP[0]
+	for _, v := range rule.Variables {
+		if field := v.Last(); field.Desc.IsList() || field.Desc.IsMap() || field.Desc.Message() != nil {
+			return fmt.Sprintf(`path variable %s is not a scalar field`, v.Path)
+		}
+	}
+	if rule.BodyField != nil && !isMessageField(rule.BodyField) {
+		return fmt.Sprintf(`body %s is not a singular message field`, rule.Body)
+	}
+	if rule.ResponseBodyField != nil && !isMessageField(rule.ResponseBodyField) {
+		return fmt.Sprintf(`response_body %s is not a singular message field`, rule.ResponseBody)
+	}
+	return ``
+}
+
+// Name returns the name of the client type for the given service, e.g. "ServiceHTTPClient".
+func (x *HTTPClientGenerator) Name(v *protogen.Service) string {
+	return v.GoName + `HTTPClient`
+}
+
+// ErrorName returns the name of the error type for the given service, e.g. "ServiceHTTPError".
+func (x *HTTPClientGenerator) ErrorName(v *protogen.Service) string {
+	return v.GoName + `HTTPError`
+}
+
+// Client returns the client struct for the given service.
+func (x *HTTPClientGenerator) Client(v *protogen.Service) *gopoet.TypeDecl {
+	name := x.Name(v)
+	return gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+		gopoet.NewField(`BaseURL`, gopoet.StringType).
+			SetComment(`BaseURL is prepended to the path of each request, e.g. "https://example.com", without a trailing slash.`),
+		gopoet.NewField(`Client`, gopoet.PointerType(gopoet.NamedType(httpPkg.Symbol(`Client`)))).
+			SetComment(`Client is used to send requests, and defaults to http.DefaultClient.`),
+	).SetComment(fmt.Sprintf(`%s is a net/http client for the %s service, using the google.api.http rules of each method.`, name, v.Desc.FullName())))
+}
+
+// Error returns the error type, and its Error method, for the given service.
+func (x *HTTPClientGenerator) Error(v *protogen.Service) []gopoet.FileElement {
+	name := x.ErrorName(v)
+	return []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+			gopoet.NewField(`Method`, gopoet.StringType).
+				SetComment(`Method is the full name of the method, e.g. "/package.Service/Method".`),
+			gopoet.NewField(`StatusCode`, gopoet.IntType).
+				SetComment(`StatusCode is the HTTP status code of the response.`),
+			gopoet.NewField(`Status`, gopoet.StringType).
+				SetComment(`Status is the HTTP status of the response, e.g. "404 Not Found".`),
+			gopoet.NewField(`Body`, gopoet.SliceType(gopoet.ByteType)).
+				SetComment(`Body is the body of the response.`),
+		).SetComment(fmt.Sprintf(`%s is returned by %s, for responses with a non-2xx status.`, name, x.Name(v)))),
+		gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, localType(name)), `Error`).
+			SetComment(`Error implements the error interface.`).
+			AddResult(``, gopoet.StringType).
+			Printlnf(`return %s("%%s: unexpected HTTP status %%s: %%s", x.Method, x.Status, %s(x.Body))`, fmtPkg.Symbol(`Sprintf`), bytesPkg.Symbol(`TrimSpace`)),
+	}
+}
+
+// Func returns the client method for the given method, which must have a google.api.http rule, see also Methods.
+func (x *HTTPClientGenerator) Func(v *Method) *gopoet.FuncSpec {
+	var (
+		rule     = v.HTTP[0]
+		method   = v.Method
+		response = x.Cache.MessageType(method.Output.Desc)
+		f        = gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, localType(x.Name(method.Parent))), method.GoName).
+				SetComment(fmt.Sprintf(`%s calls %s, via %s %s.`, method.GoName, method.Desc.FullName(), rule.Method, rule.Path)).
+				AddArg(`ctx`, gopoet.NamedType(contextPkg.Symbol(`Context`))).
+				AddArg(`req`, gopoet.PointerType(x.Cache.MessageType(method.Input.Desc))).
+				AddResult(``, gopoet.PointerType(response)).
+				AddResult(``, gopoet.ErrorType)
+	)
+	f.Print(`u := x.BaseURL`)
+	template := rule.Path
+	for _, variable := range rule.Variables {
+		i := strings.IndexByte(template, '{')
+		j := strings.IndexByte(template, '}')
+		if i != 0 {
+			f.Printf(` + %q`, template[:i])
+		}
+		f.Print(` + `).AddCode(x.pathValue(variable))
+		template = template[j+1:]
+	}
+	if template != `` {
+		f.Printf(` + %q`, template)
+	}
+	f.Println(``)
+	if params := x.queryParams(v); len(params) != 0 {
+		f.Printlnf(`q := make(%s)`, urlPkg.Symbol(`Values`))
+		for _, param := range params {
+			f.AddCode(x.queryParam(param))
+		}
+		f.Println(`if len(q) != 0 {`).
+			Println(`u += "?" + q.Encode()`).
+			Println(`}`)
+	}
+	f.Printlnf(`var body %s`, ioPkg.Symbol(`Reader`))
+	if rule.Body != `` {
+		body := `req`
+		if rule.BodyField != nil {
+			if !isMessageField(rule.BodyField) {
+				panic(fmt.Sprintf("unsupported google.api.http body for %s: %s is not a singular message field", method.Desc.FullName(), rule.Body))
+			}
+			body = `req.Get` + rule.BodyField.GoName + `()`
+		}
+		f.Printlnf(`data, err := %s(%s)`, protojsonPkg.Symbol(`Marshal`), body).
+			Println(`if err != nil {`).
+			Println(`return nil, err`).
+			Println(`}`).
+			Printlnf(`body = %s(data)`, bytesPkg.Symbol(`NewReader`))
+	}
+	f.Printlnf(`r, err := %s(ctx, %q, u, body)`, httpPkg.Symbol(`NewRequestWithContext`), rule.Method).
+		Println(`if err != nil {`).
+		Println(`return nil, err`).
+		Println(`}`).
+		Println(`r.Header.Set("Accept", "application/json")`).
+		Println(`if body != nil {`).
+		Println(`r.Header.Set("Content-Type", "application/json")`).
+		Println(`}`).
+		Println(`client := x.Client`).
+		Println(`if client == nil {`).
+		Printlnf(`client = %s`, httpPkg.Symbol(`DefaultClient`)).
+		Println(`}`).
+		Println(`res, err := client.Do(r)`).
+		Println(`if err != nil {`).
+		Println(`return nil, err`).
+		Println(`}`).
+		Println(`defer res.Body.Close()`).
+		Printlnf(`b, err := %s(res.Body)`, ioPkg.Symbol(`ReadAll`)).
+		Println(`if err != nil {`).
+		Println(`return nil, err`).
+		Println(`}`).
+		Println(`if res.StatusCode < 200 || res.StatusCode >= 300 {`).
+		Printlnf(`return nil, &%s{Method: %q, StatusCode: res.StatusCode, Status: res.Status, Body: b}`, x.ErrorName(method.Parent), FullMethodName(method)).
+		Println(`}`).
+		Printlnf(`out := new(%s)`, response)
+	target := `out`
+	if rule.ResponseBodyField != nil {
+		if !isMessageField(rule.ResponseBodyField) {
+			panic(fmt.Sprintf("unsupported google.api.http response_body for %s: %s is not a singular message field", method.Desc.FullName(), rule.ResponseBody))
+		}
+		target = `out.` + rule.ResponseBodyField.GoName
+		f.Printlnf(`%s = new(%s)`, target, x.Cache.MessageType(rule.ResponseBodyField.Message.Desc))
+	}
+	return f.Println(`if len(b) != 0 {`).
+		Printlnf(`if err := (%s{DiscardUnknown: true}).Unmarshal(b, %s); err != nil {`, protojsonPkg.Symbol(`UnmarshalOptions`), target).
+		Println(`return nil, err`).
+		Println(`}`).
+		Println(`}`).
+		Println(`return out, nil`)
+}
+
+// pathValue returns a golang expression formatting, and escaping, the value of the given path variable, where
+// patterns matching multiple segments, e.g. "shelves/*", retain any slashes.
+func (x *HTTPClientGenerator) pathValue(v PathVariable) *gopoet.CodeBlock {
+	field := v.Last()
+	if field.Desc.IsList() || field.Desc.IsMap() || field.Desc.Message() != nil {
+		panic(fmt.Sprintf("unsupported google.api.http path variable %s: %s is not a scalar field", v.Path, field.Desc.FullName()))
+	}
+	cb := gopoet.Printf(`%s(`, urlPkg.Symbol(`PathEscape`)).
+		AddCode(x.queryValue(field, httpGetterExpr(v.Fields))).
+		Print(`)`)
+	if v.Pattern == `*` {
+		return cb
+	}
+	return gopoet.Printf(`%s(`, stringsPkg.Symbol(`ReplaceAll`)).AddCode(cb).Print(`, "%2F", "/")`)
+}
+
+// queryParams returns the paths of the request fields encoded as query parameters, for the given method, i.e. all
+// scalar fields not bound to the path, or body, of the primary binding.
+func (x *HTTPClientGenerator) queryParams(v *Method) []FieldPath {
+	rule := v.HTTP[0]
+	if rule.Body == `*` {
+		return nil
+	}
+	var (
+		bound  []string
+		params []FieldPath
+	)
+	for _, variable := range rule.Variables {
+		bound = append(bound, variable.Path)
+	}
+	if rule.BodyField != nil {
+		bound = append(bound, rule.Body)
+	}
+	for _, path := range FieldPaths(v.Method.Input, &FieldPathOptions{Exclude: func(field *protogen.Field) bool {
+		return field.Desc.IsMap() || field.Message != nil && IsWellKnownType(field.Message.Desc)
+	}}) {
+		if path.Last().Desc.Message() == nil && !isBoundPath(path.Path, bound) {
+			params = append(params, path)
+		}
+	}
+	return params
+}
+
+// queryParam returns statements adding the value(s) of the given request field to the query parameters, q.
+func (x *HTTPClientGenerator) queryParam(v FieldPath) *gopoet.CodeBlock {
+	var (
+		field = v.Last()
+		desc  = field.Desc
+		expr  = httpGetterExpr(v.Fields)
+		cb    = new(gopoet.CodeBlock)
+	)
+	switch {
+	case desc.IsList():
+		cb.Printlnf(`for _, v := range %s {`, expr).
+			Printf(`q.Add(%q, `, v.Path).AddCode(x.queryValue(field, `v`)).Println(`)`).
+			Println(`}`)
+	case desc.HasPresence() && desc.Kind() != protoreflect.BytesKind &&
+		(desc.ContainingOneof() == nil || desc.ContainingOneof().IsSynthetic()):
+		cb.Printlnf(`if p := %s; p != nil && p.%s != nil {`, httpGetterExpr(v.Fields[:len(v.Fields)-1]), field.GoName).
+			Printf(`q.Add(%q, `, v.Path).AddCode(x.queryValue(field, `*p.`+field.GoName)).Println(`)`).
+			Println(`}`)
+	default:
+		cb.Printlnf(`if v := %s; %s {`, expr, zeroCheck(desc.Kind(), `v`)).
+			Printf(`q.Add(%q, `, v.Path).AddCode(x.queryValue(field, `v`)).Println(`)`).
+			Println(`}`)
+	}
+	return cb
+}
+
+// queryValue returns a golang expression formatting the given (scalar or enum) value as a string, where bytes are
+// base64 encoded, and enums are formatted as their name.
+func (x *HTTPClientGenerator) queryValue(field *protogen.Field, expr string) *gopoet.CodeBlock {
+	if field.Desc.Kind() == protoreflect.BytesKind {
+		return gopoet.Printf(`%s.EncodeToString(%s)`, base64Pkg.Symbol(`StdEncoding`), expr)
+	}
+	return x.Cache.formatScalar(field, expr)
+}
+
+// httpGetterExpr returns a golang expression accessing the value of the last of the given fields, from req, via a
+// chain of getter calls, e.g. `req.GetA().GetB()`.
+func httpGetterExpr(fields []*protogen.Field) string {
+	expr := `req`
+	for _, field := range fields {
+		expr += `.Get` + field.GoName + `()`
+	}
+	return expr
+}
+
+// isBoundPath returns true if the given field path is, or is within, any of the bound paths.
+func isBoundPath(path string, bound []string) bool {
+	for _, v := range bound {
+		if path == v || strings.HasPrefix(path, v+`.`) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMessageField returns true if the given field is a singular message field, excluding oneof fields.
+func isMessageField(field *protogen.Field) bool {
+	desc := field.Desc
+	return desc.Message() != nil && !desc.IsList() && !desc.IsMap() &&
+		(desc.ContainingOneof() == nil || desc.ContainingOneof().IsSynthetic())
+}
@@ -0,0 +1,56 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// IdempotencyGenerator generates, for each service, tables of per-method safety metadata, keyed by full method
+	// name, derived from the idempotency_level method option, e.g. for retry middleware, which may only retry
+	// idempotent methods, or hedging, and caching middleware, which may only apply to safe methods.
+	//
+	// For a service named "Service", the generated elements are named "Service_SafeMethods" (true for methods with
+	// an idempotency_level of NO_SIDE_EFFECTS), and "Service_IdempotentMethods" (true for methods with an
+	// idempotency_level of NO_SIDE_EFFECTS or IDEMPOTENT), both of type map[string]bool, with an entry for every
+	// method of the service.
+	IdempotencyGenerator struct{}
+)
+
+var (
+	_ Generator = (*IdempotencyGenerator)(nil)
+)
+
+// GenerateFile returns the safety metadata tables for each service in the given file.
+func (x *IdempotencyGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range file.Services {
+		elements = append(elements, x.Vars(v))
+	}
+	return elements
+}
+
+// Vars returns the safe and idempotent method tables for the given service.
+func (x *IdempotencyGenerator) Vars(v *protogen.Service) *gopoet.VarDecl {
+	var (
+		safe         = v.GoName + `_SafeMethods`
+		idempotent   = v.GoName + `_IdempotentMethods`
+		tableType    = gopoet.MapType(gopoet.StringType, gopoet.BoolType)
+		safeCB       = gopoet.Printlnf(`%s{`, tableType)
+		idempotentCB = gopoet.Printlnf(`%s{`, tableType)
+	)
+	for _, method := range v.Methods {
+		m := &Method{Method: method}
+		safeCB.Printlnf(`%q: %t,`, FullMethodName(method), m.IsSafe())
+		idempotentCB.Printlnf(`%q: %t,`, FullMethodName(method), m.IsIdempotent())
+	}
+	return gopoet.NewVarDecl(
+		gopoet.NewVar(safe).
+			SetComment(fmt.Sprintf(`%s maps the full name of each %s method to true if it has no side effects, i.e. it may be retried, hedged, or cached.`, safe, v.Desc.FullName())).
+			SetInitializer(safeCB.Print(`}`)),
+		gopoet.NewVar(idempotent).
+			SetComment(fmt.Sprintf(`%s maps the full name of each %s method to true if it is idempotent, i.e. it may be retried.`, idempotent, v.Desc.FullName())).
+			SetInitializer(idempotentCB.Print(`}`)),
+	)
+}
@@ -0,0 +1,121 @@
+package gopoet_protogen
+
+import (
+	"bytes"
+	"github.com/jhump/gopoet"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"google.golang.org/protobuf/compiler/protogen"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// VersionedImportAlias is an import alias policy, see also Cache.ImportAlias, which prefixes version-like package
+// names (e.g. "v1", "v2beta1") with the base name of the parent directory, of the import path, such that
+// "example.com/foo/v1" and "example.com/bar/v1" are referenced as "foov1" and "barv1", respectively. Other package
+// names are returned as-is.
+func VersionedImportAlias(importPath protogen.GoImportPath, packageName protogen.GoPackageName) string {
+	if !isVersion(string(packageName)) {
+		return string(packageName)
+	}
+	parent := path.Base(path.Dir(string(importPath)))
+	if parent == `.` || parent == `/` {
+		return string(packageName)
+	}
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, parent) + string(packageName)
+}
+
+// WriteGoFile renders the given file, like gopoet.WriteGoFile, then adds an explicit alias to each import, of a
+// package loaded into the cache, that is referenced using a name other than its package name, e.g. due to
+// ImportAlias, which gopoet would otherwise omit. Any build constraint added by GoFileFactory, or embed directive
+// added by FixtureGenerator, is also corrected.
+func (x *Cache) WriteGoFile(w io.Writer, file *gopoet.GoFile) error {
+	var b bytes.Buffer
+	if err := gopoet.WriteGoFile(&b, file); err != nil {
+		return err
+	}
+	src := fixEmbedDirectives(fixBuildConstraints(b.Bytes()))
+	aliases := make(map[string]string)
+	for _, v := range file.ImportSpecs() {
+		if v.PackageAlias != `` {
+			continue
+		}
+		name := strings.TrimSuffix(file.PrefixForPackage(v.ImportPath), `.`)
+		if packageName, ok := x.packages[protogen.GoImportPath(v.ImportPath)]; ok && string(packageName) != name {
+			aliases[v.ImportPath] = name
+		}
+	}
+	if len(aliases) == 0 {
+		_, err := w.Write(src)
+		return err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file.Name, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	for _, v := range f.Imports {
+		if importPath, err := strconv.Unquote(v.Path.Value); err == nil && v.Name == nil && aliases[importPath] != `` {
+			v.Name = ast.NewIdent(aliases[importPath])
+		}
+	}
+	b.Reset()
+	if err := format.Node(&b, fset, f); err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// goPackage returns the package with the given import path, named using the package name of the files loaded into
+// the cache, if any, or the base name of the import path, otherwise, see also ImportAlias and ManagedMode.
+func (x *Cache) goPackage(importPath protogen.GoImportPath) gopoet.Package {
+	if pkg, ok := x.pkgs[importPath]; ok {
+		// resolved by addType
+		return pkg
+	}
+	if v, ok := x.remap[importPath]; ok {
+		importPath = v
+	}
+	pkg := gopoet.NewPackage(string(importPath))
+	if packageName, ok := x.packages[importPath]; ok {
+		pkg.Name = string(packageName)
+	}
+	if x.ImportAlias != nil {
+		pkg.Name = x.ImportAlias(importPath, protogen.GoPackageName(pkg.Name))
+	}
+	return pkg
+}
+
+// isVersion returns true if the given package name is a version, e.g. "v1", "v1alpha", or "v2beta1".
+func isVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' || s[1] < '0' || s[1] > '9' {
+		return false
+	}
+	s = strings.TrimLeft(s[1:], `0123456789`)
+	for _, label := range [...]string{`alpha`, `beta`} {
+		if strings.HasPrefix(s, label) {
+			s = strings.TrimLeft(s[len(label):], `0123456789`)
+			break
+		}
+	}
+	return s == ``
+}
+
+// goIdent returns the given identifier, with the import path overridden by ManagedMode, if applicable.
+func (x *Cache) goIdent(v protogen.GoIdent) protogen.GoIdent {
+	if importPath, ok := x.remap[v.GoImportPath]; ok {
+		v.GoImportPath = importPath
+	}
+	return v
+}
@@ -0,0 +1,194 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// ValidationInterceptorGenerator generates, for each service, gRPC server interceptors, that validate each
+	// request, using the functions generated by ValidatorGenerator, which must be generated into the same package,
+	// returning an INVALID_ARGUMENT status, with google.rpc.BadRequest details, identifying the path of the invalid
+	// field, if a request is invalid. Request types that aren't declared in the same file as the service fall back
+	// to proto.CheckInitialized.
+	//
+	// For a service named "Service", the generated elements are "Service_ValidateRequest", which validates a request,
+	// given the full method name, "Service_ValidationError", which converts a validation error to a status error,
+	// "Service_ValidationUnaryServerInterceptor", and, if the service has any streaming methods,
+	// "Service_ValidationStreamServerInterceptor", which validates each message received by the stream. Methods of
+	// other services are passed through, unmodified. The generated code depends on google.golang.org/grpc, and
+	// google.golang.org/genproto.
+	ValidationInterceptorGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Validator may be set to use the names of the functions generated by a customized ValidatorGenerator.
+		Validator *ValidatorGenerator
+	}
+)
+
+var (
+	_ Generator = (*ValidationInterceptorGenerator)(nil)
+)
+
+// GenerateFile returns the validation function, error function, and interceptors, for each service in the given
+// file.
+func (x *ValidationInterceptorGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range file.Services {
+		elements = append(elements, x.ValidateFunc(v), x.ErrorFunc(v), x.UnaryFunc(v))
+		if hasStreaming(v) {
+			elements = append(elements, x.Stream(v)...)
+			elements = append(elements, x.StreamFunc(v))
+		}
+	}
+	return elements
+}
+
+// ValidateFunc returns the function validating the request of each method of the given service, which accepts the
+// full method name, and the request, returning nil for unknown methods, or unexpected request types.
+func (x *ValidationInterceptorGenerator) ValidateFunc(v *protogen.Service) *gopoet.FuncSpec {
+	name := v.GoName + `_ValidateRequest`
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s validates the request of a %s method, given the full method name.`, name, v.Desc.FullName())).
+		AddArg(`method`, gopoet.StringType).
+		AddArg(`req`, emptyInterfaceType).
+		AddResult(``, gopoet.ErrorType).
+		Println(`switch method {`)
+	for _, method := range v.Methods {
+		request, _ := x.Cache.MethodTypes(method)
+		f.Printlnf(`case %q:`, FullMethodName(method)).
+			Printlnf(`if v, ok := req.(%s); ok {`, gopoet.PointerType(request))
+		if method.Input.Desc.ParentFile().Path() == v.Desc.ParentFile().Path() {
+			f.Printlnf(`return %s(v)`, x.validator().Name(method.Input))
+		} else {
+			f.Printlnf(`return %s(v)`, protoPkg.Symbol(`CheckInitialized`))
+		}
+		f.Println(`}`)
+	}
+	return f.Println(`}`).
+		Println(`return nil`)
+}
+
+// ErrorFunc returns the function converting a validation error, for the given service, to an INVALID_ARGUMENT status
+// error, with a google.rpc.BadRequest detail, where the field path is derived from the wrapped errors, e.g.
+// "items[0].name".
+func (x *ValidationInterceptorGenerator) ErrorFunc(v *protogen.Service) *gopoet.FuncSpec {
+	var (
+		name      = v.GoName + `_ValidationError`
+		violation = errdetailsPkg.Symbol(`BadRequest_FieldViolation`)
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s converts an error returned by %s_ValidateRequest to an INVALID_ARGUMENT status error, with the path of the invalid field.`, name, v.GoName)).
+		AddArg(`err`, gopoet.ErrorType).
+		AddResult(``, gopoet.ErrorType).
+		Println(`var (`).
+		Println(`path        []string`).
+		Println(`description = err.Error()`).
+		Println(`)`).
+		Println(`for {`).
+		Printlnf(`next := %s(err)`, errorsPkg.Symbol(`Unwrap`)).
+		Println(`if next == nil {`).
+		Println(`break`).
+		Println(`}`).
+		Printlnf(`segment := %s(err.Error(), next.Error())`, stringsPkg.Symbol(`TrimSuffix`)).
+		Printlnf(`if segment == err.Error() || !%s(segment, ": ") {`, stringsPkg.Symbol(`HasSuffix`)).
+		Println(`break`).
+		Println(`}`).
+		Println(`path = append(path, segment[:len(segment)-2])`).
+		Println(`err = next`).
+		Println(`}`).
+		Printlnf(`if field := %s(err.Error(), %q); field != err.Error() {`, stringsPkg.Symbol(`TrimPrefix`), `missing required field: `).
+		Println(`path = append(path, field)`).
+		Println(`}`).
+		Printlnf(`s := %s(%s, description)`, statusPkg.Symbol(`New`), codesPkg.Symbol(`InvalidArgument`)).
+		Printlnf(`if d, err := s.WithDetails(&%s{FieldViolations: []*%s{{`, errdetailsPkg.Symbol(`BadRequest`), violation).
+		Printlnf(`Field:       %s(path, "."),`, stringsPkg.Symbol(`Join`)).
+		Println(`Description: err.Error(),`).
+		Println(`}}}); err == nil {`).
+		Println(`s = d`).
+		Println(`}`).
+		Println(`return s.Err()`)
+}
+
+// UnaryFunc returns a grpc.UnaryServerInterceptor validating the request of each unary method of the given service.
+func (x *ValidationInterceptorGenerator) UnaryFunc(v *protogen.Service) *gopoet.FuncSpec {
+	name := v.GoName + `_ValidationUnaryServerInterceptor`
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s is a grpc.UnaryServerInterceptor, validating requests using %s_ValidateRequest.`, name, v.GoName)).
+		AddArg(`ctx`, gopoet.NamedType(contextPkg.Symbol(`Context`))).
+		AddArg(`req`, emptyInterfaceType).
+		AddArg(`info`, gopoet.PointerType(gopoet.NamedType(grpcPkg.Symbol(`UnaryServerInfo`)))).
+		AddArg(`handler`, gopoet.NamedType(grpcPkg.Symbol(`UnaryHandler`))).
+		AddResult(``, emptyInterfaceType).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`if err := %s_ValidateRequest(info.FullMethod, req); err != nil {`, v.GoName).
+		Printlnf(`return nil, %s_ValidationError(err)`, v.GoName).
+		Println(`}`).
+		Println(`return handler(ctx, req)`)
+}
+
+// StreamFunc returns a grpc.StreamServerInterceptor validating each request received by each streaming method of
+// the given service.
+func (x *ValidationInterceptorGenerator) StreamFunc(v *protogen.Service) *gopoet.FuncSpec {
+	name := v.GoName + `_ValidationStreamServerInterceptor`
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s is a grpc.StreamServerInterceptor, validating requests using %s_ValidateRequest.`, name, v.GoName)).
+		AddArg(`srv`, emptyInterfaceType).
+		AddArg(`ss`, gopoet.NamedType(grpcPkg.Symbol(`ServerStream`))).
+		AddArg(`info`, gopoet.PointerType(gopoet.NamedType(grpcPkg.Symbol(`StreamServerInfo`)))).
+		AddArg(`handler`, gopoet.NamedType(grpcPkg.Symbol(`StreamHandler`))).
+		AddResult(``, gopoet.ErrorType).
+		Println(`switch info.FullMethod {`)
+	for _, method := range v.Methods {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			f.Printlnf(`case %q:`, FullMethodName(method)).
+				Printlnf(`ss = &%s{ss, info.FullMethod}`, x.streamName(v))
+		}
+	}
+	return f.Println(`}`).
+		Println(`return handler(srv, ss)`)
+}
+
+// Stream returns the (unexported) grpc.ServerStream wrapper, and its RecvMsg method, for the given service.
+func (x *ValidationInterceptorGenerator) Stream(v *protogen.Service) []gopoet.FileElement {
+	name := x.streamName(v)
+	return []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+			gopoet.NewField(``, gopoet.NamedType(grpcPkg.Symbol(`ServerStream`))),
+			gopoet.NewField(`method`, gopoet.StringType),
+		).SetComment(fmt.Sprintf(`%s wraps a grpc.ServerStream, validating received messages, see also %s_ValidationStreamServerInterceptor.`, name, v.GoName))),
+		gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, localType(name)), `RecvMsg`).
+			SetComment(`RecvMsg receives, then validates, the next message.`).
+			AddArg(`m`, emptyInterfaceType).
+			AddResult(``, gopoet.ErrorType).
+			Println(`if err := x.ServerStream.RecvMsg(m); err != nil {`).
+			Println(`return err`).
+			Println(`}`).
+			Printlnf(`if err := %s_ValidateRequest(x.method, m); err != nil {`, v.GoName).
+			Printlnf(`return %s_ValidationError(err)`, v.GoName).
+			Println(`}`).
+			Println(`return nil`),
+	}
+}
+
+func (x *ValidationInterceptorGenerator) validator() *ValidatorGenerator {
+	if x.Validator != nil {
+		return x.Validator
+	}
+	return &ValidatorGenerator{Cache: x.Cache}
+}
+
+func (x *ValidationInterceptorGenerator) streamName(v *protogen.Service) string {
+	return `validating` + v.GoName + `ServerStream`
+}
+
+// hasStreaming returns true if the given service has any client or server streaming methods.
+func hasStreaming(v *protogen.Service) bool {
+	for _, method := range v.Methods {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			return true
+		}
+	}
+	return false
+}
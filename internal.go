@@ -0,0 +1,119 @@
+package gopoet_protogen
+
+import (
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// InternalSymbols models the file-level symbols generated by protoc-gen-go, for a file, see also
+	// Cache.InternalSymbols.
+	//
+	// WARNING: Other than FileDescriptor, these are implementation details of protoc-gen-go, which are unexported,
+	// and may therefore only be referenced by code generated into the same package, and are coupled to the version
+	// of protoc-gen-go (the algorithm is that of v1.28), i.e. they may change, without notice, in future versions.
+	InternalSymbols struct {
+		// FileDescriptor is the exported protoreflect.FileDescriptor var, e.g. File_foo_v1_bar_proto.
+		FileDescriptor gopoet.Symbol
+		// RawDesc is the var containing the serialized descriptor, e.g. file_foo_v1_bar_proto_rawDesc.
+		RawDesc gopoet.Symbol
+		// RawDescGZIP is the func returning the compressed, serialized descriptor, used by Descriptor methods, e.g.
+		// file_foo_v1_bar_proto_rawDescGZIP.
+		RawDescGZIP gopoet.Symbol
+		// GoTypes is the var containing the golang types of the file, and its dependencies, e.g.
+		// file_foo_v1_bar_proto_goTypes.
+		GoTypes gopoet.Symbol
+		// DepIdxs is the var containing the dependency indexes, into GoTypes, e.g. file_foo_v1_bar_proto_depIdxs.
+		DepIdxs gopoet.Symbol
+		// EnumTypes is the []protoimpl.EnumInfo var, indexed per Enums, e.g. file_foo_v1_bar_proto_enumTypes.
+		EnumTypes gopoet.Symbol
+		// MessageTypes is the []protoimpl.MessageInfo var, indexed per Messages, e.g.
+		// file_foo_v1_bar_proto_msgTypes.
+		MessageTypes gopoet.Symbol
+		// ExtensionTypes is the []protoimpl.ExtensionInfo var, indexed per Extensions, e.g.
+		// file_foo_v1_bar_proto_extTypes.
+		ExtensionTypes gopoet.Symbol
+		// Init is the func initializing the file, e.g. file_foo_v1_bar_proto_init.
+		Init gopoet.Symbol
+		// Enums are the enums of the file, in the "flattened ordering" of protoc-gen-go, i.e. the indexes of
+		// EnumTypes.
+		Enums []*protogen.Enum
+		// Messages are the messages of the file, including map entries, in the "flattened ordering" of
+		// protoc-gen-go, i.e. the indexes of MessageTypes.
+		Messages []*protogen.Message
+		// Extensions are the extensions of the file, in the "flattened ordering" of protoc-gen-go, i.e. the indexes
+		// of ExtensionTypes.
+		Extensions []*protogen.Extension
+	}
+)
+
+// InternalSymbols returns the file-level symbols generated by protoc-gen-go, for the given file, which must be
+// loaded into the cache, see also InternalSymbols, which documents the caveats.
+func (x *Cache) InternalSymbols(file *protogen.File) *InternalSymbols {
+	var (
+		pkg    = x.goPackage(file.GoDescriptorIdent.GoImportPath)
+		prefix = file.GoDescriptorIdent.GoName
+	)
+	// https://github.com/protocolbuffers/protobuf-go/blob/v1.28.1/cmd/protoc-gen-go/internal_gengo/reflect.go#L325
+	_, n := utf8.DecodeRuneInString(prefix)
+	prefix = strings.ToLower(prefix[:n]) + prefix[n:] + `_`
+	v := InternalSymbols{
+		FileDescriptor: pkg.Symbol(file.GoDescriptorIdent.GoName),
+		RawDesc:        pkg.Symbol(prefix + `rawDesc`),
+		RawDescGZIP:    pkg.Symbol(prefix + `rawDescGZIP`),
+		GoTypes:        pkg.Symbol(prefix + `goTypes`),
+		DepIdxs:        pkg.Symbol(prefix + `depIdxs`),
+		EnumTypes:      pkg.Symbol(prefix + `enumTypes`),
+		MessageTypes:   pkg.Symbol(prefix + `msgTypes`),
+		ExtensionTypes: pkg.Symbol(prefix + `extTypes`),
+		Init:           pkg.Symbol(prefix + `init`),
+	}
+	// https://github.com/protocolbuffers/protobuf-go/blob/v1.28.1/cmd/protoc-gen-go/internal_gengo/init.go#L50
+	v.Enums = append(v.Enums, file.Enums...)
+	v.Messages = append(v.Messages, file.Messages...)
+	v.Extensions = append(v.Extensions, file.Extensions...)
+	var walk func(messages []*protogen.Message)
+	walk = func(messages []*protogen.Message) {
+		for _, message := range messages {
+			v.Enums = append(v.Enums, message.Enums...)
+			v.Messages = append(v.Messages, message.Messages...)
+			v.Extensions = append(v.Extensions, message.Extensions...)
+			walk(message.Messages)
+		}
+	}
+	walk(file.Messages)
+	return &v
+}
+
+// EnumIndex returns the index of the given enum, in EnumTypes, or -1, if it isn't declared in the file.
+func (x *InternalSymbols) EnumIndex(v *protogen.Enum) int {
+	for i, enum := range x.Enums {
+		if enum == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// MessageIndex returns the index of the given message, in MessageTypes, or -1, if it isn't declared in the file.
+func (x *InternalSymbols) MessageIndex(v *protogen.Message) int {
+	for i, message := range x.Messages {
+		if message == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// ExtensionIndex returns the index of the given extension, in ExtensionTypes, or -1, if it isn't declared in the
+// file.
+func (x *InternalSymbols) ExtensionIndex(v *protogen.Extension) int {
+	for i, extension := range x.Extensions {
+		if extension == v {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,101 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// IterGenerator generates range functions for the repeated and map fields of messages, which return push
+	// iterators, compatible with iter.Seq (for repeated fields), and iter.Seq2 (for map fields), of Go 1.23, e.g.
+	// `for k, v := range RangeMsg_Labels(msg)`. If Methods is set, the method itself is the iterator, i.e. it
+	// accepts the yield function, e.g. `for v := range msg.RangeItems`, which avoids allocating.
+	//
+	// Values are read using the getter, such that the message may be nil. Map entries are yielded in an unspecified
+	// order. Messages without any repeated or map fields are skipped.
+	IterGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Methods may be set to generate a method on each message type, named "Range" followed by the golang name of
+		// the field, rather than a function, which requires that the generated code is in the same package as the
+		// message.
+		Methods bool
+		// Filter may be set to exclude fields, which have no range function or method, see also FieldFilter.
+		Filter *FieldFilter
+	}
+)
+
+var (
+	_ Generator = (*IterGenerator)(nil)
+)
+
+// GenerateFile returns a range function or method for each repeated or map field of each message in the given file,
+// see also FileMessages.
+func (x *IterGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		for _, field := range x.Fields(v) {
+			elements = append(elements, x.Func(v, field))
+		}
+	}
+	return elements
+}
+
+// Fields returns the repeated and map fields of the given message, in declaration order.
+func (x *IterGenerator) Fields(v *protogen.Message) []Field {
+	var fields []Field
+	for _, field := range x.Filter.Fields(x.Cache.MessageFields(v)) {
+		if desc := field.Fields()[0].Desc; field.OneOf() == nil && (desc.IsList() || desc.IsMap()) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Name returns the name of the range function or method generated for the given field, which defaults to "Range"
+// followed by the golang name of the message, an underscore, and the golang name of the field, for functions, e.g.
+// RangeMsg_Items, or "Range" followed by the golang name of the field, for methods, e.g. RangeItems.
+func (x *IterGenerator) Name(field Field) string {
+	if x.Methods {
+		return `Range` + field.Name()
+	}
+	return `Range` + field.Fields()[0].GoIdent.GoName
+}
+
+// Func returns the range function or method for the given (repeated or map) field of the given message, where the
+// iterator calls yield for each element, or each key and value, until it returns false.
+func (x *IterGenerator) Func(v *protogen.Message, field Field) *gopoet.FuncSpec {
+	var (
+		name  = x.Name(field)
+		desc  = field.Fields()[0].Desc
+		yield = []gopoet.ArgType{{Type: field.Type().Elem()}}
+		vars  = [2]string{`_, v`, `v`}
+		f     *gopoet.FuncSpec
+	)
+	if desc.IsMap() {
+		yield = []gopoet.ArgType{{Type: x.Cache.fieldType(desc.MapKey())}, {Type: x.Cache.fieldType(desc.MapValue())}}
+		vars = [2]string{`k, v`, `k, v`}
+	}
+	yieldType := gopoet.FuncType(yield, []gopoet.ArgType{{Type: gopoet.BoolType}})
+	if x.Methods {
+		f = gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, x.Cache.MessageType(v.Desc)), name).
+			SetComment(fmt.Sprintf(`%s calls yield for each element of %s, until it returns false.`, name, desc.FullName())).
+			AddArg(`yield`, yieldType)
+	} else {
+		f = gopoet.NewFunc(name).
+			SetComment(fmt.Sprintf(`%s returns an iterator over the elements of %s.`, name, desc.FullName())).
+			AddArg(`x`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+			AddResult(``, gopoet.FuncType([]gopoet.ArgType{{Name: `yield`, Type: yieldType}}, nil)).
+			Printlnf(`return func(yield %s) {`, yieldType)
+	}
+	f.Printlnf(`for %s := range x.%s() {`, vars[0], field.Getter().Name).
+		Printlnf(`if !yield(%s) {`, vars[1]).
+		Println(`return`).
+		Println(`}`).
+		Println(`}`)
+	if !x.Methods {
+		f.Println(`}`)
+	}
+	return f
+}
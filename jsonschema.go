@@ -0,0 +1,88 @@
+package gopoet_protogen
+
+import (
+	"encoding/json"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// JSONSchemaGenerator renders JSON Schema (draft 2020-12) documents for messages, consistent with their protojson
+	// representation, e.g. for frontend validation. The schemas are the same as those of OpenAPIGenerator, e.g.
+	// 64-bit integers are strings, and google.protobuf.Timestamp is an RFC 3339 date-time string, except that
+	// oneof members are mutually exclusive.
+	//
+	// Each document is that of a single message, referencing a definition (in $defs) for the message, and each
+	// (transitively) referenced message and enum, see also GenerateFiles.
+	JSONSchemaGenerator struct {
+		// SchemaName may be used to override the name of the definition for each message or enum, which defaults to
+		// the full name, e.g. "pkg.Message".
+		SchemaName func(desc protoreflect.Descriptor) string
+		// UseProtoNames may be set to use proto field names as property names, rather than JSON names, consistent
+		// with protojson.MarshalOptions.UseProtoNames.
+		UseProtoNames bool
+	}
+)
+
+const (
+	jsonSchemaDialect   = `https://json-schema.org/draft/2020-12/schema`
+	jsonSchemaDefsPath  = `#/$defs/`
+	jsonSchemaExtension = `.schema.json`
+)
+
+// Schema returns the JSON Schema document for the given message.
+func (x *JSONSchemaGenerator) Schema(v *protogen.Message) *OpenAPISchema {
+	var (
+		generator = OpenAPIGenerator{
+			SchemaName:      x.SchemaName,
+			UseProtoNames:   x.UseProtoNames,
+			refPath:         jsonSchemaDefsPath,
+			exclusiveOneOfs: true,
+		}
+		schemas = make(map[string]*OpenAPISchema)
+	)
+	if schema := wellKnownSchema(v.Desc); schema != nil {
+		schema.Schema = jsonSchemaDialect
+		return schema
+	}
+	generator.addMessage(schemas, v)
+	return &OpenAPISchema{
+		Schema: jsonSchemaDialect,
+		Ref:    generator.ref(v.Desc),
+		Defs:   schemas,
+	}
+}
+
+// Document renders the JSON Schema document for the given message, as indented JSON, see also Schema.
+func (x *JSONSchemaGenerator) Document(v *protogen.Message) ([]byte, error) {
+	return json.MarshalIndent(x.Schema(v), ``, `  `)
+}
+
+// Filename returns the name of the output file for the given message, of the given input file, which is suffixed
+// with the name of the message, relative to the proto package, and ".schema.json", e.g. "foo/v1/bar.Baz.schema.json",
+// see also GeneratedFilename.
+func (x *JSONSchemaGenerator) Filename(plugin *protogen.Plugin, file *protogen.File, v *protogen.Message) (string, error) {
+	name := strings.TrimPrefix(string(v.Desc.FullName()), string(file.Desc.Package())+`.`)
+	return GeneratedFilename(plugin, file, `.`+name+jsonSchemaExtension)
+}
+
+// GenerateFiles adds an output file, to the plugin, containing the JSON Schema document of each message in the given
+// file, see also FileMessages, and Filename.
+func (x *JSONSchemaGenerator) GenerateFiles(plugin *protogen.Plugin, file *protogen.File) error {
+	for _, v := range FileMessages(file) {
+		filename, err := x.Filename(plugin, file, v)
+		if err != nil {
+			return err
+		}
+		b, err := x.Document(v)
+		if err != nil {
+			return err
+		}
+		g := plugin.NewGeneratedFile(filename, ``)
+		if _, err := g.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
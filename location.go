@@ -0,0 +1,59 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// SourceLocation models the span of a declaration in a proto source file, derived from the source code info of
+	// the file descriptor, see also DescriptorLocation.
+	SourceLocation struct {
+		// Path is the path of the proto file, e.g. "foo/v1/bar.proto".
+		Path string
+		// StartLine and StartColumn are the one-based start of the declaration, and will be zero if the file has no
+		// source code info, for the declaration.
+		StartLine, StartColumn int
+		// EndLine and EndColumn are the one-based end (exclusive) of the declaration.
+		EndLine, EndColumn int
+	}
+)
+
+// DescriptorLocation returns the location of the given descriptor, in its parent file, which will only have a Path,
+// if the file has no source code info for it, see also SourceLocation.IsValid.
+func DescriptorLocation(desc protoreflect.Descriptor) SourceLocation {
+	file := desc.ParentFile()
+	if file == nil {
+		return SourceLocation{}
+	}
+	location := SourceLocation{Path: file.Path()}
+	if v := file.SourceLocations().ByDescriptor(desc); v.Path != nil {
+		location.StartLine, location.StartColumn = v.StartLine+1, v.StartColumn+1
+		location.EndLine, location.EndColumn = v.EndLine+1, v.EndColumn+1
+	}
+	return location
+}
+
+// IsValid returns true if the location has a line and column.
+func (x SourceLocation) IsValid() bool { return x.StartLine > 0 }
+
+// String returns the location in the conventional "path:line:column" format, or just the path, if it is not valid.
+func (x SourceLocation) String() string {
+	if !x.IsValid() {
+		return x.Path
+	}
+	return fmt.Sprintf(`%s:%d:%d`, x.Path, x.StartLine, x.StartColumn)
+}
+
+// LineDirective returns a golang line directive, which sets the position of the immediately following code to the
+// start of the location, e.g. such that compiler errors and stack traces refer to the proto source, or an empty
+// string, if the location is not valid. It uses the "/*line" form, which unlike "//line" is recognised anywhere,
+// since gofmt indents comments. Note that the position of all subsequent lines is affected, and that a relative
+// Path is resolved relative to the directory of the generated file, so it may need to be adjusted, e.g. by joining
+// it with the relative path from the generated file, to the proto source root.
+func (x SourceLocation) LineDirective() string {
+	if !x.IsValid() {
+		return ``
+	}
+	return fmt.Sprintf(`/*line %s*/`, x)
+}
@@ -0,0 +1,62 @@
+package gopoet_protogen
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"io"
+	"path"
+	"strings"
+)
+
+type (
+	// ManagedMode models the go_package_prefix semantics of buf's managed mode, see also Cache.ManagedMode, such that
+	// types may be resolved consistently with code generated by buf, where descriptors obtained out-of-band (e.g.
+	// from a descriptor set) have a different (or no) go_package.
+	//
+	// As descriptors don't identify their module, modules are identified by the path prefix of their files, e.g.
+	// "acme/weather/". Files of well-known types (google/protobuf) are never managed.
+	ManagedMode struct {
+		// GoPackagePrefix is the default import path prefix of managed files.
+		GoPackagePrefix string `json:"go_package_prefix"`
+		// Except are the path prefixes of modules excluded from managed mode.
+		Except []string `json:"except,omitempty"`
+		// Override maps the path prefix of modules to their import path prefix, where the longest match applies.
+		Override map[string]string `json:"override,omitempty"`
+	}
+)
+
+// LoadManagedMode decodes a ManagedMode from the given JSON mapping file, e.g.
+// {"go_package_prefix": "example.com/gen", "override": {"acme/weather/": "example.com/weather"}}.
+func LoadManagedMode(r io.Reader) (*ManagedMode, error) {
+	var v ManagedMode
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid managed mode: %w", err)
+	}
+	return &v, nil
+}
+
+// GoPackage returns the import path, and package name, assigned to the file with the given path, or false if the
+// file isn't managed. The import path is the prefix joined with the directory of the file, and the package name is
+// derived from the import path, like buf, e.g. "foov1" for "example.com/gen/foo/v1", see also VersionedImportAlias.
+func (x *ManagedMode) GoPackage(file string) (protogen.GoImportPath, protogen.GoPackageName, bool) {
+	if strings.HasPrefix(file, `google/protobuf/`) {
+		return ``, ``, false
+	}
+	for _, except := range x.Except {
+		if strings.HasPrefix(file, except) {
+			return ``, ``, false
+		}
+	}
+	prefix, match := x.GoPackagePrefix, ``
+	for k, v := range x.Override {
+		if strings.HasPrefix(file, k) && len(k) > len(match) {
+			prefix, match = v, k
+		}
+	}
+	if prefix == `` {
+		return ``, ``, false
+	}
+	importPath := protogen.GoImportPath(path.Join(prefix, path.Dir(file)))
+	return importPath, protogen.GoPackageName(VersionedImportAlias(importPath, protogen.GoPackageName(path.Base(string(importPath))))), true
+}
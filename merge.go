@@ -0,0 +1,313 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// MergeSemantics configures how a field is merged, see also MergeGenerator.
+	MergeSemantics int32
+
+	// MergeGenerator generates functions that merge one message into another, with configurable semantics, for
+	// each field, see also MergeSemantics. Unlike proto.Merge, which always appends repeated fields, and merges
+	// message fields, scalar fields without explicit presence are only overwritten if they are non-zero, by default.
+	//
+	// Each function is named "Merge" followed by the golang name of the message, and accepts dst and src, where dst
+	// must be non-nil, unless src is nil, in which case it does nothing. Values are copied, such that dst doesn't
+	// share any memory with src. Fields of message types declared in the same file as the generated message, with
+	// MergeDeep, call the function generated for that type, and must therefore be generated into the same package,
+	// other message types are merged using proto.Merge. Unknown fields are not merged. Generated code accesses the
+	// struct fields directly, and therefore requires the open API level, see also Cache.APILevel.
+	MergeGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "Merge" followed by
+		// the golang name of the message.
+		FuncName func(v *protogen.Message) string
+		// Semantics may be used to configure the semantics of each field, and takes precedence over Option, where
+		// MergeDefault uses the default semantics of the field.
+		Semantics func(field *protogen.Field) MergeSemantics
+		// Option may be used to configure the field number of a custom enum (or integer) field option (extension of
+		// google.protobuf.FieldOptions) specifying the MergeSemantics of each field.
+		Option protoreflect.FieldNumber
+		// Repeated may be used to configure the default semantics of repeated and map fields, which defaults to
+		// MergeAppend.
+		Repeated MergeSemantics
+		// Message may be used to configure the default semantics of singular message fields, which defaults to
+		// MergeDeep.
+		Message MergeSemantics
+		// Filter may be set to exclude fields, which are left unchanged, like MergeIgnore, see also FieldFilter.
+		Filter *FieldFilter
+	}
+)
+
+const (
+	// MergeDefault uses the default semantics of the field, i.e. MergeAppend, for repeated and map fields, MergeDeep,
+	// for message fields, and MergeOverwrite, for all other fields, see also MergeGenerator.Repeated, and
+	// MergeGenerator.Message.
+	MergeDefault MergeSemantics = iota
+	// MergeOverwrite overwrites the field, if it is set in src, i.e. present, for fields with explicit presence,
+	// otherwise non-zero, or non-empty, for repeated and map fields.
+	MergeOverwrite
+	// MergeReplace replaces the field with the value in src, even if it is unset. Oneof fields don't support it.
+	MergeReplace
+	// MergeAppend appends the elements of repeated fields, and sets the entries of map fields, like proto.Merge.
+	// Only repeated and map fields support it.
+	MergeAppend
+	// MergeDeep merges message fields recursively, if they are set in src, like proto.Merge. Only singular message
+	// fields support it.
+	MergeDeep
+	// MergeIgnore leaves the field unchanged.
+	MergeIgnore
+)
+
+var (
+	_ Generator = (*MergeGenerator)(nil)
+
+	mergeSemanticsNames = [...]string{
+		`MergeDefault`,
+		`MergeOverwrite`,
+		`MergeReplace`,
+		`MergeAppend`,
+		`MergeDeep`,
+		`MergeIgnore`,
+	}
+)
+
+// String returns the name of the constant, e.g. MergeAppend.
+func (x MergeSemantics) String() string {
+	if x >= 0 && int(x) < len(mergeSemanticsNames) {
+		return mergeSemanticsNames[x]
+	}
+	return fmt.Sprintf(`MergeSemantics(%d)`, int32(x))
+}
+
+// GenerateFile returns a merge function for each message in the given file, see also FileMessages. It will panic
+// if the semantics of any field are invalid, see also FieldSemantics.
+func (x *MergeGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *MergeGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `Merge` + v.GoIdent.GoName
+}
+
+// FieldSemantics returns the semantics of the given field, resolving MergeDefault, or an error if they are not
+// supported by the field.
+func (x *MergeGenerator) FieldSemantics(field *protogen.Field) (MergeSemantics, error) {
+	var semantics MergeSemantics
+	if x.Semantics != nil {
+		semantics = x.Semantics(field)
+	} else if x.Option != 0 {
+		value, _ := OptionVarint(field.Desc, x.Option)
+		semantics = MergeSemantics(value)
+	}
+	var (
+		desc        = field.Desc
+		repeated    = desc.IsList() || desc.IsMap()
+		message     = !repeated && desc.Message() != nil
+		oneOf       = field.Oneof != nil && !field.Oneof.Desc.IsSynthetic()
+		unsupported bool
+	)
+	if semantics == MergeDefault {
+		switch {
+		case repeated:
+			semantics = x.Repeated
+		case message:
+			semantics = x.Message
+		}
+	}
+	switch semantics {
+	case MergeDefault:
+		switch {
+		case repeated:
+			semantics = MergeAppend
+		case message:
+			semantics = MergeDeep
+		default:
+			semantics = MergeOverwrite
+		}
+	case MergeOverwrite, MergeIgnore:
+	case MergeReplace:
+		unsupported = oneOf
+	case MergeAppend:
+		unsupported = !repeated
+	case MergeDeep:
+		unsupported = !message
+	default:
+		return 0, fmt.Errorf("invalid merge semantics for %s: unknown value %d", desc.FullName(), int32(semantics))
+	}
+	if unsupported {
+		return 0, fmt.Errorf("invalid merge semantics for %s: %s is not supported", desc.FullName(), semantics)
+	}
+	return semantics, nil
+}
+
+// Func returns the merge function for the given message, which merges src into dst. It will panic if the semantics
+// of any field are invalid, see also FieldSemantics.
+func (x *MergeGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name        = x.Name(v)
+		messageType = gopoet.PointerType(x.Cache.MessageType(v.Desc))
+	)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s merges src into dst, which must be non-nil, unless src is nil, copying the values of src.`, name)).
+		AddArg(`dst`, messageType).
+		AddArg(`src`, messageType).
+		Println(`if src == nil {`).
+		Println(`return`).
+		Println(`}`)
+	for _, field := range x.Filter.Fields(x.Cache.MessageFields(v)) {
+		if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+			x.mergeOneOf(f, v, field)
+			continue
+		}
+		semantics, err := x.FieldSemantics(field.Fields()[0])
+		if err != nil {
+			panic(err)
+		}
+		x.mergeField(f, v, field, semantics)
+	}
+	return f
+}
+
+func (x *MergeGenerator) mergeOneOf(f *gopoet.FuncSpec, parent *protogen.Message, field Field) {
+	var cases []OneOfField
+	for _, oneOfField := range field.OneOfFields() {
+		semantics, err := x.FieldSemantics(oneOfField.Field)
+		if err != nil {
+			panic(err)
+		}
+		if semantics != MergeIgnore {
+			cases = append(cases, oneOfField)
+		}
+	}
+	if len(cases) == 0 {
+		return
+	}
+	f.Printlnf(`switch x := src.%s.(type) {`, field.Name())
+	for _, oneOfField := range cases {
+		var (
+			wrapperType = gopoet.PointerType(oneOfField.Type)
+			goName      = oneOfField.Field.GoName
+		)
+		f.Printlnf(`case %s:`, wrapperType)
+		if semantics, _ := x.FieldSemantics(oneOfField.Field); semantics == MergeDeep {
+			f.Printlnf(`if y, ok := dst.%s.(%s); ok && y.%s != nil && x.%s != nil {`, field.Name(), wrapperType, goName, goName).
+				AddCode(x.mergeMessage(parent, oneOfField.Field.Message, `y.`+goName, `x.`+goName)).
+				Println(`break`).
+				Println(`}`)
+		}
+		f.Printf(`dst.%s = &%s{%s: `, field.Name(), oneOfField.Type, goName).
+			AddCode(x.copyValue(oneOfField.Field.Desc, `x.`+goName)).
+			Println(`}`)
+	}
+	f.Println(`}`)
+}
+
+func (x *MergeGenerator) mergeField(f *gopoet.FuncSpec, parent *protogen.Message, field Field, semantics MergeSemantics) {
+	var (
+		protoField = field.Fields()[0]
+		desc       = protoField.Desc
+		dst        = `dst.` + protoField.GoName
+		src        = `src.` + protoField.GoName
+	)
+	switch semantics {
+	case MergeIgnore:
+		return
+	case MergeDeep:
+		f.Printlnf(`if %s != nil {`, src).
+			Printlnf(`if %s == nil {`, dst).
+			Printlnf(`%s = new(%s)`, dst, x.Cache.MessageType(desc.Message())).
+			Println(`}`).
+			AddCode(x.mergeMessage(parent, protoField.Message, dst, src)).
+			Println(`}`)
+		return
+	case MergeAppend:
+		x.appendField(f, field, dst, src)
+		return
+	case MergeReplace:
+		switch {
+		case desc.IsList() || desc.IsMap():
+			f.Printlnf(`%s = nil`, dst)
+			x.appendField(f, field, dst, src)
+			return
+		case field.IsPointer() || desc.Message() != nil || desc.Kind() == protoreflect.BytesKind:
+			f.Printlnf(`%s = nil`, dst).
+				Printlnf(`if %s != nil {`, src)
+		default:
+			f.Printlnf(`%s = %s`, dst, src)
+			return
+		}
+	default:
+		f.Print(`if `).
+			AddCode(field.HasExpr(`src`)).
+			Println(` {`)
+		if desc.IsList() || desc.IsMap() {
+			f.Printlnf(`%s = nil`, dst)
+			x.appendField(f, field, dst, src)
+			f.Println(`}`)
+			return
+		}
+	}
+	if field.IsPointer() {
+		f.Printlnf(`v := *%s`, src).
+			Printlnf(`%s = &v`, dst)
+	} else {
+		f.Printf(`%s = `, dst).
+			AddCode(x.copyValue(desc, src)).
+			Println(``)
+	}
+	f.Println(`}`)
+}
+
+// appendField appends the elements of a repeated field, or sets the entries of a map field.
+func (x *MergeGenerator) appendField(f *gopoet.FuncSpec, field Field, dst, src string) {
+	desc := field.Fields()[0].Desc
+	if desc.IsMap() {
+		f.Printlnf(`if len(%s) != 0 && %s == nil {`, src, dst).
+			Printlnf(`%s = make(%s, len(%s))`, dst, field.Type(), src).
+			Println(`}`).
+			Printlnf(`for k, v := range %s {`, src).
+			Printf(`%s[k] = `, dst).
+			AddCode(x.copyValue(desc.MapValue(), `v`)).
+			Println(``).
+			Println(`}`)
+		return
+	}
+	f.Printlnf(`for _, v := range %s {`, src).
+		Printf(`%s = append(%s, `, dst, dst).
+		AddCode(x.copyValue(desc, `v`)).
+		Println(`)`).
+		Println(`}`)
+}
+
+// mergeMessage returns the code merging the given (non-nil) src message into the given (non-nil) dst message.
+func (x *MergeGenerator) mergeMessage(parent, message *protogen.Message, dst, src string) *gopoet.CodeBlock {
+	if message.Desc.ParentFile().Path() == parent.Desc.ParentFile().Path() {
+		return gopoet.Printlnf(`%s(%s, %s)`, x.Name(message), dst, src)
+	}
+	return gopoet.Printlnf(`%s(%s, %s)`, protoPkg.Symbol(`Merge`), dst, src)
+}
+
+// copyValue returns a golang expression copying the given (non-repeated) value of the given field.
+func (x *MergeGenerator) copyValue(desc protoreflect.FieldDescriptor, expr string) *gopoet.CodeBlock {
+	switch {
+	case desc.Message() != nil:
+		return gopoet.Printf(`%s(%s).(%s)`, protoPkg.Symbol(`Clone`), expr, gopoet.PointerType(x.Cache.MessageType(desc.Message())))
+	case desc.Kind() == protoreflect.BytesKind:
+		return gopoet.Printf(`append([]byte{}, %s...)`, expr)
+	}
+	return gopoet.Print(expr)
+}
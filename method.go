@@ -0,0 +1,99 @@
+package gopoet_protogen
+
+import (
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type (
+	// Method models a service method, with additional information decoded from its options, see also Cache.Method.
+	Method struct {
+		// Method is the input protogen.Method.
+		Method *protogen.Method
+		// Request is the type name of the request message, resolved using the cache.
+		Request gopoet.TypeName
+		// Response is the type name of the response message, resolved using the cache.
+		Response gopoet.TypeName
+		// HTTP are the google.api.http rules for the method, if any, with additional bindings flattened, such that
+		// the first element is the primary binding.
+		HTTP []*HTTPRule
+	}
+)
+
+const (
+	emptyFullName = `google.protobuf.Empty`
+)
+
+// Method returns the model for the given method, where the request and response messages must exist in the cache.
+// An error will be returned if the method has invalid options, e.g. a google.api.http rule referencing an unknown
+// field.
+func (x *Cache) Method(v *protogen.Method) (*Method, error) {
+	method := Method{Method: v}
+	method.Request, method.Response = x.MethodTypes(v)
+	rules, err := x.httpRules(v)
+	if err != nil {
+		return nil, err
+	}
+	method.HTTP = rules
+	return &method, nil
+}
+
+// ServiceMethods returns the model for each method of the given service, see also Cache.Method.
+func (x *Cache) ServiceMethods(v *protogen.Service) ([]*Method, error) {
+	methods := make([]*Method, len(v.Methods))
+	for i, v := range v.Methods {
+		method, err := x.Method(v)
+		if err != nil {
+			return nil, err
+		}
+		methods[i] = method
+	}
+	return methods, nil
+}
+
+// IsEmptyRequest returns true if the request message is google.protobuf.Empty.
+func (x *Method) IsEmptyRequest() bool {
+	return x.Method.Input.Desc.FullName() == emptyFullName
+}
+
+// ReturnsEmpty returns true if the response message is google.protobuf.Empty.
+func (x *Method) ReturnsEmpty() bool {
+	return x.Method.Output.Desc.FullName() == emptyFullName
+}
+
+// UsesWellKnown returns true if either the request or response message is a well-known type, see also
+// IsWellKnownType.
+func (x *Method) UsesWellKnown() bool {
+	return IsWellKnownType(x.Method.Input.Desc) || IsWellKnownType(x.Method.Output.Desc)
+}
+
+// IsWellKnownType returns true if the given descriptor is declared in the google.protobuf package, e.g.
+// google.protobuf.Empty or google.protobuf.Timestamp.
+func IsWellKnownType(desc protoreflect.Descriptor) bool {
+	return desc.ParentFile() != nil && desc.ParentFile().Package() == `google.protobuf`
+}
+
+// IdempotencyLevel returns the idempotency_level option of the method, see also IdempotencyLevel (the function).
+func (x *Method) IdempotencyLevel() descriptorpb.MethodOptions_IdempotencyLevel {
+	return IdempotencyLevel(x.Method.Desc)
+}
+
+// IsIdempotent returns true if the method has an idempotency_level of NO_SIDE_EFFECTS or IDEMPOTENT, see also
+// IdempotencyLevel.
+func (x *Method) IsIdempotent() bool {
+	return x.IdempotencyLevel() != descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN
+}
+
+// IsSafe returns true if the method has an idempotency_level of NO_SIDE_EFFECTS, i.e. it is safe, as well as
+// idempotent, see also IdempotencyLevel.
+func (x *Method) IsSafe() bool {
+	return x.IdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS
+}
+
+// IdempotencyLevel returns the idempotency_level option of the given method.
+func IdempotencyLevel(desc protoreflect.MethodDescriptor) descriptorpb.MethodOptions_IdempotencyLevel {
+	options, _ := desc.Options().(*descriptorpb.MethodOptions)
+	return options.GetIdempotencyLevel()
+}
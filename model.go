@@ -0,0 +1,195 @@
+package gopoet_protogen
+
+import (
+	"encoding/json"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"io"
+)
+
+type (
+	// ModelFile is the serialized model of a file, see also Cache.ExportModel.
+	ModelFile struct {
+		Path          string         `json:"path"`
+		GoImportPath  string         `json:"goImportPath"`
+		GoPackageName string         `json:"goPackageName"`
+		Messages      []ModelMessage `json:"messages,omitempty"`
+		Enums         []ModelEnum    `json:"enums,omitempty"`
+		Services      []ModelService `json:"services,omitempty"`
+	}
+
+	// ModelMessage is the serialized model of a message, see also ModelFile.
+	ModelMessage struct {
+		FullName string       `json:"fullName"`
+		GoType   string       `json:"goType"`
+		Fields   []ModelField `json:"fields,omitempty"`
+		// Stats is the complexity of the message, see also AnalyzeMessage.
+		Stats MessageStats `json:"stats"`
+	}
+
+	// ModelField is the serialized model of a field, see also Field, and Cache.FlatFields. Oneof members are modeled
+	// individually, with the name of the oneof.
+	ModelField struct {
+		Name     string `json:"name"`
+		Number   int32  `json:"number"`
+		JSONName string `json:"jsonName"`
+		GoName   string `json:"goName"`
+		GoType   string `json:"goType"`
+		Pointer  bool   `json:"pointer,omitempty"`
+		OneOf    string `json:"oneOf,omitempty"`
+		Getter   string `json:"getter,omitempty"`
+		Setter   string `json:"setter,omitempty"`
+		Hazzer   string `json:"hazzer,omitempty"`
+		Clearer  string `json:"clearer,omitempty"`
+	}
+
+	// ModelEnum is the serialized model of an enum, see also ModelFile.
+	ModelEnum struct {
+		FullName string           `json:"fullName"`
+		GoType   string           `json:"goType"`
+		Values   []ModelEnumValue `json:"values,omitempty"`
+	}
+
+	// ModelEnumValue is the serialized model of an enum value, see also ModelEnum.
+	ModelEnumValue struct {
+		Name    string `json:"name"`
+		Number  int32  `json:"number"`
+		GoConst string `json:"goConst"`
+	}
+
+	// ModelService is the serialized model of a service, see also ModelFile.
+	ModelService struct {
+		FullName string        `json:"fullName"`
+		GoName   string        `json:"goName"`
+		Methods  []ModelMethod `json:"methods,omitempty"`
+	}
+
+	// ModelMethod is the serialized model of a method, see also ModelService.
+	ModelMethod struct {
+		Name            string `json:"name"`
+		GoName          string `json:"goName"`
+		FullMethodName  string `json:"fullMethodName"`
+		Request         string `json:"request"`
+		Response        string `json:"response"`
+		ClientStreaming bool   `json:"clientStreaming,omitempty"`
+		ServerStreaming bool   `json:"serverStreaming,omitempty"`
+		// IdempotencyLevel is the name of the idempotency_level option, e.g. "NO_SIDE_EFFECTS", or empty, if it is
+		// IDEMPOTENCY_UNKNOWN.
+		IdempotencyLevel string `json:"idempotencyLevel,omitempty"`
+	}
+)
+
+// Model returns the serialized model of the given file, which must be loaded into the cache, see also ExportModel.
+func (x *Cache) Model(file *protogen.File) ModelFile {
+	model := ModelFile{
+		Path:          file.Desc.Path(),
+		GoImportPath:  x.goPackage(file.GoImportPath).ImportPath,
+		GoPackageName: string(file.GoPackageName),
+	}
+	analyzer := newMessageAnalyzer()
+	for _, v := range FileMessages(file) {
+		message := ModelMessage{
+			FullName: string(v.Desc.FullName()),
+			GoType:   modelType(x.MessageType(v.Desc)),
+			Stats:    analyzer.analyze(v),
+		}
+		for _, field := range x.FlatFields(v) {
+			desc := field.Fields()[0].Desc
+			f := ModelField{
+				Name:     string(desc.Name()),
+				Number:   int32(desc.Number()),
+				JSONName: desc.JSONName(),
+				GoName:   field.Name(),
+				GoType:   modelType(field.Type()),
+				Pointer:  field.IsPointer(),
+				Getter:   field.Getter().Name,
+				Setter:   field.Setter().Name,
+				Hazzer:   field.Hazzer().Name,
+				Clearer:  field.Clearer().Name,
+			}
+			if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+				f.OneOf = string(oneOf.Desc.Name())
+			}
+			message.Fields = append(message.Fields, f)
+		}
+		model.Messages = append(model.Messages, message)
+	}
+	for _, v := range FileEnums(file) {
+		enum := ModelEnum{
+			FullName: string(v.Desc.FullName()),
+			GoType:   modelType(x.enumType(v.Desc)),
+		}
+		for _, value := range v.Values {
+			enum.Values = append(enum.Values, ModelEnumValue{
+				Name:    string(value.Desc.Name()),
+				Number:  int32(value.Desc.Number()),
+				GoConst: modelType(gopoet.NamedType(x.EnumValueConst(value.Desc))),
+			})
+		}
+		model.Enums = append(model.Enums, enum)
+	}
+	for _, v := range file.Services {
+		service := ModelService{
+			FullName: string(v.Desc.FullName()),
+			GoName:   v.GoName,
+		}
+		for _, method := range v.Methods {
+			var (
+				request, response = x.MethodTypes(method)
+				idempotencyLevel  string
+			)
+			if v := IdempotencyLevel(method.Desc); v != descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN {
+				idempotencyLevel = v.String()
+			}
+			service.Methods = append(service.Methods, ModelMethod{
+				Name:             string(method.Desc.Name()),
+				GoName:           method.GoName,
+				FullMethodName:   FullMethodName(method),
+				Request:          modelType(request),
+				Response:         modelType(response),
+				ClientStreaming:  method.Desc.IsStreamingClient(),
+				ServerStreaming:  method.Desc.IsStreamingServer(),
+				IdempotencyLevel: idempotencyLevel,
+			})
+		}
+		model.Services = append(model.Services, service)
+	}
+	return model
+}
+
+// ExportModel writes the serialized model of the given files, or every file loaded into the cache, if none are
+// given, as a JSON array of ModelFile, to the given writer, e.g. for non-golang tooling, in a polyglot build, to
+// consume the same type resolution as the golang output. Golang types are formatted like go/types, qualified by
+// import path, e.g. "*example.com/foo/v1.Bar".
+func (x *Cache) ExportModel(w io.Writer, files ...*protogen.File) error {
+	x.once.Do(x.init)
+	if len(files) == 0 {
+		x.loadPending()
+		files = x.Files()
+	}
+	models := make([]ModelFile, 0, len(files))
+	for _, file := range files {
+		models = append(models, x.Model(file))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent(``, `  `)
+	return encoder.Encode(models)
+}
+
+// modelType formats the given type like go/types, qualified by import path, e.g. "map[string]*example.com/foo.Bar".
+func modelType(t gopoet.TypeName) string {
+	switch t.Kind() {
+	case gopoet.KindNamed:
+		if sym := t.Symbol(); sym.Package.ImportPath != `` {
+			return sym.Package.ImportPath + `.` + sym.Name
+		}
+	case gopoet.KindPtr:
+		return `*` + modelType(t.Elem())
+	case gopoet.KindSlice:
+		return `[]` + modelType(t.Elem())
+	case gopoet.KindMap:
+		return `map[` + modelType(t.Key()) + `]` + modelType(t.Elem())
+	}
+	return t.String()
+}
@@ -0,0 +1,149 @@
+package gopoet_protogen
+
+import (
+	"bufio"
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Module models the golang module that code is generated into, identified by the module plugin parameter (see
+	// also PluginModule), or a go.mod file (see also LoadGoMod), such that generators may distinguish packages within
+	// the module, which may be generated alongside each other, from external packages, which may only be imported.
+	Module struct {
+		// Path is the module path, e.g. "example.com/foo".
+		Path string
+	}
+)
+
+// PluginModule returns the Module identified by the module plugin parameter, or false if it wasn't provided, see
+// also PluginParameter.
+func PluginModule(plugin *protogen.Plugin) (*Module, bool) {
+	if v, ok := PluginParameter(plugin, `module`); ok && v != `` {
+		return &Module{Path: v}, true
+	}
+	return nil, false
+}
+
+// LoadGoMod decodes the Module declared by the given go.mod file, i.e. the module directive, which must be present.
+func LoadGoMod(r io.Reader) (*Module, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, `//`); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != `module` {
+			continue
+		}
+		modulePath := fields[1]
+		if strings.HasPrefix(modulePath, `"`) || strings.HasPrefix(modulePath, "`") {
+			v, err := strconv.Unquote(modulePath)
+			if err != nil {
+				return nil, fmt.Errorf("invalid go.mod: invalid module path %s", modulePath)
+			}
+			modulePath = v
+		}
+		if err := checkImportPath(modulePath); err != nil {
+			return nil, fmt.Errorf("invalid go.mod: %w", err)
+		}
+		return &Module{Path: modulePath}, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid go.mod: %w", err)
+	}
+	return nil, fmt.Errorf("invalid go.mod: missing module directive")
+}
+
+// Contains returns true if the given import path is within the module, i.e. it is the module path, or prefixed by
+// the module path, followed by a slash. Note that nested modules are not detected.
+func (x *Module) Contains(importPath protogen.GoImportPath) bool {
+	return string(importPath) == x.Path || strings.HasPrefix(string(importPath), x.Path+`/`)
+}
+
+// Dir returns the (slash separated) directory of the package with the given import path, relative to the root of
+// the module, e.g. "foo/v1", or "." for the module path itself, or false if it isn't within the module.
+func (x *Module) Dir(importPath protogen.GoImportPath) (string, bool) {
+	if !x.Contains(importPath) {
+		return ``, false
+	}
+	if dir := strings.TrimPrefix(string(importPath), x.Path+`/`); dir != string(importPath) {
+		return dir, true
+	}
+	return `.`, true
+}
+
+// Classify returns the golang import paths of all files loaded into the given cache (see also Cache.ImportPaths),
+// partitioned into those within the module, and those external to it, in the same order.
+func (x *Module) Classify(cache *Cache) (internal, external []protogen.GoImportPath) {
+	for _, v := range cache.ImportPaths() {
+		if x.Contains(v) {
+			internal = append(internal, v)
+		} else {
+			external = append(external, v)
+		}
+	}
+	return internal, external
+}
+
+// Check returns a warning for each file loaded into the given cache, with a golang package within the module, that
+// imports a file with a golang package that may not be imported by it, i.e. an internal package (a package with an
+// "internal" path element) that isn't rooted at the parent of the internal element, see also CanImport.
+func (x *Module) Check(cache *Cache) []string {
+	var warnings []string
+	for _, file := range cache.files {
+		from := cache.fileImportPath(file)
+		if !x.Contains(from) {
+			continue
+		}
+		imports := file.Desc.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			dep := cache.file(imports.Get(i).Path())
+			if dep == nil {
+				continue
+			}
+			if to := cache.fileImportPath(dep); !CanImport(from, to) {
+				warnings = append(warnings, fmt.Sprintf("%s: golang package %s may not import internal package %s, of %s", file.Desc.Path(), string(from), string(to), dep.Desc.Path()))
+			}
+		}
+	}
+	return warnings
+}
+
+// CanImport returns true if the golang package with the import path from may import the package with the import
+// path to, according to the rules for internal packages, i.e. to may only be imported by packages rooted at the
+// parent of its last "internal" path element, e.g. "a/b/internal/c" may be imported by "a/b", and "a/b/d", but not
+// "a/e".
+func CanImport(from, to protogen.GoImportPath) bool {
+	elems := strings.Split(string(to), `/`)
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i] != `internal` {
+			continue
+		}
+		root := path.Join(elems[:i]...)
+		return root == `` || string(from) == root || strings.HasPrefix(string(from), root+`/`)
+	}
+	return true
+}
+
+// ImportPaths returns the distinct golang import paths of all files loaded into the cache, including any overrides
+// by ManagedMode, in the order they were first added.
+func (x *Cache) ImportPaths() []protogen.GoImportPath {
+	x.once.Do(x.init)
+	var (
+		importPaths []protogen.GoImportPath
+		seen        = make(map[protogen.GoImportPath]bool)
+	)
+	for _, file := range x.files {
+		if v := x.fileImportPath(file); !seen[v] {
+			seen[v] = true
+			importPaths = append(importPaths, v)
+		}
+	}
+	return importPaths
+}
@@ -0,0 +1,124 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// Namer determines the names of generated symbols, and is consulted via Cache.Namer, such that naming conventions
+	// may be enforced without forking generator implementations. Custom implementations may embed DefaultNamer, to
+	// override only some names.
+	Namer interface {
+		// GetterName returns the name of the getter method, for a field (or oneof) with the given golang name.
+		GetterName(goName string) string
+		// BuilderName returns the name of the constructor function for the given message, see also
+		// ConstructorGenerator.
+		BuilderName(v *protogen.Message) string
+		// InterfaceName returns the name of the (unexported) interface type implemented by the wrapper types of the
+		// given oneof.
+		InterfaceName(v *protogen.Oneof) string
+		// FileSuffix returns the suffix of output files for the named plugin (e.g. "grpc"), for use with NewGoFile.
+		FileSuffix(name string) string
+	}
+
+	// DefaultNamer implements Namer using the conventions of protoc-gen-go, and is used if Cache.Namer is nil.
+	DefaultNamer struct{}
+
+	// RPCNamer determines the names of the symbols generated by an RPC framework, for services, and the paths their
+	// methods are served on, and is consulted via Cache.RPCNamer, such that generators may support frameworks other
+	// than grpc-go, see also GRPCNamer, and TwirpNamer.
+	RPCNamer interface {
+		// ServerInterface returns the name of the interface implemented by servers of the given service.
+		ServerInterface(v *protogen.Service) string
+		// ServerConstructor returns the name of the function registering, or wrapping, an implementation of the
+		// server interface of the given service.
+		ServerConstructor(v *protogen.Service) string
+		// ClientInterface returns the name of the interface implemented by clients of the given service.
+		ClientInterface(v *protogen.Service) string
+		// ClientConstructor returns the name of the function constructing a client of the given service.
+		ClientConstructor(v *protogen.Service) string
+		// MethodPath returns the path the given method is served on, e.g. "/pkg.Service/Method".
+		MethodPath(v *protogen.Method) string
+		// FileSuffix returns the suffix of the files generated by the framework, for use with NewGoFile.
+		FileSuffix() string
+	}
+
+	// GRPCNamer implements RPCNamer using the conventions of protoc-gen-go-grpc, and is used if Cache.RPCNamer is
+	// nil.
+	GRPCNamer struct{}
+)
+
+var (
+	_ Namer    = DefaultNamer{}
+	_ RPCNamer = GRPCNamer{}
+)
+
+// GetterName returns "Get" followed by the given name.
+func (DefaultNamer) GetterName(goName string) string {
+	return `Get` + goName
+}
+
+// BuilderName returns "New" followed by the golang name of the message.
+func (DefaultNamer) BuilderName(v *protogen.Message) string {
+	return `New` + v.GoIdent.GoName
+}
+
+// InterfaceName returns "is" followed by the golang name of the oneof.
+func (DefaultNamer) InterfaceName(v *protogen.Oneof) string {
+	return `is` + v.GoIdent.GoName
+}
+
+// FileSuffix returns an underscore, followed by the given name, followed by ".pb.go", e.g. "_grpc.pb.go", or just
+// ".pb.go", if the name is empty.
+func (DefaultNamer) FileSuffix(name string) string {
+	if name == `` {
+		return `.pb.go`
+	}
+	return `_` + name + `.pb.go`
+}
+
+// namer returns the Namer of the cache, or DefaultNamer, if it is nil.
+func (x *Cache) namer() Namer {
+	if x != nil && x.Namer != nil {
+		return x.Namer
+	}
+	return DefaultNamer{}
+}
+
+// ServerInterface returns the golang name of the service, followed by "Server".
+func (GRPCNamer) ServerInterface(v *protogen.Service) string {
+	return v.GoName + `Server`
+}
+
+// ServerConstructor returns "Register", followed by the golang name of the service, followed by "Server".
+func (GRPCNamer) ServerConstructor(v *protogen.Service) string {
+	return `Register` + v.GoName + `Server`
+}
+
+// ClientInterface returns the golang name of the service, followed by "Client".
+func (GRPCNamer) ClientInterface(v *protogen.Service) string {
+	return v.GoName + `Client`
+}
+
+// ClientConstructor returns "New", followed by the golang name of the service, followed by "Client".
+func (GRPCNamer) ClientConstructor(v *protogen.Service) string {
+	return `New` + v.GoName + `Client`
+}
+
+// MethodPath returns the full method name, see also FullMethodName.
+func (GRPCNamer) MethodPath(v *protogen.Method) string {
+	return FullMethodName(v)
+}
+
+// FileSuffix returns "_grpc.pb.go".
+func (GRPCNamer) FileSuffix() string {
+	return `_grpc.pb.go`
+}
+
+// rpcNamer returns the RPCNamer of the cache, or GRPCNamer, if it is nil.
+func (x *Cache) rpcNamer() RPCNamer {
+	if x != nil && x.RPCNamer != nil {
+		return x.RPCNamer
+	}
+	return GRPCNamer{}
+}
@@ -0,0 +1,63 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// MessageChain returns the chain of messages, from the top-level message declared in the file, to the given message
+// (inclusive), e.g. for Parent_Child_Grandchild, the messages Parent, Parent_Child, and Parent_Child_Grandchild. The
+// first element is therefore the owning top-level message. The parent file must be loaded into the cache,
+// otherwise it will panic.
+func (x *Cache) MessageChain(v protoreflect.MessageDescriptor) []*protogen.Message {
+	var descs []protoreflect.MessageDescriptor
+	for d := protoreflect.Descriptor(v); d != nil; d = d.Parent() {
+		if d, ok := d.(protoreflect.MessageDescriptor); ok {
+			descs = append(descs, d)
+		}
+	}
+	var messages []*protogen.Message
+	if file := x.file(v.ParentFile().Path()); file != nil {
+		messages = file.Messages
+	}
+	chain := make([]*protogen.Message, 0, len(descs))
+	for i := len(descs) - 1; i >= 0; i-- {
+		var found *protogen.Message
+		for _, m := range messages {
+			if m.Desc.FullName() == descs[i].FullName() {
+				found = m
+				break
+			}
+		}
+		if found == nil {
+			panic(fmt.Sprintf("unknown type: %v", v))
+		}
+		chain = append(chain, found)
+		messages = found.Messages
+	}
+	return chain
+}
+
+// TopLevelMessage returns the top-level message that (transitively) contains the given message, or the message
+// itself, if it is not nested, see also MessageChain.
+func (x *Cache) TopLevelMessage(v protoreflect.MessageDescriptor) *protogen.Message {
+	return x.MessageChain(v)[0]
+}
+
+// GoNameChain returns the golang name of each element of a chain of messages, relative to the parent, e.g. Parent,
+// Child, and Grandchild, for Parent_Child_Grandchild, see also MessageChain. Names are derived from the golang
+// identifiers, and may therefore differ from the proto names, e.g. FooBar for foo_bar.
+func GoNameChain(chain []*protogen.Message) []string {
+	names := make([]string, len(chain))
+	for i, v := range chain {
+		names[i] = v.GoIdent.GoName
+		if i != 0 {
+			// the separator is omitted if the nested name starts with a lowercase letter, e.g. MFooBar for M.foo_bar
+			names[i] = strings.TrimPrefix(names[i], chain[i-1].GoIdent.GoName)
+			names[i] = strings.TrimPrefix(names[i], `_`)
+		}
+	}
+	return names
+}
@@ -0,0 +1,381 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+	"strconv"
+)
+
+type (
+	// NormalizeRule models the normalization of a field, see also FieldNormalizeRule.
+	NormalizeRule struct {
+		// Trim removes leading and trailing white space from string values.
+		Trim bool
+		// Lower converts string values to lower case.
+		Lower bool
+		// Upper converts string values to upper case.
+		Upper bool
+		// Sort sorts the values of repeated string, numeric, and enum fields, in ascending order.
+		Sort bool
+		// Min is the minimum of numeric values, if set.
+		Min *float64
+		// Max is the maximum of numeric values, if set.
+		Max *float64
+	}
+
+	// NormalizeGenerator generates functions that normalize messages, in-place, e.g. to sanitize input, driven by
+	// rules for each field, typically declared using a custom field option, see also FieldNormalizeRule.
+	//
+	// Each function is named "Normalize" followed by the golang name of the message, and accepts a (possibly nil)
+	// pointer to the message. Rules apply to the values of singular, repeated, and map fields (excluding keys), and
+	// oneof fields. Fields of message types declared in the same file as the generated message, that (transitively)
+	// contain fields with rules, are normalized recursively, calling the function generated for that type, and must
+	// therefore be generated into the same package. Generated code accesses the struct fields directly, and
+	// therefore requires the open API level, see also Cache.APILevel.
+	NormalizeGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "Normalize"
+		// followed by the golang name of the message.
+		FuncName func(v *protogen.Message) string
+		// Rule may be used to configure the rule of each field, and takes precedence over Option, where nil means
+		// the field isn't normalized.
+		Rule func(field *protogen.Field) *NormalizeRule
+		// Option may be used to configure the field number of a custom message field option (extension of
+		// google.protobuf.FieldOptions) specifying the rule of each field, see also FieldNormalizeRule.
+		Option protoreflect.FieldNumber
+	}
+)
+
+var (
+	_ Generator = (*NormalizeGenerator)(nil)
+)
+
+// FieldNormalizeRule decodes the custom field option with the given number, returning nil if it isn't set, which
+// must be a message compatible with the following, or an error, if it is invalid:
+//
+//	message Normalize {
+//	  bool trim = 1;
+//	  bool lower = 2;
+//	  bool upper = 3;
+//	  bool sort = 4;
+//	  optional double min = 5;
+//	  optional double max = 6;
+//	}
+func FieldNormalizeRule(desc protoreflect.FieldDescriptor, number protoreflect.FieldNumber) (*NormalizeRule, error) {
+	values := OptionBytes(desc, number)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var rule NormalizeRule
+	for _, b := range values {
+		for len(b) != 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid normalize option for %s: %w", desc.FullName(), protowire.ParseError(n))
+			}
+			b = b[n:]
+			switch {
+			case num >= 1 && num <= 4 && typ == protowire.VarintType:
+				var value uint64
+				if value, n = protowire.ConsumeVarint(b); n < 0 {
+					break
+				}
+				switch num {
+				case 1:
+					rule.Trim = value != 0
+				case 2:
+					rule.Lower = value != 0
+				case 3:
+					rule.Upper = value != 0
+				case 4:
+					rule.Sort = value != 0
+				}
+			case (num == 5 || num == 6) && typ == protowire.Fixed64Type:
+				var value uint64
+				if value, n = protowire.ConsumeFixed64(b); n < 0 {
+					break
+				}
+				f := math.Float64frombits(value)
+				if num == 5 {
+					rule.Min = &f
+				} else {
+					rule.Max = &f
+				}
+			default:
+				n = protowire.ConsumeFieldValue(num, typ, b)
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("invalid normalize option for %s: %w", desc.FullName(), protowire.ParseError(n))
+			}
+			b = b[n:]
+		}
+	}
+	return &rule, nil
+}
+
+// GenerateFile returns a normalize function for each message in the given file, see also FileMessages. It will
+// panic if the rule of any field is invalid, see also FieldRule.
+func (x *NormalizeGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *NormalizeGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `Normalize` + v.GoIdent.GoName
+}
+
+// FieldRule returns the rule of the given field, or nil, if it isn't normalized, or an error, if the rule isn't
+// supported by the field, e.g. Lower, for a non-string field.
+func (x *NormalizeGenerator) FieldRule(field *protogen.Field) (*NormalizeRule, error) {
+	var rule *NormalizeRule
+	if x.Rule != nil {
+		rule = x.Rule(field)
+	} else if x.Option != 0 {
+		var err error
+		if rule, err = FieldNormalizeRule(field.Desc, x.Option); err != nil {
+			return nil, err
+		}
+	}
+	if rule == nil {
+		return nil, nil
+	}
+	desc := field.Desc
+	if desc.IsMap() {
+		desc = desc.MapValue()
+	}
+	kind := desc.Kind()
+	switch {
+	case (rule.Trim || rule.Lower || rule.Upper) && kind != protoreflect.StringKind:
+		return nil, fmt.Errorf("invalid normalize rule for %s: trim, lower, and upper require a string field", field.Desc.FullName())
+	case rule.Lower && rule.Upper:
+		return nil, fmt.Errorf("invalid normalize rule for %s: lower and upper are mutually exclusive", field.Desc.FullName())
+	case rule.Sort && (!field.Desc.IsList() || kind == protoreflect.BoolKind || kind == protoreflect.BytesKind || kind == protoreflect.MessageKind || kind == protoreflect.GroupKind):
+		return nil, fmt.Errorf("invalid normalize rule for %s: sort requires a repeated string, numeric, or enum field", field.Desc.FullName())
+	case (rule.Min != nil || rule.Max != nil) && !isNumericKind(kind):
+		return nil, fmt.Errorf("invalid normalize rule for %s: min and max require a numeric field", field.Desc.FullName())
+	case rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max:
+		return nil, fmt.Errorf("invalid normalize rule for %s: min is greater than max", field.Desc.FullName())
+	}
+	for _, limit := range [...]*float64{rule.Min, rule.Max} {
+		if limit == nil || kind == protoreflect.FloatKind || kind == protoreflect.DoubleKind {
+			continue
+		}
+		if *limit != math.Trunc(*limit) || (*limit < 0 && isUnsignedKind(kind)) {
+			return nil, fmt.Errorf("invalid normalize rule for %s: %v is not a valid %s", field.Desc.FullName(), *limit, kind)
+		}
+	}
+	return rule, nil
+}
+
+// Func returns the normalize function for the given message, which accepts a (possibly nil) pointer to the message.
+// It will panic if the rule of any field is invalid, see also FieldRule.
+func (x *NormalizeGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.Name(v)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s normalizes the fields of a %s, in-place.`, name, v.Desc.FullName())).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc)))
+	var started bool
+	for _, field := range x.Cache.MessageFields(v) {
+		var code *gopoet.CodeBlock
+		if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+			code = x.normalizeOneOf(v, field)
+		} else {
+			code = x.normalizeField(v, field)
+		}
+		if code == nil {
+			continue
+		}
+		if !started {
+			started = true
+			f.Println(`if v == nil {`).
+				Println(`return`).
+				Println(`}`)
+		}
+		f.AddCode(code)
+	}
+	return f
+}
+
+func (x *NormalizeGenerator) normalizeOneOf(parent *protogen.Message, field Field) *gopoet.CodeBlock {
+	var cases []*gopoet.CodeBlock
+	for _, oneOfField := range field.OneOfFields() {
+		var (
+			code   *gopoet.CodeBlock
+			goName = oneOfField.Field.GoName
+		)
+		if x.recurse(parent, oneOfField.Field, make(map[protoreflect.FullName]bool)) {
+			code = gopoet.Printlnf(`%s(x.%s)`, x.Name(oneOfField.Field.Message), goName)
+		} else if rule := x.rule(oneOfField.Field); rule != nil && rule.values() {
+			code = x.normalizeValue(oneOfField.Field.Desc, rule, `x.`+goName)
+		}
+		if code != nil {
+			cases = append(cases, gopoet.Printlnf(`case %s:`, gopoet.PointerType(oneOfField.Type)).AddCode(code))
+		}
+	}
+	if len(cases) == 0 {
+		return nil
+	}
+	code := gopoet.Printlnf(`switch x := v.%s.(type) {`, field.Name())
+	for _, c := range cases {
+		code.AddCode(c)
+	}
+	return code.Println(`}`)
+}
+
+func (x *NormalizeGenerator) normalizeField(parent *protogen.Message, field Field) *gopoet.CodeBlock {
+	var (
+		protoField = field.Fields()[0]
+		desc       = protoField.Desc
+		expr       = `v.` + protoField.GoName
+	)
+	if x.recurse(parent, protoField, make(map[protoreflect.FullName]bool)) {
+		switch {
+		case desc.IsMap():
+			return gopoet.Printlnf(`for _, e := range %s {`, expr).
+				Printlnf(`%s(e)`, x.Name(protoField.Message.Fields[1].Message)).
+				Println(`}`)
+		case desc.IsList():
+			return gopoet.Printlnf(`for _, e := range %s {`, expr).
+				Printlnf(`%s(e)`, x.Name(protoField.Message)).
+				Println(`}`)
+		}
+		return gopoet.Printlnf(`%s(%s)`, x.Name(protoField.Message), expr)
+	}
+	rule := x.rule(protoField)
+	if rule == nil || (!rule.values() && !rule.Sort) {
+		return nil
+	}
+	switch {
+	case desc.IsMap():
+		return gopoet.Printlnf(`for k, e := range %s {`, expr).
+			AddCode(x.normalizeValue(desc.MapValue(), rule, `e`)).
+			Printlnf(`%s[k] = e`, expr).
+			Println(`}`)
+	case desc.IsList():
+		code := new(gopoet.CodeBlock)
+		if rule.values() {
+			code.Printlnf(`for i := range %s {`, expr).
+				AddCode(x.normalizeValue(desc, rule, expr+`[i]`)).
+				Println(`}`)
+		}
+		if rule.Sort {
+			code.Printlnf(`%s(%s, func(i, j int) bool {`, sortPkg.Symbol(`Slice`), expr).
+				Printlnf(`return %s[i] < %s[j]`, expr, expr).
+				Println(`})`)
+		}
+		return code
+	case field.IsPointer():
+		return gopoet.Printlnf(`if %s != nil {`, expr).
+			AddCode(x.normalizeValue(desc, rule, `*`+expr)).
+			Println(`}`)
+	}
+	return x.normalizeValue(desc, rule, expr)
+}
+
+// normalizeValue returns the code normalizing the given (non-repeated) value, of the given field, in-place.
+func (x *NormalizeGenerator) normalizeValue(desc protoreflect.FieldDescriptor, rule *NormalizeRule, expr string) *gopoet.CodeBlock {
+	code := new(gopoet.CodeBlock)
+	if rule.Trim || rule.Lower || rule.Upper {
+		var (
+			value = expr
+			args  []interface{}
+		)
+		for _, v := range [...]struct {
+			enabled bool
+			name    string
+		}{
+			{rule.Trim, `TrimSpace`},
+			{rule.Lower, `ToLower`},
+			{rule.Upper, `ToUpper`},
+		} {
+			if v.enabled {
+				value = `%s(` + value + `)`
+				args = append([]interface{}{stringsPkg.Symbol(v.name)}, args...)
+			}
+		}
+		code.Printlnf(`%s = `+value, append([]interface{}{expr}, args...)...)
+	}
+	for _, limit := range [...]struct {
+		value *float64
+		op    string
+	}{
+		{rule.Min, `<`},
+		{rule.Max, `>`},
+	} {
+		if limit.value == nil {
+			continue
+		}
+		literal := strconv.FormatFloat(*limit.value, 'g', -1, 64)
+		if desc.Kind() != protoreflect.FloatKind && desc.Kind() != protoreflect.DoubleKind {
+			literal = strconv.FormatFloat(*limit.value, 'f', -1, 64)
+		}
+		code.Printlnf(`if %s %s %s {`, expr, limit.op, literal).
+			Printlnf(`%s = %s`, expr, literal).
+			Println(`}`)
+	}
+	return code
+}
+
+// values returns true if the rule normalizes each value, i.e. it has any rules other than Sort.
+func (x *NormalizeRule) values() bool {
+	return x.Trim || x.Lower || x.Upper || x.Min != nil || x.Max != nil
+}
+
+// rule returns the rule of the given field, panicking if it is invalid.
+func (x *NormalizeGenerator) rule(field *protogen.Field) *NormalizeRule {
+	rule, err := x.FieldRule(field)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+// recurse returns true if the given field has a message type, declared in the same file as the parent, that
+// (transitively) contains fields with rules. The visiting map is used to handle recursive types.
+func (x *NormalizeGenerator) recurse(parent *protogen.Message, field *protogen.Field, visiting map[protoreflect.FullName]bool) bool {
+	message := field.Message
+	if message != nil && message.Desc.IsMapEntry() {
+		message = message.Fields[1].Message
+	}
+	if message == nil || message.Desc.ParentFile().Path() != parent.Desc.ParentFile().Path() || visiting[message.Desc.FullName()] {
+		return false
+	}
+	visiting[message.Desc.FullName()] = true
+	defer delete(visiting, message.Desc.FullName())
+	for _, field := range message.Fields {
+		if x.rule(field) != nil || x.recurse(message, field, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumericKind returns true if the given kind is an integer, or floating point, kind.
+func isNumericKind(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.BoolKind, protoreflect.EnumKind, protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return true
+}
+
+// isUnsignedKind returns true if the given kind is an unsigned integer kind.
+func isUnsignedKind(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+		return true
+	}
+	return false
+}
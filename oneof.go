@@ -0,0 +1,70 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// OneOf models the discriminator of a oneof, generated by protoc-gen-go for the hybrid and opaque API levels,
+	// i.e. the "Which" method, and the case constants, see also Cache.OneOf.
+	OneOf struct {
+		// Oneof is the input protogen.Oneof.
+		Oneof *protogen.Oneof
+		// Which is the discriminator method, e.g. WhichFoo, returning CaseType.
+		Which gopoet.MethodType
+		// CaseType is the (unexported) type of the case constants, e.g. case_Msg_Foo.
+		CaseType gopoet.TypeName
+		// NotSet is the case constant indicating that no field is set, e.g. Msg_Foo_not_set_case.
+		NotSet gopoet.Symbol
+		// Cases are the case constants for each field of the oneof, in declaration order, e.g. Msg_Bar_case.
+		Cases []gopoet.Symbol
+	}
+)
+
+// OneOf returns the discriminator model for the given (non-synthetic) oneof, which must be loaded into the cache,
+// otherwise it will panic.
+func (x *Cache) OneOf(v *protogen.Oneof) *OneOf {
+	x.once.Do(x.init)
+	if v.Desc.IsSynthetic() || x.lookup(v.Parent.Desc) == nil {
+		panic(fmt.Sprintf("unknown type: %v", v.Desc))
+	}
+	var (
+		pkg      = x.goPackage(v.GoIdent.GoImportPath)
+		message  = v.Parent.GoIdent.GoName
+		caseType = gopoet.NamedType(pkg.Symbol(`case_` + message + `_` + v.GoName))
+		which    = `Which` + v.GoName
+	)
+	if x.APILevel == APIOpaque {
+		which = x.methodName(`Which`, v.Desc.Name(), v.GoName)
+	}
+	oneOf := OneOf{
+		Oneof:    v,
+		Which:    gopoet.MethodType{Name: which, Signature: gopoet.Signature{Results: []gopoet.ArgType{{Type: caseType}}}},
+		CaseType: caseType,
+		NotSet:   pkg.Symbol(message + `_` + v.GoName + `_not_set_case`),
+	}
+	for _, field := range v.Fields {
+		oneOf.Cases = append(oneOf.Cases, pkg.Symbol(message+`_`+field.GoName+`_case`))
+	}
+	return &oneOf
+}
+
+// Switch returns a switch statement over the discriminator of expr, a golang expression of the message type, with a
+// case for each field of the oneof, where body returns the code for each case, and def is the (optional) code for
+// the default case, which includes the not set case.
+func (x *OneOf) Switch(expr string, body func(field *protogen.Field) *gopoet.CodeBlock, def *gopoet.CodeBlock) *gopoet.CodeBlock {
+	cb := gopoet.Printlnf(`switch %s.%s() {`, expr, x.Which.Name)
+	for i, field := range x.Oneof.Fields {
+		cb.Printlnf(`case %s:`, x.Cases[i])
+		if code := body(field); code != nil {
+			cb.AddCode(code)
+		}
+	}
+	if def != nil {
+		cb.Println(`default:`)
+		cb.AddCode(def)
+	}
+	return cb.Println(`}`)
+}
@@ -0,0 +1,293 @@
+package gopoet_protogen
+
+import (
+	"encoding/json"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// OpenAPIGenerator renders OpenAPI 3.1 component schemas for messages and enums, consistent with their
+	// protojson representation, as a complementary output to generated golang code, e.g. for REST plugins.
+	OpenAPIGenerator struct {
+		// SchemaName may be used to override the name of the component schema for each message or enum, which
+		// defaults to the full name, e.g. "pkg.Message".
+		SchemaName func(desc protoreflect.Descriptor) string
+		// UseProtoNames may be set to use proto field names as property names, rather than JSON names, consistent
+		// with protojson.MarshalOptions.UseProtoNames.
+		UseProtoNames bool
+
+		// refPath overrides openAPISchemaPath, see also JSONSchemaGenerator.
+		refPath string
+		// exclusiveOneOfs enables constraints, on message schemas, that at most one member of each oneof is present.
+		exclusiveOneOfs bool
+	}
+
+	// OpenAPISchema models an OpenAPI 3.1 (JSON Schema) schema object, see also OpenAPIGenerator.
+	OpenAPISchema struct {
+		Schema               string                    `json:"$schema,omitempty"`
+		Ref                  string                    `json:"$ref,omitempty"`
+		Type                 interface{}               `json:"type,omitempty"`
+		Format               string                    `json:"format,omitempty"`
+		Pattern              string                    `json:"pattern,omitempty"`
+		Description          string                    `json:"description,omitempty"`
+		Enum                 []interface{}             `json:"enum,omitempty"`
+		Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+		Required             []string                  `json:"required,omitempty"`
+		Items                *OpenAPISchema            `json:"items,omitempty"`
+		AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+		AllOf                []*OpenAPISchema          `json:"allOf,omitempty"`
+		AnyOf                []*OpenAPISchema          `json:"anyOf,omitempty"`
+		OneOf                []*OpenAPISchema          `json:"oneOf,omitempty"`
+		Not                  *OpenAPISchema            `json:"not,omitempty"`
+		Defs                 map[string]*OpenAPISchema `json:"$defs,omitempty"`
+	}
+)
+
+const (
+	openAPIVersion    = `3.1.0`
+	openAPISchemaPath = `#/components/schemas/`
+)
+
+// SchemaNameFor returns the name of the component schema for the given message or enum.
+func (x *OpenAPIGenerator) SchemaNameFor(desc protoreflect.Descriptor) string {
+	if x.SchemaName != nil {
+		return x.SchemaName(desc)
+	}
+	return string(desc.FullName())
+}
+
+// Schemas returns the component schemas, keyed by name, for every message and enum declared in the given files, as
+// well as any (transitively) referenced messages and enums, excluding well-known types that are represented using
+// inline schemas, e.g. google.protobuf.Timestamp. Map entry messages are always excluded.
+func (x *OpenAPIGenerator) Schemas(files ...*protogen.File) map[string]*OpenAPISchema {
+	schemas := make(map[string]*OpenAPISchema)
+	for _, file := range files {
+		for _, v := range FileEnums(file) {
+			x.addEnum(schemas, v)
+		}
+		for _, v := range FileMessages(file) {
+			x.addMessage(schemas, v)
+		}
+	}
+	return schemas
+}
+
+// Document renders a minimal OpenAPI 3.1 document, as indented JSON, with the given title and version, and the
+// component schemas for the given files, see also Schemas.
+func (x *OpenAPIGenerator) Document(title, version string, files ...*protogen.File) ([]byte, error) {
+	type (
+		info struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		}
+		components struct {
+			Schemas map[string]*OpenAPISchema `json:"schemas"`
+		}
+		document struct {
+			OpenAPI    string     `json:"openapi"`
+			Info       info       `json:"info"`
+			Components components `json:"components"`
+		}
+	)
+	return json.MarshalIndent(document{
+		OpenAPI:    openAPIVersion,
+		Info:       info{Title: title, Version: version},
+		Components: components{Schemas: x.Schemas(files...)},
+	}, ``, `  `)
+}
+
+func (x *OpenAPIGenerator) addEnum(schemas map[string]*OpenAPISchema, v *protogen.Enum) {
+	name := x.SchemaNameFor(v.Desc)
+	if _, ok := schemas[name]; ok {
+		return
+	}
+	schema := OpenAPISchema{Type: `string`, Description: commentText(v.Comments.Leading)}
+	for _, value := range v.Values {
+		schema.Enum = append(schema.Enum, string(value.Desc.Name()))
+	}
+	schemas[name] = &schema
+}
+
+func (x *OpenAPIGenerator) addMessage(schemas map[string]*OpenAPISchema, v *protogen.Message) {
+	name := x.SchemaNameFor(v.Desc)
+	if _, ok := schemas[name]; ok || v.Desc.IsMapEntry() || wellKnownSchema(v.Desc) != nil {
+		return
+	}
+	schema := OpenAPISchema{Type: `object`, Description: commentText(v.Comments.Leading)}
+	// added prior to recursing, to handle recursive types
+	schemas[name] = &schema
+	for _, field := range v.Fields {
+		if schema.Properties == nil {
+			schema.Properties = make(map[string]*OpenAPISchema)
+		}
+		property := x.propertyName(field)
+		schema.Properties[property] = x.fieldSchema(schemas, field)
+		if field.Desc.Cardinality() == protoreflect.Required {
+			schema.Required = append(schema.Required, property)
+		}
+	}
+	if !x.exclusiveOneOfs {
+		return
+	}
+	var constraints []*OpenAPISchema
+	for _, oneOf := range v.Oneofs {
+		if oneOf.Desc.IsSynthetic() || len(oneOf.Fields) < 2 {
+			continue
+		}
+		// exactly one of: each member, or none of them
+		var (
+			members    = make([]*OpenAPISchema, 0, len(oneOf.Fields))
+			constraint = OpenAPISchema{OneOf: make([]*OpenAPISchema, 0, len(oneOf.Fields)+1)}
+		)
+		for _, field := range oneOf.Fields {
+			members = append(members, &OpenAPISchema{Required: []string{x.propertyName(field)}})
+		}
+		constraint.OneOf = append(append(constraint.OneOf, members...), &OpenAPISchema{Not: &OpenAPISchema{AnyOf: members}})
+		constraints = append(constraints, &constraint)
+	}
+	switch len(constraints) {
+	case 0:
+	case 1:
+		schema.OneOf = constraints[0].OneOf
+	default:
+		schema.AllOf = constraints
+	}
+}
+
+// ref returns the $ref of the schema for the given message or enum.
+func (x *OpenAPIGenerator) ref(desc protoreflect.Descriptor) string {
+	if x.refPath != `` {
+		return x.refPath + x.SchemaNameFor(desc)
+	}
+	return openAPISchemaPath + x.SchemaNameFor(desc)
+}
+
+func (x *OpenAPIGenerator) propertyName(field *protogen.Field) string {
+	if x.UseProtoNames {
+		return string(field.Desc.Name())
+	}
+	return field.Desc.JSONName()
+}
+
+func (x *OpenAPIGenerator) fieldSchema(schemas map[string]*OpenAPISchema, field *protogen.Field) *OpenAPISchema {
+	var schema *OpenAPISchema
+	switch {
+	case field.Desc.IsMap():
+		schema = &OpenAPISchema{Type: `object`, AdditionalProperties: x.valueSchema(schemas, field.Message.Fields[1])}
+	case field.Desc.IsList():
+		schema = &OpenAPISchema{Type: `array`, Items: x.valueSchema(schemas, field)}
+	default:
+		schema = x.valueSchema(schemas, field)
+	}
+	if description := commentText(field.Comments.Leading); description != `` {
+		if schema.Ref != `` {
+			// siblings of $ref are permitted by OpenAPI 3.1
+			schema = &OpenAPISchema{Ref: schema.Ref}
+		}
+		schema.Description = description
+	}
+	return schema
+}
+
+// valueSchema returns the schema of a single value of the given field.
+func (x *OpenAPIGenerator) valueSchema(schemas map[string]*OpenAPISchema, field *protogen.Field) *OpenAPISchema {
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return &OpenAPISchema{Type: `boolean`}
+	case protoreflect.StringKind:
+		return &OpenAPISchema{Type: `string`}
+	case protoreflect.BytesKind:
+		return &OpenAPISchema{Type: `string`, Format: `byte`}
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind:
+		return &OpenAPISchema{Type: `integer`, Format: `int32`}
+	case protoreflect.Uint32Kind,
+		protoreflect.Fixed32Kind:
+		return &OpenAPISchema{Type: `integer`, Format: `uint32`}
+	case protoreflect.Int64Kind,
+		protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind:
+		return &OpenAPISchema{Type: `string`, Format: `int64`}
+	case protoreflect.Uint64Kind,
+		protoreflect.Fixed64Kind:
+		return &OpenAPISchema{Type: `string`, Format: `uint64`}
+	case protoreflect.FloatKind:
+		return &OpenAPISchema{Type: `number`, Format: `float`}
+	case protoreflect.DoubleKind:
+		return &OpenAPISchema{Type: `number`, Format: `double`}
+	case protoreflect.EnumKind:
+		if field.Enum.Desc.FullName() == `google.protobuf.NullValue` {
+			return &OpenAPISchema{Type: `null`}
+		}
+		x.addEnum(schemas, field.Enum)
+		return &OpenAPISchema{Ref: x.ref(field.Enum.Desc)}
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if schema := wellKnownSchema(field.Message.Desc); schema != nil {
+			return schema
+		}
+		x.addMessage(schemas, field.Message)
+		return &OpenAPISchema{Ref: x.ref(field.Message.Desc)}
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+// wellKnownSchema returns an inline schema for well-known types that have a special protojson representation, or
+// nil if the given message doesn't have one.
+func wellKnownSchema(desc protoreflect.MessageDescriptor) *OpenAPISchema {
+	switch desc.FullName() {
+	case `google.protobuf.Timestamp`:
+		return &OpenAPISchema{Type: `string`, Format: `date-time`}
+	case `google.protobuf.Duration`:
+		return &OpenAPISchema{Type: `string`, Pattern: `^-?[0-9]+(\.[0-9]+)?s$`}
+	case `google.protobuf.FieldMask`:
+		return &OpenAPISchema{Type: `string`}
+	case `google.protobuf.Struct`:
+		return &OpenAPISchema{Type: `object`}
+	case `google.protobuf.ListValue`:
+		return &OpenAPISchema{Type: `array`, Items: &OpenAPISchema{}}
+	case `google.protobuf.Value`:
+		return &OpenAPISchema{}
+	case `google.protobuf.Empty`:
+		return &OpenAPISchema{Type: `object`}
+	case anyFullName:
+		return &OpenAPISchema{
+			Type:       `object`,
+			Properties: map[string]*OpenAPISchema{`@type`: {Type: `string`}},
+			Required:   []string{`@type`},
+		}
+	case `google.protobuf.BoolValue`:
+		return &OpenAPISchema{Type: []string{`boolean`, `null`}}
+	case `google.protobuf.StringValue`:
+		return &OpenAPISchema{Type: []string{`string`, `null`}}
+	case `google.protobuf.BytesValue`:
+		return &OpenAPISchema{Type: []string{`string`, `null`}, Format: `byte`}
+	case `google.protobuf.Int32Value`:
+		return &OpenAPISchema{Type: []string{`integer`, `null`}, Format: `int32`}
+	case `google.protobuf.UInt32Value`:
+		return &OpenAPISchema{Type: []string{`integer`, `null`}, Format: `uint32`}
+	case `google.protobuf.Int64Value`:
+		return &OpenAPISchema{Type: []string{`string`, `null`}, Format: `int64`}
+	case `google.protobuf.UInt64Value`:
+		return &OpenAPISchema{Type: []string{`string`, `null`}, Format: `uint64`}
+	case `google.protobuf.FloatValue`:
+		return &OpenAPISchema{Type: []string{`number`, `null`}, Format: `float`}
+	case `google.protobuf.DoubleValue`:
+		return &OpenAPISchema{Type: []string{`number`, `null`}, Format: `double`}
+	default:
+		return nil
+	}
+}
+
+// commentText returns the given comment, with leading and trailing whitespace trimmed from each line.
+func commentText(comment protogen.Comments) string {
+	lines := strings.Split(strings.TrimSpace(string(comment)), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,253 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// OperationInfo models the google.longrunning.operation_info option of a method returning a
+	// google.longrunning.Operation, with the types resolved using the cache, see also Cache.MethodOperation.
+	OperationInfo struct {
+		// Response is the message the operation resolves to, on success.
+		Response *protogen.Message
+		// Metadata is the message of the operation metadata, or nil, if metadata_type is not set.
+		Metadata *protogen.Message
+	}
+
+	// OperationGenerator generates typed wrappers for the long-running operations (AIP-151) returned by methods
+	// with the google.longrunning.operation_info option, like those generated by gapic, but for any plugin.
+	//
+	// For a method named "CreateBook", of a service named "Library", a type named "Library_CreateBookOperation" is
+	// generated, wrapping the google.longrunning.Operation, along with a constructor, "NewLibrary_CreateBookOperation",
+	// which accepts the operation, and the GetOperation method of the operations client, e.g.
+	// `NewLibrary_CreateBookOperation(op, operationsClient.GetOperation)`. The generated type has Metadata and
+	// Response methods, which unmarshal the typed messages, a Poll method, which fetches the latest state of the
+	// operation, and a Wait method, which polls until the operation is done, returning the response. Errors of
+	// failed operations are converted using status.ErrorProto, of google.golang.org/grpc, which requires that the
+	// google.rpc.Status is generated by google.golang.org/genproto, as is the case with the published protos.
+	OperationGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files, including
+		// google/longrunning/operations.proto.
+		Cache *Cache
+	}
+)
+
+const (
+	// OperationInfoFieldNumber is the field number of the google.longrunning.operation_info option, in
+	// google.protobuf.MethodOptions.
+	OperationInfoFieldNumber protoreflect.FieldNumber = 1049
+
+	operationFullName = `google.longrunning.Operation`
+)
+
+var (
+	_ Generator = (*OperationGenerator)(nil)
+)
+
+// MethodOperation decodes the google.longrunning.operation_info option of the given method, if it returns a
+// google.longrunning.Operation, without depending on the generated google.longrunning package, returning nil if
+// the method doesn't return an operation, or the option isn't set. Types are resolved relative to the package of
+// the method, falling back to the fully-qualified name. An error will be returned if the option is invalid, e.g.
+// the response_type is empty, or any type isn't loaded into the cache.
+func (x *Cache) MethodOperation(v *protogen.Method) (*OperationInfo, error) {
+	if v.Output.Desc.FullName() != operationFullName || v.Desc.IsStreamingClient() || v.Desc.IsStreamingServer() {
+		return nil, nil
+	}
+	values := OptionBytes(v.Desc, OperationInfoFieldNumber)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var responseType, metadataType string
+	for _, b := range values {
+		for len(b) != 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid google.longrunning.operation_info option for %s: %w", v.Desc.FullName(), protowire.ParseError(n))
+			}
+			b = b[n:]
+			if typ != protowire.BytesType {
+				if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+					return nil, fmt.Errorf("invalid google.longrunning.operation_info option for %s: %w", v.Desc.FullName(), protowire.ParseError(n))
+				}
+				b = b[n:]
+				continue
+			}
+			value, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid google.longrunning.operation_info option for %s: %w", v.Desc.FullName(), protowire.ParseError(n))
+			}
+			b = b[n:]
+			switch num {
+			case 1:
+				responseType = string(value)
+			case 2:
+				metadataType = string(value)
+			}
+		}
+	}
+	if responseType == `` {
+		return nil, fmt.Errorf("invalid google.longrunning.operation_info option for %s: missing response_type", v.Desc.FullName())
+	}
+	var info OperationInfo
+	for _, t := range [...]struct {
+		name   string
+		target **protogen.Message
+	}{
+		{responseType, &info.Response},
+		{metadataType, &info.Metadata},
+	} {
+		if t.name == `` {
+			continue
+		}
+		name := strings.TrimPrefix(t.name, `.`)
+		if *t.target = x.Message(v.Desc.ParentFile().Package().Append(protoreflect.Name(name))); *t.target == nil {
+			*t.target = x.Message(protoreflect.FullName(name))
+		}
+		if *t.target == nil {
+			return nil, fmt.Errorf("invalid google.longrunning.operation_info option for %s: unknown type %q", v.Desc.FullName(), t.name)
+		}
+	}
+	return &info, nil
+}
+
+// GenerateFile returns the operation type, constructor, and methods, for each method of each service in the given
+// file, returning an operation, with the operation_info option. It will panic if the option is invalid.
+func (x *OperationGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			info, err := x.Cache.MethodOperation(method)
+			if err != nil {
+				panic(err)
+			}
+			if info != nil {
+				elements = append(elements, x.Operation(method, info)...)
+			}
+		}
+	}
+	return elements
+}
+
+// Name returns the name of the operation type generated for the given method.
+func (x *OperationGenerator) Name(v *protogen.Method) string {
+	return v.Parent.GoName + `_` + v.GoName + `Operation`
+}
+
+// Operation returns the operation type, constructor, and methods, for the given method, with the given info.
+func (x *OperationGenerator) Operation(v *protogen.Method, info *OperationInfo) []gopoet.FileElement {
+	var (
+		name          = x.Name(v)
+		receiver      = gopoet.NewPointerReceiverForType(`x`, localType(name))
+		operationType = gopoet.PointerType(x.Cache.MessageType(v.Output.Desc))
+		getRequest    = x.Cache.Message(v.Output.Desc.ParentFile().Package().Append(`GetOperationRequest`))
+		contextType   = gopoet.NamedType(contextPkg.Symbol(`Context`))
+		optionsType   = gopoet.SliceType(gopoet.NamedType(grpcPkg.Symbol(`CallOption`)))
+		responseType  = gopoet.PointerType(x.Cache.MessageType(info.Response.Desc))
+	)
+	if getRequest == nil {
+		panic(fmt.Sprintf("unknown type: %v", v.Output.Desc.ParentFile().Package().Append(`GetOperationRequest`)))
+	}
+	var (
+		requestType = gopoet.PointerType(x.Cache.MessageType(getRequest.Desc))
+		getType     = gopoet.FuncTypeVariadic(
+			[]gopoet.ArgType{{Type: contextType}, {Type: requestType}, {Type: optionsType}},
+			[]gopoet.ArgType{{Type: operationType}, {Type: gopoet.ErrorType}},
+		)
+		setName = `request.Name = x.Operation.GetName()`
+	)
+	for _, field := range x.Cache.MessageFields(getRequest) {
+		if field.Fields()[0].Desc.Name() == `name` && field.Setter().Name != `` {
+			setName = fmt.Sprintf(`request.%s(x.Operation.GetName())`, field.Setter().Name)
+		}
+	}
+	elements := []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+			gopoet.NewField(`Operation`, operationType),
+			gopoet.NewField(`get`, getType),
+			gopoet.NewField(`options`, optionsType),
+		).SetComment(fmt.Sprintf(`%s wraps the operation returned by %s, which resolves to a %s.`, name, v.Desc.FullName(), info.Response.Desc.FullName()))),
+		gopoet.NewFunc(`New`+name).
+			SetComment(fmt.Sprintf(`New%s returns a wrapper for the given operation, which will be polled using get.`, name)).
+			AddArg(`operation`, operationType).
+			AddArg(`get`, getType).
+			AddArg(`options`, optionsType).
+			SetVariadic(true).
+			AddResult(``, gopoet.PointerType(localType(name))).
+			Printlnf(`return &%s{Operation: operation, get: get, options: options}`, localType(name)),
+		gopoet.NewMethod(receiver, `Done`).
+			SetComment(`Done returns true if the operation is done, i.e. succeeded or failed.`).
+			AddResult(``, gopoet.BoolType).
+			Println(`return x.Operation.GetDone()`),
+		gopoet.NewMethod(receiver, `Response`).
+			SetComment(`Response returns the response of the operation, nil if it isn't done, or an error, if it failed.`).
+			AddResult(``, responseType).
+			AddResult(``, gopoet.ErrorType).
+			Println(`if !x.Operation.GetDone() {`).
+			Println(`return nil, nil`).
+			Println(`}`).
+			Println(`if err := x.Operation.GetError(); err != nil {`).
+			Printlnf(`return nil, %s(err)`, statusPkg.Symbol(`ErrorProto`)).
+			Println(`}`).
+			Printlnf(`response := new(%s)`, responseType.Elem()).
+			Println(`if err := x.Operation.GetResponse().UnmarshalTo(response); err != nil {`).
+			Println(`return nil, err`).
+			Println(`}`).
+			Println(`return response, nil`),
+	}
+	if info.Metadata != nil {
+		metadataType := gopoet.PointerType(x.Cache.MessageType(info.Metadata.Desc))
+		elements = append(elements, gopoet.NewMethod(receiver, `Metadata`).
+			SetComment(`Metadata returns the metadata of the operation, or nil, if there is none.`).
+			AddResult(``, metadataType).
+			AddResult(``, gopoet.ErrorType).
+			Println(`if x.Operation.GetMetadata() == nil {`).
+			Println(`return nil, nil`).
+			Println(`}`).
+			Printlnf(`metadata := new(%s)`, metadataType.Elem()).
+			Println(`if err := x.Operation.GetMetadata().UnmarshalTo(metadata); err != nil {`).
+			Println(`return nil, err`).
+			Println(`}`).
+			Println(`return metadata, nil`))
+	}
+	return append(elements,
+		gopoet.NewMethod(receiver, `Poll`).
+			SetComment(`Poll fetches the latest state of the operation, unless it is done.`).
+			AddArg(`ctx`, contextType).
+			AddResult(``, gopoet.ErrorType).
+			Println(`if x.Operation.GetDone() {`).
+			Println(`return nil`).
+			Println(`}`).
+			Printlnf(`request := new(%s)`, requestType.Elem()).
+			Println(setName).
+			Println(`operation, err := x.get(ctx, request, x.options...)`).
+			Println(`if err != nil {`).
+			Println(`return err`).
+			Println(`}`).
+			Println(`x.Operation = operation`).
+			Println(`return nil`),
+		gopoet.NewMethod(receiver, `Wait`).
+			SetComment(`Wait polls the operation, at the given interval, until it is done, returning the response, see also Response.`).
+			AddArg(`ctx`, contextType).
+			AddArg(`interval`, durationType).
+			AddResult(``, responseType).
+			AddResult(``, gopoet.ErrorType).
+			Println(`for !x.Operation.GetDone() {`).
+			Printlnf(`timer := %s(interval)`, timePkg.Symbol(`NewTimer`)).
+			Println(`select {`).
+			Println(`case <-ctx.Done():`).
+			Println(`timer.Stop()`).
+			Println(`return nil, ctx.Err()`).
+			Println(`case <-timer.C:`).
+			Println(`}`).
+			Println(`if err := x.Poll(ctx); err != nil {`).
+			Println(`return nil, err`).
+			Println(`}`).
+			Println(`}`).
+			Println(`return x.Response()`),
+	)
+}
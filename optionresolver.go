@@ -0,0 +1,92 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"sync"
+)
+
+type (
+	// OptionResolver computes the effective value of a custom option, for a descriptor, by merging the values set on
+	// each of its parents, from the outermost (the file), to the innermost (the descriptor itself), e.g. a file-level
+	// default, overridden by a message-level value, overridden by a field-level value. Results are cached, per
+	// descriptor, and it is safe for concurrent use. Fields must not be modified after first use.
+	OptionResolver struct {
+		// Extensions are the extension types of the option, at each level, e.g. an extension of
+		// google.protobuf.FileOptions, and one of google.protobuf.FieldOptions, with the same value type. The value
+		// of a descriptor is that of the first extension (of its options message) that is set, see also GetOption.
+		Extensions []protoreflect.ExtensionType
+		// Value may be used to override how the (own) value of a descriptor is read, returning false if it isn't set,
+		// and takes precedence over Extensions.
+		Value func(desc protoreflect.Descriptor) (interface{}, bool)
+		// Merge may be used to override how the effective value of a parent (outer) is merged with the value of a
+		// descriptor (inner). By default, message values are merged using proto.Merge, into a clone of the outer
+		// value, and other values are replaced by the inner value.
+		Merge func(outer, inner interface{}) interface{}
+
+		mu     sync.Mutex
+		values map[protoreflect.Descriptor]optionValue
+	}
+
+	optionValue struct {
+		value interface{}
+		ok    bool
+	}
+)
+
+// Resolve returns the effective value of the option, for the given descriptor, and whether it (or any parent) has
+// the option set. Message values must be treated as read-only, as they may be shared.
+func (x *OptionResolver) Resolve(desc protoreflect.Descriptor) (interface{}, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.values == nil {
+		x.values = make(map[protoreflect.Descriptor]optionValue)
+	}
+	v := x.resolve(desc)
+	return v.value, v.ok
+}
+
+func (x *OptionResolver) resolve(desc protoreflect.Descriptor) optionValue {
+	if v, ok := x.values[desc]; ok {
+		return v
+	}
+	var v optionValue
+	if parent := desc.Parent(); parent != nil {
+		v = x.resolve(parent)
+	}
+	if value, ok := x.value(desc); ok {
+		if v.ok {
+			value = x.merge(v.value, value)
+		}
+		v = optionValue{value: value, ok: true}
+	}
+	x.values[desc] = v
+	return v
+}
+
+// value returns the (own) value of the given descriptor, see also Value.
+func (x *OptionResolver) value(desc protoreflect.Descriptor) (interface{}, bool) {
+	if x.Value != nil {
+		return x.Value(desc)
+	}
+	for _, ext := range x.Extensions {
+		if v, ok := GetOption(desc, ext); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (x *OptionResolver) merge(outer, inner interface{}) interface{} {
+	if x.Merge != nil {
+		return x.Merge(outer, inner)
+	}
+	if outer, ok := outer.(proto.Message); ok {
+		if inner, ok := inner.(proto.Message); ok {
+			v := proto.Clone(outer)
+			proto.Merge(v, inner)
+			return v
+		}
+	}
+	return inner
+}
@@ -0,0 +1,121 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// DebugRedactFieldNumber is the field number of the debug_redact option, in google.protobuf.FieldOptions.
+	DebugRedactFieldNumber protoreflect.FieldNumber = 16
+)
+
+// GetOption returns the value of the given extension, from the options of the given descriptor, and whether it was
+// present. Unlike proto.GetExtension, the extension will be resolved even if it was not registered at the time the
+// descriptor was parsed (e.g. the plugin doesn't import the package declaring the extension, or vice versa).
+func GetOption(desc protoreflect.Descriptor, ext protoreflect.ExtensionType) (interface{}, bool) {
+	options := desc.Options()
+	if options == nil || !options.ProtoReflect().IsValid() {
+		return nil, false
+	}
+	if proto.HasExtension(options, ext) {
+		return proto.GetExtension(options, ext), true
+	}
+	if len(options.ProtoReflect().GetUnknown()) == 0 {
+		return nil, false
+	}
+	b, err := proto.Marshal(options)
+	if err != nil {
+		return nil, false
+	}
+	var resolver protoregistry.Types
+	if err := resolver.RegisterExtension(ext); err != nil {
+		return nil, false
+	}
+	options = options.ProtoReflect().New().Interface()
+	if err := (proto.UnmarshalOptions{Resolver: &resolver}).Unmarshal(b, options); err != nil {
+		return nil, false
+	}
+	if !proto.HasExtension(options, ext) {
+		return nil, false
+	}
+	return proto.GetExtension(options, ext), true
+}
+
+// OptionFields returns the raw (encoded) fields with the given number, from the options of the given descriptor,
+// in the order they were encoded. This may be used to decode custom options without a corresponding extension type,
+// or options unknown to the linked version of descriptorpb.
+func OptionFields(desc protoreflect.Descriptor, number protoreflect.FieldNumber) protoreflect.RawFields {
+	options := desc.Options()
+	if options == nil || !options.ProtoReflect().IsValid() {
+		return nil
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(options)
+	if err != nil {
+		return nil
+	}
+	var fields protoreflect.RawFields
+	for len(b) != 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fields
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return fields
+		}
+		if num == number {
+			fields = append(fields, b[:n+m]...)
+		}
+		b = b[n+m:]
+	}
+	return fields
+}
+
+// OptionVarint decodes the last varint value with the given number from the options of the given descriptor, see
+// also OptionFields. This is suitable for bool, enum, and (non-zigzag) integer options.
+func OptionVarint(desc protoreflect.Descriptor, number protoreflect.FieldNumber) (uint64, bool) {
+	var (
+		value uint64
+		ok    bool
+	)
+	for b := OptionFields(desc, number); len(b) != 0; {
+		_, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		if typ == protowire.VarintType {
+			value, n = protowire.ConsumeVarint(b)
+			ok = true
+		} else {
+			n = protowire.ConsumeFieldValue(number, typ, b)
+		}
+		b = b[n:]
+	}
+	return value, ok
+}
+
+// OptionBytes decodes each length-delimited value with the given number from the options of the given descriptor,
+// see also OptionFields. This is suitable for string, bytes, message (which may be merged), and packed options.
+func OptionBytes(desc protoreflect.Descriptor, number protoreflect.FieldNumber) [][]byte {
+	var values [][]byte
+	for b := OptionFields(desc, number); len(b) != 0; {
+		_, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		if typ == protowire.BytesType {
+			var value []byte
+			value, n = protowire.ConsumeBytes(b)
+			values = append(values, value)
+		} else {
+			n = protowire.ConsumeFieldValue(number, typ, b)
+		}
+		b = b[n:]
+	}
+	return values
+}
+
+// IsDebugRedact returns true if the given field has the debug_redact option set.
+func IsDebugRedact(desc protoreflect.FieldDescriptor) bool {
+	v, _ := OptionVarint(desc, DebugRedactFieldNumber)
+	return v != 0
+}
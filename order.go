@@ -0,0 +1,43 @@
+package gopoet_protogen
+
+import (
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"sort"
+)
+
+// Range calls f with the type of each message and enum loaded into the cache, including any pending lazy loading,
+// and any imported, ordered by full name, until f returns false.
+//
+// Like the rest of the cache, and the generators, the order never depends on golang map iteration, so generated
+// output is stable, given the same input. Other APIs follow declaration order, e.g. FileMessages and MessageFields,
+// or the order files were added, e.g. Files.
+func (x *Cache) Range(f func(fullName protoreflect.FullName, t gopoet.TypeName) bool) {
+	x.once.Do(x.init)
+	x.loadPending()
+	names := make([]protoreflect.FullName, 0, len(x.types))
+	for k := range x.types {
+		names = append(names, k)
+	}
+	sortFullNames(names)
+	for _, k := range names {
+		if !f(k, x.types[k]) {
+			return
+		}
+	}
+}
+
+// sortedKeys returns the keys of the given map, sorted.
+func sortedKeys(m map[protoreflect.FullName]protogen.GoIdent) []protoreflect.FullName {
+	keys := make([]protoreflect.FullName, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortFullNames(keys)
+	return keys
+}
+
+func sortFullNames(names []protoreflect.FullName) {
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+}
@@ -0,0 +1,174 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// PagerGenerator generates pagers for the paginated methods of services (AIP-158), which fetch pages on demand,
+	// see also MethodPagination.
+	//
+	// For a method named "ListBooks", of a service named "Library", a pager type named "Library_ListBooksPager" is
+	// generated, along with a constructor, "NewLibrary_ListBooksPager", which accepts the method of the client,
+	// generated by protoc-gen-go-grpc, e.g. `NewLibrary_ListBooksPager(client.ListBooks, req)`. The NextPage method
+	// returns each page, then io.EOF, and the All method returns an iterator over the items of all pages, with any
+	// error, compatible with iter.Seq2, of Go 1.23.
+	PagerGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+	}
+
+	// Pagination models the fields of a paginated method (AIP-158), see also MethodPagination.
+	Pagination struct {
+		// PageSize is the page_size field of the request, an int32.
+		PageSize *protogen.Field
+		// PageToken is the page_token field of the request, a string.
+		PageToken *protogen.Field
+		// NextPageToken is the next_page_token field of the response, a string.
+		NextPageToken *protogen.Field
+		// Items is the first repeated (non-map) field of the response, in declaration order.
+		Items *protogen.Field
+	}
+)
+
+var (
+	_ Generator = (*PagerGenerator)(nil)
+)
+
+// MethodPagination returns the pagination fields of the given method, or nil, if it is not a unary method, with a
+// request with page_size and page_token fields, and a response with a next_page_token field, and a repeated field.
+func MethodPagination(v *protogen.Method) *Pagination {
+	if v.Desc.IsStreamingClient() || v.Desc.IsStreamingServer() {
+		return nil
+	}
+	var p Pagination
+	for _, field := range v.Input.Fields {
+		switch {
+		case field.Desc.IsList():
+		case field.Desc.Name() == `page_size` && field.Desc.Kind() == protoreflect.Int32Kind:
+			p.PageSize = field
+		case field.Desc.Name() == `page_token` && field.Desc.Kind() == protoreflect.StringKind:
+			p.PageToken = field
+		}
+	}
+	for _, field := range v.Output.Fields {
+		switch {
+		case field.Desc.IsList():
+			if p.Items == nil {
+				p.Items = field
+			}
+		case field.Desc.IsMap():
+		case field.Desc.Name() == `next_page_token` && field.Desc.Kind() == protoreflect.StringKind:
+			p.NextPageToken = field
+		}
+	}
+	if p.PageSize == nil || p.PageToken == nil || p.NextPageToken == nil || p.Items == nil {
+		return nil
+	}
+	return &p
+}
+
+// GenerateFile returns the pager type, constructor, and methods, for each paginated method of each service in the
+// given file.
+func (x *PagerGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if p := MethodPagination(method); p != nil {
+				elements = append(elements, x.Pager(method, p)...)
+			}
+		}
+	}
+	return elements
+}
+
+// Name returns the name of the pager type generated for the given method.
+func (x *PagerGenerator) Name(v *protogen.Method) string {
+	return v.Parent.GoName + `_` + v.GoName + `Pager`
+}
+
+// Pager returns the pager type, constructor, and methods, for the given method, with the given pagination fields.
+func (x *PagerGenerator) Pager(v *protogen.Method, p *Pagination) []gopoet.FileElement {
+	var (
+		name              = x.Name(v)
+		pagerType         = gopoet.PointerType(localType(name))
+		receiver          = gopoet.NewPointerReceiverForType(`x`, localType(name))
+		request, response = x.Cache.MethodTypes(v)
+		requestType       = gopoet.PointerType(request)
+		responseType      = gopoet.PointerType(response)
+		contextType       = gopoet.NamedType(contextPkg.Symbol(`Context`))
+		optionsType       = gopoet.SliceType(gopoet.NamedType(grpcPkg.Symbol(`CallOption`)))
+		fetchType         = gopoet.FuncTypeVariadic(
+			[]gopoet.ArgType{{Type: contextType}, {Type: requestType}, {Type: optionsType}},
+			[]gopoet.ArgType{{Type: responseType}, {Type: gopoet.ErrorType}},
+		)
+		token     = x.Cache.PathFields(FieldPath{Fields: []*protogen.Field{p.PageToken}})[0]
+		setToken  = fmt.Sprintf(`x.request.%s = token`, token.Name())
+		items     = x.Cache.PathFields(FieldPath{Fields: []*protogen.Field{p.Items}})[0]
+		next      = x.Cache.PathFields(FieldPath{Fields: []*protogen.Field{p.NextPageToken}})[0]
+		itemType  = items.Type().Elem()
+		yieldType = gopoet.FuncType([]gopoet.ArgType{{Type: itemType}, {Type: gopoet.ErrorType}}, []gopoet.ArgType{{Type: gopoet.BoolType}})
+	)
+	if setter := token.Setter(); setter.Name != `` {
+		setToken = fmt.Sprintf(`x.request.%s(token)`, setter.Name)
+	}
+	return []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+			gopoet.NewField(`fetch`, fetchType),
+			gopoet.NewField(`request`, requestType),
+			gopoet.NewField(`options`, optionsType),
+			gopoet.NewField(`done`, gopoet.BoolType),
+		).SetComment(fmt.Sprintf(`%s fetches the pages of %s, on demand.`, name, v.Desc.FullName()))),
+		gopoet.NewFunc(`New`+name).
+			SetComment(fmt.Sprintf(`New%s returns a pager for the given request, which is cloned, starting from its page token.`, name)).
+			AddArg(`fetch`, fetchType).
+			AddArg(`request`, requestType).
+			AddArg(`options`, optionsType).
+			SetVariadic(true).
+			AddResult(``, pagerType).
+			Printlnf(`return &%s{fetch: fetch, request: %s(request).(%s), options: options}`, localType(name), protoPkg.Symbol(`Clone`), requestType),
+		gopoet.NewMethod(receiver, `NextPage`).
+			SetComment(`NextPage fetches the next page, or returns io.EOF, after the last page.`).
+			AddArg(`ctx`, contextType).
+			AddResult(``, responseType).
+			AddResult(``, gopoet.ErrorType).
+			Println(`if x.done {`).
+			Printlnf(`return nil, %s`, ioPkg.Symbol(`EOF`)).
+			Println(`}`).
+			Println(`page, err := x.fetch(ctx, x.request, x.options...)`).
+			Println(`if err != nil {`).
+			Println(`return nil, err`).
+			Println(`}`).
+			Printlnf(`if token := page.%s(); token != "" {`, next.Getter().Name).
+			Println(setToken).
+			Println(`} else {`).
+			Println(`x.done = true`).
+			Println(`}`).
+			Println(`return page, nil`),
+		gopoet.NewMethod(receiver, `All`).
+			SetComment(`All returns an iterator over the items of the remaining pages, which stops after the first error.`).
+			AddArg(`ctx`, contextType).
+			AddResult(``, gopoet.FuncType([]gopoet.ArgType{{Name: `yield`, Type: yieldType}}, nil)).
+			Printlnf(`return func(yield %s) {`, yieldType).
+			Println(`for {`).
+			Println(`page, err := x.NextPage(ctx)`).
+			Printlnf(`if err == %s {`, ioPkg.Symbol(`EOF`)).
+			Println(`return`).
+			Println(`}`).
+			Println(`if err != nil {`).
+			Printlnf(`var zero %s`, itemType).
+			Println(`yield(zero, err)`).
+			Println(`return`).
+			Println(`}`).
+			Printlnf(`for _, v := range page.%s() {`, items.Getter().Name).
+			Println(`if !yield(v, nil) {`).
+			Println(`return`).
+			Println(`}`).
+			Println(`}`).
+			Println(`}`).
+			Println(`}`),
+	}
+}
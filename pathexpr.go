@@ -0,0 +1,89 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// PathExpr returns a golang expression accessing the value of the last of the given fields, via a chain of getter
+// calls on the given root expression, e.g. `root.GetB().GetC()`, which is nil-safe, as getters return the zero value
+// for nil messages. Each field except the last must be a singular message field, of the message type of the
+// previous field (or the root), otherwise it will panic.
+func PathExpr(root *gopoet.CodeBlock, fields ...Field) *gopoet.CodeBlock {
+	cb := new(gopoet.CodeBlock).AddCode(root)
+	for i, field := range fields {
+		if i != len(fields)-1 {
+			checkPathField(field)
+		}
+		cb.Printf(`.%s()`, field.Getter().Name)
+	}
+	return cb
+}
+
+// PathExists returns a golang expression that is true if each of the given fields is present, via the root
+// expression, which short-circuits, e.g. `root.GetB() != nil && root.GetB().GetC() != nil`, see also PathExpr. The
+// last field is considered present if it is non-nil, for messages, oneofs, and fields with explicit presence,
+// non-empty, for repeated and map fields, or non-zero, otherwise.
+func PathExists(root *gopoet.CodeBlock, fields ...Field) *gopoet.CodeBlock {
+	if len(fields) == 0 {
+		panic(`invalid field path: no fields`)
+	}
+	cb := new(gopoet.CodeBlock)
+	for i := range fields[:len(fields)-1] {
+		checkPathField(fields[i])
+		cb.AddCode(PathExpr(root, fields[:i+1]...)).Print(` != nil && `)
+	}
+	var (
+		field  = fields[len(fields)-1]
+		parent = PathExpr(root, fields[:len(fields)-1]...)
+		desc   = field.Fields()[0].Desc
+	)
+	switch {
+	case field.OneOf() != nil && !field.OneOf().Desc.IsSynthetic(),
+		desc.Message() != nil && !desc.IsList() && !desc.IsMap():
+		cb.AddCode(PathExpr(root, fields...)).Print(` != nil`)
+	case desc.IsList() || desc.IsMap():
+		cb.Print(`len(`).AddCode(PathExpr(root, fields...)).Print(`) != 0`)
+	case desc.HasPresence():
+		// the getter can't distinguish presence, so the field is accessed directly, guarded by the nil checks
+		if len(fields) == 1 {
+			cb.AddCode(parent).Print(` != nil && `)
+		}
+		cb.AddCode(parent).Printf(`.%s != nil`, field.Name())
+	default:
+		check := strings.SplitN(zeroCheck(desc.Kind(), "\x00"), "\x00", 2)
+		cb.Print(check[0]).AddCode(PathExpr(root, fields...)).Print(check[1])
+	}
+	return cb
+}
+
+// PathFields returns the Field values corresponding to each of the fields of the given path, see also
+// Cache.MessageFields.
+func (x *Cache) PathFields(v FieldPath) []Field {
+	fields := make([]Field, len(v.Fields))
+	for i, field := range v.Fields {
+		for _, f := range x.MessageFields(field.Parent) {
+			for _, g := range f.Fields() {
+				if g == field {
+					fields[i] = f
+				}
+			}
+		}
+		if fields[i] == nil {
+			panic(fmt.Sprintf("unknown type: %v", field.Desc))
+		}
+	}
+	return fields
+}
+
+// checkPathField panics if the given field is not a singular message field.
+func checkPathField(field Field) {
+	if field.OneOf() != nil && !field.OneOf().Desc.IsSynthetic() ||
+		field.Kind() != protoreflect.MessageKind && field.Kind() != protoreflect.GroupKind ||
+		field.Fields()[0].Desc.IsList() ||
+		field.Fields()[0].Desc.IsMap() {
+		panic(fmt.Sprintf("invalid field path: %s is not a singular message field", field.Name()))
+	}
+}
@@ -0,0 +1,154 @@
+package gopoet_protogen
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"io"
+)
+
+type (
+	// SymbolKind is the kind of a top-level golang declaration, see also PlannedSymbol.
+	SymbolKind int
+
+	// PlannedSymbol models a top-level declaration a generator would emit, see also PlanFile.
+	PlannedSymbol struct {
+		// Generator is the name of the (dereferenced) type of the generator, e.g. "RedactGenerator".
+		Generator string `json:"generator"`
+		// Kind is the kind of declaration.
+		Kind SymbolKind `json:"kind"`
+		// Name is the name of the declaration, where methods are qualified by their receiver type, e.g. "Foo.Bar".
+		Name string `json:"name"`
+	}
+
+	// GenerationPlan models an output file that would be generated, and its top-level declarations, without
+	// rendering it, e.g. to preview the output, or compute the build graph, of a large number of files cheaply, see
+	// also Pool.DryRun, and WritePlanReport.
+	GenerationPlan struct {
+		// File is the path of the input file.
+		File string `json:"file"`
+		// Name is the (base) name of the output file, e.g. "foo.pb.x.go", see also NewGoFile.
+		Name string `json:"name"`
+		// Package is the import path of the golang package of the output file.
+		Package string `json:"package"`
+		// Symbols are the declarations of the output file, in order.
+		Symbols []PlannedSymbol `json:"symbols"`
+	}
+)
+
+const (
+	// SymbolFunc is a function.
+	SymbolFunc SymbolKind = iota
+	// SymbolMethod is a method, i.e. a function with a receiver.
+	SymbolMethod
+	// SymbolType is a type, including aliases.
+	SymbolType
+	// SymbolConst is a constant.
+	SymbolConst
+	// SymbolVar is a variable.
+	SymbolVar
+)
+
+var (
+	symbolKindNames = [...]string{
+		`func`,
+		`method`,
+		`type`,
+		`const`,
+		`var`,
+	}
+)
+
+// String returns the keyword-like name of the kind, e.g. "func", or "method".
+func (x SymbolKind) String() string {
+	if x >= 0 && int(x) < len(symbolKindNames) {
+		return symbolKindNames[x]
+	}
+	return fmt.Sprintf(`SymbolKind(%d)`, int(x))
+}
+
+// MarshalText implements encoding.TextMarshaler, using String.
+func (x SymbolKind) MarshalText() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+// PlanFile returns the top-level declarations each of the given generators would emit for the given file, in the
+// order they would be generated, see also Generate. Elements are generated, as they must be to determine their
+// names, but aren't rendered, or formatted, which is (typically) the bulk of the cost of generation. Note that
+// declarations like `var _ = ...` are included, with the name "_".
+func PlanFile(file *protogen.File, generators ...Generator) []PlannedSymbol {
+	var symbols []PlannedSymbol
+	for _, generator := range generators {
+		name := generatorName(generator)
+		for _, element := range generator.GenerateFile(file) {
+			symbols = appendSymbols(symbols, name, element)
+		}
+	}
+	return symbols
+}
+
+// Plan returns the plan for the given output file, populated by the given generators, for the given input file,
+// without modifying, or rendering, the output file, see also PlanFile.
+func Plan(dst *gopoet.GoFile, file *protogen.File, generators ...Generator) GenerationPlan {
+	return GenerationPlan{
+		File:    file.Desc.Path(),
+		Name:    dst.Name,
+		Package: dst.Package().ImportPath,
+		Symbols: PlanFile(file, generators...),
+	}
+}
+
+// Counts returns the number of symbols of each kind.
+func (x GenerationPlan) Counts() map[SymbolKind]int {
+	counts := make(map[SymbolKind]int)
+	for _, v := range x.Symbols {
+		counts[v.Kind]++
+	}
+	return counts
+}
+
+// WritePlanReport writes the given plans, as a JSON array of GenerationPlan, to the given writer, e.g. as a build
+// artifact, see also Pool.DryRun, and PoolResult.Plan.
+func WritePlanReport(w io.Writer, plans []GenerationPlan) error {
+	plans = append([]GenerationPlan{}, plans...)
+	for i := range plans {
+		if plans[i].Symbols == nil {
+			plans[i].Symbols = []PlannedSymbol{}
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent(``, `  `)
+	return encoder.Encode(plans)
+}
+
+func appendSymbols(dst []PlannedSymbol, generator string, element gopoet.FileElement) []PlannedSymbol {
+	add := func(kind SymbolKind, name string) {
+		dst = append(dst, PlannedSymbol{Generator: generator, Kind: kind, Name: name})
+	}
+	switch element := element.(type) {
+	case *gopoet.FuncSpec:
+		if element.Receiver == nil {
+			add(SymbolFunc, element.Name)
+		} else {
+			add(SymbolMethod, element.Receiver.Type+`.`+element.Name)
+		}
+	case *gopoet.TypeDecl:
+		for _, v := range element.Types {
+			add(SymbolType, v.Name)
+		}
+	case *gopoet.ConstDecl:
+		for _, v := range element.Consts {
+			for _, name := range v.Names {
+				add(SymbolConst, name)
+			}
+		}
+	case *gopoet.VarDecl:
+		for _, v := range element.Vars {
+			for _, name := range v.Names {
+				add(SymbolVar, name)
+			}
+		}
+	}
+	return dst
+}
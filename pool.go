@@ -0,0 +1,118 @@
+package gopoet_protogen
+
+import (
+	"bytes"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"runtime"
+	"sync"
+)
+
+type (
+	// Pool generates and renders files concurrently, using a frozen Cache, see also Cache.Freeze.
+	Pool struct {
+		// Cache is frozen by Run, and must already contain all referenced files.
+		Cache *Cache
+		// NewFile returns the output file for a given input file, or nil to skip it, and must be safe to call
+		// concurrently.
+		NewFile func(file *protogen.File) *gopoet.GoFile
+		// Generators populate each output file, see also Generate. They must be safe to call concurrently.
+		Generators []Generator
+		// Workers is the maximum number of files that will be generated concurrently, and defaults to
+		// runtime.GOMAXPROCS.
+		Workers int
+		// CodeSizes may be set to measure the code emitted by each generator, for each file, see also
+		// PoolResult.Sizes, and WriteCodeSizeReport.
+		CodeSizes bool
+		// DryRun may be set to plan each file, rather than rendering it, such that only PoolResult.Plan is set, see
+		// also Plan. This is typically much faster than a full generation pass, e.g. for previews.
+		DryRun bool
+	}
+
+	// PoolResult models the output of Pool.Run, for a single input file.
+	PoolResult struct {
+		// Source is the input file.
+		Source *protogen.File
+		// File is the generated output file.
+		File *gopoet.GoFile
+		// Content is the rendered (and formatted) golang source of File.
+		Content []byte
+		// Sizes is the code emitted by each generator, if Pool.CodeSizes is set, see also Cache.CodeSizes.
+		Sizes []CodeSize
+		// Plan is the plan of File, if Pool.DryRun is set, in which case File isn't populated, and Content is nil.
+		Plan *GenerationPlan
+	}
+)
+
+// Run generates and renders the given files concurrently, returning the results in the same order as the input,
+// excluding any files skipped by NewFile. If any files fail, the error of the first, in input order, is returned.
+func (x *Pool) Run(files []*protogen.File) ([]PoolResult, error) {
+	x.Cache.Freeze()
+
+	workers := x.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	var (
+		results = make([]PoolResult, len(files))
+		errs    = make([]error, len(files))
+		indexes = make(chan int)
+		wg      sync.WaitGroup
+	)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], errs[i] = x.run(files[i])
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	n := 0
+	for i := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if results[i].File != nil {
+			results[n] = results[i]
+			n++
+		}
+	}
+	return results[:n], nil
+}
+
+func (x *Pool) run(file *protogen.File) (PoolResult, error) {
+	result := PoolResult{Source: file, File: x.NewFile(file)}
+	if result.File == nil {
+		return result, nil
+	}
+	if x.DryRun {
+		plan := Plan(result.File, file, x.Generators...)
+		result.Plan = &plan
+		return result, nil
+	}
+	Generate(result.File, file, x.Generators...)
+	var b bytes.Buffer
+	if err := x.Cache.WriteGoFile(&b, result.File); err != nil {
+		return result, err
+	}
+	result.Content = b.Bytes()
+	if x.CodeSizes {
+		sizes, err := x.Cache.CodeSizes(result.File, file, x.Generators...)
+		if err != nil {
+			return result, err
+		}
+		result.Sizes = sizes
+	}
+	return result, nil
+}
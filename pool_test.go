@@ -0,0 +1,175 @@
+package gopoet_protogen_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jhump/gopoet"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"github.com/joeycumines/gopoet-protogen/protogentest"
+	"google.golang.org/protobuf/compiler/protogen"
+	"path"
+	"testing"
+)
+
+type (
+	// invalidGenerator emits code that fails to format, for the files it includes.
+	invalidGenerator struct {
+		Include func(file *protogen.File) bool
+	}
+)
+
+var (
+	_ gopoet_protogen.Generator = (*invalidGenerator)(nil)
+)
+
+func (x *invalidGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	if !x.Include(file) {
+		return nil
+	}
+	return []gopoet.FileElement{gopoet.NewFunc(`Invalid`).Printlnf(`// %s`, file.Desc.Path()).Println(`}{`)}
+}
+
+// compilePoolFiles compiles n files, each in its own package, importing libraryProto.
+func compilePoolFiles(t testing.TB, n int) *protogentest.Result {
+	t.Helper()
+	sources := map[string]string{libraryFile: libraryProto}
+	for i := 0; i < n; i++ {
+		sources[fmt.Sprintf(`pool/v%d/pool.proto`, i)] = fmt.Sprintf(`syntax = "proto3";
+
+package pool.v%[1]d;
+
+import "test/v1/library.proto";
+
+message Shelf {
+  string name = 1;
+  repeated test.v1.Book books = 2;
+  map<string, Shelf> children = 3;
+  oneof kind {
+    int64 id = 4;
+    string code = 5;
+  }
+}
+
+message Index {
+  repeated Shelf shelves = 1;
+  test.v1.Genre genre = %[2]d;
+}
+`, i, i+2)
+	}
+	return protogentest.MustCompile(t, sources, nil)
+}
+
+// newPool returns a pool generating every file of the given result, except those in package pool.v0.
+func newPool(result *protogentest.Result, workers int) *gopoet_protogen.Pool {
+	return &gopoet_protogen.Pool{
+		Cache: result.Cache,
+		NewFile: func(file *protogen.File) *gopoet.GoFile {
+			if file.Desc.Package() == `pool.v0` {
+				return nil
+			}
+			return gopoet.NewGoFile(path.Base(file.GeneratedFilenamePrefix)+`.pb.x.go`, string(file.GoImportPath), string(file.GoPackageName))
+		},
+		Generators: []gopoet_protogen.Generator{
+			&gopoet_protogen.DiffGenerator{Cache: result.Cache},
+			&gopoet_protogen.SizeGenerator{Cache: result.Cache},
+			&gopoet_protogen.DebugStringGenerator{Cache: result.Cache},
+		},
+		Workers: workers,
+	}
+}
+
+func TestPool_Run_order(t *testing.T) {
+	const files = 32
+	var expected [][]byte
+	for _, workers := range [...]int{1, 4, files * 2} {
+		t.Run(fmt.Sprint(workers), func(t *testing.T) {
+			result := compilePoolFiles(t, files)
+			results, err := newPool(result, workers).Run(result.Plugin.Files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var (
+				i       = 0
+				content [][]byte
+			)
+			for _, file := range result.Plugin.Files {
+				if file.Desc.Package() == `pool.v0` {
+					continue
+				}
+				if i >= len(results) || results[i].Source != file {
+					t.Fatalf("unexpected result %d for %s", i, file.Desc.Path())
+				}
+				if !bytes.Contains(results[i].Content, []byte(`package `+string(file.GoPackageName))) {
+					t.Errorf("unexpected content for %s:\n%s", file.Desc.Path(), results[i].Content)
+				}
+				content = append(content, results[i].Content)
+				i++
+			}
+			if i != len(results) {
+				t.Fatalf("unexpected results: %d", len(results))
+			}
+			if expected == nil {
+				expected = content
+			} else {
+				for i := range expected {
+					if !bytes.Equal(expected[i], content[i]) {
+						t.Errorf("result %d differs from serial run:\n%s", i, protogentest.Diff(string(expected[i]), string(content[i])))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPool_Run_error(t *testing.T) {
+	result := compilePoolFiles(t, 32)
+	pool := newPool(result, 8)
+	include := func(file *protogen.File) bool {
+		return file.Desc.Package() == `pool.v7` || file.Desc.Package() == `pool.v20`
+	}
+	pool.Generators = append(pool.Generators, &invalidGenerator{Include: include})
+	// both fail, but the error of the first file, in input order, is returned
+	var expected string
+	for _, file := range result.Plugin.Files {
+		if include(file) {
+			expected = `// ` + file.Desc.Path()
+			break
+		}
+	}
+	for i := 0; i < 3; i++ {
+		results, err := pool.Run(result.Plugin.Files)
+		if results != nil {
+			t.Fatal(results)
+		}
+		var formatErr *gopoet.FormatError
+		if !errors.As(err, &formatErr) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Contains(formatErr.Unformatted, []byte(expected+"\n")) {
+			t.Fatalf("unexpected error:\n%s", formatErr.Unformatted)
+		}
+	}
+}
+
+func BenchmarkPool_Run(b *testing.B) {
+	result := compilePoolFiles(b, 64)
+	for _, bm := range [...]struct {
+		name    string
+		workers int
+	}{
+		{`serial`, 1},
+		{`parallel`, 0},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			pool := newPool(result, bm.workers)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := pool.Run(result.Plugin.Files); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package protogentest
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// API returns the exported API of the golang package in the given directory, one sorted line per symbol, field,
+// method, and interface method, in the format of the golang api/go1.txt files, e.g.
+// "pkg gopoet_protogen, method (*Cache) AddFile(*protogen.File)", and
+// "pkg gopoet_protogen, type Cache struct, Lazy bool", such that any change to the shape of the API changes at
+// least one line, see also AssertAPI. Types are formatted as they appear in the source, and test files, as well as
+// files excluded by build constraints, are ignored.
+func API(dir string) ([]byte, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		fset  = token.NewFileSet()
+		lines []string
+	)
+	for _, name := range pkg.GoFiles {
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		a := &apiWriter{fset: fset, prefix: `pkg ` + pkg.Name + `, `}
+		for _, decl := range file.Decls {
+			a.decl(decl)
+		}
+		lines = append(lines, a.lines...)
+	}
+	sort.Strings(lines)
+	var b bytes.Buffer
+	for i, line := range lines {
+		if i == 0 || line != lines[i-1] {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// CompareAPI compares two outputs of API, returning the removed lines, i.e. incompatible changes (which includes
+// changed symbols), and the added lines, i.e. compatible changes.
+func CompareAPI(expected, actual []byte) (removed, added []string) {
+	var (
+		a = apiLines(expected)
+		b = apiLines(actual)
+	)
+	for line := range a {
+		if !b[line] {
+			removed = append(removed, line)
+		}
+	}
+	for line := range b {
+		if !a[line] {
+			added = append(added, line)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added
+}
+
+// AssertAPI compares the API of the golang package in the given directory against the file at the given path (e.g.
+// api.txt), failing the test, listing incompatible changes and additions separately, if they differ, see also API,
+// and CompareAPI. This guards against changes to the shape of a public API, e.g. between minor versions, as every
+// change must be committed to the file. If the update flag is set, the file will be written instead, see also
+// UpdateFlag.
+func AssertAPI(t testing.TB, dir, golden string) {
+	t.Helper()
+
+	actual, err := API(dir)
+	if err != nil {
+		t.Fatalf("failed to load API of %s: %v", dir, err)
+	}
+
+	if updateGolden() {
+		if err := os.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read API file (run with -%s to create it): %v", UpdateFlag, err)
+	}
+
+	removed, added := CompareAPI(expected, actual)
+	if len(removed) != 0 {
+		t.Errorf("incompatible API changes to %s, removed or changed from %s:\n-%s", dir, golden, strings.Join(removed, "\n-"))
+	}
+	if len(added) != 0 {
+		t.Errorf("API additions to %s, not in %s (run with -%s to update):\n+%s", dir, golden, UpdateFlag, strings.Join(added, "\n+"))
+	}
+}
+
+type apiWriter struct {
+	fset   *token.FileSet
+	prefix string
+	lines  []string
+}
+
+func (x *apiWriter) emit(format string, args ...interface{}) {
+	x.lines = append(x.lines, x.prefix+fmt.Sprintf(format, args...))
+}
+
+func (x *apiWriter) decl(decl ast.Decl) {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if !decl.Name.IsExported() {
+			return
+		}
+		if decl.Recv == nil {
+			x.emit(`func %s%s`, decl.Name.Name, x.signature(decl.Type))
+			return
+		}
+		recv := decl.Recv.List[0].Type
+		base := recv
+		if star, ok := base.(*ast.StarExpr); ok {
+			base = star.X
+		}
+		if ident, ok := base.(*ast.Ident); ok && ident.IsExported() {
+			x.emit(`method (%s) %s%s`, x.expr(recv), decl.Name.Name, x.signature(decl.Type))
+		}
+
+	case *ast.GenDecl:
+		var typ ast.Expr
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if spec.Name.IsExported() {
+					x.typeSpec(spec)
+				}
+			case *ast.ValueSpec:
+				kind := `var`
+				if decl.Tok == token.CONST {
+					kind = `const`
+					// implicit repetition of the previous type, e.g. with iota
+					if spec.Type != nil || len(spec.Values) != 0 {
+						typ = spec.Type
+					}
+				} else {
+					typ = spec.Type
+				}
+				for i, name := range spec.Names {
+					switch {
+					case !name.IsExported():
+					case typ != nil:
+						x.emit(`%s %s %s`, kind, name.Name, x.expr(typ))
+					case decl.Tok == token.CONST && i < len(spec.Values):
+						if lit, ok := spec.Values[i].(*ast.BasicLit); ok {
+							x.emit(`%s %s = %s`, kind, name.Name, lit.Value)
+							break
+						}
+						fallthrough
+					default:
+						x.emit(`%s %s`, kind, name.Name)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (x *apiWriter) typeSpec(spec *ast.TypeSpec) {
+	name := spec.Name.Name
+	if spec.Assign.IsValid() {
+		x.emit(`type %s = %s`, name, x.expr(spec.Type))
+		return
+	}
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		x.emit(`type %s struct`, name)
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 {
+				if embedded := x.expr(field.Type); ast.IsExported(embedded[strings.LastIndexAny(embedded, `.*`)+1:]) {
+					x.emit(`type %s struct, embedded %s`, name, embedded)
+				}
+				continue
+			}
+			for _, n := range field.Names {
+				if n.IsExported() {
+					x.emit(`type %s struct, %s %s`, name, n.Name, x.expr(field.Type))
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		x.emit(`type %s interface { %s }`, name, strings.Join(x.interfaceMethods(t), `, `))
+		for _, field := range t.Methods.List {
+			if len(field.Names) == 0 {
+				x.emit(`type %s interface, embedded %s`, name, x.expr(field.Type))
+				continue
+			}
+			for _, n := range field.Names {
+				x.emit(`type %s interface, %s%s`, name, n.Name, x.signature(field.Type.(*ast.FuncType)))
+			}
+		}
+	default:
+		x.emit(`type %s %s`, name, x.expr(spec.Type))
+	}
+}
+
+// interfaceMethods returns the (sorted) names of the methods of the given interface, including unexported methods,
+// as they determine which types implement the interface.
+func (x *apiWriter) interfaceMethods(t *ast.InterfaceType) []string {
+	var names []string
+	for _, field := range t.Methods.List {
+		for _, n := range field.Names {
+			names = append(names, n.Name)
+		}
+		if len(field.Names) == 0 {
+			names = append(names, x.expr(field.Type))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// signature formats the given function type without parameter names, e.g. "(string, ...int) (bool, error)".
+func (x *apiWriter) signature(t *ast.FuncType) string {
+	s := `(` + strings.Join(x.fieldTypes(t.Params), `, `) + `)`
+	if t.Results == nil {
+		return s
+	}
+	results := x.fieldTypes(t.Results)
+	if len(results) == 1 {
+		return s + ` ` + results[0]
+	}
+	return s + ` (` + strings.Join(results, `, `) + `)`
+}
+
+func (x *apiWriter) fieldTypes(fields *ast.FieldList) []string {
+	var types []string
+	if fields == nil {
+		return nil
+	}
+	for _, field := range fields.List {
+		t := x.expr(field.Type)
+		if len(field.Names) == 0 {
+			types = append(types, t)
+		}
+		for range field.Names {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func (x *apiWriter) expr(expr ast.Expr) string {
+	if t, ok := expr.(*ast.FuncType); ok {
+		return `func` + x.signature(t)
+	}
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, x.fset, expr); err != nil {
+		panic(err)
+	}
+	// normalize multi-line types, e.g. inline structs
+	return strings.Join(strings.Fields(b.String()), ` `)
+}
+
+func apiLines(b []byte) map[string]bool {
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != `` {
+			lines[line] = true
+		}
+	}
+	return lines
+}
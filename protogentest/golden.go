@@ -0,0 +1,203 @@
+package protogentest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/jhump/gopoet"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// UpdateFlag is the name of the command line flag that, if true, causes AssertGolden to (re)write golden files,
+	// e.g. `go test ./... -update`. The flag is registered on init, unless a flag of the same name already exists.
+	UpdateFlag = `update`
+)
+
+func init() {
+	if flag.Lookup(UpdateFlag) == nil {
+		flag.Bool(UpdateFlag, false, `update golden files`)
+	}
+}
+
+// Render renders the given file, returning the gofmt-formatted source.
+func Render(file *gopoet.GoFile) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gopoet.WriteGoFile(&b, file); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// AssertGolden renders the given file, and compares it against the contents of the golden file, at the given path,
+// failing the test with a line diff if they differ. The golden file is gofmt-formatted prior to comparison.
+// If the update flag is set, the golden file will be written instead, see also UpdateFlag.
+func AssertGolden(t testing.TB, file *gopoet.GoFile, golden string) {
+	t.Helper()
+
+	actual, err := Render(file)
+	if err != nil {
+		if err, ok := err.(*gopoet.FormatError); ok {
+			t.Fatalf("failed to format %s: %v\n%s", file.Name, err.Err, err.Unformatted)
+		}
+		t.Fatalf("failed to render %s: %v", file.Name, err)
+	}
+
+	assertGolden(t, file.Name, actual, golden, true)
+}
+
+// AssertGoldenBytes is AssertGolden, for output other than golang files, e.g. that of OpenAPIGenerator.Document,
+// where the golden file is compared as-is.
+func AssertGoldenBytes(t testing.TB, actual []byte, golden string) {
+	t.Helper()
+	assertGolden(t, `output`, actual, golden, false)
+}
+
+func assertGolden(t testing.TB, name string, actual []byte, golden string, gofmt bool) {
+	t.Helper()
+
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -%s to create it): %v", UpdateFlag, err)
+	}
+	if gofmt {
+		if formatted, err := format.Source(expected); err == nil {
+			expected = formatted
+		}
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%s differs from golden file %s (run with -%s to update):\n%s", name, golden, UpdateFlag, Diff(string(expected), string(actual)))
+	}
+}
+
+// AssertStable renders the file returned by generate the given number of times (at least twice), failing the test
+// with a line diff if any render differs from the first, e.g. to guard against output that depends on golang map
+// iteration order. Each call to generate should construct the file from scratch, including any Cache.
+func AssertStable(t testing.TB, n int, generate func() *gopoet.GoFile) {
+	t.Helper()
+	assertStable(t, n, func() (string, []byte) {
+		file := generate()
+		actual, err := Render(file)
+		if err != nil {
+			t.Fatalf("failed to render %s: %v", file.Name, err)
+		}
+		return file.Name, actual
+	})
+}
+
+// AssertStableBytes is AssertStable, for output other than golang files, e.g. that of Cache.Export, or
+// Cache.ExportModel. Binary output is supported, though the diff is only readable for text.
+func AssertStableBytes(t testing.TB, n int, generate func() []byte) {
+	t.Helper()
+	assertStable(t, n, func() (string, []byte) {
+		return `output`, generate()
+	})
+}
+
+func assertStable(t testing.TB, n int, generate func() (string, []byte)) {
+	t.Helper()
+
+	if n < 2 {
+		n = 2
+	}
+	var expected []byte
+	for i := 0; i < n; i++ {
+		name, actual := generate()
+		if i == 0 {
+			expected = actual
+		} else if !bytes.Equal(expected, actual) {
+			t.Fatalf("%s differs between renders (1 and %d):\n%s", name, i+1, Diff(string(expected), string(actual)))
+		}
+	}
+}
+
+// Diff returns a line diff between expected and actual, with lines prefixed by "-" or "+" respectively, and a
+// single line of context (prefixed by a space) on either side of each change. Each line also includes its line
+// number, in expected for removed lines, and actual otherwise.
+func Diff(expected, actual string) string {
+	var (
+		a = strings.Split(expected, "\n")
+		b = strings.Split(actual, "\n")
+		// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+		lcs = make([][]int, len(a)+1)
+	)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op     byte
+		number int
+		text   string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', j + 1, b[j]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', i + 1, a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', j + 1, b[j]})
+			j++
+		}
+	}
+
+	var (
+		s    strings.Builder
+		last = -1
+	)
+	for k, v := range lines {
+		if v.op == ' ' &&
+			(k == 0 || lines[k-1].op == ' ') &&
+			(k == len(lines)-1 || lines[k+1].op == ' ') {
+			continue
+		}
+		if last != -1 && k != last+1 {
+			s.WriteString("...\n")
+		}
+		last = k
+		fmt.Fprintf(&s, "%c%d: %s\n", v.op, v.number, v.text)
+	}
+	return s.String()
+}
+
+func updateGolden() bool {
+	if f := flag.Lookup(UpdateFlag); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			v, _ := getter.Get().(bool)
+			return v
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+// Package protogentest implements utilities for testing code generators built using gopoet_protogen, by compiling
+// .proto sources in-memory, and constructing the protogen.Plugin and Cache, without invoking protoc.
+package protogentest
+
+import (
+	"fmt"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type (
+	// Options configures Compile.
+	Options struct {
+		// Parameter is the plugin parameter, as would be passed by protoc, e.g. "paths=source_relative".
+		Parameter string
+		// ImportPrefix is used to derive the go_package of any source files that don't specify one, and defaults to
+		// DefaultImportPrefix. The import path will be the prefix, joined with the directory of the file.
+		ImportPrefix string
+	}
+
+	// Result models the output of Compile.
+	Result struct {
+		// Plugin is constructed from the compiled sources, with every source file marked for generation.
+		Plugin *protogen.Plugin
+		// Cache is populated with every file in Plugin.
+		Cache *gopoet_protogen.Cache
+	}
+)
+
+const (
+	// DefaultImportPrefix is the default value for Options.ImportPrefix.
+	DefaultImportPrefix = `example.com/protogentest`
+)
+
+// Compile parses the given sources, a map of .proto file names to their contents, and returns the result, which
+// will include any imported well-known types (e.g. google/protobuf/timestamp.proto).
+func Compile(sources map[string]string, options *Options) (*Result, error) {
+	if options == nil {
+		options = new(Options)
+	}
+	importPrefix := options.ImportPrefix
+	if importPrefix == `` {
+		importPrefix = DefaultImportPrefix
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parser := protoparse.Parser{
+		Accessor:              protoparse.FileContentsFromMap(sources),
+		IncludeSourceCodeInfo: true,
+	}
+	files, err := parser.ParseFiles(names...)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := []string{options.Parameter}
+	for _, file := range files {
+		if file.GetFileOptions().GetGoPackage() == `` {
+			parameters = append(parameters, fmt.Sprintf(`M%s=%s`, file.GetName(), path.Join(importPrefix, path.Dir(file.GetName()))))
+		}
+	}
+
+	plugin, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: names,
+		Parameter:      proto.String(strings.Trim(strings.Join(parameters, `,`), `,`)),
+		ProtoFile:      desc.ToFileDescriptorSet(files...).GetFile(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := Result{Plugin: plugin, Cache: new(gopoet_protogen.Cache)}
+	for _, file := range plugin.Files {
+		result.Cache.AddFile(file)
+	}
+	return &result, nil
+}
+
+// MustCompile is Compile, but calls t.Fatal on error.
+func MustCompile(t testing.TB, sources map[string]string, options *Options) *Result {
+	t.Helper()
+	result, err := Compile(sources, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// File returns the file with the given name, or panics if it doesn't exist.
+func (x *Result) File(name string) *protogen.File {
+	if file := x.Plugin.FilesByPath[name]; file != nil {
+		return file
+	}
+	panic(fmt.Sprintf("unknown file: %s", name))
+}
+
+// Message returns the message with the given full name, or panics if it doesn't exist.
+func (x *Result) Message(fullName protoreflect.FullName) *protogen.Message {
+	for _, file := range x.Plugin.Files {
+		for _, v := range gopoet_protogen.FileMessages(file) {
+			if v.Desc.FullName() == fullName {
+				return v
+			}
+		}
+	}
+	panic(fmt.Sprintf("unknown message: %s", fullName))
+}
+
+// Fields returns the fields of the message with the given full name, see also Cache.MessageFields.
+func (x *Result) Fields(fullName protoreflect.FullName) []gopoet_protogen.Field {
+	return x.Cache.MessageFields(x.Message(fullName))
+}
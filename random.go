@@ -0,0 +1,289 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// RandomGenerator generates factory functions, for each message, that construct an instance with every field
+	// populated with plausible random values, using a *rand.Rand (math/rand), e.g. for table-driven tests.
+	//
+	// For a message named "Foo", the generated elements are "NewFooForTest", and "randomFoo", which also accepts the
+	// current depth. Fields are populated using the field model, see also Cache.MessageFields: strings are short,
+	// lowercase, words, numbers are small and non-negative, enums are set to a valid value, other than zero (if
+	// possible), repeated and map fields have between one and three values, and each oneof is set to one of its
+	// members. Fields of message types declared in the same file call the function generated for that type, and must
+	// therefore be generated into the same package, where recursive fields (see also RecursiveFields) are only set
+	// up to MaxDepth, unless they are required, such that required fields are always set, though a cycle of required
+	// fields can't be constructed. google.protobuf.Timestamp and google.protobuf.Duration values are set to recent
+	// times, and short durations, other message types are set to an empty message (which therefore must not have
+	// required fields), except for google.protobuf.Value, which is never set. Generated code accesses the struct
+	// fields directly, and therefore requires the open API level, see also Cache.APILevel.
+	RandomGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "New" followed by
+		// the golang name of the message, followed by "ForTest".
+		FuncName func(v *protogen.Message) string
+		// MaxDepth may be used to override the maximum depth of nested messages, beyond which (optional) recursive
+		// fields aren't set, which defaults to 2.
+		MaxDepth int
+		// Filter may be set to exclude fields, which are left unset, see also FieldFilter.
+		Filter *FieldFilter
+	}
+)
+
+var (
+	_ Generator = (*RandomGenerator)(nil)
+
+	randPkg = gopoet.NewPackage("math/rand")
+)
+
+// GenerateFile returns the factory functions for each message in the given file, see also FileMessages.
+func (x *RandomGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v), x.DepthFunc(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *RandomGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `New` + v.GoIdent.GoName + `ForTest`
+}
+
+// DepthName returns the name of the (unexported) function generated for the given message, which accepts the
+// current depth.
+func (x *RandomGenerator) DepthName(v *protogen.Message) string {
+	return `random` + v.GoIdent.GoName
+}
+
+// Func returns the factory function for the given message.
+func (x *RandomGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.Name(v)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a %s, with every field populated with random values, e.g. for tests.`, name, v.Desc.FullName())).
+		AddArg(`r`, gopoet.PointerType(gopoet.NamedType(randPkg.Symbol(`Rand`)))).
+		AddResult(``, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		Printlnf(`return %s(r, 0)`, x.DepthName(v))
+}
+
+// DepthFunc returns the function constructing the given message, at a given depth, see also Func.
+func (x *RandomGenerator) DepthFunc(v *protogen.Message) *gopoet.FuncSpec {
+	var (
+		name   = x.DepthName(v)
+		t      = x.Cache.MessageType(v.Desc)
+		words  bool
+		fields = gopoet.Printlnf(`v := new(%s)`, t)
+	)
+	for _, field := range x.Filter.Fields(x.Cache.MessageFields(v)) {
+		if field.Kind() == 0 {
+			x.oneOf(fields, &words, v, field)
+		} else {
+			x.field(fields, &words, v, field)
+		}
+	}
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns a %s, with every field populated with random values, at the given depth.`, name, v.Desc.FullName())).
+		AddArg(`r`, gopoet.PointerType(gopoet.NamedType(randPkg.Symbol(`Rand`)))).
+		AddArg(`depth`, gopoet.IntType).
+		AddResult(``, gopoet.PointerType(t))
+	if words {
+		f.Println(`word := func() string {`).
+			Println(`b := make([]byte, 1+r.Intn(8))`).
+			Println(`for i := range b {`).
+			Println(`b[i] = 'a' + byte(r.Intn(26))`).
+			Println(`}`).
+			Println(`return string(b)`).
+			Println(`}`)
+	}
+	return f.AddCode(fields).
+		Println(`return v`)
+}
+
+// oneOf populates a (merged) oneof field, with one of its members.
+func (x *RandomGenerator) oneOf(cb *gopoet.CodeBlock, words *bool, parent *protogen.Message, field Field) {
+	var members []OneOfField
+	for _, member := range field.OneOfFields() {
+		if desc := member.Field.Desc; desc.Message() == nil || x.supported(desc.Message()) {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		return
+	}
+	cb.Printlnf(`switch r.Intn(%d) {`, len(members))
+	for i, member := range members {
+		cb.Printlnf(`case %d:`, i)
+		guard := x.recursive(parent, member.Field.Message)
+		if guard {
+			cb.Printlnf(`if depth < %d {`, x.maxDepth())
+		}
+		cb.Printf(`v.%s = &%s{%s: `, field.Name(), member.Type, member.Field.GoName).
+			AddCode(x.value(words, parent, member.Field)).
+			Println(`}`)
+		if guard {
+			cb.Println(`}`)
+		}
+	}
+	cb.Println(`}`)
+}
+
+// field populates a non-oneof field.
+func (x *RandomGenerator) field(cb *gopoet.CodeBlock, words *bool, parent *protogen.Message, field Field) {
+	var (
+		f    = field.Fields()[0]
+		desc = f.Desc
+		expr = `v.` + field.Name()
+	)
+	switch {
+	case desc.IsMap():
+		key, value := f.Message.Fields[0], f.Message.Fields[1]
+		if value.Message != nil && !x.supported(value.Desc.Message()) {
+			return
+		}
+		guard := x.recursive(parent, value.Message)
+		if guard {
+			cb.Printlnf(`if depth < %d {`, x.maxDepth())
+		}
+		cb.Printlnf(`%s = make(%s)`, expr, field.Type()).
+			Println(`for i, n := 0, 1+r.Intn(3); i < n; i++ {`).
+			Printf(`%s[`, expr).
+			AddCode(x.value(words, parent, key)).
+			Print(`] = `).
+			AddCode(x.value(words, parent, value)).
+			Println(``).
+			Println(`}`)
+		if guard {
+			cb.Println(`}`)
+		}
+
+	case desc.Message() != nil && !x.supported(desc.Message()):
+
+	case desc.IsList():
+		guard := x.recursive(parent, f.Message)
+		if guard {
+			cb.Printlnf(`if depth < %d {`, x.maxDepth())
+		}
+		cb.Println(`for i, n := 0, 1+r.Intn(3); i < n; i++ {`).
+			Printf(`%s = append(%s, `, expr, expr).
+			AddCode(x.value(words, parent, f)).
+			Println(`)`).
+			Println(`}`)
+		if guard {
+			cb.Println(`}`)
+		}
+
+	case desc.Message() != nil && x.recursive(parent, f.Message) && !field.IsRequired():
+		cb.Printlnf(`if depth < %d {`, x.maxDepth()).
+			Printf(`%s = `, expr).
+			AddCode(x.value(words, parent, f)).
+			Println(``).
+			Println(`}`)
+
+	case field.IsPointer():
+		cb.Println(`{`).
+			Print(`e := `).
+			AddCode(x.value(words, parent, f)).
+			Println(``).
+			Printlnf(`%s = &e`, expr).
+			Println(`}`)
+
+	default:
+		cb.Printf(`%s = `, expr).
+			AddCode(x.value(words, parent, f)).
+			Println(``)
+	}
+}
+
+// value returns an expression evaluating to a single random value of the given field.
+func (x *RandomGenerator) value(words *bool, parent *protogen.Message, field *protogen.Field) *gopoet.CodeBlock {
+	desc := field.Desc
+	switch desc.Kind() {
+	case protoreflect.BoolKind:
+		return gopoet.Print(`r.Intn(2) == 0`)
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind:
+		return gopoet.Print(`r.Int31n(1000)`)
+	case protoreflect.Int64Kind,
+		protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind:
+		return gopoet.Print(`r.Int63n(1000)`)
+	case protoreflect.Uint32Kind,
+		protoreflect.Fixed32Kind:
+		return gopoet.Print(`uint32(r.Intn(1000))`)
+	case protoreflect.Uint64Kind,
+		protoreflect.Fixed64Kind:
+		return gopoet.Print(`uint64(r.Intn(1000))`)
+	case protoreflect.FloatKind:
+		return gopoet.Print(`r.Float32() * 100`)
+	case protoreflect.DoubleKind:
+		return gopoet.Print(`r.Float64() * 100`)
+	case protoreflect.StringKind:
+		*words = true
+		return gopoet.Print(`word()`)
+	case protoreflect.BytesKind:
+		*words = true
+		return gopoet.Print(`[]byte(word())`)
+	case protoreflect.EnumKind:
+		values := EnumValues(field.Enum)
+		if len(values) > 1 && values[0].Desc.Number() == 0 {
+			values = values[1:]
+		}
+		cb := gopoet.Printf(`[]%s{`, x.Cache.enumType(desc.Enum()))
+		for i, value := range values {
+			if i != 0 {
+				cb.Print(`, `)
+			}
+			cb.Printf(`%s`, x.Cache.EnumValueConst(value.Desc))
+		}
+		return cb.Printf(`}[r.Intn(%d)]`, len(values))
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		t := x.Cache.MessageType(desc.Message())
+		switch {
+		case x.sameFile(parent, field.Message):
+			return gopoet.Printf(`%s(r, depth+1)`, x.DepthName(field.Message))
+		case desc.Message().FullName() == `google.protobuf.Timestamp`:
+			// within about a year of 2020-09-13
+			return gopoet.Printf(`&%s{Seconds: 1600000000 + r.Int63n(31536000)}`, t)
+		case desc.Message().FullName() == `google.protobuf.Duration`:
+			return gopoet.Printf(`&%s{Seconds: r.Int63n(3600)}`, t)
+		}
+		return gopoet.Printf(`new(%s)`, t)
+	default:
+		panic(fmt.Sprintf("unknown type: %v", desc))
+	}
+}
+
+// supported returns false for message types that can't be populated, see also RandomGenerator.
+func (x *RandomGenerator) supported(desc protoreflect.MessageDescriptor) bool {
+	return desc.FullName() != `google.protobuf.Value`
+}
+
+// recursive returns true if the given message type (which may be nil), of a field of the parent, has a factory
+// function, which (transitively) constructs the parent, i.e. the field must be depth capped, see also MaxDepth.
+func (x *RandomGenerator) recursive(parent *protogen.Message, message *protogen.Message) bool {
+	return x.sameFile(parent, message) && recursiveField(parent, message)
+}
+
+// sameFile returns true if the given message type (which may be nil) is declared in the same file as the parent,
+// i.e. it has a factory function.
+func (x *RandomGenerator) sameFile(parent *protogen.Message, message *protogen.Message) bool {
+	return message != nil && message.Desc.ParentFile().Path() == parent.Desc.ParentFile().Path()
+}
+
+func (x *RandomGenerator) maxDepth() int {
+	if x.MaxDepth > 0 {
+		return x.MaxDepth
+	}
+	return 2
+}
@@ -0,0 +1,88 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// IsRecursive returns true if the given message is recursive, i.e. it (transitively) contains a field of its own
+// type, directly, or via other (mutually recursive) messages, through singular, repeated, or map (value) message
+// fields. Generators producing recursive functions, or inlining code for nested messages, should cap the depth of,
+// or generate iterative code for, such messages, see also RecursiveFields, and RecursionGroup.
+func IsRecursive(v *protogen.Message) bool {
+	return len(RecursiveFields(v)) != 0
+}
+
+// RecursiveFields returns the fields of the given message which (transitively) contain the message itself, i.e. the
+// fields that must be depth capped, in order to terminate, in declaration order, see also IsRecursive.
+func RecursiveFields(v *protogen.Message) []*protogen.Field {
+	var fields []*protogen.Field
+	for _, field := range v.Fields {
+		if target := fieldMessage(field); target != nil && messageReaches(target, v.Desc.FullName(), make(map[protoreflect.FullName]bool)) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// RecursionGroup returns the messages which are mutually recursive with the given message (i.e. its strongly
+// connected component), starting with the message itself, followed by the others, in depth-first order, by field
+// declaration, or nil if it isn't recursive, see also IsRecursive. Map entries are omitted.
+func RecursionGroup(v *protogen.Message) []*protogen.Message {
+	if !IsRecursive(v) {
+		return nil
+	}
+	var (
+		group = []*protogen.Message{v}
+		seen  = map[protoreflect.FullName]bool{v.Desc.FullName(): true}
+		visit func(m *protogen.Message)
+	)
+	visit = func(m *protogen.Message) {
+		for _, field := range m.Fields {
+			target := fieldMessage(field)
+			if target == nil || seen[target.Desc.FullName()] {
+				continue
+			}
+			seen[target.Desc.FullName()] = true
+			if messageReaches(target, v.Desc.FullName(), make(map[protoreflect.FullName]bool)) {
+				group = append(group, target)
+			}
+			visit(target)
+		}
+	}
+	visit(v)
+	return group
+}
+
+// recursiveField returns true if the given message type (which may be nil), of a field of the given parent,
+// (transitively) contains the parent, i.e. it is the type of one of RecursiveFields, which must be depth capped.
+func recursiveField(parent, message *protogen.Message) bool {
+	return message != nil && messageReaches(message, parent.Desc.FullName(), make(map[protoreflect.FullName]bool))
+}
+
+// messageReaches returns true if the given message is, or (transitively) contains a field of, the message with the
+// given full name, where visited tracks the messages already searched.
+func messageReaches(v *protogen.Message, name protoreflect.FullName, visited map[protoreflect.FullName]bool) bool {
+	if v.Desc.FullName() == name {
+		return true
+	}
+	if visited[v.Desc.FullName()] {
+		return false
+	}
+	visited[v.Desc.FullName()] = true
+	for _, field := range v.Fields {
+		if target := fieldMessage(field); target != nil && messageReaches(target, name, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldMessage returns the message type of the given field, or of the values of the given map field, or nil, if it
+// isn't a message.
+func fieldMessage(field *protogen.Field) *protogen.Message {
+	if field.Desc.IsMap() {
+		return field.Message.Fields[1].Message
+	}
+	return field.Message
+}
@@ -0,0 +1,229 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// RedactGenerator generates functions that redact sensitive fields of messages, in-place, either clearing them,
+	// or masking string values, recursing into (non-sensitive) message fields.
+	//
+	// Fields of message types declared in the same file as the generated message call the function generated for
+	// that type, and must therefore be generated into the same package, other message types are not recursed into.
+	RedactGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "Redact" followed
+		// by the golang name of the message.
+		FuncName func(v *protogen.Message) string
+		// Sensitive may be used to configure which fields are sensitive, and takes precedence over Option.
+		Sensitive func(field *protogen.Field) bool
+		// Option may be used to configure the field number of a custom bool field option (extension of
+		// google.protobuf.FieldOptions) marking fields as sensitive. If both Sensitive and Option are unset, fields
+		// with the debug_redact option are sensitive, see also IsDebugRedact.
+		Option protoreflect.FieldNumber
+		// Mask may be used to replace the values of sensitive string fields (including repeated and map values),
+		// instead of clearing them. Empty strings are not masked.
+		Mask string
+	}
+)
+
+var (
+	_ Generator = (*RedactGenerator)(nil)
+)
+
+// GenerateFile returns a redact function for each message in the given file, see also FileMessages.
+func (x *RedactGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *RedactGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `Redact` + v.GoIdent.GoName
+}
+
+// IsSensitive returns true if the given field is sensitive.
+func (x *RedactGenerator) IsSensitive(field *protogen.Field) bool {
+	if x.Sensitive != nil {
+		return x.Sensitive(field)
+	}
+	if x.Option != 0 {
+		v, _ := OptionVarint(field.Desc, x.Option)
+		return v != 0
+	}
+	return IsDebugRedact(field.Desc)
+}
+
+// Func returns the redact function for the given message, which accepts a (possibly nil) pointer to the message.
+func (x *RedactGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.Name(v)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s redacts the sensitive fields of a %s, in-place.`, name, v.Desc.FullName())).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc)))
+	var (
+		visiting = make(map[protoreflect.FullName]bool)
+		started  bool
+	)
+	for _, field := range x.Cache.MessageFields(v) {
+		if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+			var (
+				cases []OneOfField
+				bound bool
+			)
+			for _, field := range field.OneOfFields() {
+				if x.IsSensitive(field.Field) {
+					cases = append(cases, field)
+					bound = bound || x.mask(field.Field.Desc)
+				} else if x.recurse(v, field.Field, visiting) {
+					cases = append(cases, field)
+					bound = true
+				}
+			}
+			if len(cases) == 0 {
+				continue
+			}
+			if !started {
+				started = true
+				f.Println(`if v == nil {`).
+					Println(`return`).
+					Println(`}`)
+			}
+			if bound {
+				f.Printlnf(`switch x := v.%s.(type) {`, field.Name())
+			} else {
+				f.Printlnf(`switch v.%s.(type) {`, field.Name())
+			}
+			for _, oneOfField := range cases {
+				f.Printlnf(`case %s:`, gopoet.PointerType(oneOfField.Type))
+				switch {
+				case !x.IsSensitive(oneOfField.Field):
+					f.Printlnf(`%s(x.%s)`, x.Name(oneOfField.Field.Message), oneOfField.Field.GoName)
+				case x.mask(oneOfField.Field.Desc):
+					f.Printlnf(`if x.%s != "" {`, oneOfField.Field.GoName)
+					f.Printlnf(`x.%s = %q`, oneOfField.Field.GoName, x.Mask)
+					f.Println(`}`)
+				default:
+					f.Printlnf(`v.%s = nil`, field.Name())
+				}
+			}
+			f.Println(`}`)
+			continue
+		}
+		desc := field.Fields()[0]
+		if !x.IsSensitive(desc) && !x.recurse(v, desc, visiting) {
+			continue
+		}
+		if !started {
+			started = true
+			f.Println(`if v == nil {`).
+				Println(`return`).
+				Println(`}`)
+		}
+		x.redactField(f, desc)
+	}
+	return f
+}
+
+func (x *RedactGenerator) redactField(f *gopoet.FuncSpec, field *protogen.Field) {
+	var (
+		desc = field.Desc
+		expr = `v.` + field.GoName
+	)
+	switch {
+	case !x.IsSensitive(field):
+		switch {
+		case desc.IsMap():
+			f.Printlnf(`for _, e := range %s {`, expr)
+			f.Printlnf(`%s(e)`, x.Name(field.Message.Fields[1].Message))
+			f.Println(`}`)
+		case desc.IsList():
+			f.Printlnf(`for _, e := range %s {`, expr)
+			f.Printlnf(`%s(e)`, x.Name(field.Message))
+			f.Println(`}`)
+		default:
+			f.Printlnf(`%s(%s)`, x.Name(field.Message), expr)
+		}
+
+	case desc.IsMap() && x.mask(desc.MapValue()):
+		f.Printlnf(`for k, e := range %s {`, expr)
+		f.Println(`if e != "" {`)
+		f.Printlnf(`%s[k] = %q`, expr, x.Mask)
+		f.Println(`}`)
+		f.Println(`}`)
+
+	case desc.IsList() && x.mask(desc):
+		f.Printlnf(`for i, e := range %s {`, expr)
+		f.Println(`if e != "" {`)
+		f.Printlnf(`%s[i] = %q`, expr, x.Mask)
+		f.Println(`}`)
+		f.Println(`}`)
+
+	case desc.IsMap() || desc.IsList() || desc.Message() != nil || desc.Kind() == protoreflect.BytesKind:
+		f.Printlnf(`%s = nil`, expr)
+
+	case desc.HasPresence() && x.mask(desc):
+		f.Printlnf(`if %s != nil {`, expr)
+		f.Printlnf(`%s = %s(%q)`, expr, protoPkg.Symbol(`String`), x.Mask)
+		f.Println(`}`)
+
+	case desc.HasPresence():
+		f.Printlnf(`%s = nil`, expr)
+
+	case x.mask(desc):
+		f.Printlnf(`if %s != "" {`, expr)
+		f.Printlnf(`%s = %q`, expr, x.Mask)
+		f.Println(`}`)
+
+	default:
+		f.Printlnf(`%s = %s`, expr, zeroValue(desc.Kind()))
+	}
+}
+
+// mask returns true if the given (sensitive) field should be masked, rather than cleared, ignoring cardinality.
+func (x *RedactGenerator) mask(desc protoreflect.FieldDescriptor) bool {
+	return x.Mask != `` && desc.Kind() == protoreflect.StringKind
+}
+
+// recurse returns true if the given (non-sensitive) field has a message type, declared in the same file as the
+// parent, that (transitively) contains sensitive fields. The visiting map is used to handle recursive types.
+func (x *RedactGenerator) recurse(parent *protogen.Message, field *protogen.Field, visiting map[protoreflect.FullName]bool) bool {
+	message := field.Message
+	if message != nil && message.Desc.IsMapEntry() {
+		message = message.Fields[1].Message
+	}
+	if message == nil || message.Desc.ParentFile().Path() != parent.Desc.ParentFile().Path() || visiting[message.Desc.FullName()] {
+		return false
+	}
+	visiting[message.Desc.FullName()] = true
+	defer delete(visiting, message.Desc.FullName())
+	for _, field := range message.Fields {
+		if x.IsSensitive(field) || x.recurse(message, field, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// zeroValue returns the golang zero value for the given (non-pointer) scalar kind.
+func zeroValue(kind protoreflect.Kind) string {
+	switch kind {
+	case protoreflect.BoolKind:
+		return `false`
+	case protoreflect.StringKind:
+		return `""`
+	case protoreflect.BytesKind:
+		return `nil`
+	default:
+		return `0`
+	}
+}
@@ -0,0 +1,76 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+)
+
+type (
+	// FileRegistryGenerator generates a function that registers every file loaded into the cache into a
+	// protoregistry.Files, using the descriptor vars generated by protoc-gen-go, see also Cache.FileDescriptorVar,
+	// for plugins and servers that build isolated registries, rather than using protoregistry.GlobalFiles.
+	//
+	// Files are registered in the order they were loaded into the cache, which is dependency order, for the files of
+	// a protogen.Plugin, and files that are already registered (by path) are skipped.
+	FileRegistryGenerator struct {
+		// Cache provides the files to register, see also Cache.Files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "RegisterFiles".
+		FuncName string
+		// Registry may be set to the symbol of a *protoregistry.Files var, that the files will be registered into, by
+		// an init function, see also InitFunc.
+		Registry gopoet.Symbol
+	}
+)
+
+// Name returns the name of the generated function.
+func (x *FileRegistryGenerator) Name() string {
+	if x.FuncName != `` {
+		return x.FuncName
+	}
+	return `RegisterFiles`
+}
+
+// Func returns the registration function, which accepts a *protoregistry.Files, and returns the first error
+// encountered while registering the files, if any.
+func (x *FileRegistryGenerator) Func() *gopoet.FuncSpec {
+	name := x.Name()
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s registers all known files into the given registry, skipping any that are already registered.`, name)).
+		AddArg(`files`, gopoet.PointerType(gopoet.NamedType(protoregistryPkg.Symbol(`Files`)))).
+		AddResult(``, gopoet.ErrorType)
+	if len(x.Cache.Files()) == 0 {
+		return f.Println(`return nil`)
+	}
+	f.Printlnf(`for _, fd := range []%s{`, protoreflectPkg.Symbol(`FileDescriptor`))
+	for _, file := range x.Cache.Files() {
+		f.Printlnf(`%s,`, x.Cache.FileDescriptorVar(file.Desc.Path()))
+	}
+	return f.Println(`} {`).
+		Println(`if _, err := files.FindFileByPath(fd.Path()); err == nil {`).
+		Println(`continue`).
+		Println(`}`).
+		Println(`if err := files.RegisterFile(fd); err != nil {`).
+		Println(`return err`).
+		Println(`}`).
+		Println(`}`).
+		Println(`return nil`)
+}
+
+// InitFunc returns an init function, that calls the registration function, see also Func, with Registry, panicking
+// if registration fails. Registry must be set, otherwise it will panic. Since init functions run in file order, the
+// (idempotent) init function generated by protoc-gen-go is called first, for each file in the package of Registry.
+func (x *FileRegistryGenerator) InitFunc() *gopoet.FuncSpec {
+	if x.Registry.Name == `` {
+		panic(`registry not set`)
+	}
+	f := gopoet.NewFunc(`init`)
+	for _, file := range x.Cache.Files() {
+		if x.Cache.FileDescriptorVar(file.Desc.Path()).Package.ImportPath == x.Registry.Package.ImportPath {
+			f.Printlnf(`%s()`, fileSymbol(file, `_init`))
+		}
+	}
+	return f.Printlnf(`if err := %s(%s); err != nil {`, x.Name(), x.Registry).
+		Println(`panic(err)`).
+		Println(`}`)
+}
@@ -0,0 +1,270 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+type (
+	// Resource models a google.api.resource option (AIP-123), see also MessageResource.
+	Resource struct {
+		// Type is the resource type, e.g. "library.googleapis.com/Book".
+		Type string
+		// Patterns are the resource name patterns, in order, e.g. "publishers/{publisher}/books/{book}".
+		Patterns []ResourcePattern
+		// NameField is the string field containing the resource name, which defaults to the field named "name".
+		NameField *protogen.Field
+		// Plural is the plural form of the resource type, e.g. "books", if set.
+		Plural string
+		// Singular is the singular form of the resource type, e.g. "book", if set.
+		Singular string
+	}
+
+	// ResourcePattern models a resource name pattern (AIP-122), see also ParseResourcePattern.
+	ResourcePattern struct {
+		// Pattern is the resource name pattern, e.g. "publishers/{publisher}/books/{book}".
+		Pattern string
+		// Segments are the segments of the pattern, i.e. it split by "/".
+		Segments []ResourceSegment
+	}
+
+	// ResourceSegment models a segment of a resource name pattern, which is either a literal (collection
+	// identifier), or a variable (resource ID), e.g. "books", or "{book}".
+	ResourceSegment struct {
+		// Literal is the collection identifier, for literal segments.
+		Literal string
+		// Variable is the name of the variable, without braces, for variable segments.
+		Variable string
+	}
+
+	// ResourceNameGenerator generates resource name types for messages with a google.api.resource option (AIP-122),
+	// replacing hand-rolled parsing of resource names.
+	//
+	// For a message named "Book", with the pattern "publishers/{publisher}/books/{book}", a struct named "BookName"
+	// is generated, with a string field for each variable, e.g. Publisher and Book, a String method, formatting the
+	// name, a function named "ParseBookName", a function named "BookNameMatchesPattern", and a function named
+	// "BookNameOf", which parses the name field of the message. If the resource has multiple patterns, the name of
+	// each type is suffixed by an underscore, and the variables of the pattern, e.g. "BookName_PublisherBook".
+	// Variables match exactly one non-empty segment.
+	ResourceNameGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+	}
+)
+
+const (
+	// ResourceFieldNumber is the field number of the google.api.resource option, in google.protobuf.MessageOptions.
+	ResourceFieldNumber protoreflect.FieldNumber = 1053
+)
+
+var (
+	_ Generator = (*ResourceNameGenerator)(nil)
+)
+
+// MessageResource decodes the google.api.resource option of the given message, without depending on the generated
+// google.api package, returning nil if it isn't set. An error will be returned if the option is invalid, e.g. has a
+// name_field that isn't a string field of the message, or an unsupported pattern, see also ParseResourcePattern.
+func MessageResource(v *protogen.Message) (*Resource, error) {
+	values := OptionBytes(v.Desc, ResourceFieldNumber)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var (
+		resource  Resource
+		nameField = `name`
+	)
+	for _, b := range values {
+		for len(b) != 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid google.api.resource option for %s: %w", v.Desc.FullName(), protowire.ParseError(n))
+			}
+			b = b[n:]
+			if typ != protowire.BytesType {
+				if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+					return nil, fmt.Errorf("invalid google.api.resource option for %s: %w", v.Desc.FullName(), protowire.ParseError(n))
+				}
+				b = b[n:]
+				continue
+			}
+			value, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid google.api.resource option for %s: %w", v.Desc.FullName(), protowire.ParseError(n))
+			}
+			b = b[n:]
+			switch num {
+			case 1:
+				resource.Type = string(value)
+			case 2:
+				pattern, err := ParseResourcePattern(string(value))
+				if err != nil {
+					return nil, fmt.Errorf("invalid google.api.resource option for %s: %w", v.Desc.FullName(), err)
+				}
+				resource.Patterns = append(resource.Patterns, pattern)
+			case 3:
+				nameField = string(value)
+			case 5:
+				resource.Plural = string(value)
+			case 6:
+				resource.Singular = string(value)
+			}
+		}
+	}
+	for _, field := range v.Fields {
+		if string(field.Desc.Name()) == nameField && field.Desc.Kind() == protoreflect.StringKind && !field.Desc.IsList() {
+			resource.NameField = field
+		}
+	}
+	if resource.NameField == nil {
+		return nil, fmt.Errorf("invalid google.api.resource option for %s: unknown name field %q", v.Desc.FullName(), nameField)
+	}
+	return &resource, nil
+}
+
+// ParseResourcePattern parses the given resource name pattern, which must consist of literal, and variable,
+// segments, separated by "/", e.g. "publishers/{publisher}/books/{book}". Complex resource ID segments (AIP-4231),
+// e.g. "{a}~{b}", and variable patterns, e.g. "{book=**}", are not supported.
+func ParseResourcePattern(pattern string) (ResourcePattern, error) {
+	result := ResourcePattern{Pattern: pattern}
+	seen := make(map[string]struct{})
+	for _, segment := range strings.Split(pattern, `/`) {
+		switch {
+		case segment == ``:
+			return ResourcePattern{}, fmt.Errorf("invalid resource pattern %q: empty segment", pattern)
+		case strings.HasPrefix(segment, `{`) && strings.HasSuffix(segment, `}`):
+			variable := segment[1 : len(segment)-1]
+			if variable == `` || strings.ContainsAny(variable, `{}=*`) {
+				return ResourcePattern{}, fmt.Errorf("invalid resource pattern %q: unsupported segment %q", pattern, segment)
+			}
+			if _, ok := seen[variable]; ok {
+				return ResourcePattern{}, fmt.Errorf("invalid resource pattern %q: duplicate variable %q", pattern, variable)
+			}
+			seen[variable] = struct{}{}
+			result.Segments = append(result.Segments, ResourceSegment{Variable: variable})
+		case strings.ContainsAny(segment, `{}`):
+			return ResourcePattern{}, fmt.Errorf("invalid resource pattern %q: unsupported segment %q", pattern, segment)
+		default:
+			result.Segments = append(result.Segments, ResourceSegment{Literal: segment})
+		}
+	}
+	return result, nil
+}
+
+// Variables returns the names of the variables of the pattern, in order.
+func (x ResourcePattern) Variables() []string {
+	var variables []string
+	for _, segment := range x.Segments {
+		if segment.Variable != `` {
+			variables = append(variables, segment.Variable)
+		}
+	}
+	return variables
+}
+
+// GenerateFile returns the resource name types, and functions, for each message in the given file, with a
+// google.api.resource option, see also FileMessages. It will panic if the option is invalid.
+func (x *ResourceNameGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		resource, err := MessageResource(v)
+		if err != nil {
+			panic(err)
+		}
+		if resource == nil {
+			continue
+		}
+		for _, pattern := range resource.Patterns {
+			elements = append(elements, x.Type(v, resource, pattern)...)
+		}
+	}
+	return elements
+}
+
+// Name returns the name of the resource name type generated for the given pattern, of the given message.
+func (x *ResourceNameGenerator) Name(v *protogen.Message, resource *Resource, pattern ResourcePattern) string {
+	name := v.GoIdent.GoName + `Name`
+	if len(resource.Patterns) > 1 {
+		name += `_`
+		for _, variable := range pattern.Variables() {
+			name += resourceFieldName(variable)
+		}
+	}
+	return name
+}
+
+// Type returns the resource name type, and its functions, for the given pattern, of the given message.
+func (x *ResourceNameGenerator) Type(v *protogen.Message, resource *Resource, pattern ResourcePattern) []gopoet.FileElement {
+	var (
+		name     = x.Name(v, resource, pattern)
+		nameType = localType(name)
+		fields   []*gopoet.FieldSpec
+		format   []string
+		check    = []string{fmt.Sprintf(`len(parts) != %d`, len(pattern.Segments))}
+		values   []string
+	)
+	for i, segment := range pattern.Segments {
+		separator := `/`
+		if i == len(pattern.Segments)-1 {
+			separator = ``
+		}
+		if segment.Literal != `` {
+			format = append(format, fmt.Sprintf(`%q`, segment.Literal+separator))
+			check = append(check, fmt.Sprintf(`parts[%d] != %q`, i, segment.Literal))
+			continue
+		}
+		field := resourceFieldName(segment.Variable)
+		fields = append(fields, gopoet.NewField(field, gopoet.StringType))
+		format = append(format, `x.`+field)
+		if separator != `` {
+			format = append(format, `"/"`)
+		}
+		check = append(check, fmt.Sprintf(`parts[%d] == ""`, i))
+		values = append(values, fmt.Sprintf(`%s: parts[%d]`, field, i))
+	}
+	return []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name, fields...).
+			SetComment(fmt.Sprintf(`%s is a %s resource name, with the pattern %q.`, name, v.Desc.FullName(), pattern.Pattern))),
+		gopoet.NewMethod(gopoet.NewReceiverForType(`x`, nameType), `String`).
+			SetComment(`String formats the resource name.`).
+			AddResult(``, gopoet.StringType).
+			Printlnf(`return %s`, strings.ReplaceAll(strings.Join(format, ` + `), `/" + "`, `/`)),
+		gopoet.NewFunc(`Parse`+name).
+			SetComment(fmt.Sprintf(`Parse%s parses the given resource name, or returns an error if it doesn't match the pattern.`, name)).
+			AddArg(`s`, gopoet.StringType).
+			AddResult(``, nameType).
+			AddResult(``, gopoet.ErrorType).
+			Printlnf(`parts := %s(s, "/")`, stringsPkg.Symbol(`Split`)).
+			Printlnf(`if %s {`, strings.Join(check, ` || `)).
+			Printlnf(`return %s{}, %s("invalid %s name %%q, expected pattern %%q", s, %q)`, nameType, fmtPkg.Symbol(`Errorf`), v.Desc.FullName(), pattern.Pattern).
+			Println(`}`).
+			Printlnf(`return %s{%s}, nil`, nameType, strings.Join(values, `, `)),
+		gopoet.NewFunc(name+`MatchesPattern`).
+			SetComment(fmt.Sprintf(`%sMatchesPattern returns true if the given resource name matches the pattern %q.`, name, pattern.Pattern)).
+			AddArg(`s`, gopoet.StringType).
+			AddResult(``, gopoet.BoolType).
+			Printlnf(`_, err := Parse%s(s)`, name).
+			Println(`return err == nil`),
+		gopoet.NewFunc(name+`Of`).
+			SetComment(fmt.Sprintf(`%sOf parses the %s field of the given message, see also Parse%s.`, name, resource.NameField.Desc.Name(), name)).
+			AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+			AddResult(``, nameType).
+			AddResult(``, gopoet.ErrorType).
+			Printlnf(`return Parse%s(v.%s())`, name, x.Cache.PathFields(FieldPath{Fields: []*protogen.Field{resource.NameField}})[0].Getter().Name),
+	}
+}
+
+// resourceFieldName returns the golang name of the field for the given (snake case) variable, e.g. Publisher, for
+// publisher, and BookId, for book_id.
+func resourceFieldName(variable string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(variable, `_`) {
+		if part != `` {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,235 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// RouteTableGenerator generates a routing table of every gRPC method of the files loaded into the cache, keyed
+	// by full method name, with the request and response message types, and the streaming kind, of each, plus a
+	// codec-agnostic dispatch function, e.g. for reverse proxies, or API gateways, that route calls to backends
+	// without depending on generated server code. It isn't a Generator, as the table isn't specific to any one file,
+	// see also Elements.
+	//
+	// With the default Name, the generated declarations are a RouteKind type (with the constants RouteUnary,
+	// RouteClientStreaming, RouteServerStreaming, and RouteBidiStreaming), a Route struct, a Routes map, and a
+	// DispatchRoute function, which decodes the request, for unary and server streaming routes, using any codec,
+	// then calls a handler with the route, and the request.
+	RouteTableGenerator struct {
+		// Cache provides the services, see also Cache.Files, and is used to resolve message types.
+		Cache *Cache
+		// Name may be used to override the name of the generated struct type, which defaults to "Route", and
+		// prefixes the names of the other declarations.
+		Name string
+		// Include may be set to filter the services in the table, which defaults to all services.
+		Include func(v *protogen.Service) bool
+	}
+)
+
+var (
+	routeKindNames = [...]string{
+		`Unary`,
+		`ClientStreaming`,
+		`ServerStreaming`,
+		`BidiStreaming`,
+	}
+)
+
+// Elements returns all the declarations of the routing table, in order.
+func (x *RouteTableGenerator) Elements() []gopoet.FileElement {
+	return []gopoet.FileElement{
+		x.KindType(),
+		x.KindConsts(),
+		x.KindString(),
+		x.Type(),
+		x.Var(),
+		x.DispatchFunc(),
+	}
+}
+
+// TypeName returns the name of the generated struct type.
+func (x *RouteTableGenerator) TypeName() string {
+	if x.Name != `` {
+		return x.Name
+	}
+	return `Route`
+}
+
+// KindName returns the name of the generated streaming kind type, e.g. RouteKind.
+func (x *RouteTableGenerator) KindName() string {
+	return x.TypeName() + `Kind`
+}
+
+// VarName returns the name of the generated map, e.g. Routes.
+func (x *RouteTableGenerator) VarName() string {
+	return x.TypeName() + `s`
+}
+
+// DispatchName returns the name of the generated dispatch function, e.g. DispatchRoute.
+func (x *RouteTableGenerator) DispatchName() string {
+	return `Dispatch` + x.TypeName()
+}
+
+// KindConst returns the name of the streaming kind constant for the given method, e.g. RouteServerStreaming.
+func (x *RouteTableGenerator) KindConst(v *protogen.Method) string {
+	return x.TypeName() + routeKindNames[routeKind(v)]
+}
+
+// Methods returns the methods in the table, in the order of Cache.Files, then declaration order.
+func (x *RouteTableGenerator) Methods() []*protogen.Method {
+	var methods []*protogen.Method
+	for _, file := range x.Cache.Files() {
+		for _, service := range file.Services {
+			if x.Include == nil || x.Include(service) {
+				methods = append(methods, service.Methods...)
+			}
+		}
+	}
+	return methods
+}
+
+// KindType returns the streaming kind type.
+func (x *RouteTableGenerator) KindType() *gopoet.TypeDecl {
+	name := x.KindName()
+	return gopoet.NewTypeDecl(gopoet.NewTypeSpec(name, gopoet.IntType).
+		SetComment(fmt.Sprintf(`%s is the streaming kind of a %s.`, name, x.TypeName())))
+}
+
+// KindConsts returns the streaming kind constants.
+func (x *RouteTableGenerator) KindConsts() *gopoet.ConstDecl {
+	var (
+		decl     = gopoet.NewConstDecl()
+		comments = [...]string{
+			`is a unary method.`,
+			`is a client streaming method.`,
+			`is a server streaming method.`,
+			`is a bidirectional streaming method.`,
+		}
+	)
+	for i, kind := range routeKindNames {
+		name := x.TypeName() + kind
+		c := gopoet.NewConst(name).SetComment(name + ` ` + comments[i])
+		if i == 0 {
+			c.SetType(localType(x.KindName())).Initialize(`iota`)
+		}
+		decl.AddConst(c)
+	}
+	return decl
+}
+
+// KindString returns the String method of the streaming kind type.
+func (x *RouteTableGenerator) KindString() *gopoet.FuncSpec {
+	f := gopoet.NewMethod(gopoet.NewReceiverForType(`x`, localType(x.KindName())), `String`).
+		SetComment(`String returns the name of the streaming kind, e.g. "ServerStreaming".`).
+		AddResult(``, gopoet.StringType).
+		Println(`switch x {`)
+	for _, kind := range routeKindNames {
+		f.Printlnf(`case %s%s:`, x.TypeName(), kind).
+			Printlnf(`return %q`, kind)
+	}
+	return f.Println(`default:`).
+		Printlnf(`return %s("%s(%%d)", int(x))`, fmtPkg.Symbol(`Sprintf`), x.KindName()).
+		Println(`}`)
+}
+
+// Type returns the struct type modelling each method.
+func (x *RouteTableGenerator) Type() *gopoet.TypeDecl {
+	var (
+		name            = x.TypeName()
+		messageTypeFunc = gopoet.FuncType(nil, []gopoet.ArgType{{Type: gopoet.NamedType(protoreflectPkg.Symbol(`MessageType`))}})
+	)
+	return gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+		gopoet.NewField(`FullMethod`, gopoet.StringType).
+			SetComment(`FullMethod is the full name of the method, as used by gRPC, e.g. "/pkg.Service/Method".`),
+		gopoet.NewField(`Method`, gopoet.NamedType(protoreflectPkg.Symbol(`FullName`))).
+			SetComment(`Method is the full name of the method descriptor, e.g. "pkg.Service.Method".`),
+		gopoet.NewField(`Kind`, localType(x.KindName())).
+			SetComment(`Kind is the streaming kind of the method.`),
+		gopoet.NewField(`Request`, messageTypeFunc).
+			SetComment(`Request returns the type of the request message(s).`),
+		gopoet.NewField(`Response`, messageTypeFunc).
+			SetComment(`Response returns the type of the response message(s).`),
+	).SetComment(fmt.Sprintf(`%s models a gRPC method, for routing calls by full method name, see also %s.`, name, x.VarName())))
+}
+
+// Var returns the routing table, which maps the full method name of each method (see also Cache.MethodPath) to a
+// pointer to the struct type, see also Type. Message types are resolved by functions, as they may not be initialized
+// until after the table, e.g. if it is generated into the package declaring the messages.
+func (x *RouteTableGenerator) Var() *gopoet.VarDecl {
+	var (
+		name = x.VarName()
+		cb   = gopoet.Printlnf(`%s{`, gopoet.MapType(gopoet.StringType, gopoet.PointerType(localType(x.TypeName()))))
+	)
+	for _, method := range x.Methods() {
+		request, response := x.Cache.MethodTypes(method)
+		cb.Printlnf(`%q: {`, x.Cache.MethodPath(method)).
+			Printlnf(`FullMethod: %q,`, x.Cache.MethodPath(method)).
+			Printlnf(`Method: %q,`, method.Desc.FullName()).
+			Printlnf(`Kind: %s,`, x.KindConst(method)).
+			Print(`Request: `).AddCode(messageTypeFunc(request)).Println(`,`).
+			Print(`Response: `).AddCode(messageTypeFunc(response)).Println(`,`).
+			Println(`},`)
+	}
+	return gopoet.NewVarDecl(gopoet.NewVar(name).
+		SetComment(fmt.Sprintf(`%s maps the full method name of each known gRPC method to its %s.`, name, x.TypeName())).
+		SetInitializer(cb.Print(`}`)))
+}
+
+// DispatchFunc returns the dispatch function, which looks up the route for a full method name, decodes the
+// request using the given function, e.g. unmarshalling using any codec, unless the route is client streaming, in
+// which case the request is nil, then calls the given handler, returning its result.
+func (x *RouteTableGenerator) DispatchFunc() *gopoet.FuncSpec {
+	var (
+		name    = x.DispatchName()
+		route   = gopoet.PointerType(localType(x.TypeName()))
+		message = gopoet.NamedType(protoPkg.Symbol(`Message`))
+		ctx     = gopoet.NamedType(contextPkg.Symbol(`Context`))
+	)
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s routes a call to the given full method name, decoding the request, unless it is client streaming, `+
+			`in which case the handler receives a nil request, then calls the handler, returning an error if the method is unknown.`, name)).
+		AddArg(`ctx`, ctx).
+		AddArg(`fullMethod`, gopoet.StringType).
+		AddArg(`decode`, gopoet.FuncType(
+			[]gopoet.ArgType{{Type: message}},
+			[]gopoet.ArgType{{Type: gopoet.ErrorType}},
+		)).
+		AddArg(`handle`, gopoet.FuncType(
+			[]gopoet.ArgType{{Type: ctx}, {Type: route}, {Type: message}},
+			[]gopoet.ArgType{{Type: message}, {Type: gopoet.ErrorType}},
+		)).
+		AddResult(``, message).
+		AddResult(``, gopoet.ErrorType).
+		Printlnf(`route := %s[fullMethod]`, x.VarName()).
+		Println(`if route == nil {`).
+		Printlnf(`return nil, %s("unknown method: %%s", fullMethod)`, fmtPkg.Symbol(`Errorf`)).
+		Println(`}`).
+		Printlnf(`var req %s`, message).
+		Printlnf(`if route.Kind == %[1]sUnary || route.Kind == %[1]sServerStreaming {`, x.TypeName()).
+		Println(`req = route.Request().New().Interface()`).
+		Println(`if err := decode(req); err != nil {`).
+		Println(`return nil, err`).
+		Println(`}`).
+		Println(`}`).
+		Println(`return handle(ctx, route, req)`)
+}
+
+// routeKind returns the index of the streaming kind of the given method, see also routeKindNames.
+func routeKind(v *protogen.Method) int {
+	kind := 0
+	if v.Desc.IsStreamingClient() {
+		kind |= 1
+	}
+	if v.Desc.IsStreamingServer() {
+		kind |= 2
+	}
+	return kind
+}
+
+// messageTypeFunc returns a golang expression of a function returning the protoreflect.MessageType of the given
+// (generated) message type, which is only valid once the package declaring the message is initialized.
+func messageTypeFunc(t gopoet.TypeName) *gopoet.CodeBlock {
+	return gopoet.Printf(`func() %s { return (%s)(nil).ProtoReflect().Type() }`, protoreflectPkg.Symbol(`MessageType`), gopoet.PointerType(t))
+}
@@ -0,0 +1,86 @@
+package gopoet_protogen_test
+
+import (
+	"github.com/jhump/gopoet"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"testing"
+)
+
+func TestRouteTableGenerator_run(t *testing.T) {
+	result := compileLibrary(t)
+	file := result.File(libraryFile)
+	// generated into the package declaring the messages, which are initialized after package-level vars
+	dst := gopoet.NewGoFile(`gen_routing.go`, string(file.GoImportPath), string(file.GoPackageName))
+	for _, element := range (&gopoet_protogen.RouteTableGenerator{Cache: result.Cache}).Elements() {
+		dst.AddElement(element)
+	}
+	runGenerated(t, result, []*gopoet.GoFile{dst}, map[string]string{
+		`test/v1/gen_routing_test.go`: `package testv1
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRoutes(t *testing.T) {
+	for method, kind := range map[string]RouteKind{
+		"/test.v1.Library/GetBook":     RouteUnary,
+		"/test.v1.Library/ListBooks":   RouteUnary,
+		"/test.v1.Library/WatchBooks":  RouteServerStreaming,
+		"/test.v1.Library/UploadBooks": RouteClientStreaming,
+		"/test.v1.Library/SyncBooks":   RouteBidiStreaming,
+	} {
+		route := Routes[method]
+		if route == nil || route.FullMethod != method || route.Kind != kind {
+			t.Errorf("unexpected route for %s: %+v", method, route)
+		}
+	}
+	if len(Routes) != 5 {
+		t.Errorf("unexpected routes: %d", len(Routes))
+	}
+	if v := Routes["/test.v1.Library/SyncBooks"]; v.Method != "test.v1.Library.SyncBooks" || v.Kind.String() != "BidiStreaming" {
+		t.Error(v.Method, v.Kind)
+	}
+	if v := RouteKind(9).String(); v != "RouteKind(9)" {
+		t.Error(v)
+	}
+}
+
+func TestDispatchRoute(t *testing.T) {
+	b, err := proto.Marshal(&GetBookRequest{Name: "shelves/1/books/2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := DispatchRoute(context.Background(), "/test.v1.Library/GetBook", func(req proto.Message) error {
+		return proto.Unmarshal(b, req)
+	}, func(ctx context.Context, route *Route, req proto.Message) (proto.Message, error) {
+		res := route.Response().New().Interface().(*Book)
+		res.Name = req.(*GetBookRequest).GetName()
+		return res, nil
+	})
+	if err != nil || res.(*Book).GetName() != "shelves/1/books/2" {
+		t.Fatal(res, err)
+	}
+
+	_, err = DispatchRoute(context.Background(), "/test.v1.Library/UploadBooks", func(req proto.Message) error {
+		t.Error("unexpected decode")
+		return nil
+	}, func(ctx context.Context, route *Route, req proto.Message) (proto.Message, error) {
+		if req != nil || route.Request().Descriptor().FullName() != "test.v1.Book" {
+			t.Error(req, route.Request().Descriptor().FullName())
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DispatchRoute(context.Background(), "/test.v1.Library/Unknown", nil, nil); err == nil {
+		t.Error("expected error")
+	}
+}
+`,
+	})
+}
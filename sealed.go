@@ -0,0 +1,123 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// SealedOneofGenerator generates, for each (non-synthetic) oneof, an exported sealed interface, implemented by
+	// the wrapper types of its fields, via an unexported marker method, an exported alias for each wrapper type,
+	// and a function converting from the unexported interface generated by protoc-gen-go (e.g. the result of the
+	// getter of the oneof), providing a public API over oneofs, e.g. for functions accepting any case of a oneof.
+	//
+	// For a oneof named "Choice", of a message named "Msg", the generated elements are the interface "MsgChoice",
+	// with the marker method "isMsgChoice", the aliases "MsgChoice" followed by the golang name of each field, e.g.
+	// "MsgChoiceFoo", and the function "ToMsgChoice". The marker methods are declared on the wrapper types, and
+	// therefore must be generated into the same package as the messages.
+	SealedOneofGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// TypeName may be used to override the name of the generated interface, which defaults to the golang name
+		// of the message, followed by the golang name of the oneof, and is used as the prefix of the other names.
+		TypeName func(v *protogen.Oneof) string
+	}
+)
+
+var (
+	_ Generator = (*SealedOneofGenerator)(nil)
+)
+
+// GenerateFile returns the interface, marker methods, aliases, and conversion function, for each oneof of each
+// message in the given file, see also FileMessages.
+func (x *SealedOneofGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		for _, field := range x.Cache.MessageFields(v) {
+			if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+				elements = append(elements, x.Interface(field))
+				elements = append(elements, x.Markers(field)...)
+				elements = append(elements, x.Aliases(field), x.Func(field))
+			}
+		}
+	}
+	return elements
+}
+
+// Name returns the name of the interface generated for the given oneof.
+func (x *SealedOneofGenerator) Name(v *protogen.Oneof) string {
+	if x.TypeName != nil {
+		return x.TypeName(v)
+	}
+	return v.Parent.GoIdent.GoName + v.GoName
+}
+
+// MarkerName returns the name of the marker method of the interface generated for the given oneof.
+func (x *SealedOneofGenerator) MarkerName(v *protogen.Oneof) string {
+	return `is` + x.Name(v)
+}
+
+// AliasName returns the name of the alias generated for the wrapper type of the given oneof field.
+func (x *SealedOneofGenerator) AliasName(v *protogen.Field) string {
+	return x.Name(v.Oneof) + v.GoName
+}
+
+// FuncName returns the name of the conversion function generated for the given oneof.
+func (x *SealedOneofGenerator) FuncName(v *protogen.Oneof) string {
+	return `To` + x.Name(v)
+}
+
+// Interface returns the sealed interface for the given (oneof) field.
+func (x *SealedOneofGenerator) Interface(field Field) *gopoet.TypeDecl {
+	var (
+		oneOf = field.OneOf()
+		name  = x.Name(oneOf)
+	)
+	return gopoet.NewTypeDecl(gopoet.NewInterfaceTypeSpec(name, gopoet.NewInterfaceMethod(x.MarkerName(oneOf))).
+		SetComment(fmt.Sprintf(`%s is implemented by (pointers to) the wrapper types of the fields of the %s oneof, of %s, which may be used in a type switch, and is sealed, see also %s.`, name, oneOf.Desc.Name(), oneOf.Parent.Desc.FullName(), x.FuncName(oneOf))))
+}
+
+// Markers returns the marker methods, implementing the sealed interface, for each field of the given (oneof)
+// field.
+func (x *SealedOneofGenerator) Markers(field Field) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range field.OneOfFields() {
+		elements = append(elements, gopoet.NewMethod(gopoet.NewPointerReceiverForType(`x`, v.Type), x.MarkerName(field.OneOf())).
+			SetComment(fmt.Sprintf(`%s implements %s.`, x.MarkerName(field.OneOf()), x.Name(field.OneOf()))))
+	}
+	return elements
+}
+
+// Aliases returns the aliases of the wrapper types of each field of the given (oneof) field.
+func (x *SealedOneofGenerator) Aliases(field Field) *gopoet.TypeDecl {
+	var specs []*gopoet.TypeSpec
+	for _, v := range field.OneOfFields() {
+		name := x.AliasName(v.Field)
+		specs = append(specs, gopoet.NewTypeAlias(name, v.Type).
+			SetComment(fmt.Sprintf(`%s is the wrapper type of the %s field, which implements %s.`, name, v.Field.Desc.FullName(), x.Name(field.OneOf()))))
+	}
+	return gopoet.NewTypeDecl(specs...)
+}
+
+// Func returns the function converting the unexported interface of the given (oneof) field (i.e. Field.Type) to
+// the sealed interface, returning nil, if the value is nil.
+func (x *SealedOneofGenerator) Func(field Field) *gopoet.FuncSpec {
+	var (
+		oneOf = field.OneOf()
+		name  = x.FuncName(oneOf)
+		iface = localType(x.Name(oneOf))
+	)
+	var example string
+	if getter := field.Getter().Name; getter != `` {
+		example = `, e.g. the result of ` + getter
+	}
+	return gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s converts the value of the %s oneof, of %s%s, to %s, returning nil, if it isn't set.`, name, oneOf.Desc.Name(), oneOf.Parent.Desc.FullName(), example, iface)).
+		AddArg(`v`, field.Type()).
+		AddResult(``, iface).
+		Println(`if v == nil {`).
+		Println(`return nil`).
+		Println(`}`).
+		Printlnf(`return v.(%s)`, iface)
+}
@@ -0,0 +1,182 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// ServiceMethodsGenerator generates, for each service, a constant per method, containing the full method name
+	// (as used by gRPC, e.g. "/pkg.Service/Method"), and a map of full method names to the request and response
+	// message types, e.g. for implementing interceptors, without scattering string literals.
+	ServiceMethodsGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// ConstName may be used to override the name of the generated constant for each method, which defaults to
+		// the golang name of the service, then "_", the golang name of the method, then "_FullMethod".
+		ConstName func(v *protogen.Method) string
+		// VarName may be used to override the name of the generated map for each service, which defaults to the
+		// golang name of the service, followed by "_MethodTypes".
+		VarName func(v *protogen.Service) string
+		// FullNames may be set to also generate, for each service, constants of type protoreflect.FullName, for
+		// the service, and each method, named the golang name of the service, then (for methods) "_", and the
+		// golang name of the method, then "_FullName", e.g. for authorization policies keyed by full name.
+		FullNames bool
+	}
+)
+
+var (
+	_ Generator = (*ServiceMethodsGenerator)(nil)
+)
+
+// FullMethodName returns the full name of the given method, as used by gRPC, e.g. "/pkg.Service/Method".
+func FullMethodName(v *protogen.Method) string {
+	return fmt.Sprintf(`/%s/%s`, v.Parent.Desc.FullName(), v.Desc.Name())
+}
+
+// ServiceDescVar returns the symbol of the grpc.ServiceDesc var generated by protoc-gen-go-grpc for the given
+// service, e.g. Foo_ServiceDesc, which is in the same package as the messages of the file.
+func (x *Cache) ServiceDescVar(v *protogen.Service) gopoet.Symbol {
+	return x.servicePackage(v).Symbol(v.GoName + `_ServiceDesc`)
+}
+
+// FullMethodNameConst returns the symbol of the full method name constant generated by protoc-gen-go-grpc (v1.3 and
+// later) for the given method, e.g. Foo_Bar_FullMethodName, see also FullMethodName.
+func (x *Cache) FullMethodNameConst(v *protogen.Method) gopoet.Symbol {
+	return x.servicePackage(v.Parent).Symbol(v.Parent.GoName + `_` + v.GoName + `_FullMethodName`)
+}
+
+// ServerInterface returns the symbol of the server interface of the given service, see also RPCNamer.
+func (x *Cache) ServerInterface(v *protogen.Service) gopoet.Symbol {
+	return x.servicePackage(v).Symbol(x.rpcNamer().ServerInterface(v))
+}
+
+// ServerConstructor returns the symbol of the function registering, or wrapping, an implementation of the server
+// interface of the given service, see also RPCNamer.
+func (x *Cache) ServerConstructor(v *protogen.Service) gopoet.Symbol {
+	return x.servicePackage(v).Symbol(x.rpcNamer().ServerConstructor(v))
+}
+
+// ClientInterface returns the symbol of the client interface of the given service, see also RPCNamer.
+func (x *Cache) ClientInterface(v *protogen.Service) gopoet.Symbol {
+	return x.servicePackage(v).Symbol(x.rpcNamer().ClientInterface(v))
+}
+
+// ClientConstructor returns the symbol of the function constructing a client of the given service, see also
+// RPCNamer.
+func (x *Cache) ClientConstructor(v *protogen.Service) gopoet.Symbol {
+	return x.servicePackage(v).Symbol(x.rpcNamer().ClientConstructor(v))
+}
+
+// MethodPath returns the path the given method is served on, e.g. "/pkg.Service/Method", see also RPCNamer.
+func (x *Cache) MethodPath(v *protogen.Method) string {
+	return x.rpcNamer().MethodPath(v)
+}
+
+// servicePackage returns the golang package of the file declaring the given service, which must be loaded into the
+// cache, otherwise it will panic.
+func (x *Cache) servicePackage(v *protogen.Service) gopoet.Package {
+	file := x.file(v.Desc.ParentFile().Path())
+	if file == nil {
+		panic(fmt.Sprintf("unknown type: %v", v.Desc))
+	}
+	return x.goPackage(file.GoImportPath)
+}
+
+// MethodTypes returns the gopoet type names for the request and response messages of the given method.
+func (x *Cache) MethodTypes(v *protogen.Method) (request, response gopoet.TypeName) {
+	return x.MessageType(v.Input.Desc), x.MessageType(v.Output.Desc)
+}
+
+// GenerateFile returns the method constants and the method types map, and the full name constants, if FullNames is
+// set, for each service in the given file.
+func (x *ServiceMethodsGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range file.Services {
+		if len(v.Methods) == 0 {
+			continue
+		}
+		elements = append(elements, x.Consts(v), x.Var(v))
+		if x.FullNames {
+			elements = append(elements, x.FullNameConsts(v))
+		}
+	}
+	return elements
+}
+
+// ConstNameFor returns the name of the constant generated for the given method.
+func (x *ServiceMethodsGenerator) ConstNameFor(v *protogen.Method) string {
+	if x.ConstName != nil {
+		return x.ConstName(v)
+	}
+	return v.Parent.GoName + `_` + v.GoName + `_FullMethod`
+}
+
+// VarNameFor returns the name of the map generated for the given service.
+func (x *ServiceMethodsGenerator) VarNameFor(v *protogen.Service) string {
+	if x.VarName != nil {
+		return x.VarName(v)
+	}
+	return v.GoName + `_MethodTypes`
+}
+
+// Consts returns the method constants for the given service.
+func (x *ServiceMethodsGenerator) Consts(v *protogen.Service) *gopoet.ConstDecl {
+	decl := gopoet.NewConstDecl()
+	for _, method := range v.Methods {
+		name := x.ConstNameFor(method)
+		decl.AddConst(gopoet.NewConst(name).
+			SetComment(fmt.Sprintf(`%s is the full name of the %s method.`, name, method.Desc.FullName())).
+			Initialize(`%q`, FullMethodName(method)))
+	}
+	return decl
+}
+
+// FullNameConsts returns the full name constants, of type protoreflect.FullName, for the given service, and each of
+// its methods, see also FullNames.
+func (x *ServiceMethodsGenerator) FullNameConsts(v *protogen.Service) *gopoet.ConstDecl {
+	var (
+		decl     = gopoet.NewConstDecl()
+		fullName = gopoet.NamedType(protoreflectPkg.Symbol(`FullName`))
+		name     = v.GoName + `_FullName`
+	)
+	decl.AddConst(gopoet.NewConst(name).
+		SetType(fullName).
+		SetComment(fmt.Sprintf(`%s is the full name of the %s service.`, name, v.Desc.FullName())).
+		Initialize(`%q`, v.Desc.FullName()))
+	for _, method := range v.Methods {
+		name := v.GoName + `_` + method.GoName + `_FullName`
+		decl.AddConst(gopoet.NewConst(name).
+			SetType(fullName).
+			SetComment(fmt.Sprintf(`%s is the full name of the %s method.`, name, method.Desc.FullName())).
+			Initialize(`%q`, method.Desc.FullName()))
+	}
+	return decl
+}
+
+// Var returns the method types map for the given service, which is keyed by full method name, and has values of an
+// anonymous struct type, with Request and Response fields, of type func() protoreflect.MessageType. Message types are
+// resolved by functions, as they may not be initialized until after the map, e.g. if it is generated into the
+// package declaring the messages.
+func (x *ServiceMethodsGenerator) Var(v *protogen.Service) *gopoet.VarDecl {
+	var (
+		name      = x.VarNameFor(v)
+		typeFunc  = gopoet.FuncType(nil, []gopoet.ArgType{{Type: gopoet.NamedType(protoreflectPkg.Symbol(`MessageType`))}})
+		valueType = gopoet.StructType(
+			gopoet.FieldType{Name: `Request`, Type: typeFunc},
+			gopoet.FieldType{Name: `Response`, Type: typeFunc},
+		)
+		cb = gopoet.Printlnf(`%s{`, gopoet.MapType(gopoet.StringType, valueType))
+	)
+	for _, method := range v.Methods {
+		request, response := x.Cache.MethodTypes(method)
+		cb.Printlnf(`%s: {`, x.ConstNameFor(method))
+		cb.Print(`Request: `).AddCode(messageTypeFunc(request)).Println(`,`)
+		cb.Print(`Response: `).AddCode(messageTypeFunc(response)).Println(`,`)
+		cb.Println(`},`)
+	}
+	return gopoet.NewVarDecl(gopoet.NewVar(name).
+		SetComment(fmt.Sprintf(`%s maps the full name of each %s method to functions returning the request and response message types.`, name, v.Desc.FullName())).
+		SetInitializer(cb.Print(`}`)))
+}
@@ -0,0 +1,43 @@
+package gopoet_protogen_test
+
+import (
+	"github.com/jhump/gopoet"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"testing"
+)
+
+func TestServiceMethodsGenerator_run(t *testing.T) {
+	result := compileLibrary(t)
+	// generated into the package declaring the messages, which are initialized after package-level vars
+	dst := generateFile(result.File(libraryFile), `gen_service.go`, &gopoet_protogen.ServiceMethodsGenerator{
+		Cache:     result.Cache,
+		FullNames: true,
+	})
+	runGenerated(t, result, []*gopoet.GoFile{dst}, map[string]string{
+		`test/v1/gen_service_test.go`: `package testv1
+
+import (
+	"testing"
+)
+
+func TestLibrary_MethodTypes(t *testing.T) {
+	if Library_GetBook_FullMethod != "/test.v1.Library/GetBook" || Library_GetBook_FullName != "test.v1.Library.GetBook" {
+		t.Error(Library_GetBook_FullMethod, Library_GetBook_FullName)
+	}
+	if len(Library_MethodTypes) != 5 {
+		t.Errorf("unexpected methods: %d", len(Library_MethodTypes))
+	}
+	types := Library_MethodTypes[Library_GetBook_FullMethod]
+	if v := types.Request().Descriptor().FullName(); v != "test.v1.GetBookRequest" {
+		t.Error(v)
+	}
+	if _, ok := types.Response().New().Interface().(*Book); !ok {
+		t.Error(types.Response().Descriptor().FullName())
+	}
+	if v := Library_MethodTypes[Library_UploadBooks_FullMethod].Response().Descriptor().FullName(); v != "test.v1.ListBooksResponse" {
+		t.Error(v)
+	}
+}
+`,
+	})
+}
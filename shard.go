@@ -0,0 +1,61 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// FileSharder distributes the elements generated for an input file across multiple output files, e.g.
+	// "foo_1.pb.x.go" and "foo_2.pb.x.go", to keep large outputs manageable. Elements are assigned in order, such
+	// that each output file receives a contiguous run of at most MaxElements elements, and the assignment is
+	// deterministic for a given input. All output files belong to the same package, so elements may reference each
+	// other freely.
+	FileSharder struct {
+		// NewFile returns a new output file, for the given input file, with the given suffix, e.g.
+		// GoFileFactory.NewGoFile, which also provides each output file with the same header.
+		NewFile func(file *protogen.File, suffix string) (*gopoet.GoFile, error)
+		// Suffix is the suffix of the output files (e.g. ".pb.x.go"), which is prefixed by an underscore and the
+		// (one-based) number of each file, if there are multiple files.
+		Suffix string
+		// MaxElements is the maximum number of elements in each output file, and if it is not positive, all elements
+		// will be generated into a single file.
+		MaxElements int
+	}
+)
+
+// Shard returns the output files for the given input file, populated by each of the given generators, see also
+// Generate. If there are no elements, a single empty file will be returned.
+func (x *FileSharder) Shard(file *protogen.File, generators ...Generator) ([]*gopoet.GoFile, error) {
+	var elements []gopoet.FileElement
+	for _, generator := range generators {
+		elements = append(elements, generator.GenerateFile(file)...)
+	}
+	if x.MaxElements <= 0 || len(elements) <= x.MaxElements {
+		goFile, err := x.NewFile(file, x.Suffix)
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range elements {
+			goFile.AddElement(element)
+		}
+		return []*gopoet.GoFile{goFile}, nil
+	}
+	var goFiles []*gopoet.GoFile
+	for i := 0; i < len(elements); i += x.MaxElements {
+		goFile, err := x.NewFile(file, fmt.Sprintf(`_%d%s`, len(goFiles)+1, x.Suffix))
+		if err != nil {
+			return nil, err
+		}
+		end := i + x.MaxElements
+		if end > len(elements) {
+			end = len(elements)
+		}
+		for _, element := range elements[i:end] {
+			goFile.AddElement(element)
+		}
+		goFiles = append(goFiles, goFile)
+	}
+	return goFiles, nil
+}
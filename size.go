@@ -0,0 +1,215 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// SizeGenerator generates functions that approximate the size of a message, in bytes, once encoded in the
+	// protobuf wire format, without the overhead of proto.Marshal or proto.Size.
+	//
+	// Fields of message types declared in the same file as the generated message call the function generated for
+	// that type, and must therefore be generated into the same package, other message types fall back to proto.Size.
+	SizeGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "SizeBytes"
+		// followed by the golang name of the message.
+		FuncName func(v *protogen.Message) string
+	}
+)
+
+var (
+	_ Generator = (*SizeGenerator)(nil)
+)
+
+// GenerateFile returns a size function for each message in the given file, see also FileMessages.
+func (x *SizeGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *SizeGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `SizeBytes` + v.GoIdent.GoName
+}
+
+// Func returns the size function for the given message, which accepts a (possibly nil) pointer to the message, and
+// returns the approximate encoded size as an int.
+func (x *SizeGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.Name(v)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the approximate size of a %s, in bytes, once encoded in the protobuf wire format.`, name, v.Desc.FullName())).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(`n`, gopoet.IntType).
+		Println(`if v == nil {`).
+		Println(`return`).
+		Println(`}`)
+	for _, field := range x.Cache.MessageFields(v) {
+		if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+			f.Printlnf(`switch x := v.%s.(type) {`, field.Name())
+			for _, field := range field.OneOfFields() {
+				format, args := x.sizeValue(v, field.Field, `x.`+field.Field.GoName)
+				f.Printlnf(`case %s:`, gopoet.PointerType(field.Type))
+				f.Printlnf(`n += %d + `+format, append([]interface{}{protowire.SizeTag(field.Field.Desc.Number())}, args...)...)
+			}
+			f.Println(`}`)
+			continue
+		}
+		x.sizeField(f, v, field.Fields()[0])
+	}
+	return f.Println(`n += len(v.ProtoReflect().GetUnknown())`).
+		Println(`return`)
+}
+
+func (x *SizeGenerator) sizeField(f *gopoet.FuncSpec, parent *protogen.Message, field *protogen.Field) {
+	var (
+		desc = field.Desc
+		expr = `v.` + field.GoName
+		tag  = protowire.SizeTag(desc.Number())
+	)
+	switch {
+	case desc.IsMap():
+		keyFormat, keyArgs := x.sizeValue(parent, field.Message.Fields[0], `k`)
+		valueFormat, valueArgs := x.sizeValue(parent, field.Message.Fields[1], `e`)
+		f.Printlnf(`for %s := range %s {`, rangeVars(fixedSize(desc.MapKey().Kind()) == 0, fixedSize(desc.MapValue().Kind()) == 0), expr)
+		f.Printlnf(`n += %d + %s(1 + `+keyFormat+` + 1 + `+valueFormat+`)`, append(append([]interface{}{tag, protowirePkg.Symbol(`SizeBytes`)}, keyArgs...), valueArgs...)...)
+		f.Println(`}`)
+
+	case desc.IsList() && desc.IsPacked():
+		f.Printlnf(`if len(%s) != 0 {`, expr)
+		if size := fixedSize(desc.Kind()); size != 0 {
+			f.Printlnf(`n += %d + %s(len(%s)*%d)`, tag, protowirePkg.Symbol(`SizeBytes`), expr, size)
+		} else {
+			format, args := x.sizeValue(parent, field, `e`)
+			f.Println(`var l int`)
+			f.Printlnf(`for _, e := range %s {`, expr)
+			f.Printlnf(`l += `+format, args...)
+			f.Println(`}`)
+			f.Printlnf(`n += %d + %s(l)`, tag, protowirePkg.Symbol(`SizeBytes`))
+		}
+		f.Println(`}`)
+
+	case desc.IsList():
+		if size := fixedSize(desc.Kind()); size != 0 {
+			f.Printlnf(`n += len(%s) * %d`, expr, tag+size)
+		} else {
+			format, args := x.sizeValue(parent, field, `e`)
+			f.Printlnf(`for _, e := range %s {`, expr)
+			f.Printlnf(`n += %d + `+format, append([]interface{}{tag}, args...)...)
+			f.Println(`}`)
+		}
+
+	case desc.Message() != nil || desc.Kind() == protoreflect.BytesKind && desc.HasPresence():
+		format, args := x.sizeValue(parent, field, expr)
+		f.Printlnf(`if %s != nil {`, expr)
+		f.Printlnf(`n += %d + `+format, append([]interface{}{tag}, args...)...)
+		f.Println(`}`)
+
+	case desc.HasPresence():
+		format, args := x.sizeValue(parent, field, `*`+expr)
+		f.Printlnf(`if %s != nil {`, expr)
+		f.Printlnf(`n += %d + `+format, append([]interface{}{tag}, args...)...)
+		f.Println(`}`)
+
+	default:
+		format, args := x.sizeValue(parent, field, expr)
+		f.Printlnf(`if %s {`, zeroCheck(desc.Kind(), expr))
+		f.Printlnf(`n += %d + `+format, append([]interface{}{tag}, args...)...)
+		f.Println(`}`)
+	}
+}
+
+// sizeValue returns a format string and args for an expression evaluating to the size of a single value (excluding
+// the tag, except for the end tag of groups), where expr is the golang expression for the value.
+func (x *SizeGenerator) sizeValue(parent *protogen.Message, field *protogen.Field, expr string) (string, []interface{}) {
+	desc := field.Desc
+	if size := fixedSize(desc.Kind()); size != 0 {
+		return fmt.Sprint(size), nil
+	}
+	switch desc.Kind() {
+	case protoreflect.EnumKind,
+		protoreflect.Int32Kind,
+		protoreflect.Int64Kind,
+		protoreflect.Uint32Kind,
+		protoreflect.Uint64Kind:
+		return `%s(uint64(` + expr + `))`, []interface{}{protowirePkg.Symbol(`SizeVarint`)}
+	case protoreflect.Sint32Kind,
+		protoreflect.Sint64Kind:
+		return `%s(%s(int64(` + expr + `)))`, []interface{}{protowirePkg.Symbol(`SizeVarint`), protowirePkg.Symbol(`EncodeZigZag`)}
+	case protoreflect.StringKind,
+		protoreflect.BytesKind:
+		return `%s(len(` + expr + `))`, []interface{}{protowirePkg.Symbol(`SizeBytes`)}
+	case protoreflect.MessageKind:
+		format, args := x.sizeMessage(parent, field.Message, expr)
+		return `%s(` + format + `)`, append([]interface{}{protowirePkg.Symbol(`SizeBytes`)}, args...)
+	case protoreflect.GroupKind:
+		format, args := x.sizeMessage(parent, field.Message, expr)
+		return format + fmt.Sprintf(` + %d`, protowire.SizeTag(desc.Number())), args
+	default:
+		panic(fmt.Sprintf("unknown type: %v", desc))
+	}
+}
+
+func (x *SizeGenerator) sizeMessage(parent *protogen.Message, message *protogen.Message, expr string) (string, []interface{}) {
+	if message.Desc.ParentFile().Path() == parent.Desc.ParentFile().Path() {
+		return x.Name(message) + `(` + expr + `)`, nil
+	}
+	return `%s(` + expr + `)`, []interface{}{protoPkg.Symbol(`Size`)}
+}
+
+// fixedSize returns the encoded size of the given kind, or 0 if it is variable.
+func fixedSize(kind protoreflect.Kind) int {
+	switch kind {
+	case protoreflect.BoolKind:
+		return 1
+	case protoreflect.Fixed32Kind,
+		protoreflect.Sfixed32Kind,
+		protoreflect.FloatKind:
+		return 4
+	case protoreflect.Fixed64Kind,
+		protoreflect.Sfixed64Kind,
+		protoreflect.DoubleKind:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// rangeVars returns the variables for a range statement over a map, where the key is k and the value is e.
+func rangeVars(key, value bool) string {
+	switch {
+	case key && value:
+		return `k, e`
+	case key:
+		return `k`
+	case value:
+		return `_, e`
+	default:
+		return `_`
+	}
+}
+
+// zeroCheck returns a golang expression that is true if the given scalar value is not the zero value.
+func zeroCheck(kind protoreflect.Kind, expr string) string {
+	switch kind {
+	case protoreflect.BoolKind:
+		return expr
+	case protoreflect.StringKind:
+		return expr + ` != ""`
+	case protoreflect.BytesKind:
+		return `len(` + expr + `) != 0`
+	default:
+		return expr + ` != 0`
+	}
+}
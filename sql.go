@@ -0,0 +1,134 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// SQLGenerator generates functions mapping messages to and from SQL rows, for each message, a function listing
+	// the column names, a function scanning a row into the message, and a function returning the column values,
+	// e.g. for use with database/sql or pgx.
+	//
+	// Only singular scalar and enum fields (excluding oneof fields, but including optional fields, which are
+	// scanned as nullable) are mapped to columns, and messages without any columns are skipped.
+	SQLGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Column may be used to override the column name of each field, and takes precedence over Option, where an
+		// empty string excludes the field.
+		Column func(field *protogen.Field) string
+		// Option may be used to configure the field number of a custom string field option (extension of
+		// google.protobuf.FieldOptions) specifying the column name of each field. If both Column and Option are
+		// unset, or the option is not set for a field, the column name defaults to the column of the storage
+		// metadata of the field (see also Cache.FieldStorage), which may also exclude the field, if set, else the
+		// proto field name.
+		Option protoreflect.FieldNumber
+	}
+)
+
+var (
+	_ Generator = (*SQLGenerator)(nil)
+
+	emptyInterfaceType = gopoet.InterfaceType(nil)
+)
+
+// GenerateFile returns the columns, scan, and values functions, for each message in the given file, that has any
+// columns, see also FileMessages.
+func (x *SQLGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		if len(x.Columns(v)) != 0 {
+			elements = append(elements, x.ColumnsFunc(v), x.ScanFunc(v), x.ValuesFunc(v))
+		}
+	}
+	return elements
+}
+
+// ColumnName returns the column name of the given field, or an empty string if it is not mapped to a column.
+func (x *SQLGenerator) ColumnName(field *protogen.Field) string {
+	desc := field.Desc
+	if desc.IsList() || desc.IsMap() || desc.Message() != nil ||
+		desc.ContainingOneof() != nil && !desc.ContainingOneof().IsSynthetic() {
+		return ``
+	}
+	if x.Column != nil {
+		return x.Column(field)
+	}
+	if x.Option != 0 {
+		if values := OptionBytes(desc, x.Option); len(values) != 0 {
+			return string(values[len(values)-1])
+		}
+	}
+	if storage := x.Cache.FieldStorage(desc); storage != nil {
+		if storage.Ignore {
+			return ``
+		}
+		if storage.Column != `` {
+			return storage.Column
+		}
+	}
+	return string(desc.Name())
+}
+
+// Columns returns the fields of the given message that are mapped to columns, in declaration order.
+func (x *SQLGenerator) Columns(v *protogen.Message) []*protogen.Field {
+	var fields []*protogen.Field
+	for _, field := range v.Fields {
+		if x.ColumnName(field) != `` {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ColumnsFunc returns a function that returns the column names for the given message, in the same order as the
+// scan and values functions.
+func (x *SQLGenerator) ColumnsFunc(v *protogen.Message) *gopoet.FuncSpec {
+	name := v.GoIdent.GoName + `Columns`
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the column names for a %s.`, name, v.Desc.FullName())).
+		AddResult(``, gopoet.SliceType(gopoet.StringType)).
+		Println(`return []string{`)
+	for _, field := range x.Columns(v) {
+		f.Printlnf(`%q,`, x.ColumnName(field))
+	}
+	return f.Println(`}`)
+}
+
+// ScanFunc returns a function that scans a row into the given message, where the row may be any value with a Scan
+// method like sql.Row.Scan, and the columns are in the order returned by the columns function.
+func (x *SQLGenerator) ScanFunc(v *protogen.Message) *gopoet.FuncSpec {
+	name := `Scan` + v.GoIdent.GoName
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s scans a row into a %s, see also %sColumns.`, name, v.Desc.FullName(), v.GoIdent.GoName)).
+		AddArg(`dest`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddArg(`row`, gopoet.InterfaceType(nil, gopoet.MethodType{Name: `Scan`, Signature: gopoet.Signature{
+			Args:       []gopoet.ArgType{{Name: `dest`, Type: gopoet.SliceType(emptyInterfaceType)}},
+			Results:    []gopoet.ArgType{{Type: gopoet.ErrorType}},
+			IsVariadic: true,
+		}})).
+		AddResult(``, gopoet.ErrorType).
+		Println(`return row.Scan(`)
+	for _, field := range x.Columns(v) {
+		f.Printlnf(`&dest.%s,`, field.GoName)
+	}
+	return f.Println(`)`)
+}
+
+// ValuesFunc returns a function that returns the column values of the given message, in the order returned by the
+// columns function, where optional fields are nil if unset.
+func (x *SQLGenerator) ValuesFunc(v *protogen.Message) *gopoet.FuncSpec {
+	name := v.GoIdent.GoName + `Values`
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns the column values for a %s, see also %sColumns.`, name, v.Desc.FullName(), v.GoIdent.GoName)).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, gopoet.SliceType(emptyInterfaceType)).
+		Println(`return []interface{}{`)
+	for _, field := range x.Columns(v) {
+		f.Printlnf(`v.%s,`, field.GoName)
+	}
+	return f.Println(`}`)
+}
@@ -0,0 +1,94 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+	"unicode"
+)
+
+type (
+	// SQLEnumGenerator renders SQL DDL statements for enums, e.g. for teams that keep database enums in sync with
+	// proto enums, see also SQLGenerator, which maps enum fields to columns by number.
+	//
+	// By default, each enum is rendered as a domain over integer, constrained by a CHECK constraint to the known
+	// numbers, e.g. `CREATE DOMAIN test_v1_level AS INTEGER CHECK (VALUE IN (0, 1, 2));`, or, if Names is set, as
+	// an enum type of the value names, e.g. `CREATE TYPE test_v1_level AS ENUM ('LEVEL_ZERO', ...);`, both of which
+	// are PostgreSQL syntax. The CHECK constraints for other databases may be rendered using Check.
+	SQLEnumGenerator struct {
+		// TypeName may be used to override the SQL type name of each enum, which defaults to the snake case full name
+		// of the enum, with dots replaced by underscores, e.g. test_v1_level, for test.v1.Level.
+		TypeName func(v *protogen.Enum) string
+		// Names may be set to store enums by value name, rather than number.
+		Names bool
+		// Aliases determines which value names are included, for enums with aliases, if Names is set, which
+		// defaults to the first declared value of each number. Numbers are always distinct.
+		Aliases EnumAliasMode
+	}
+)
+
+// TypeNameFor returns the SQL type name of the given enum.
+func (x *SQLEnumGenerator) TypeNameFor(v *protogen.Enum) string {
+	if x.TypeName != nil {
+		return x.TypeName(v)
+	}
+	parts := strings.Split(string(v.Desc.FullName()), `.`)
+	for i, part := range parts {
+		parts[i] = snakeCase(part)
+	}
+	return strings.Join(parts, `_`)
+}
+
+// DDL renders the statements creating the SQL type for each enum declared in the given files, see also FileEnums.
+func (x *SQLEnumGenerator) DDL(files ...*protogen.File) string {
+	var b strings.Builder
+	for _, file := range files {
+		for _, v := range FileEnums(file) {
+			fmt.Fprintf(&b, "-- %s\n", v.Desc.FullName())
+			if x.Names {
+				fmt.Fprintf(&b, "CREATE TYPE %s AS ENUM (%s);\n\n", x.TypeNameFor(v), strings.Join(x.values(v), `, `))
+			} else {
+				fmt.Fprintf(&b, "CREATE DOMAIN %s AS INTEGER CHECK (VALUE IN (%s));\n\n", x.TypeNameFor(v), strings.Join(x.values(v), `, `))
+			}
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Check renders a CHECK constraint, restricting the given column to the values of the given enum, e.g.
+// `CHECK (level IN (0, 1, 2))`, for use in a column or table definition.
+func (x *SQLEnumGenerator) Check(v *protogen.Enum, column string) string {
+	return fmt.Sprintf(`CHECK (%s IN (%s))`, column, strings.Join(x.values(v), `, `))
+}
+
+// values returns the SQL literals for the values of the given enum, see also EnumAliasMode.Values.
+func (x *SQLEnumGenerator) values(v *protogen.Enum) []string {
+	var values []string
+	if x.Names {
+		for _, value := range x.Aliases.Values(v) {
+			values = append(values, `'`+strings.ReplaceAll(string(value.Desc.Name()), `'`, `''`)+`'`)
+		}
+	} else {
+		for _, value := range EnumValues(v) {
+			values = append(values, fmt.Sprint(value.Desc.Number()))
+		}
+	}
+	return values
+}
+
+// snakeCase converts the given CamelCase name to lower snake case, e.g. FooBar to foo_bar, and HTTPCode to
+// http_code.
+func snakeCase(s string) string {
+	var (
+		b     strings.Builder
+		runes = []rune(s)
+	)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i != 0 && runes[i-1] != '_' &&
+			(!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package gopoet_protogen_test
+
+import (
+	"bytes"
+	"github.com/jhump/gopoet"
+	"github.com/joeycumines/gopoet-protogen/protogentest"
+	"testing"
+)
+
+const (
+	// stableRuns is the number of times output is generated, each from scratch, by the stability tests, which
+	// should be enough for output that depends on golang map iteration order to differ.
+	stableRuns = 10
+)
+
+func TestGenerators_stable(t *testing.T) {
+	for _, tc := range generatorTests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			protogentest.AssertStable(t, stableRuns, func() *gopoet.GoFile {
+				result := protogentest.MustCompile(t, tc.sources, nil)
+				return tc.generate(result.Cache, result.File(tc.file))
+			})
+		})
+	}
+}
+
+func TestDocuments_stable(t *testing.T) {
+	for _, tc := range documentTests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			protogentest.AssertStableBytes(t, stableRuns, func() []byte {
+				return tc.generate(t, protogentest.MustCompile(t, tc.sources, nil))
+			})
+		})
+	}
+}
+
+func TestCache_Export_stable(t *testing.T) {
+	sources := httpSources()
+	protogentest.AssertStableBytes(t, stableRuns, func() []byte {
+		var b bytes.Buffer
+		if err := protogentest.MustCompile(t, sources, nil).Cache.Export(&b); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	})
+}
+
+func TestCache_ExportModel_stable(t *testing.T) {
+	sources := httpSources()
+	protogentest.AssertStableBytes(t, stableRuns, func() []byte {
+		var b bytes.Buffer
+		if err := protogentest.MustCompile(t, sources, nil).Cache.ExportModel(&b); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	})
+}
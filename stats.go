@@ -0,0 +1,374 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+)
+
+type (
+	// MessageStats models the complexity of a message, e.g. such that generators may choose between strategies, like
+	// inlining the code for nested messages, or generating helper functions, see also AnalyzeMessage, and
+	// ModelMessage.Stats. Counts are of the fields of the message itself, while the Has fields, Depth, and MaxSize,
+	// consider nested messages, transitively, via message, repeated, and map fields.
+	MessageStats struct {
+		// Fields is the number of fields, including the members of oneofs.
+		Fields int `json:"fields"`
+		// Messages is the number of fields (including repeated fields) of message types, excluding maps.
+		Messages int `json:"messages,omitempty"`
+		// Repeated is the number of repeated fields, excluding maps.
+		Repeated int `json:"repeated,omitempty"`
+		// Maps is the number of map fields.
+		Maps int `json:"maps,omitempty"`
+		// Oneofs is the number of oneofs, excluding the synthetic oneofs of proto3 optional fields.
+		Oneofs int `json:"oneofs,omitempty"`
+		// Depth is the maximum nesting depth of messages, where a message without message fields (or map fields with
+		// message values) has a depth of 1. Recursive fields aren't followed, see also RecursiveFields.
+		Depth int `json:"depth"`
+		// Recursive is true if the message, or any nested message, is recursive, see also IsRecursive.
+		Recursive bool `json:"recursive,omitempty"`
+		// HasMaps is true if the message, or any nested message, has map fields.
+		HasMaps bool `json:"hasMaps,omitempty"`
+		// HasOneofs is true if the message, or any nested message, has (non-synthetic) oneofs.
+		HasOneofs bool `json:"hasOneofs,omitempty"`
+		// HasAny is true if the message, or any nested message, has google.protobuf.Any fields, see also IsAny.
+		HasAny bool `json:"hasAny,omitempty"`
+		// MaxSize is the estimated maximum encoded size of the message, in bytes, excluding unknown fields, if it is
+		// bounded, see also MessageMaxSize.
+		MaxSize *uint64 `json:"maxSize,omitempty"`
+	}
+
+	// messageAnalyzer computes MessageStats, memoizing the results of every message it visits, such that each
+	// message is only visited once, regardless of the shape of the schema, see also AnalyzeMessage.
+	messageAnalyzer struct {
+		// results are the transitive statistics of each visited message, which are nil while it is being visited.
+		results map[protoreflect.FullName]*messageResult
+		// index is the order each message was first visited, see also visit.
+		index map[protoreflect.FullName]int
+		// stack is the messages visited, which aren't yet assigned to a component.
+		stack []*protogen.Message
+		// sizes memoizes messageMaxSize.
+		sizes map[protoreflect.FullName]messageSize
+	}
+
+	// messageResult models the transitive statistics of a message, see also MessageStats.
+	messageResult struct {
+		depth     int
+		recursive bool
+		hasMaps   bool
+		hasOneofs bool
+		hasAny    bool
+	}
+
+	// messageSize is a memoized result of messageMaxSize.
+	messageSize struct {
+		size uint64
+		ok   bool
+	}
+)
+
+const (
+	// utf8MaxBytes is the maximum number of bytes of a single UTF-8 encoded character.
+	utf8MaxBytes = 4
+)
+
+// AnalyzeMessage returns the statistics of the given message, see also MessageStats.
+func AnalyzeMessage(v *protogen.Message) MessageStats {
+	return newMessageAnalyzer().analyze(v)
+}
+
+// newMessageAnalyzer initializes a new messageAnalyzer.
+func newMessageAnalyzer() *messageAnalyzer {
+	return &messageAnalyzer{
+		results: make(map[protoreflect.FullName]*messageResult),
+		index:   make(map[protoreflect.FullName]int),
+		sizes:   make(map[protoreflect.FullName]messageSize),
+	}
+}
+
+// analyze returns the statistics of the given message, see also AnalyzeMessage.
+func (x *messageAnalyzer) analyze(v *protogen.Message) MessageStats {
+	stats := MessageStats{Fields: len(v.Fields)}
+	for _, oneof := range v.Oneofs {
+		if !oneof.Desc.IsSynthetic() {
+			stats.Oneofs++
+		}
+	}
+	for _, field := range v.Fields {
+		switch {
+		case field.Desc.IsMap():
+			stats.Maps++
+		case field.Desc.IsList():
+			stats.Repeated++
+		}
+		if field.Message != nil && !field.Desc.IsMap() {
+			stats.Messages++
+		}
+	}
+	if _, ok := x.index[v.Desc.FullName()]; !ok {
+		x.visit(v)
+	}
+	result := x.results[v.Desc.FullName()]
+	stats.Depth = result.depth
+	stats.Recursive = result.recursive
+	stats.HasMaps = result.hasMaps
+	stats.HasOneofs = result.hasOneofs
+	stats.HasAny = result.hasAny
+	if size, ok := messageMaxSize(v.Desc, x.sizes); ok {
+		stats.MaxSize = &size
+	}
+	return stats
+}
+
+// visit is Tarjan's strongly connected components algorithm, over the message fields (see also fieldMessage),
+// which computes the results of each component (i.e. set of mutually recursive messages) once all the messages it
+// references are complete, returning the lowest index reachable from the given message.
+func (x *messageAnalyzer) visit(v *protogen.Message) int {
+	index := len(x.index)
+	x.index[v.Desc.FullName()] = index
+	x.stack = append(x.stack, v)
+	low := index
+	for _, field := range v.Fields {
+		target := fieldMessage(field)
+		if target == nil {
+			continue
+		}
+		if i, ok := x.index[target.Desc.FullName()]; !ok {
+			if i := x.visit(target); i < low {
+				low = i
+			}
+		} else if x.results[target.Desc.FullName()] == nil && i < low {
+			// on the stack, i.e. part of the current component
+			low = i
+		}
+	}
+	if low == index {
+		i := len(x.stack) - 1
+		for x.stack[i] != v {
+			i--
+		}
+		x.component(x.stack[i:])
+		x.stack = x.stack[:i]
+	}
+	return low
+}
+
+// component sets the results of the given mutually recursive messages (or a single message), which share all but
+// the depth, as every message within the component is reachable from every other.
+func (x *messageAnalyzer) component(messages []*protogen.Message) {
+	var (
+		shared  = messageResult{recursive: len(messages) > 1}
+		members = make(map[protoreflect.FullName]bool, len(messages))
+		depths  = make([]int, len(messages))
+	)
+	for _, v := range messages {
+		members[v.Desc.FullName()] = true
+	}
+	for i, v := range messages {
+		depths[i] = 1
+		for _, field := range v.Fields {
+			if field.Desc.IsMap() {
+				shared.hasMaps = true
+			}
+			if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+				shared.hasOneofs = true
+			}
+			target := fieldMessage(field)
+			if target == nil {
+				continue
+			}
+			if target.Desc.FullName() == anyFullName {
+				shared.hasAny = true
+			}
+			if members[target.Desc.FullName()] {
+				// a recursive field, which isn't followed
+				shared.recursive = true
+				continue
+			}
+			result := x.results[target.Desc.FullName()]
+			shared.recursive = shared.recursive || result.recursive
+			shared.hasMaps = shared.hasMaps || result.hasMaps
+			shared.hasOneofs = shared.hasOneofs || result.hasOneofs
+			shared.hasAny = shared.hasAny || result.hasAny
+			if d := 1 + result.depth; d > depths[i] {
+				depths[i] = d
+			}
+		}
+	}
+	for i, v := range messages {
+		result := shared
+		result.depth = depths[i]
+		x.results[v.Desc.FullName()] = &result
+	}
+}
+
+// MessageMaxSize returns the estimated maximum encoded size of the given message, in bytes, and true, if it is
+// bounded, i.e. it isn't recursive, and every repeated, and map, field has a maximum number of items, and every
+// string, and bytes, field (including keys, and values) has a maximum length, constrained by the buf.validate.field
+// option, see also FieldValidateConstraints. Strings constrained by a maximum number of characters are assumed to
+// be 4 bytes per character, all varints are assumed to be of maximum length, and unknown fields are ignored, such
+// that the estimate is an upper bound. Note that google.protobuf.Any is unbounded, unless constrained.
+func MessageMaxSize(desc protoreflect.MessageDescriptor) (uint64, bool) {
+	return messageMaxSize(desc, make(map[protoreflect.FullName]messageSize))
+}
+
+// messageMaxSize implements MessageMaxSize, memoizing the result for each message in sizes, where messages that are
+// being visited are unbounded, as reaching them again means they are recursive.
+func messageMaxSize(desc protoreflect.MessageDescriptor, sizes map[protoreflect.FullName]messageSize) (uint64, bool) {
+	if v, ok := sizes[desc.FullName()]; ok {
+		return v.size, v.ok
+	}
+	sizes[desc.FullName()] = messageSize{}
+	size, ok := fieldsMaxSize(desc, sizes)
+	sizes[desc.FullName()] = messageSize{size: size, ok: ok}
+	return size, ok
+}
+
+// fieldsMaxSize returns the maximum encoded size of the fields of the given message, see also messageMaxSize.
+func fieldsMaxSize(desc protoreflect.MessageDescriptor, sizes map[protoreflect.FullName]messageSize) (uint64, bool) {
+	var (
+		size   uint64
+		oneofs = make(map[protoreflect.FullName]uint64)
+	)
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		n, ok := fieldMaxSize(field, FieldValidateConstraints(field), sizes)
+		if !ok {
+			return 0, false
+		}
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if n > oneofs[oneof.FullName()] {
+				oneofs[oneof.FullName()] = n
+			}
+			continue
+		}
+		if size, ok = addSize(size, n); !ok {
+			return 0, false
+		}
+	}
+	for _, n := range oneofs {
+		var ok bool
+		if size, ok = addSize(size, n); !ok {
+			return 0, false
+		}
+	}
+	return size, true
+}
+
+// fieldMaxSize returns the maximum encoded size of the given field, including tags, with the given constraints,
+// which may be nil, see also MessageMaxSize.
+func fieldMaxSize(desc protoreflect.FieldDescriptor, constraints *FieldConstraints, sizes map[protoreflect.FullName]messageSize) (uint64, bool) {
+	tag := uint64(protowire.SizeTag(desc.Number()))
+	if !desc.IsList() && !desc.IsMap() {
+		n, ok := valueMaxSize(desc, constraints, sizes)
+		if !ok {
+			return 0, false
+		}
+		return addSize(tag, n)
+	}
+	if constraints == nil || constraints.MaxItems == nil {
+		return 0, false
+	}
+	items := *constraints.MaxItems
+	if desc.IsMap() {
+		key, ok := fieldMaxSize(desc.MapKey(), constraints.Keys, sizes)
+		if !ok {
+			return 0, false
+		}
+		value, ok := fieldMaxSize(desc.MapValue(), constraints.Values, sizes)
+		if !ok {
+			return 0, false
+		}
+		entry, ok := addSize(key, value)
+		if !ok {
+			return 0, false
+		}
+		if entry, ok = addSize(tag, bytesSize(entry)); !ok {
+			return 0, false
+		}
+		return mulSize(items, entry)
+	}
+	value, ok := valueMaxSize(desc, constraints.Items, sizes)
+	if !ok {
+		return 0, false
+	}
+	if desc.IsPacked() {
+		n, ok := mulSize(items, value)
+		if !ok || items == 0 {
+			return 0, ok
+		}
+		return addSize(tag, bytesSize(n))
+	}
+	if value, ok = addSize(tag, value); !ok {
+		return 0, false
+	}
+	return mulSize(items, value)
+}
+
+// valueMaxSize returns the maximum encoded size of a single value of the given field, excluding the tag, where
+// constraints are those of the value, e.g. of the items of a repeated field.
+func valueMaxSize(desc protoreflect.FieldDescriptor, constraints *FieldConstraints, sizes map[protoreflect.FullName]messageSize) (uint64, bool) {
+	switch kind := desc.Kind(); kind {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		switch {
+		case constraints == nil:
+			return 0, false
+		case constraints.MaxBytes != nil:
+			return bytesSize(*constraints.MaxBytes), true
+		case constraints.MaxLen != nil && kind == protoreflect.BytesKind:
+			return bytesSize(*constraints.MaxLen), true
+		case constraints.MaxLen != nil:
+			n, ok := mulSize(*constraints.MaxLen, utf8MaxBytes)
+			if !ok {
+				return 0, false
+			}
+			return bytesSize(n), true
+		default:
+			return 0, false
+		}
+	case protoreflect.MessageKind:
+		n, ok := messageMaxSize(desc.Message(), sizes)
+		if !ok {
+			return 0, false
+		}
+		return bytesSize(n), true
+	case protoreflect.GroupKind:
+		n, ok := messageMaxSize(desc.Message(), sizes)
+		if !ok {
+			return 0, false
+		}
+		// the end group tag
+		return addSize(n, uint64(protowire.SizeTag(desc.Number())))
+	case protoreflect.Uint32Kind, protoreflect.Sint32Kind:
+		return uint64(protowire.SizeVarint(math.MaxUint32)), true
+	default:
+		if n := fixedSize(kind); n != 0 {
+			return uint64(n), true
+		}
+		// negative int32, and enum, values are encoded as 64-bit varints
+		return uint64(protowire.SizeVarint(math.MaxUint64)), true
+	}
+}
+
+// bytesSize returns the size of a length-delimited value of the given length, i.e. including the length prefix.
+func bytesSize(n uint64) uint64 {
+	return uint64(protowire.SizeVarint(n)) + n
+}
+
+// addSize returns a + b, and false, if it overflows.
+func addSize(a, b uint64) (uint64, bool) {
+	if a > math.MaxUint64-b {
+		return 0, false
+	}
+	return a + b, true
+}
+
+// mulSize returns a * b, and false, if it overflows.
+func mulSize(a, b uint64) (uint64, bool) {
+	if a != 0 && b > math.MaxUint64/a {
+		return 0, false
+	}
+	return a * b, true
+}
@@ -0,0 +1,53 @@
+package gopoet_protogen_test
+
+import (
+	"fmt"
+	gopoet_protogen "github.com/joeycumines/gopoet-protogen"
+	"github.com/joeycumines/gopoet-protogen/protogentest"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeMessage(t *testing.T) {
+	result := compileLibrary(t)
+	for _, tc := range [...]struct {
+		name     protoreflect.FullName
+		expected gopoet_protogen.MessageStats
+	}{
+		{`test.v1.Book`, gopoet_protogen.MessageStats{Fields: 12, Messages: 2, Repeated: 1, Maps: 1, Oneofs: 1, Depth: 2, Recursive: true, HasMaps: true, HasOneofs: true}},
+		{`test.v1.Nested`, gopoet_protogen.MessageStats{Fields: 2, Messages: 1, Depth: 1, Recursive: true, HasMaps: true, HasOneofs: true}},
+		{`test.v1.Deep`, gopoet_protogen.MessageStats{Fields: 2, Messages: 2, Repeated: 1, Depth: 1, Recursive: true, HasMaps: true, HasOneofs: true}},
+		{`test.v1.ListBooksResponse`, gopoet_protogen.MessageStats{Fields: 2, Messages: 1, Repeated: 1, Depth: 3, Recursive: true, HasMaps: true, HasOneofs: true}},
+		{`test.v1.GetBookRequest`, gopoet_protogen.MessageStats{Fields: 1, Depth: 1}},
+	} {
+		t.Run(string(tc.name), func(t *testing.T) {
+			if stats := gopoet_protogen.AnalyzeMessage(result.Message(tc.name)); fmt.Sprintf(`%+v`, stats) != fmt.Sprintf(`%+v`, tc.expected) {
+				t.Errorf("unexpected stats:\n%+v\n%+v", stats, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAnalyzeMessage_diamond(t *testing.T) {
+	// every message references the next twice, i.e. there are 2^levels paths to the last message
+	const levels = 64
+	var b strings.Builder
+	b.WriteString("syntax = \"proto3\";\n\npackage test.v1;\n\noption go_package = \"example.com/protogentest/test/v1;testv1\";\n")
+	for i := 0; i < levels; i++ {
+		fmt.Fprintf(&b, "\nmessage M%d {\n  M%[2]d a = 1;\n  repeated M%[2]d b = 2;\n  map<string, M%[2]d> c = 3;\n}\n", i, i+1)
+	}
+	fmt.Fprintf(&b, "\nmessage M%d {\n  int32 value = 1;\n}\n", levels)
+	result := protogentest.MustCompile(t, map[string]string{`test/v1/diamond.proto`: b.String()}, nil)
+
+	stats := gopoet_protogen.AnalyzeMessage(result.Message(`test.v1.M0`))
+	if stats.Depth != levels+1 || stats.Recursive || !stats.HasMaps || stats.MaxSize != nil {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if size, ok := gopoet_protogen.MessageMaxSize(result.Message(protoreflect.FullName(fmt.Sprintf(`test.v1.M%d`, levels))).Desc); !ok || size != 11 {
+		t.Error(size, ok)
+	}
+	if model := result.Cache.Model(result.File(`test/v1/diamond.proto`)); len(model.Messages) != levels+1 || model.Messages[1].Stats.Depth != levels {
+		t.Errorf("unexpected model: %+v", model.Messages[1])
+	}
+}
@@ -0,0 +1,105 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// FieldStorage models the storage metadata of a field, e.g. the column name, and type, shared by storage-layer
+	// generators (e.g. SQLGenerator), see also Field.Storage, and Cache.FieldStorage.
+	//
+	// It is typically decoded from a user-defined extension of google.protobuf.FieldOptions (see also
+	// Cache.StorageOption, and DecodeFieldStorage), of a message type (with any name) declaring any of the following
+	// fields, where the field numbers, and wire types, must match, e.g.
+	//
+	//	message Storage {
+	//	  string column = 1;
+	//	  string type = 2;
+	//	  bool primary_key = 3;
+	//	  bool unique = 4;
+	//	  bool index = 5;
+	//	  optional bool nullable = 6;
+	//	  string default = 7;
+	//	  bool ignore = 8;
+	//	}
+	//
+	//	extend google.protobuf.FieldOptions {
+	//	  Storage storage = 50000;
+	//	}
+	FieldStorage struct {
+		// Column is the name of the column, or an empty string, to use the default, e.g. the field name.
+		Column string
+		// Type is the (database-specific) type of the column, e.g. "VARCHAR(255)", or an empty string, to use the
+		// default.
+		Type string
+		// PrimaryKey is true if the column is (part of) the primary key.
+		PrimaryKey bool
+		// Unique is true if the column has a unique constraint.
+		Unique bool
+		// Index is true if the column is indexed.
+		Index bool
+		// Nullable is whether the column is nullable, if set, otherwise it defaults to whether the field has
+		// explicit presence.
+		Nullable *bool
+		// Default is the (database-specific) default expression of the column, if any.
+		Default string
+		// Ignore is true if the field isn't stored.
+		Ignore bool
+	}
+)
+
+// DecodeFieldStorage decodes the option (extension of google.protobuf.FieldOptions) with the given field number,
+// of the given field, see also FieldStorage, or returns nil, if it isn't set. Multiple values are merged.
+func DecodeFieldStorage(desc protoreflect.FieldDescriptor, number protoreflect.FieldNumber) *FieldStorage {
+	values := OptionBytes(desc, number)
+	if len(values) == 0 {
+		return nil
+	}
+	var storage FieldStorage
+	for _, b := range values {
+		rangeWireFields(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) {
+			switch typ {
+			case protowire.BytesType:
+				switch num {
+				case 1:
+					storage.Column = string(b)
+				case 2:
+					storage.Type = string(b)
+				case 7:
+					storage.Default = string(b)
+				}
+			case protowire.VarintType:
+				switch num {
+				case 3:
+					storage.PrimaryKey = v != 0
+				case 4:
+					storage.Unique = v != 0
+				case 5:
+					storage.Index = v != 0
+				case 6:
+					nullable := v != 0
+					storage.Nullable = &nullable
+				case 8:
+					storage.Ignore = v != 0
+				}
+			}
+		})
+	}
+	return &storage
+}
+
+// FieldStorage returns the storage metadata of the given field, using Cache.Storage, if set, otherwise decoding
+// Cache.StorageOption, if set, see also DecodeFieldStorage, or nil, if there is none.
+func (x *Cache) FieldStorage(desc protoreflect.FieldDescriptor) *FieldStorage {
+	switch {
+	case x == nil:
+		return nil
+	case x.Storage != nil:
+		return x.Storage(desc)
+	case x.StorageOption != 0:
+		return DecodeFieldStorage(desc, x.StorageOption)
+	default:
+		return nil
+	}
+}
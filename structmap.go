@@ -0,0 +1,277 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"go/types"
+	"google.golang.org/protobuf/compiler/protogen"
+	"reflect"
+	"strings"
+)
+
+type (
+	// StructMapGenerator generates functions that map messages to and from arbitrary golang structs, e.g. domain
+	// models, or DTOs, described using go/types (e.g. loaded using golang.org/x/tools/go/packages), matching fields
+	// by name, and converting values using Cache.ValueConversion.
+	//
+	// Exported struct fields are matched to proto fields by the name in their struct tag (see Tag), if any, else by
+	// golang name, ignoring case, e.g. ID matches the field id, with the golang name Id. Singular, repeated (to and
+	// from slices), and map (to and from maps with the same key type) fields are supported, as are message fields
+	// whose message is mapped to the struct type, by a function generated into the same package. Fields of oneofs
+	// aren't supported. Values aren't copied, i.e. messages, bytes, and slices of struct fields may be shared.
+	//
+	// Matched fields that can't be converted are assigned directly, causing a compile-time error, unless
+	// SkipMismatches is set. Check reports all mismatches, including unmatched fields, e.g. to fail generation.
+	StructMapGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// Target returns the struct a given message (of the generated file) will be mapped to and from, or nil if
+		// the message should be skipped, and must be set. The underlying type must be a struct.
+		Target func(v *protogen.Message) *types.Named
+		// Tag is the struct tag key, whose value (up to any comma) is the name of the matching proto field, or "-"
+		// to skip the struct field, and defaults to "proto".
+		Tag string
+		// SkipMismatches may be set to skip matched fields that can't be converted, rather than generating code that
+		// fails to compile, see also Check.
+		SkipMismatches bool
+	}
+
+	// structMapField models a proto field matched to a struct field.
+	structMapField struct {
+		field  *protogen.Field
+		name   string
+		target gopoet.TypeName
+	}
+)
+
+var (
+	_ Generator = (*StructMapGenerator)(nil)
+)
+
+// GenerateFile returns a pair of mapping functions (to and from the target) for each message in the given file,
+// that has a target, see also FileMessages. Targets that aren't structs panic, unless Cache.Diagnostics is set, in
+// which case an error is reported, and the message skipped.
+func (x *StructMapGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		target := x.Target(v)
+		if target == nil {
+			continue
+		}
+		if _, ok := target.Underlying().(*types.Struct); !ok {
+			x.Cache.Diagnostics.Errorf(v.Desc, "invalid target for %s: %s is not a struct", v.Desc.FullName(), target)
+			continue
+		}
+		elements = append(elements, x.ToFunc(v, target), x.FromFunc(v, target))
+	}
+	return elements
+}
+
+// ToName returns the name of the function mapping the given message to the target, which is the golang name of
+// the message, followed by "To", and the name of the target, e.g. BarToBarModel.
+func (x *StructMapGenerator) ToName(v *protogen.Message, target *types.Named) string {
+	return v.GoIdent.GoName + `To` + target.Obj().Name()
+}
+
+// FromName returns the name of the function mapping the target to the given message, which is the golang name of
+// the message, followed by "From", and the name of the target, e.g. BarFromBarModel.
+func (x *StructMapGenerator) FromName(v *protogen.Message, target *types.Named) string {
+	return v.GoIdent.GoName + `From` + target.Obj().Name()
+}
+
+// ToFunc returns the function mapping the given message to a pointer to the target.
+func (x *StructMapGenerator) ToFunc(v *protogen.Message, target *types.Named) *gopoet.FuncSpec {
+	name := x.ToName(v, target)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s maps a %s to a %s, returning nil if v is nil.`, name, v.Desc.FullName(), target.Obj().Name())).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, gopoet.PointerType(gopoet.TypeNameForGoType(target))).
+		Println(`if v == nil {`).
+		Println(`return nil`).
+		Println(`}`).
+		Printlnf(`r := new(%s)`, gopoet.TypeNameForGoType(target))
+	for _, m := range x.fields(v, target) {
+		to, _ := x.convert(v, m.field, m.target)
+		x.assign(f, m, true, to)
+	}
+	return f.Println(`return r`)
+}
+
+// FromFunc returns the function mapping a pointer to the target to the given message.
+func (x *StructMapGenerator) FromFunc(v *protogen.Message, target *types.Named) *gopoet.FuncSpec {
+	name := x.FromName(v, target)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s maps a %s to a %s, returning nil if v is nil.`, name, target.Obj().Name(), v.Desc.FullName())).
+		AddArg(`v`, gopoet.PointerType(gopoet.TypeNameForGoType(target))).
+		AddResult(``, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		Println(`if v == nil {`).
+		Println(`return nil`).
+		Println(`}`).
+		Printlnf(`r := new(%s)`, x.Cache.MessageType(v.Desc))
+	for _, m := range x.fields(v, target) {
+		_, from := x.convert(v, m.field, m.target)
+		x.assign(f, m, false, from)
+	}
+	return f.Println(`return r`)
+}
+
+// Check returns a diagnostic for each field of the given message, or its target, that isn't matched, and each
+// matched field that can't be converted, see also StructMapGenerator.
+func (x *StructMapGenerator) Check(v *protogen.Message) []string {
+	target := x.Target(v)
+	if target == nil {
+		return nil
+	}
+	if _, ok := target.Underlying().(*types.Struct); !ok {
+		return []string{fmt.Sprintf("%s: target %s is not a struct", v.Desc.FullName(), target)}
+	}
+	var (
+		diagnostics []string
+		matched     = make(map[*protogen.Field]bool)
+	)
+	x.match(v, target, func(sf *types.Var, field *protogen.Field) {
+		switch {
+		case field == nil:
+			diagnostics = append(diagnostics, fmt.Sprintf("%s: no field matches %s.%s", v.Desc.FullName(), target.Obj().Name(), sf.Name()))
+		case field.Oneof != nil && !field.Oneof.Desc.IsSynthetic():
+			matched[field] = true
+			diagnostics = append(diagnostics, fmt.Sprintf("%s: oneof field %s is unsupported", v.Desc.FullName(), field.Desc.Name()))
+		default:
+			matched[field] = true
+			if to, _ := x.convert(v, field, gopoet.TypeNameForGoType(sf.Type())); to == nil {
+				diagnostics = append(diagnostics, fmt.Sprintf("%s: cannot convert field %s to %s.%s (%s)", v.Desc.FullName(), field.Desc.Name(), target.Obj().Name(), sf.Name(), sf.Type()))
+			}
+		}
+	})
+	for _, field := range v.Fields {
+		if !matched[field] {
+			diagnostics = append(diagnostics, fmt.Sprintf("%s: field %s matches no field of %s", v.Desc.FullName(), field.Desc.Name(), target.Obj().Name()))
+		}
+	}
+	return diagnostics
+}
+
+// fields returns the matched fields of the given message, and target, in struct field order, excluding any that
+// can't be converted, if SkipMismatches is set.
+func (x *StructMapGenerator) fields(v *protogen.Message, target *types.Named) []structMapField {
+	if _, ok := target.Underlying().(*types.Struct); !ok {
+		panic(fmt.Sprintf("invalid target for %s: %s is not a struct", v.Desc.FullName(), target))
+	}
+	var fields []structMapField
+	x.match(v, target, func(sf *types.Var, field *protogen.Field) {
+		if field == nil || field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+			return
+		}
+		m := structMapField{field: field, name: sf.Name(), target: gopoet.TypeNameForGoType(sf.Type())}
+		if to, _ := x.convert(v, field, m.target); to == nil && x.SkipMismatches {
+			return
+		}
+		fields = append(fields, m)
+	})
+	return fields
+}
+
+// match calls fn for each exported, non-embedded, and non-skipped field of the given (struct) target, in order,
+// with the matching field of the given message, or nil.
+func (x *StructMapGenerator) match(v *protogen.Message, target *types.Named, fn func(sf *types.Var, field *protogen.Field)) {
+	structType := target.Underlying().(*types.Struct)
+	for i := 0; i < structType.NumFields(); i++ {
+		sf := structType.Field(i)
+		if !sf.Exported() || sf.Embedded() {
+			continue
+		}
+		if name, skip := x.structFieldName(structType.Tag(i)); !skip {
+			fn(sf, x.matchField(v, sf.Name(), name))
+		}
+	}
+}
+
+// structFieldName returns the proto field name from the given struct tag, if any, and whether the struct field
+// should be skipped.
+func (x *StructMapGenerator) structFieldName(tag string) (string, bool) {
+	key := x.Tag
+	if key == `` {
+		key = `proto`
+	}
+	value, ok := reflect.StructTag(tag).Lookup(key)
+	if !ok {
+		return ``, false
+	}
+	if i := strings.IndexByte(value, ','); i >= 0 {
+		value = value[:i]
+	}
+	return value, value == `-`
+}
+
+// matchField returns the field of the given message with the given (tag) name, if it is set, else the field with
+// the given golang name, ignoring case, or nil.
+func (x *StructMapGenerator) matchField(v *protogen.Message, goName, name string) *protogen.Field {
+	for _, field := range v.Fields {
+		if name != `` && string(field.Desc.Name()) == name ||
+			name == `` && strings.EqualFold(field.GoName, goName) {
+			return field
+		}
+	}
+	return nil
+}
+
+// convert returns the conversions between a single value of the given field, and a value of target, where target
+// is a slice or map, for repeated and map fields, or nil, if it is unsupported.
+func (x *StructMapGenerator) convert(owner *protogen.Message, field *protogen.Field, target gopoet.TypeName) (to, from func(expr string) *gopoet.CodeBlock) {
+	switch {
+	case field.Desc.IsMap():
+		if target.Kind() != gopoet.KindMap || !sameType(x.Cache.valueType(field.Message.Fields[0].Desc), target.Key()) {
+			return nil, nil
+		}
+		field, target = field.Message.Fields[1], target.Elem()
+	case field.Desc.IsList():
+		if target.Kind() != gopoet.KindSlice {
+			return nil, nil
+		}
+		target = target.Elem()
+	}
+	if field.Message != nil && target.Kind() == gopoet.KindPtr && target.Elem().Kind() == gopoet.KindNamed &&
+		field.Message.Desc.ParentFile().Path() == owner.Desc.ParentFile().Path() {
+		if t := x.Target(field.Message); t != nil && sameType(gopoet.TypeNameForGoType(t), target.Elem()) {
+			return func(expr string) *gopoet.CodeBlock {
+					return gopoet.Printf(`%s(%s)`, x.ToName(field.Message, t), expr)
+				}, func(expr string) *gopoet.CodeBlock {
+					return gopoet.Printf(`%s(%s)`, x.FromName(field.Message, t), expr)
+				}
+		}
+	}
+	if c := x.Cache.ValueConversion(field, target); c != nil {
+		return c.To, c.From
+	}
+	return nil, nil
+}
+
+// assign prints statements assigning the value of the given matched field, to the struct field, if toStruct is
+// true, else the proto field, using the given conversion, which assigns directly, if it is nil.
+func (x *StructMapGenerator) assign(f *gopoet.FuncSpec, m structMapField, toStruct bool, conv func(expr string) *gopoet.CodeBlock) {
+	if conv == nil {
+		conv = printCode
+	}
+	dst, src, dstType := m.field.GoName, m.name, x.Cache.fieldType(m.field.Desc)
+	if toStruct {
+		dst, src, dstType = src, dst, m.target
+	}
+	switch {
+	case m.field.Desc.IsMap():
+		f.Printlnf(`if v.%s != nil {`, src).
+			Printlnf(`r.%s = make(%s, len(v.%s))`, dst, dstType, src).
+			Printlnf(`for k, e := range v.%s {`, src).
+			Printf(`r.%s[k] = `, dst).AddCode(conv(`e`)).Println(``).
+			Println(`}`).
+			Println(`}`)
+	case m.field.Desc.IsList():
+		f.Printlnf(`if v.%s != nil {`, src).
+			Printlnf(`r.%s = make(%s, len(v.%s))`, dst, dstType, src).
+			Printlnf(`for i, e := range v.%s {`, src).
+			Printf(`r.%s[i] = `, dst).AddCode(conv(`e`)).Println(``).
+			Println(`}`).
+			Println(`}`)
+	default:
+		f.Printf(`r.%s = `, dst).AddCode(conv(`v.` + src)).Println(``)
+	}
+}
@@ -0,0 +1,146 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"text/template"
+)
+
+// FuncMap returns functions for text/template (or html/template), exposing the field model, and type resolution,
+// of the cache, for existing template-based generators. Type names are qualified using the given imports, which
+// may be those of a gopoet.GoFile, and will be updated with any referenced packages, or, if nil, the package name.
+//
+// The following functions are provided:
+//
+//   - fields: the fields of a *protogen.Message, see also Cache.MessageFields
+//   - flatFields: the fields of a *protogen.Message, with oneof members, see also Cache.FlatFields
+//   - type: the golang type of a Field, OneOfField, *protogen.Field, *protogen.Message, *protogen.Enum, or
+//     gopoet.TypeName
+//   - baseType: the golang type of a Field, without any pointer used to represent presence, see also
+//     Field.BaseType
+//   - getter: the name of the getter method of a Field, or OneOfField
+//   - setter: the name of the setter method of a Field, or OneOfField, if any, see also Cache.APILevel
+//   - zero: the golang zero value of a Field, OneOfField, or *protogen.Field, e.g. `""`, or nil
+//   - comments: the sanitized leading comments of a Field, OneOfField, *protogen.Field, *protogen.Message,
+//     *protogen.Enum, *protogen.EnumValue, *protogen.Oneof, *protogen.Service, *protogen.Method, or
+//     protogen.Comments, see also Cache.Comment
+//   - enumValue: the golang constant of a *protogen.EnumValue, see also Cache.EnumValueConst
+//
+// Functions return an error if they are called with an unsupported argument.
+func (x *Cache) FuncMap(imports *gopoet.Imports) template.FuncMap {
+	qualify := func(t gopoet.TypeName) string {
+		if imports != nil {
+			t = imports.EnsureTypeImported(t)
+		}
+		return t.String()
+	}
+	return template.FuncMap{
+		`fields`:     x.MessageFields,
+		`flatFields`: x.FlatFields,
+		`type`: func(v interface{}) (string, error) {
+			switch v := v.(type) {
+			case Field:
+				return qualify(v.Type()), nil
+			case OneOfField:
+				return qualify(v.Getter.Signature.Results[0].Type), nil
+			case *protogen.Field:
+				return qualify(x.fieldType(v.Desc)), nil
+			case *protogen.Message:
+				return qualify(x.MessageType(v.Desc)), nil
+			case *protogen.Enum:
+				return qualify(x.enumType(v.Desc)), nil
+			case gopoet.TypeName:
+				return qualify(v), nil
+			}
+			return ``, fmt.Errorf("type: unsupported argument: %T", v)
+		},
+		`baseType`: func(v Field) string {
+			return qualify(v.BaseType())
+		},
+		`getter`: func(v interface{}) (string, error) {
+			switch v := v.(type) {
+			case Field:
+				return v.Getter().Name, nil
+			case OneOfField:
+				return v.Getter.Name, nil
+			}
+			return ``, fmt.Errorf("getter: unsupported argument: %T", v)
+		},
+		`setter`: func(v interface{}) (string, error) {
+			switch v := v.(type) {
+			case Field:
+				return v.Setter().Name, nil
+			case OneOfField:
+				return v.Setter.Name, nil
+			}
+			return ``, fmt.Errorf("setter: unsupported argument: %T", v)
+		},
+		`zero`: func(v interface{}) (string, error) {
+			var (
+				t    gopoet.TypeName
+				kind protoreflect.Kind
+			)
+			switch v := v.(type) {
+			case Field:
+				if v.Kind() == 0 {
+					// merged oneof
+					return `nil`, nil
+				}
+				t, kind = v.Type(), v.Kind()
+			case OneOfField:
+				t, kind = v.Getter.Signature.Results[0].Type, v.Kind()
+			case *protogen.Field:
+				t, kind = x.fieldType(v.Desc), v.Desc.Kind()
+			default:
+				return ``, fmt.Errorf("zero: unsupported argument: %T", v)
+			}
+			switch t.Kind() {
+			case gopoet.KindPtr, gopoet.KindSlice, gopoet.KindMap:
+				return `nil`, nil
+			}
+			return zeroValue(kind), nil
+		},
+		`comments`: func(v interface{}) (string, error) {
+			var comments protogen.Comments
+			switch v := v.(type) {
+			case Field:
+				if v.Kind() == 0 {
+					// merged oneof
+					comments = v.OneOf().Comments.Leading
+				} else {
+					comments = v.Fields()[0].Comments.Leading
+				}
+			case OneOfField:
+				comments = v.Field.Comments.Leading
+			case *protogen.Field:
+				comments = v.Comments.Leading
+			case *protogen.Message:
+				comments = v.Comments.Leading
+			case *protogen.Enum:
+				comments = v.Comments.Leading
+			case *protogen.EnumValue:
+				comments = v.Comments.Leading
+			case *protogen.Oneof:
+				comments = v.Comments.Leading
+			case *protogen.Service:
+				comments = v.Comments.Leading
+			case *protogen.Method:
+				comments = v.Comments.Leading
+			case protogen.Comments:
+				comments = v
+			default:
+				return ``, fmt.Errorf("comments: unsupported argument: %T", v)
+			}
+			return x.Comment(comments), nil
+		},
+		`enumValue`: func(v *protogen.EnumValue) string {
+			sym := x.EnumValueConst(v.Desc)
+			if imports != nil {
+				sym = imports.EnsureImported(sym)
+			}
+			return sym.String()
+		},
+	}
+}
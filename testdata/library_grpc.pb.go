@@ -0,0 +1,367 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: test/v1/library.proto
+
+package testv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Library_GetBook_FullMethodName     = "/test.v1.Library/GetBook"
+	Library_ListBooks_FullMethodName   = "/test.v1.Library/ListBooks"
+	Library_WatchBooks_FullMethodName  = "/test.v1.Library/WatchBooks"
+	Library_UploadBooks_FullMethodName = "/test.v1.Library/UploadBooks"
+	Library_SyncBooks_FullMethodName   = "/test.v1.Library/SyncBooks"
+)
+
+// LibraryClient is the client API for Library service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LibraryClient interface {
+	GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*Book, error)
+	ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error)
+	WatchBooks(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (Library_WatchBooksClient, error)
+	UploadBooks(ctx context.Context, opts ...grpc.CallOption) (Library_UploadBooksClient, error)
+	SyncBooks(ctx context.Context, opts ...grpc.CallOption) (Library_SyncBooksClient, error)
+}
+
+type libraryClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLibraryClient(cc grpc.ClientConnInterface) LibraryClient {
+	return &libraryClient{cc}
+}
+
+func (c *libraryClient) GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*Book, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Book)
+	err := c.cc.Invoke(ctx, Library_GetBook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *libraryClient) ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBooksResponse)
+	err := c.cc.Invoke(ctx, Library_ListBooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *libraryClient) WatchBooks(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (Library_WatchBooksClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Library_ServiceDesc.Streams[0], Library_WatchBooks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &libraryWatchBooksClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Library_WatchBooksClient interface {
+	Recv() (*Book, error)
+	grpc.ClientStream
+}
+
+type libraryWatchBooksClient struct {
+	grpc.ClientStream
+}
+
+func (x *libraryWatchBooksClient) Recv() (*Book, error) {
+	m := new(Book)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *libraryClient) UploadBooks(ctx context.Context, opts ...grpc.CallOption) (Library_UploadBooksClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Library_ServiceDesc.Streams[1], Library_UploadBooks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &libraryUploadBooksClient{ClientStream: stream}
+	return x, nil
+}
+
+type Library_UploadBooksClient interface {
+	Send(*Book) error
+	CloseAndRecv() (*ListBooksResponse, error)
+	grpc.ClientStream
+}
+
+type libraryUploadBooksClient struct {
+	grpc.ClientStream
+}
+
+func (x *libraryUploadBooksClient) Send(m *Book) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *libraryUploadBooksClient) CloseAndRecv() (*ListBooksResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ListBooksResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *libraryClient) SyncBooks(ctx context.Context, opts ...grpc.CallOption) (Library_SyncBooksClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Library_ServiceDesc.Streams[2], Library_SyncBooks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &librarySyncBooksClient{ClientStream: stream}
+	return x, nil
+}
+
+type Library_SyncBooksClient interface {
+	Send(*Book) error
+	Recv() (*Book, error)
+	grpc.ClientStream
+}
+
+type librarySyncBooksClient struct {
+	grpc.ClientStream
+}
+
+func (x *librarySyncBooksClient) Send(m *Book) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *librarySyncBooksClient) Recv() (*Book, error) {
+	m := new(Book)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LibraryServer is the server API for Library service.
+// All implementations must embed UnimplementedLibraryServer
+// for forward compatibility.
+type LibraryServer interface {
+	GetBook(context.Context, *GetBookRequest) (*Book, error)
+	ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error)
+	WatchBooks(*GetBookRequest, Library_WatchBooksServer) error
+	UploadBooks(Library_UploadBooksServer) error
+	SyncBooks(Library_SyncBooksServer) error
+	mustEmbedUnimplementedLibraryServer()
+}
+
+// UnimplementedLibraryServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedLibraryServer struct{}
+
+func (UnimplementedLibraryServer) GetBook(context.Context, *GetBookRequest) (*Book, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBook not implemented")
+}
+func (UnimplementedLibraryServer) ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBooks not implemented")
+}
+func (UnimplementedLibraryServer) WatchBooks(*GetBookRequest, Library_WatchBooksServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchBooks not implemented")
+}
+func (UnimplementedLibraryServer) UploadBooks(Library_UploadBooksServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadBooks not implemented")
+}
+func (UnimplementedLibraryServer) SyncBooks(Library_SyncBooksServer) error {
+	return status.Errorf(codes.Unimplemented, "method SyncBooks not implemented")
+}
+func (UnimplementedLibraryServer) mustEmbedUnimplementedLibraryServer() {}
+func (UnimplementedLibraryServer) testEmbeddedByValue()                 {}
+
+// UnsafeLibraryServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LibraryServer will
+// result in compilation errors.
+type UnsafeLibraryServer interface {
+	mustEmbedUnimplementedLibraryServer()
+}
+
+func RegisterLibraryServer(s grpc.ServiceRegistrar, srv LibraryServer) {
+	// If the following call pancis, it indicates UnimplementedLibraryServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Library_ServiceDesc, srv)
+}
+
+func _Library_GetBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LibraryServer).GetBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Library_GetBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LibraryServer).GetBook(ctx, req.(*GetBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Library_ListBooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LibraryServer).ListBooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Library_ListBooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LibraryServer).ListBooks(ctx, req.(*ListBooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Library_WatchBooks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetBookRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LibraryServer).WatchBooks(m, &libraryWatchBooksServer{ServerStream: stream})
+}
+
+type Library_WatchBooksServer interface {
+	Send(*Book) error
+	grpc.ServerStream
+}
+
+type libraryWatchBooksServer struct {
+	grpc.ServerStream
+}
+
+func (x *libraryWatchBooksServer) Send(m *Book) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Library_UploadBooks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LibraryServer).UploadBooks(&libraryUploadBooksServer{ServerStream: stream})
+}
+
+type Library_UploadBooksServer interface {
+	SendAndClose(*ListBooksResponse) error
+	Recv() (*Book, error)
+	grpc.ServerStream
+}
+
+type libraryUploadBooksServer struct {
+	grpc.ServerStream
+}
+
+func (x *libraryUploadBooksServer) SendAndClose(m *ListBooksResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *libraryUploadBooksServer) Recv() (*Book, error) {
+	m := new(Book)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Library_SyncBooks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LibraryServer).SyncBooks(&librarySyncBooksServer{ServerStream: stream})
+}
+
+type Library_SyncBooksServer interface {
+	Send(*Book) error
+	Recv() (*Book, error)
+	grpc.ServerStream
+}
+
+type librarySyncBooksServer struct {
+	grpc.ServerStream
+}
+
+func (x *librarySyncBooksServer) Send(m *Book) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *librarySyncBooksServer) Recv() (*Book, error) {
+	m := new(Book)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Library_ServiceDesc is the grpc.ServiceDesc for Library service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Library_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.v1.Library",
+	HandlerType: (*LibraryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBook",
+			Handler:    _Library_GetBook_Handler,
+		},
+		{
+			MethodName: "ListBooks",
+			Handler:    _Library_ListBooks_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchBooks",
+			Handler:       _Library_WatchBooks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "UploadBooks",
+			Handler:       _Library_UploadBooks_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SyncBooks",
+			Handler:       _Library_SyncBooks_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "test/v1/library.proto",
+}
@@ -0,0 +1,72 @@
+package gopoet_protogen
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"strings"
+)
+
+type (
+	// TwirpNamer implements RPCNamer using the conventions of protoc-gen-twirp (v8), where the server and client
+	// share a single interface, named after the service, and methods are served on paths prefixed by "/twirp", by
+	// default, e.g. "/twirp/pkg.Service/Method". Twirp files are generated into the same package as the messages.
+	TwirpNamer struct {
+		// PathPrefix may be set to the prefix configured using twirp.WithServerPathPrefix, and defaults to "/twirp".
+		// It may be set to "/", for no prefix, e.g. for servers using twirp.WithServerPathPrefix("").
+		PathPrefix string
+	}
+)
+
+var (
+	_ RPCNamer = TwirpNamer{}
+)
+
+// ServerInterface returns the golang name of the service.
+func (TwirpNamer) ServerInterface(v *protogen.Service) string {
+	return v.GoName
+}
+
+// ServerConstructor returns "New", followed by the golang name of the service, followed by "Server".
+func (TwirpNamer) ServerConstructor(v *protogen.Service) string {
+	return `New` + v.GoName + `Server`
+}
+
+// ClientInterface returns the golang name of the service.
+func (TwirpNamer) ClientInterface(v *protogen.Service) string {
+	return v.GoName
+}
+
+// ClientConstructor returns "New", followed by the golang name of the service, followed by "ProtobufClient".
+func (TwirpNamer) ClientConstructor(v *protogen.Service) string {
+	return `New` + v.GoName + `ProtobufClient`
+}
+
+// JSONClientConstructor returns "New", followed by the golang name of the service, followed by "JSONClient".
+func (TwirpNamer) JSONClientConstructor(v *protogen.Service) string {
+	return `New` + v.GoName + `JSONClient`
+}
+
+// PathPrefixConst returns the golang name of the service, followed by "PathPrefix", i.e. the name of the constant
+// containing the (default) path prefix of the service, see also ServicePathPrefix.
+func (TwirpNamer) PathPrefixConst(v *protogen.Service) string {
+	return v.GoName + `PathPrefix`
+}
+
+// ServicePathPrefix returns the path prefix of the methods of the given service, e.g. "/twirp/pkg.Service/".
+func (x TwirpNamer) ServicePathPrefix(v *protogen.Service) string {
+	prefix := x.PathPrefix
+	if prefix == `` {
+		prefix = `/twirp`
+	}
+	return strings.TrimSuffix(prefix, `/`) + `/` + string(v.Desc.FullName()) + `/`
+}
+
+// MethodPath returns the path prefix of the service, followed by the golang (camel case) name of the method, e.g.
+// "/twirp/pkg.Service/Method", as used by Twirp clients.
+func (x TwirpNamer) MethodPath(v *protogen.Method) string {
+	return x.ServicePathPrefix(v.Parent) + v.GoName
+}
+
+// FileSuffix returns ".twirp.go".
+func (TwirpNamer) FileSuffix() string {
+	return `.twirp.go`
+}
@@ -0,0 +1,276 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// TypeScriptGenerator renders TypeScript declarations (.d.ts), mirroring the protojson representation of each
+	// message and enum, e.g. for TypeScript clients of golang servers, generated alongside each other.
+	//
+	// Each message is an interface, and each enum a union of its value names, named like the golang type, e.g.
+	// "Foo_Bar", for a nested message "Foo.Bar". Properties use the JSON name (or the proto name, see also
+	// UseProtoNames), and are optional, as protojson omits unpopulated fields, except for proto2 required fields.
+	// Well-known types with a special protojson representation are inlined, e.g. google.protobuf.Timestamp is a
+	// string, as are 64-bit integers. Types declared in other files are imported, as namespaces, using a path
+	// relative to the output file, see also GenerateFiles.
+	TypeScriptGenerator struct {
+		// Cache is used to resolve the fields of each message, and must contain all referenced files.
+		Cache *Cache
+		// UseProtoNames may be set to use proto field names as property names, rather than JSON names, consistent
+		// with protojson.MarshalOptions.UseProtoNames.
+		UseProtoNames bool
+	}
+)
+
+const (
+	typeScriptExtension = `.d.ts`
+)
+
+// Declarations renders the TypeScript declarations for the messages and enums of the given file.
+func (x *TypeScriptGenerator) Declarations(file *protogen.File) []byte {
+	var (
+		body    strings.Builder
+		imports = make(map[string]string)
+	)
+	for _, v := range FileEnums(file) {
+		writeTypeScriptComment(&body, v.Comments.Leading, ``)
+		fmt.Fprintf(&body, "export type %s =", v.GoIdent.GoName)
+		for _, value := range v.Values {
+			fmt.Fprintf(&body, "\n  | %s", strconv.Quote(string(value.Desc.Name())))
+		}
+		body.WriteString(";\n\n")
+	}
+	for _, v := range FileMessages(file) {
+		writeTypeScriptComment(&body, v.Comments.Leading, ``)
+		fmt.Fprintf(&body, "export interface %s {\n", v.GoIdent.GoName)
+		for _, field := range x.Cache.FlatFields(v) {
+			f := field.Fields()[0]
+			writeTypeScriptComment(&body, f.Comments.Leading, `  `)
+			name := f.Desc.JSONName()
+			if x.UseProtoNames {
+				name = string(f.Desc.Name())
+			}
+			if !isTypeScriptIdentifier(name) {
+				name = strconv.Quote(name)
+			}
+			optional := `?`
+			if field.IsRequired() {
+				optional = ``
+			}
+			fmt.Fprintf(&body, "  %s%s: %s;\n", name, optional, x.fieldType(file, imports, f))
+		}
+		body.WriteString("}\n\n")
+	}
+	var (
+		b     strings.Builder
+		paths = make([]string, 0, len(imports))
+	)
+	fmt.Fprintf(&b, "// Code generated from %s. DO NOT EDIT.\n\n", file.Desc.Path())
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Fprintf(&b, "import type * as %s from %s;\n", imports[p], strconv.Quote(p))
+	}
+	if len(paths) != 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.TrimSuffix(body.String(), "\n"))
+	return []byte(b.String())
+}
+
+// Filename returns the name of the output file for the given input file, e.g. "foo/v1/bar.d.ts", see also
+// GeneratedFilename.
+func (x *TypeScriptGenerator) Filename(plugin *protogen.Plugin, file *protogen.File) (string, error) {
+	return GeneratedFilename(plugin, file, typeScriptExtension)
+}
+
+// GenerateFiles adds an output file, to the plugin, containing the TypeScript declarations of the given file, if it
+// declares any messages or enums, see also Filename.
+func (x *TypeScriptGenerator) GenerateFiles(plugin *protogen.Plugin, file *protogen.File) error {
+	if len(FileMessages(file)) == 0 && len(FileEnums(file)) == 0 {
+		return nil
+	}
+	filename, err := x.Filename(plugin, file)
+	if err != nil {
+		return err
+	}
+	g := plugin.NewGeneratedFile(filename, ``)
+	_, err = g.Write(x.Declarations(file))
+	return err
+}
+
+// fieldType returns the TypeScript type of the given field, adding any imports.
+func (x *TypeScriptGenerator) fieldType(file *protogen.File, imports map[string]string, field *protogen.Field) string {
+	switch {
+	case field.Desc.IsMap():
+		return `{ [key: string]: ` + x.valueType(file, imports, field.Message.Fields[1]) + ` }`
+	case field.Desc.IsList():
+		t := x.valueType(file, imports, field)
+		if strings.ContainsAny(t, ` |`) {
+			t = `(` + t + `)`
+		}
+		return t + `[]`
+	}
+	return x.valueType(file, imports, field)
+}
+
+// valueType returns the TypeScript type of a single value of the given field.
+func (x *TypeScriptGenerator) valueType(file *protogen.File, imports map[string]string, field *protogen.Field) string {
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return `boolean`
+	case protoreflect.StringKind,
+		protoreflect.BytesKind,
+		protoreflect.Int64Kind,
+		protoreflect.Sint64Kind,
+		protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind,
+		protoreflect.Fixed64Kind:
+		return `string`
+	case protoreflect.Int32Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind,
+		protoreflect.Fixed32Kind,
+		protoreflect.FloatKind,
+		protoreflect.DoubleKind:
+		return `number`
+	case protoreflect.EnumKind:
+		if field.Enum.Desc.FullName() == `google.protobuf.NullValue` {
+			return `null`
+		}
+		return x.typeRef(file, imports, field.Enum.Desc, field.Enum.GoIdent.GoName)
+	case protoreflect.MessageKind,
+		protoreflect.GroupKind:
+		if t := wellKnownTypeScript(field.Message.Desc); t != `` {
+			return t
+		}
+		return x.typeRef(file, imports, field.Message.Desc, field.Message.GoIdent.GoName)
+	default:
+		return `unknown`
+	}
+}
+
+// typeRef returns a reference to the named type, declared by the given descriptor, importing it if it is declared
+// in a different file.
+func (x *TypeScriptGenerator) typeRef(file *protogen.File, imports map[string]string, desc protoreflect.Descriptor, name string) string {
+	p := desc.ParentFile().Path()
+	if p == file.Desc.Path() {
+		return name
+	}
+	other := x.Cache.file(p)
+	if other == nil {
+		panic(fmt.Sprintf("unknown type: %v", desc))
+	}
+	rel, err := relativePath(path.Dir(file.GeneratedFilenamePrefix), other.GeneratedFilenamePrefix)
+	if err != nil {
+		panic(err)
+	}
+	alias, ok := imports[rel]
+	if !ok {
+		alias = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, strings.TrimSuffix(p, `.proto`))
+		imports[rel] = alias
+	}
+	return alias + `.` + name
+}
+
+// relativePath returns the (slash separated) path of target, relative to the directory dir, prefixed with "./" if
+// it doesn't start with "../", as required by TypeScript module specifiers.
+func relativePath(dir, target string) (string, error) {
+	var (
+		from = strings.Split(path.Clean(dir), `/`)
+		to   = strings.Split(path.Clean(target), `/`)
+	)
+	if from[0] == `.` {
+		from = from[:0]
+	}
+	for len(from) != 0 && len(to) > 1 && from[0] == to[0] {
+		from, to = from[1:], to[1:]
+	}
+	for _, v := range from {
+		if v == `..` {
+			return ``, fmt.Errorf("relative path: cannot resolve %q from %q", target, dir)
+		}
+	}
+	rel := strings.Repeat(`../`, len(from)) + strings.Join(to, `/`)
+	if !strings.HasPrefix(rel, `../`) {
+		rel = `./` + rel
+	}
+	return rel, nil
+}
+
+// wellKnownTypeScript returns an inline TypeScript type for well-known types that have a special protojson
+// representation, or an empty string, if the given message doesn't have one, see also wellKnownSchema.
+func wellKnownTypeScript(desc protoreflect.MessageDescriptor) string {
+	switch desc.FullName() {
+	case `google.protobuf.Timestamp`,
+		`google.protobuf.Duration`,
+		`google.protobuf.FieldMask`:
+		return `string`
+	case `google.protobuf.Struct`:
+		return `{ [key: string]: unknown }`
+	case `google.protobuf.ListValue`:
+		return `unknown[]`
+	case `google.protobuf.Value`:
+		return `unknown`
+	case `google.protobuf.Empty`:
+		return `Record<string, never>`
+	case anyFullName:
+		return `{ "@type": string; [key: string]: unknown }`
+	case `google.protobuf.BoolValue`:
+		return `boolean | null`
+	case `google.protobuf.StringValue`,
+		`google.protobuf.BytesValue`,
+		`google.protobuf.Int64Value`,
+		`google.protobuf.UInt64Value`:
+		return `string | null`
+	case `google.protobuf.Int32Value`,
+		`google.protobuf.UInt32Value`,
+		`google.protobuf.FloatValue`,
+		`google.protobuf.DoubleValue`:
+		return `number | null`
+	default:
+		return ``
+	}
+}
+
+// writeTypeScriptComment writes the given comment as a JSDoc comment, with the given indent, if it isn't empty.
+func writeTypeScriptComment(b *strings.Builder, comment protogen.Comments, indent string) {
+	text := commentText(comment)
+	if text == `` {
+		return
+	}
+	fmt.Fprintf(b, "%s/**\n", indent)
+	for _, line := range strings.Split(strings.ReplaceAll(text, `*/`, `* /`), "\n") {
+		if line == `` {
+			fmt.Fprintf(b, "%s *\n", indent)
+		} else {
+			fmt.Fprintf(b, "%s * %s\n", indent, line)
+		}
+	}
+	fmt.Fprintf(b, "%s */\n", indent)
+}
+
+// isTypeScriptIdentifier returns true if the given property name doesn't need to be quoted.
+func isTypeScriptIdentifier(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i != 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return s != ``
+}
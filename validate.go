@@ -0,0 +1,128 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// ValidatorGenerator generates functions that validate messages, returning an error if any required fields are
+	// missing, see also Field.IsRequired. Fields with the REQUIRED google.api.field_behavior, that aren't also
+	// OUTPUT_ONLY, are also required, and are missing if they aren't present, see also Field.HasExpr. Errors are
+	// prefixed with the path of the invalid field.
+	//
+	// Fields of message types declared in the same file as the generated message call the function generated for
+	// that type, and must therefore be generated into the same package, other message types fall back to
+	// proto.CheckInitialized.
+	ValidatorGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// FuncName may be used to override the name of the generated function, which defaults to "Validate"
+		// followed by the golang name of the message.
+		FuncName func(v *protogen.Message) string
+	}
+)
+
+var (
+	_ Generator = (*ValidatorGenerator)(nil)
+)
+
+// GenerateFile returns a validation function for each message in the given file, see also FileMessages.
+func (x *ValidatorGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Func(v))
+	}
+	return elements
+}
+
+// Name returns the name of the function generated for the given message.
+func (x *ValidatorGenerator) Name(v *protogen.Message) string {
+	if x.FuncName != nil {
+		return x.FuncName(v)
+	}
+	return `Validate` + v.GoIdent.GoName
+}
+
+// Func returns the validation function for the given message, which accepts a (possibly nil) pointer to the
+// message, and returns an error if it is invalid.
+func (x *ValidatorGenerator) Func(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.Name(v)
+	f := gopoet.NewFunc(name).
+		SetComment(fmt.Sprintf(`%s returns an error if a %s is missing any required fields.`, name, v.Desc.FullName())).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, gopoet.ErrorType).
+		Println(`if v == nil {`).
+		Println(`return nil`).
+		Println(`}`)
+	for _, field := range x.Cache.MessageFields(v) {
+		if oneOf := field.OneOf(); oneOf != nil && !oneOf.Desc.IsSynthetic() {
+			var cases []OneOfField
+			for _, field := range field.OneOfFields() {
+				if field.Field.Message != nil {
+					cases = append(cases, field)
+				}
+			}
+			if len(cases) == 0 {
+				continue
+			}
+			f.Printlnf(`switch x := v.%s.(type) {`, field.Name())
+			for _, field := range cases {
+				f.Printlnf(`case %s:`, gopoet.PointerType(field.Type))
+				x.validateMessage(f, v, field.Field, `x.`+field.Field.GoName, string(field.Field.Desc.Name()), ``)
+			}
+			f.Println(`}`)
+			continue
+		}
+		x.validateField(f, v, field)
+	}
+	return f.Println(`return nil`)
+}
+
+func (x *ValidatorGenerator) validateField(f *gopoet.FuncSpec, parent *protogen.Message, field Field) {
+	var (
+		desc = field.Fields()[0].Desc
+		expr = `v.` + field.Name()
+	)
+	if field.IsRequired() {
+		f.Printlnf(`if %s == nil {`, expr)
+		f.Printlnf(`return %s.New(%q)`, errorsPkg, `missing required field: `+desc.Name())
+		f.Println(`}`)
+	} else if requiredField(field) {
+		f.Print(`if !(`).AddCode(field.HasExpr(`v`)).Println(`) {`)
+		f.Printlnf(`return %s.New(%q)`, errorsPkg, `missing required field: `+desc.Name())
+		f.Println(`}`)
+	}
+	switch {
+	case desc.IsMap():
+		if value := field.Fields()[0].Message.Fields[1]; value.Message != nil {
+			f.Printlnf(`for k, e := range %s {`, expr)
+			x.validateMessage(f, parent, value, `e`, string(desc.Name()), `k`)
+			f.Println(`}`)
+		}
+	case desc.Message() == nil:
+	case desc.IsList():
+		f.Printlnf(`for i, e := range %s {`, expr)
+		x.validateMessage(f, parent, field.Fields()[0], `e`, string(desc.Name()), `i`)
+		f.Println(`}`)
+	default:
+		x.validateMessage(f, parent, field.Fields()[0], expr, string(desc.Name()), ``)
+	}
+}
+
+// validateMessage prints a check for a single message value, where name is the name of the field, and index is the
+// optional variable for the list index or map key, used to construct the path prefix of the error.
+func (x *ValidatorGenerator) validateMessage(f *gopoet.FuncSpec, parent *protogen.Message, field *protogen.Field, expr string, name string, index string) {
+	if field.Message.Desc.ParentFile().Path() == parent.Desc.ParentFile().Path() {
+		f.Printlnf(`if err := %s(%s); err != nil {`, x.Name(field.Message), expr)
+	} else {
+		f.Printlnf(`if err := %s(%s); err != nil {`, protoPkg.Symbol(`CheckInitialized`), expr)
+	}
+	if index == `` {
+		f.Printlnf(`return %s(%q, err)`, fmtPkg.Symbol(`Errorf`), name+`: %w`)
+	} else {
+		f.Printlnf(`return %s(%q, %s, err)`, fmtPkg.Symbol(`Errorf`), name+`[%v]: %w`, index)
+	}
+	f.Println(`}`)
+}
@@ -0,0 +1,274 @@
+package gopoet_protogen
+
+import (
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"reflect"
+	"strings"
+)
+
+type (
+	// ValueConversion builds golang expressions converting between a single value of a field, and a target golang
+	// type, e.g. for mapping, or DTO generators, see also Cache.ValueConversion.
+	ValueConversion struct {
+		// Target is the golang type values are converted to, and from.
+		Target gopoet.TypeName
+		// To returns a golang expression converting expr, a single value of the field, to Target.
+		To func(expr string) *gopoet.CodeBlock
+		// From returns a golang expression converting expr, of Target, to a single value of the field.
+		From func(expr string) *gopoet.CodeBlock
+	}
+
+	// convertCode returns a golang expression converting the given expression.
+	convertCode func(expr *gopoet.CodeBlock) *gopoet.CodeBlock
+)
+
+const (
+	durationFullName = `google.protobuf.Duration`
+)
+
+var (
+	durationpbPkg  = gopoet.NewPackage("google.golang.org/protobuf/types/known/durationpb")
+	timestamppbPkg = gopoet.NewPackage("google.golang.org/protobuf/types/known/timestamppb")
+	wrapperspbPkg  = gopoet.NewPackage("google.golang.org/protobuf/types/known/wrapperspb")
+
+	// pointerFuncs are the names of the functions of the proto package returning a pointer to a value of each kind.
+	pointerFuncs = map[reflect.Kind]string{
+		reflect.Bool:    `Bool`,
+		reflect.Int32:   `Int32`,
+		reflect.Int64:   `Int64`,
+		reflect.Uint32:  `Uint32`,
+		reflect.Uint64:  `Uint64`,
+		reflect.Float32: `Float32`,
+		reflect.Float64: `Float64`,
+		reflect.String:  `String`,
+	}
+)
+
+// ValueConversion returns the conversion between a single value of the given field, and the target type, or nil if
+// it is unsupported. A single value has the type of the golang struct field, for singular fields, i.e. a pointer,
+// for scalars with explicit presence, or of an element, for repeated fields. Map fields aren't supported, though
+// their key and value fields (of the map entry message) are.
+//
+// The supported conversions are:
+//
+//   - T to T, i.e. the identity conversion
+//   - any numeric type to any other numeric type, e.g. int32 to int, which may truncate
+//   - string to []byte, and bytes to string
+//   - enums to integer types, e.g. int32, and from integers (of any value) to enums
+//   - enums to string, as the value name, and from string, where unknown names are converted to zero
+//   - *T to T, where nil is converted to the default value of the field, and T to *T, where T is any of the above
+//   - wrapper messages to T, where nil is converted to zero, and T to wrapper messages, where T is any of the above,
+//     e.g. *wrapperspb.Int32Value to int64
+//   - *timestamppb.Timestamp to time.Time, and *durationpb.Duration to time.Duration, where nil is converted to
+//     the Unix epoch, and zero, respectively
+//   - messages to and from their custom type, see also CustomType
+func (x *Cache) ValueConversion(field *protogen.Field, target gopoet.TypeName) *ValueConversion {
+	if field.Desc.IsMap() {
+		return nil
+	}
+	var to, from func(expr string) *gopoet.CodeBlock
+	switch {
+	case field.Message != nil:
+		to, from = x.messageConversion(field, target)
+
+	case sameType(x.valueType(field.Desc), target):
+		to, from = printCode, printCode
+
+	case isPointerField(field.Desc):
+		scalarTo, scalarFrom := x.scalarConversion(field, target)
+		if scalarTo == nil {
+			return nil
+		}
+		valueType := x.valueType(field.Desc).Elem()
+		to = func(expr string) *gopoet.CodeBlock {
+			return scalarTo(gopoet.Printf(`func() %s { if v := %s; v != nil { return *v }; return `, valueType, expr).
+				AddCode(x.defaultValue(field.Desc)).
+				Print(` }()`))
+		}
+		from = func(expr string) *gopoet.CodeBlock {
+			value := scalarFrom(primaryCode(expr))
+			if field.Desc.Kind() == protoreflect.EnumKind {
+				return value.Print(`.Enum()`)
+			}
+			return gopoet.Printf(`%s(`, protoPkg.Symbol(pointerFuncs[valueType.BasicKind()])).AddCode(value).Print(`)`)
+		}
+
+	default:
+		scalarTo, scalarFrom := x.scalarConversion(field, target)
+		if scalarTo == nil {
+			return nil
+		}
+		to = func(expr string) *gopoet.CodeBlock { return scalarTo(primaryCode(expr)) }
+		from = func(expr string) *gopoet.CodeBlock { return scalarFrom(primaryCode(expr)) }
+	}
+	if to == nil {
+		return nil
+	}
+	return &ValueConversion{Target: target, To: to, From: from}
+}
+
+// messageConversion returns the conversion functions between a single value of the given message field, and the
+// target type, or nil, if it is unsupported.
+func (x *Cache) messageConversion(field *protogen.Field, target gopoet.TypeName) (to, from func(expr string) *gopoet.CodeBlock) {
+	if custom := x.CustomType(field.Message.Desc); custom != nil {
+		if sameType(custom.Type, target) {
+			return custom.FromProto, custom.ToProto
+		}
+		return nil, nil
+	}
+	switch name := field.Message.Desc.FullName(); {
+	case sameType(gopoet.PointerType(x.MessageType(field.Message.Desc)), target):
+		return printCode, printCode
+
+	case name == timestampFullName && sameType(target, gopoet.NamedType(timePkg.Symbol(`Time`))):
+		return func(expr string) *gopoet.CodeBlock {
+				return gopoet.Printf(`%s.AsTime()`, expr)
+			}, func(expr string) *gopoet.CodeBlock {
+				return gopoet.Printf(`%s(%s)`, timestamppbPkg.Symbol(`New`), expr)
+			}
+
+	case name == durationFullName && sameType(target, durationType):
+		return func(expr string) *gopoet.CodeBlock {
+				return gopoet.Printf(`%s.AsDuration()`, expr)
+			}, func(expr string) *gopoet.CodeBlock {
+				return gopoet.Printf(`%s(%s)`, durationpbPkg.Symbol(`New`), expr)
+			}
+
+	case isWrapper(field.Message.Desc):
+		scalarTo, scalarFrom := x.scalarConversion(field.Message.Fields[0], target)
+		if scalarTo == nil {
+			return nil, nil
+		}
+		constructor := wrapperspbPkg.Symbol(strings.TrimSuffix(string(field.Message.Desc.Name()), `Value`))
+		return func(expr string) *gopoet.CodeBlock {
+				return scalarTo(gopoet.Printf(`%s.GetValue()`, expr))
+			}, func(expr string) *gopoet.CodeBlock {
+				return gopoet.Printf(`%s(`, constructor).AddCode(scalarFrom(primaryCode(expr))).Print(`)`)
+			}
+	}
+	return nil, nil
+}
+
+// scalarConversion returns the conversion functions between a single (non-pointer) value of the given scalar or
+// enum field, and the target type, or nil, if it is unsupported. The functions accept, and return, primary
+// expressions, i.e. that may be followed by a selector, see also primaryCode.
+func (x *Cache) scalarConversion(field *protogen.Field, target gopoet.TypeName) (to, from convertCode) {
+	var (
+		kind      = goKind(field.Desc.Kind())
+		valueType = x.valueType(field.Desc)
+	)
+	if valueType.Kind() == gopoet.KindPtr {
+		valueType = valueType.Elem()
+	}
+	switch {
+	case sameType(valueType, target):
+		return identityCode, identityCode
+
+	case isNumericKind(kind) && isNumericType(target),
+		kind == protoreflect.StringKind && sameType(target, bytesType),
+		kind == protoreflect.BytesKind && sameType(target, gopoet.StringType),
+		kind == protoreflect.EnumKind && isNumericType(target) && target.BasicKind() != reflect.Float32 && target.BasicKind() != reflect.Float64:
+		return typeConversion(target), typeConversion(valueType)
+
+	case kind == protoreflect.EnumKind && sameType(target, gopoet.StringType):
+		return func(expr *gopoet.CodeBlock) *gopoet.CodeBlock {
+				return expr.Print(`.String()`)
+			}, func(expr *gopoet.CodeBlock) *gopoet.CodeBlock {
+				return gopoet.Printf(`%s(%s[`, valueType, x.enumMap(field.Enum, `_value`)).AddCode(expr).Print(`])`)
+			}
+	}
+	return nil, nil
+}
+
+// valueType returns the golang type of a single value of the given (non-map) field, see also ValueConversion.
+func (x *Cache) valueType(desc protoreflect.FieldDescriptor) gopoet.TypeName {
+	t := x.fieldType(desc)
+	if desc.IsList() {
+		t = t.Elem()
+	}
+	switch {
+	case desc.Message() != nil:
+	case isPointerField(desc):
+		if t.Kind() != gopoet.KindPtr {
+			t = gopoet.PointerType(t)
+		}
+	case t.Kind() == gopoet.KindPtr:
+		// proto2 oneof fields
+		t = t.Elem()
+	}
+	return t
+}
+
+// isWrapper returns true if the given message is one of the wrapper types, e.g. google.protobuf.Int32Value.
+func isWrapper(desc protoreflect.MessageDescriptor) bool {
+	return desc.ParentFile().Path() == `google/protobuf/wrappers.proto`
+}
+
+// isNumericType returns true if the given type is a basic integer or float type, e.g. int, or float64.
+func isNumericType(t gopoet.TypeName) bool {
+	t = basicType(t)
+	if t.Kind() != gopoet.KindBasic {
+		return false
+	}
+	switch t.BasicKind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// sameType returns true if the given types are identical, where named types are identified by import path.
+func sameType(a, b gopoet.TypeName) bool {
+	a, b = basicType(a), basicType(b)
+	if a.Kind() != b.Kind() {
+		return false
+	}
+	switch a.Kind() {
+	case gopoet.KindBasic:
+		return a.BasicKind() == b.BasicKind()
+	case gopoet.KindNamed:
+		return a.Symbol().Package.ImportPath == b.Symbol().Package.ImportPath && a.Symbol().Name == b.Symbol().Name
+	case gopoet.KindPtr, gopoet.KindSlice:
+		return sameType(a.Elem(), b.Elem())
+	case gopoet.KindMap:
+		return sameType(a.Key(), b.Key()) && sameType(a.Elem(), b.Elem())
+	default:
+		return a.String() == b.String()
+	}
+}
+
+// basicType returns gopoet.Int32Type, if the given type is int32Type (or equivalent), or the given type.
+func basicType(t gopoet.TypeName) gopoet.TypeName {
+	if t.Kind() == gopoet.KindNamed && t.Symbol() == int32Type.Symbol() {
+		return gopoet.Int32Type
+	}
+	return t
+}
+
+// typeConversion returns a function converting expressions to the given type.
+func typeConversion(t gopoet.TypeName) convertCode {
+	return func(expr *gopoet.CodeBlock) *gopoet.CodeBlock {
+		return gopoet.Printf(`%s(`, t).AddCode(expr).Print(`)`)
+	}
+}
+
+func identityCode(expr *gopoet.CodeBlock) *gopoet.CodeBlock {
+	return expr
+}
+
+// primaryCode returns the given expression, parenthesized if it is a dereference, e.g. `(*v)`.
+func primaryCode(expr string) *gopoet.CodeBlock {
+	if strings.HasPrefix(expr, `*`) {
+		expr = `(` + expr + `)`
+	}
+	return gopoet.Print(expr)
+}
+
+func printCode(expr string) *gopoet.CodeBlock {
+	return gopoet.Print(expr)
+}
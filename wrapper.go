@@ -0,0 +1,122 @@
+package gopoet_protogen
+
+import (
+	"fmt"
+	"github.com/jhump/gopoet"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+type (
+	// WrapperGenerator generates, for each message, a named wrapper type, embedding a pointer to the message, e.g.
+	// "type Foo struct { *foopb.Foo }", such that the getters (and fields) of the message are promoted, to which
+	// hand-written domain methods may be added, in a separate file, in the same package, keeping business logic off
+	// the generated structs. The wrapper types must be generated into a different package to the messages, if they
+	// have the same names (the default).
+	//
+	// For a message named "Foo", the generated elements are the wrapper type, named "Foo", and a function named
+	// "WrapFoo", which wraps a (possibly nil) message. If Methods is set, an interface, named "FooMethods", is also
+	// generated, with a compile time assertion that the wrapper type implements it, i.e. slots for the domain
+	// methods, the stubs of which may be generated once, to scaffold the hand-written file, see also Stubs.
+	WrapperGenerator struct {
+		// Cache is used to resolve message types, and must contain all referenced files.
+		Cache *Cache
+		// TypeName may be used to override the name of the wrapper type, which defaults to the golang name of the
+		// message.
+		TypeName func(v *protogen.Message) string
+		// Methods may be used to declare the domain methods of each wrapper type, which must be implemented, in a
+		// separate (hand-written) file, using value receivers.
+		Methods func(v *protogen.Message) []gopoet.MethodType
+	}
+)
+
+var (
+	_ Generator = (*WrapperGenerator)(nil)
+)
+
+// GenerateFile returns the wrapper type, wrap function, and methods interface, if any, for each message in the given
+// file, see also FileMessages.
+func (x *WrapperGenerator) GenerateFile(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		elements = append(elements, x.Type(v), x.WrapFunc(v))
+		elements = append(elements, x.Interface(v)...)
+	}
+	return elements
+}
+
+// Name returns the name of the wrapper type for the given message.
+func (x *WrapperGenerator) Name(v *protogen.Message) string {
+	if x.TypeName != nil {
+		return x.TypeName(v)
+	}
+	return v.GoIdent.GoName
+}
+
+// Type returns the wrapper type for the given message.
+func (x *WrapperGenerator) Type(v *protogen.Message) *gopoet.TypeDecl {
+	name := x.Name(v)
+	return gopoet.NewTypeDecl(gopoet.NewStructTypeSpec(name,
+		gopoet.NewField(``, gopoet.PointerType(x.Cache.MessageType(v.Desc))),
+	).SetComment(fmt.Sprintf(`%s wraps a %s, promoting its getters, with domain methods, which are declared in a separate file.`, name, v.Desc.FullName())))
+}
+
+// WrapFunc returns the function wrapping the given message.
+func (x *WrapperGenerator) WrapFunc(v *protogen.Message) *gopoet.FuncSpec {
+	name := x.Name(v)
+	return gopoet.NewFunc(`Wrap`+name).
+		SetComment(fmt.Sprintf(`Wrap%s returns a %s wrapping the given (possibly nil) message.`, name, name)).
+		AddArg(`v`, gopoet.PointerType(x.Cache.MessageType(v.Desc))).
+		AddResult(``, localType(name)).
+		Printlnf(`return %s{v}`, name)
+}
+
+// Interface returns the interface of the domain methods of the given message, and an assertion that the wrapper type
+// implements it, or nil, if there are none, see also Methods.
+func (x *WrapperGenerator) Interface(v *protogen.Message) []gopoet.FileElement {
+	methods := x.methods(v)
+	if len(methods) == 0 {
+		return nil
+	}
+	var (
+		name     = x.Name(v)
+		iface    = name + `Methods`
+		elements = make([]gopoet.InterfaceElement, 0, len(methods))
+	)
+	for _, method := range methods {
+		m := gopoet.NewInterfaceMethod(method.Name)
+		m.Signature = method.Signature
+		elements = append(elements, m)
+	}
+	return []gopoet.FileElement{
+		gopoet.NewTypeDecl(gopoet.NewInterfaceTypeSpec(iface, elements...).
+			SetComment(fmt.Sprintf(`%s are the domain methods of %s, which must be implemented in a separate file.`, iface, name))),
+		gopoet.NewVarDecl(gopoet.NewVar(`_`).
+			SetType(localType(iface)).
+			SetInitializer(gopoet.Printf(`%s{}`, name))),
+	}
+}
+
+// Stubs returns stub implementations of the domain methods, of each message in the given file, which panic, to
+// scaffold the hand-written file, see also Methods. Unlike GenerateFile, the stubs are intended to be generated once,
+// e.g. if the file doesn't exist, then edited.
+func (x *WrapperGenerator) Stubs(file *protogen.File) []gopoet.FileElement {
+	var elements []gopoet.FileElement
+	for _, v := range FileMessages(file) {
+		name := x.Name(v)
+		for _, method := range x.methods(v) {
+			f := gopoet.NewMethod(gopoet.NewReceiverForType(`x`, localType(name)), method.Name).
+				SetComment(fmt.Sprintf(`%s implements %sMethods.`, method.Name, name))
+			f.Signature = method.Signature
+			f.Printlnf(`panic(%q)`, `not implemented: `+name+`.`+method.Name)
+			elements = append(elements, f)
+		}
+	}
+	return elements
+}
+
+func (x *WrapperGenerator) methods(v *protogen.Message) []gopoet.MethodType {
+	if x.Methods == nil {
+		return nil
+	}
+	return x.Methods(v)
+}